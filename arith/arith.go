@@ -0,0 +1,708 @@
+// Package arith implements a recursive-descent parser for arithmetic expressions according to the following grammar.
+//
+//	expr     -> sum
+//	sum      -> product ( ( '+' | '-' ) product )*
+//	product  -> unary ( ( '*' | '/' | '%' ) unary )*
+//	unary    -> '-' unary | power
+//	power    -> term ( '**' unary )?
+//	term     -> '(' expr ')' | unparsed
+//	unparsed -> .*
+//
+// Sums and products are parsed into left-associative trees of BinExpr nodes, so that a - b - c is parsed as
+// (a - b) - c, while exponentiation is right-associative, so that a ** b ** c is parsed as a ** (b ** c). As in
+// Python, exponentiation binds more tightly than unary minus on its left, so that -a ** b is parsed as -(a ** b).
+//
+// It leaves unparsed portions of the expression in parse.Unparsed nodes, for later consumption. The syntax used by
+// this parser is configurable at runtime, see NewParser for details. By default, the usual symbolic operators are
+// used.
+//
+// Since operators are recognized anywhere, identifiers containing operators, such as task-ref, are split by this
+// parser. Such names must be quoted where they appear, for instance as ${workflow["task-ref"]}. The sign of the
+// exponent of a number is not an operator, so that 1.5e-5 * 2 is parsed as a product of two numbers.
+package arith
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/spine"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BinExpr represents a binary arithmetic expression.
+type BinExpr struct {
+	LHS parse.AST  // LHS is the left-hand side
+	RHS parse.AST  // RHS is the right-hand side
+	Op  Op         // Op is one of OpAdd, OpSubtract, OpMultiply, OpDivide, OpModulo or OpPower.
+	Src parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (b *BinExpr) String() string {
+	return format(b)
+}
+
+// Span returns the location of this expression in the source text.
+func (b *BinExpr) Span() parse.Span {
+	return b.Src
+}
+
+// Parse runs the provided parse.Parser on all unparsed nodes in this AST.
+func (b *BinExpr) Parse(p parse.Parser) error {
+	return WalkChain(b, func(b *BinExpr, deepest bool) error {
+		if deepest {
+			if err := parseChild(&b.LHS, p); err != nil {
+				return err
+			}
+		}
+		return parseChild(&b.RHS, p)
+	})
+}
+
+// Children returns the left-hand and right-hand sides of this expression.
+func (b *BinExpr) Children() []parse.AST {
+	return []parse.AST{b.LHS, b.RHS}
+}
+
+// WithChildren returns a copy of this expression with the provided left-hand and right-hand sides.
+func (b *BinExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("BinExpr", children, 2)
+	return &BinExpr{LHS: children[0], RHS: children[1], Op: b.Op, Src: b.Src}
+}
+
+// WalkChain calls visit for the provided BinExpr and every BinExpr found by repeatedly following its left-hand side,
+// starting from the deepest, for which deepest is true and whose left-hand side begins the chain. Long chains such as
+// a + b + c are visited iteratively and in order, without allocating. Walking stops at the first error from visit.
+func WalkChain(b *BinExpr, visit func(b *BinExpr, deepest bool) error) error {
+	return spine.Walk(b, leftBin, visit)
+}
+
+// leftBin returns the left-hand side of the provided BinExpr, if it is also a BinExpr.
+func leftBin(b *BinExpr) (*BinExpr, bool) {
+	lhs, ok := b.LHS.(*BinExpr)
+	return lhs, ok
+}
+
+// UnaryExpr represents a unary arithmetic expression.
+type UnaryExpr struct {
+	Op   Op // Op can only be OpNegate.
+	Expr parse.AST
+	Src  parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (u *UnaryExpr) String() string {
+	return format(u)
+}
+
+// Span returns the location of this expression in the source text.
+func (u *UnaryExpr) Span() parse.Span {
+	return u.Src
+}
+
+// Parse runs the provided parse.Parser on all unparsed nodes in this AST.
+func (u *UnaryExpr) Parse(p parse.Parser) error {
+	return parseChild(&u.Expr, p)
+}
+
+// Children returns the operand of this expression.
+func (u *UnaryExpr) Children() []parse.AST {
+	return []parse.AST{u.Expr}
+}
+
+// WithChildren returns a copy of this expression with the provided operand.
+func (u *UnaryExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("UnaryExpr", children, 1)
+	return &UnaryExpr{Op: u.Op, Expr: children[0], Src: u.Src}
+}
+
+// checkChildren panics if the number of children passed to WithChildren is not as expected.
+func checkChildren(node string, children []parse.AST, expected int) {
+	if len(children) != expected {
+		panic(fmt.Sprintf("arith: %s.WithChildren called with %d children; expected %d", node, len(children), expected))
+	}
+}
+
+// parseChild replaces the provided child with the result of parsing it if it is parse.Unparsed, and otherwise parses
+// it recursively.
+func parseChild(child *parse.AST, p parse.Parser) error {
+	if unparsed, ok := (*child).(parse.Unparsed); ok {
+		parsed, err := p.Parse(unparsed.Contents)
+		if err != nil {
+			return err
+		}
+		*child = parsed
+		return nil
+	}
+	return (*child).Parse(p)
+}
+
+// Op represents an arithmetic operation recognized by this grammar.
+type Op uint8
+
+const (
+	OpAdd Op = iota + 1
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpModulo
+	OpPower
+	OpNegate
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpAdd:
+		return "+"
+	case OpSubtract, OpNegate:
+		return "-"
+	case OpMultiply:
+		return "*"
+	case OpDivide:
+		return "/"
+	case OpModulo:
+		return "%"
+	case OpPower:
+		return "**"
+	default:
+		return "unknown op"
+	}
+}
+
+// Token is a token required by this grammar.
+type Token uint8
+
+const (
+	Plus       Token = iota + 1 // Plus represents addition.
+	Minus                       // Minus represents subtraction and negation.
+	Times                       // Times represents multiplication.
+	Divide                      // Divide represents division.
+	Modulo                      // Modulo represents the remainder of division.
+	Power                       // Power represents exponentiation.
+	OpenParen                   // OpenParen represents the start of a sub-expression.
+	CloseParen                  // CloseParen represents the end of a sub-expression.
+)
+
+type ParserOpt func(*Parser)
+
+// Parser parses this grammar. A Parser is safe for concurrent use by multiple goroutines.
+type Parser struct {
+	config          map[Token]string
+	caseInsensitive bool
+	wordBoundaries  bool
+	quotes          []rune
+
+	matcher   *parse.KeywordTrie
+	tokenizer parse.Tokenizer
+}
+
+// parser holds the state of a single call to Parser.Parse, so that a Parser can be used concurrently.
+type parser struct {
+	*Parser
+	tokens     []parse.Token
+	curr       int
+	expected   []Token // expected lists the tokens which failed to match at index expectedAt.
+	expectedAt int
+}
+
+// WithTokens configures the syntax used by this parser using the provided token mapping. The provided map must contain
+// distinct entries for each Token provided in this package: Plus, Minus, Times, Divide, Modulo, Power, OpenParen, and
+// CloseParen.
+func WithTokens(config map[Token]string) ParserOpt {
+	return func(parser *Parser) {
+		parser.config = config
+	}
+}
+
+// WithWordBoundaries sets whether keywords which begin or end with a letter, digit or underscore are only recognized
+// at identifier boundaries when tokenizing. Symbolic keywords such as '+' are recognized anywhere. Word boundaries
+// are required by default, so that, for instance, the identifier MODE is not tokenized as the keyword MOD followed by
+// E unless this option is disabled.
+func WithWordBoundaries(wordBoundaries bool) ParserOpt {
+	return func(parser *Parser) {
+		parser.wordBoundaries = wordBoundaries
+	}
+}
+
+// WithQuotes sets the runes which delimit string literals. By default, the runes in parse.DefaultQuotes are used.
+// String literals are always treated as a single token, and are never interpreted as keywords, so that the expression
+// "a-b" + c contains a single addition. Calling WithQuotes with no arguments disables string literals.
+func WithQuotes(quotes ...rune) ParserOpt {
+	return func(parser *Parser) {
+		parser.quotes = quotes
+	}
+}
+
+// WithCaseSensitive sets whether the configured parser is case-sensitive.
+func WithCaseSensitive(caseSensitive bool) ParserOpt {
+	return func(parser *Parser) {
+		parser.caseInsensitive = !caseSensitive
+	}
+}
+
+// NewParser returns a parser configured according to the provided options. If no options are configured, the default
+// parser is returned.
+func NewParser(opts ...ParserOpt) (*Parser, error) {
+	p := &Parser{
+		config: map[Token]string{
+			Plus:       "+",
+			Minus:      "-",
+			Times:      "*",
+			Divide:     "/",
+			Modulo:     "%",
+			Power:      "**",
+			OpenParen:  "(",
+			CloseParen: ")",
+		},
+		matcher:        &parse.KeywordTrie{},
+		quotes:         parse.DefaultQuotes,
+		wordBoundaries: true,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Parser) init() error {
+	if len(p.config[OpenParen]) != 1 || len(p.config[CloseParen]) != 1 {
+		return fmt.Errorf("%w: OpenParen and CloseParen must each have length 1", parse.ErrConfig)
+	}
+	if p.config[OpenParen] == p.config[CloseParen] {
+		return fmt.Errorf("%w: OpenParen and CloseParen must each be distinct", parse.ErrConfig)
+	}
+	p.tokenizer = parse.Tokenizer{
+		Open:           []rune(p.config[OpenParen])[0],
+		Close:          []rune(p.config[CloseParen])[0],
+		Keywords:       p.matcher,
+		Quotes:         p.quotes,
+		Numbers:        true,
+		WordBoundaries: p.wordBoundaries,
+	}
+	for _, q := range p.quotes {
+		if unicode.IsSpace(q) || q == p.tokenizer.Open || q == p.tokenizer.Close {
+			return fmt.Errorf("%w: quote '%c' must not be whitespace, OpenParen or CloseParen", parse.ErrConfig, q)
+		}
+		for _, str := range p.config {
+			if strings.ContainsRune(str, q) {
+				return fmt.Errorf("%w: quote '%c' must not appear in any configured token", parse.ErrConfig, q)
+			}
+		}
+	}
+	if p.caseInsensitive {
+		newTokens := make(map[Token]string, len(p.config))
+		for token, str := range p.config {
+			newTokens[token] = strings.ToLower(str)
+		}
+		p.config = newTokens
+	}
+	for _, str := range p.config {
+		p.matcher.Add(str)
+	}
+	if p.matcher.Count() != 8 {
+		return fmt.Errorf("%w: token collision detected; at least two of the provided tokens are identical", parse.ErrConfig)
+	}
+	return nil
+}
+
+// ParseStr tokenizes and parses the provided string. See Parser.Parse for details. Any *parse.SyntaxError returned
+// includes the provided string as its Source.
+func (p *Parser) ParseStr(str string) (parse.AST, error) {
+	tokens, err := p.tokenize(str)
+	var ast parse.AST
+	if err == nil {
+		ast, err = p.Parse(tokens)
+	}
+	var syntaxErr *parse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Source = str
+	}
+	return ast, err
+}
+
+// Parse parses the provided list of tokens, producing a parse.AST. The provided tokens are first split further at
+// every operator, since tokens produced by other parsers, such as the 2*x in a > 2*x, do not separate arithmetic
+// operators from their operands. A *parse.SyntaxError is returned if the provided tokens do not conform to the grammar
+// specified in this package.
+func (p *Parser) Parse(tokens []parse.Token) (parse.AST, error) {
+	tokens, err := p.tokenizer.Retokenize(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return (&parser{Parser: p, tokens: tokens, expectedAt: -1}).parse()
+}
+
+func (p *parser) parse() (parse.AST, error) {
+	ast, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.curr != len(p.tokens) {
+		return nil, p.errorf("expected end of expression; found '%s'", p.peek().Text)
+	}
+	return ast, nil
+}
+
+func (p *Parser) tokenize(str string) ([]parse.Token, error) {
+	return p.tokenizer.Tokenize(str)
+}
+
+func (p *Parser) isKeyword(token parse.Token) bool {
+	if token.Quote != 0 {
+		return false
+	}
+	str := token.Text
+	if p.caseInsensitive {
+		str = strings.ToLower(str)
+	}
+	return p.matcher.Contains(str)
+}
+
+func (p *parser) match(token Token) bool {
+	if p.is(p.curr, token) {
+		p.curr++
+		return true
+	}
+	p.expect(token)
+	return false
+}
+
+// is reports whether the token at index i is the provided Token.
+func (p *parser) is(i int, token Token) bool {
+	if i >= len(p.tokens) || p.tokens[i].Quote != 0 {
+		return false
+	}
+	curr := p.tokens[i].Text
+	if p.caseInsensitive {
+		curr = strings.ToLower(curr)
+	}
+	return curr == p.config[token]
+}
+
+// groupEnd returns the index of the CloseParen matching the OpenParen at index i, or the number of tokens if it is
+// not closed. If the token at index i is not an OpenParen, -1 is returned.
+func (p *parser) groupEnd(i int) int {
+	if !p.is(i, OpenParen) {
+		return -1
+	}
+	depth := 0
+	for ; i < len(p.tokens); i++ {
+		if p.is(i, OpenParen) {
+			depth++
+		} else if p.is(i, CloseParen) {
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(p.tokens)
+}
+
+// expect records that the provided token was expected at the current position, for use in error messages.
+func (p *parser) expect(token Token) {
+	if p.expectedAt != p.curr {
+		p.expected, p.expectedAt = p.expected[:0], p.curr
+	}
+	for _, t := range p.expected {
+		if t == token {
+			return
+		}
+	}
+	p.expected = append(p.expected, token)
+}
+
+// errorf returns a *parse.SyntaxError located at the current token.
+func (p *parser) errorf(format string, args ...any) error {
+	var expected []string
+	if p.expectedAt == p.curr {
+		for _, token := range p.expected {
+			expected = append(expected, p.config[token])
+		}
+	}
+	return parse.NewSyntaxError(p.tokens, p.curr, expected, format, args...)
+}
+
+func (p *parser) peek() parse.Token {
+	return p.tokens[p.curr]
+}
+
+// span returns the location of the tokens from start up to the current token.
+func (p *parser) span(start int) parse.Span {
+	return parse.Span{Start: p.tokens[start].Src.Start, End: p.tokens[p.curr-1].Src.End}
+}
+
+func (p *parser) parseExpr() (parse.AST, error) {
+	return p.parseChain(p.parseProduct, Plus, Minus)
+}
+
+func (p *parser) parseProduct() (parse.AST, error) {
+	return p.parseChain(p.parseUnary, Times, Divide, Modulo)
+}
+
+// parseChain parses one or more operands separated by any of the provided tokens, producing a left-associative chain
+// of BinExpr nodes. Operands are parsed iteratively, so that long chains do not recurse deeply.
+func (p *parser) parseChain(operand func() (parse.AST, error), ops ...Token) (parse.AST, error) {
+	start := p.curr
+	lhs, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.matchOps(ops...); op != 0; op = p.matchOps(ops...) {
+		rhs, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinExpr{LHS: lhs, RHS: rhs, Op: tokenToOp(op), Src: p.span(start)}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseUnary() (parse.AST, error) {
+	start := p.curr
+	if p.match(Minus) {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: OpNegate, Expr: expr, Src: p.span(start)}, nil
+	}
+	return p.parsePower()
+}
+
+func (p *parser) parsePower() (parse.AST, error) {
+	start := p.curr
+	lhs, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	if p.match(Power) {
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &BinExpr{LHS: lhs, RHS: rhs, Op: OpPower, Src: p.span(start)}, nil
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseTerm() (parse.AST, error) {
+	// a group followed by more of the same term, as in f(x), is left for later consumption
+	if end := p.groupEnd(p.curr); end < 0 || end+1 >= len(p.tokens) || p.isKeyword(p.tokens[end+1]) {
+		if p.match(OpenParen) {
+			ast, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.match(CloseParen) {
+				return nil, p.errorf("expected '%s'", p.config[CloseParen])
+			}
+			return ast, nil
+		}
+	}
+	return p.parseRest()
+}
+
+func (p *parser) parseRest() (parse.AST, error) {
+	var result []parse.Token
+	for p.curr < len(p.tokens) {
+		if end := p.groupEnd(p.curr); end >= 0 && end < len(p.tokens) {
+			// keep balanced groups which form part of a term, such as f(x)
+			result = append(result, p.tokens[p.curr:end+1]...)
+			p.curr = end + 1
+			continue
+		}
+		if p.isKeyword(p.peek()) {
+			break
+		}
+		result = append(result, p.peek())
+		p.curr++
+	}
+	if result == nil {
+		if p.curr < len(p.tokens) {
+			return nil, p.errorf("unexpected '%s'", p.peek().Text)
+		}
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return parse.Unparsed{Contents: result}, nil
+}
+
+// matchOps attempts to match all of the provided ops in order, returning the first one matched. If none match, 0 is
+// returned.
+func (p *parser) matchOps(ops ...Token) Token {
+	for _, op := range ops {
+		if p.match(op) {
+			return op
+		}
+	}
+	return 0
+}
+
+func tokenToOp(t Token) Op {
+	switch t {
+	case Plus:
+		return OpAdd
+	case Minus:
+		return OpSubtract
+	case Times:
+		return OpMultiply
+	case Divide:
+		return OpDivide
+	case Modulo:
+		return OpModulo
+	case Power:
+		return OpPower
+	}
+	return 0
+}
+
+func opToToken(op Op) Token {
+	switch op {
+	case OpAdd:
+		return Plus
+	case OpSubtract, OpNegate:
+		return Minus
+	case OpMultiply:
+		return Times
+	case OpDivide:
+		return Divide
+	case OpModulo:
+		return Modulo
+	case OpPower:
+		return Power
+	}
+	return 0
+}
+
+// Levels of precedence used when printing.
+const (
+	precSum     = parse.PrecArith
+	precProduct = parse.PrecArith + 10
+	precUnary   = parse.PrecArith + 20
+	precPower   = parse.PrecArith + 30
+)
+
+// Print implements parse.Printer, rendering BinExpr and UnaryExpr nodes using the syntax configured for this Parser.
+// Parentheses are only added where they are required, so that parsing the resulting text using this Parser produces
+// the original AST.
+func (p *Parser) Print(ast parse.AST, f *parse.Formatter) (string, int, error) {
+	open, close := p.config[OpenParen], p.config[CloseParen]
+	switch ast := ast.(type) {
+	case *BinExpr:
+		if ast.Op == OpPower {
+			lhs, err := f.Operand(ast.LHS, precPower+1, open, close)
+			if err != nil {
+				return "", 0, err
+			}
+			rhs, err := f.Operand(ast.RHS, precUnary, open, close)
+			if err != nil {
+				return "", 0, err
+			}
+			return lhs + " " + p.config[Power] + " " + rhs, precPower, nil
+		}
+		return p.printChain(ast, f)
+	case *UnaryExpr:
+		if ast.Op != OpNegate {
+			return "", 0, fmt.Errorf("cannot print unknown unary operator: %v", ast.Op)
+		}
+		text, err := f.Operand(ast.Expr, precUnary, open, close)
+		if err != nil {
+			return "", 0, err
+		}
+		op := p.config[Minus]
+		// separate alphabetic operators from their operand
+		if r, _ := utf8.DecodeLastRuneInString(op); unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			op += " "
+		}
+		return op + text, precUnary, nil
+	default:
+		return "", 0, fmt.Errorf("%w: %T", parse.ErrUnknownAST, ast)
+	}
+}
+
+// printChain prints a chain of left-associative BinExpr nodes iteratively, since it may be very long.
+func (p *Parser) printChain(b *BinExpr, f *parse.Formatter) (string, int, error) {
+	// the chain ends at the first power, which is printed on its own
+	left := func(b *BinExpr) (*BinExpr, bool) {
+		lhs, ok := leftBin(b)
+		return lhs, ok && lhs.Op != OpPower
+	}
+
+	// parenthesizing the left-hand side of a BinExpr adds an open parenthesis to the start of the text, so all of them
+	// must be written first, while the operators are printed on a first pass along the chain.
+	var sb strings.Builder
+	var lhs string
+	var lhsPrec, prec int
+	var ops []string
+	var precs []int
+	err := spine.Walk(b, left, func(b *BinExpr, deepest bool) error {
+		if deepest {
+			var err error
+			if lhs, lhsPrec, err = f.Print(b.LHS); err != nil {
+				return err
+			}
+			prec = lhsPrec
+		}
+		op, opPrec, err := p.printOp(b.Op)
+		if err != nil {
+			return err
+		}
+		if prec < opPrec {
+			sb.WriteString(p.config[OpenParen])
+		}
+		prec = opPrec
+		ops, precs = append(ops, op), append(precs, opPrec)
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	sb.WriteString(lhs)
+	prec = lhsPrec
+	i := 0
+	err = spine.Walk(b, left, func(b *BinExpr, _ bool) error {
+		if prec < precs[i] {
+			sb.WriteString(p.config[CloseParen])
+		}
+		prec = precs[i]
+		rhs, err := f.Operand(b.RHS, precs[i]+1, p.config[OpenParen], p.config[CloseParen])
+		if err != nil {
+			return err
+		}
+		sb.WriteString(" " + ops[i] + " " + rhs)
+		i++
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return sb.String(), prec, nil
+}
+
+// printOp returns the configured token and the precedence of the provided left-associative binary operator.
+func (p *Parser) printOp(op Op) (string, int, error) {
+	switch op {
+	case OpAdd, OpSubtract:
+		return p.config[opToToken(op)], precSum, nil
+	case OpMultiply, OpDivide, OpModulo:
+		return p.config[opToToken(op)], precProduct, nil
+	}
+	return "", 0, fmt.Errorf("cannot print unknown binary operator: %v", op)
+}
+
+// defaultParser is used to implement the String method of each node.
+var defaultParser, _ = NewParser()
+
+// format returns the text of the provided AST in the default syntax, or a description of the error encountered.
+func format(ast parse.AST) string {
+	text, err := parse.Format(ast, defaultParser)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return text
+}
@@ -0,0 +1,333 @@
+package arith
+
+import (
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/asttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParser_Parse(t *testing.T) {
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{
+			"a + b * 2",
+			add(un("a"), mul(un("b"), un("2"))),
+		},
+		{
+			"a - b - c",
+			sub(sub(un("a"), un("b")), un("c")),
+		},
+		{
+			"(a - b) * c % d / e",
+			div(mod(mul(sub(un("a"), un("b")), un("c")), un("d")), un("e")),
+		},
+		{
+			"2 ** 3 ** 2",
+			pow(un("2"), pow(un("3"), un("2"))),
+		},
+		{
+			"-a ** 2",
+			neg(pow(un("a"), un("2"))),
+		},
+		{
+			"(-a) ** -2",
+			pow(neg(un("a")), neg(un("2"))),
+		},
+		{
+			"a - -b",
+			sub(un("a"), neg(un("b"))),
+		},
+		{
+			"${a}+${b}*2",
+			add(un("${a}"), mul(un("${b}"), un("2"))),
+		},
+		{
+			`"a-b" + 'c*d'`,
+			add(un(`"a-b"`), un("'c*d'")),
+		},
+		{
+			"len(x) * (y + 1)",
+			mul(un("len", "(", "x", ")"), add(un("y"), un("1"))),
+		},
+		{
+			"1.5e-5 * 2",
+			mul(un("1.5e-5"), un("2")),
+		},
+		{
+			"x-1E+3-e-5",
+			sub(sub(sub(un("x"), un("1E+3")), un("e")), un("5")),
+		},
+		{
+			"x",
+			un("x"),
+		},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+}
+
+func TestParser_Parse_Retokenize(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	tokens, err := (&parse.Tokenizer{Open: '(', Close: ')', Keywords: &parse.KeywordTrie{}}).Tokenize("x+1 *(y)")
+	require.NoError(t, err)
+	require.Len(t, tokens, 5)
+
+	ast, err := p.Parse(tokens)
+	require.NoError(t, err)
+	assert.EqualValues(t, add(un("x"), mul(un("1"), un("y"))), asttest.StripSpans(ast))
+	assert.Equal(t, 2, parse.SpanOf(ast.(*BinExpr).RHS).Start.Offset)
+}
+
+func TestParser_Span(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.ParseStr("a + -(b ** c)")
+	require.NoError(t, err)
+
+	offsets := func(ast parse.AST) [2]int {
+		span := parse.SpanOf(ast)
+		return [2]int{span.Start.Offset, span.End.Offset}
+	}
+
+	root := ast.(*BinExpr)
+	assert.Equal(t, [2]int{0, 13}, offsets(root))
+	assert.Equal(t, [2]int{4, 13}, offsets(root.RHS))
+	assert.Equal(t, [2]int{6, 12}, offsets(root.RHS.(*UnaryExpr).Expr))
+}
+
+func TestParser_LongChain(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	const n = 100000
+	terms := make([]string, n)
+	for i := range terms {
+		terms[i] = "1"
+	}
+	text := strings.Join(terms, " + ")
+	ast, err := p.ParseStr(text)
+	require.NoError(t, err)
+	require.NoError(t, ast.Parse(p))
+
+	result, err := Eval(ast, intInterpreter)
+	require.NoError(t, err)
+	assert.Equal(t, int64(n), result)
+
+	printed, err := parse.Format(ast, p)
+	require.NoError(t, err)
+	assert.Equal(t, text, printed)
+}
+
+func TestParser_Concurrent(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST // output is nil if an error is expected
+	}{
+		{"a + b * 2", add(un("a"), mul(un("b"), un("2")))},
+		{"-(a)", neg(un("a"))},
+		{"a + * b", nil},
+		{"(a + b", nil},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tt := tests[(g+i)%len(tests)]
+				ast, err := p.ParseStr(tt.input)
+				if tt.output == nil {
+					assert.ErrorIs(t, err, parse.ErrParse, tt.input)
+					continue
+				}
+				if assert.NoError(t, err, tt.input) {
+					assert.EqualValues(t, tt.output, asttest.StripSpans(ast), tt.input)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestParser_ParseError(t *testing.T) {
+	tests := []string{
+		"a +",
+		"* b",
+		"a + * b",
+		"(a + b",
+		"a ** ** b",
+		"-",
+		"()",
+		"a 'unterminated",
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			ast, err := p.ParseStr(tt)
+			assert.ErrorIs(t, err, parse.ErrParse, "ast was: %#v", ast)
+		})
+	}
+}
+
+func TestParser_SyntaxError(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	_, err = p.ParseStr("(a + b * c")
+	var syntaxErr *parse.SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 6, syntaxErr.Index)
+	assert.Equal(t, []string{"**", "*", "/", "%", "+", "-", ")"}, syntaxErr.Expected)
+	assert.Equal(t, "error parsing: expected ')' at 1:11", err.Error())
+}
+
+func TestWithWordBoundaries(t *testing.T) {
+	tokens := map[Token]string{
+		Plus: "+", Minus: "-", Times: "*", Divide: "/", Modulo: "mod", Power: "**", OpenParen: "(", CloseParen: ")",
+	}
+	p, err := NewParser(WithTokens(tokens))
+	require.NoError(t, err)
+	ast, err := p.ParseStr("mode mod 2")
+	require.NoError(t, err)
+	assert.EqualValues(t, mod(un("mode"), un("2")), asttest.StripSpans(ast))
+
+	p, err = NewParser(WithTokens(tokens), WithWordBoundaries(false))
+	require.NoError(t, err)
+	ast, err = p.ParseStr("x mode")
+	require.NoError(t, err)
+	assert.EqualValues(t, mod(un("x"), un("e")), asttest.StripSpans(ast))
+}
+
+func TestParser_Print(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	custom, err := NewParser(WithWordBoundaries(true), WithTokens(map[Token]string{
+		Plus:       "plus",
+		Minus:      "minus",
+		Times:      "times",
+		Divide:     "over",
+		Modulo:     "mod",
+		Power:      "^",
+		OpenParen:  "[",
+		CloseParen: "]",
+	}))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  parse.AST
+		output string
+		custom string
+	}{
+		{add(un("a"), mul(un("b"), un("2"))), "a + b * 2", "a plus b times 2"},
+		{mul(add(un("a"), un("b")), un("2")), "(a + b) * 2", "[a plus b] times 2"},
+		{sub(un("a"), sub(un("b"), un("c"))), "a - (b - c)", "a minus [b minus c]"},
+		{sub(add(un("a"), un("b")), un("c")), "a + b - c", "a plus b minus c"},
+		{div(mul(un("a"), un("b")), add(un("c"), un("d"))), "a * b / (c + d)", "a times b over [c plus d]"},
+		{mod(pow(un("a"), un("b")), un("c")), "a ** b % c", "a ^ b mod c"},
+		{pow(pow(un("a"), un("b")), pow(un("c"), un("d"))), "(a ** b) ** c ** d", "[a ^ b] ^ c ^ d"},
+		{pow(neg(un("a")), neg(un("b"))), "(-a) ** -b", "[minus a] ^ minus b"},
+		{neg(pow(un("a"), un("b"))), "-a ** b", "minus a ^ b"},
+		{neg(neg(add(un("a"), un("b")))), "--(a + b)", "minus minus [a plus b]"},
+		{add(pow(un("a"), un("2")), un("b")), "a ** 2 + b", "a ^ 2 plus b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			for _, c := range []struct {
+				p        *Parser
+				expected string
+			}{{p, tt.output}, {custom, tt.custom}} {
+				text, err := parse.Format(tt.input, c.p)
+				require.NoError(t, err)
+				assert.Equal(t, c.expected, text)
+
+				ast, err := c.p.ParseStr(text)
+				require.NoError(t, err)
+				assert.EqualValues(t, tt.input, asttest.StripSpans(ast))
+			}
+			assert.Equal(t, tt.output, tt.input.(fmt.Stringer).String())
+		})
+	}
+}
+
+func TestWithTokens(t *testing.T) {
+	testWithErrors := []map[Token]string{
+		{Plus: "+"},
+		{Plus: "+", Minus: "+", Times: "*", Divide: "/", Modulo: "%", Power: "**", OpenParen: "(", CloseParen: ")"},
+		{Plus: "+", Minus: "-", Times: "*", Divide: "/", Modulo: "%", Power: "**", OpenParen: "((", CloseParen: ")"},
+		{Plus: "+", Minus: "-", Times: "*", Divide: "/", Modulo: "'", Power: "**", OpenParen: "(", CloseParen: ")"},
+	}
+	for idx, config := range testWithErrors {
+		t.Run(fmt.Sprintf("error case %d", idx), func(t *testing.T) {
+			_, err := NewParser(WithTokens(config))
+			assert.ErrorIs(t, err, parse.ErrConfig)
+		})
+	}
+}
+
+func TestWithChildren(t *testing.T) {
+	for _, ast := range []parse.AST{add(un("a"), un("b")), neg(un("a"))} {
+		branch, ok := ast.(parse.Branch)
+		require.True(t, ok)
+
+		children := make([]parse.AST, len(branch.Children()))
+		for i := range children {
+			children[i] = un(fmt.Sprint("c", i))
+		}
+		assert.Equal(t, children, parse.ChildrenOf(branch.WithChildren(children)))
+		assert.Panics(t, func() { branch.WithChildren(nil) })
+	}
+}
+
+func add(a, b parse.AST) parse.AST {
+	return &BinExpr{LHS: a, RHS: b, Op: OpAdd}
+}
+
+func sub(a, b parse.AST) parse.AST {
+	return &BinExpr{LHS: a, RHS: b, Op: OpSubtract}
+}
+
+func mul(a, b parse.AST) parse.AST {
+	return &BinExpr{LHS: a, RHS: b, Op: OpMultiply}
+}
+
+func div(a, b parse.AST) parse.AST {
+	return &BinExpr{LHS: a, RHS: b, Op: OpDivide}
+}
+
+func mod(a, b parse.AST) parse.AST {
+	return &BinExpr{LHS: a, RHS: b, Op: OpModulo}
+}
+
+func pow(a, b parse.AST) parse.AST {
+	return &BinExpr{LHS: a, RHS: b, Op: OpPower}
+}
+
+func neg(a parse.AST) parse.AST {
+	return &UnaryExpr{Expr: a, Op: OpNegate}
+}
+
+// un stands for unparsed and returns a parse.Unparsed
+func un(tokens ...string) parse.AST {
+	return parse.Unparsed{Contents: parse.NewTokens(tokens...)}
+}
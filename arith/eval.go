@@ -0,0 +1,314 @@
+package arith
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"math"
+	"math/big"
+)
+
+// maxBits limits the size of the results of integer exponentiation.
+const maxBits = 1 << 16
+
+// Eval evaluates the provided arithmetic expression, using the provided Interpreter to find the value of each operand
+// which is not itself an arithmetic expression. See Interpreter for details.
+func Eval(expr parse.AST, operands parse.Interpreter[any]) (any, error) {
+	return Interpreter(operands)(expr)
+}
+
+// Interpreter returns a parse.Interpreter which evaluates BinExpr and UnaryExpr nodes, producing an int64, *big.Int or
+// float64. The value of each operand which is not itself an arithmetic expression is found using the provided
+// Interpreter, as is the value of any other node passed to the returned Interpreter. This allows the returned
+// Interpreter to be used as the values Interpreter passed to comp.Interpreter.
+//
+// Arithmetic is performed according to the following rules.
+//   - Integers of any Go integer type are converted to int64, or to *big.Int if they do not fit. json.Number values
+//     are converted to int64 or float64.
+//   - If either operand is a floating point number, both are converted to float64.
+//   - Integer arithmetic is exact. Results which overflow int64 are returned as *big.Int, and *big.Int results which
+//     fit in int64 are returned as int64.
+//   - Integer division truncates toward zero, and the result of % has the sign of the dividend, as in Go. Raising an
+//     integer to a negative power produces a float64.
+//   - Division by zero, and operands which are not numbers, result in parse.ErrEval.
+func Interpreter(operands parse.Interpreter[any]) parse.Interpreter[any] {
+	var interpret parse.Interpreter[any]
+	interpret = func(ast parse.AST) (any, error) {
+		if operands == nil {
+			return nil, fmt.Errorf("%w: nil Interpreter", parse.ErrEval)
+		}
+		switch ast := ast.(type) {
+		case *BinExpr:
+			var lhs any
+			err := WalkChain(ast, func(b *BinExpr, deepest bool) error {
+				var err error
+				if deepest {
+					if lhs, err = number(interpret, b.LHS); err != nil {
+						return err
+					}
+				}
+				rhs, err := number(interpret, b.RHS)
+				if err != nil {
+					return err
+				}
+				lhs, err = apply(b.Op, lhs, rhs)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			return lhs, nil
+		case *UnaryExpr:
+			val, err := number(interpret, ast.Expr)
+			if err != nil {
+				return nil, err
+			}
+			if ast.Op != OpNegate {
+				return nil, fmt.Errorf("%w: unexpected unary operator: %v", parse.ErrEval, ast.Op)
+			}
+			return negate(val), nil
+		case nil:
+			return nil, fmt.Errorf("%w: nil expression", parse.ErrEval)
+		default:
+			return operands(ast)
+		}
+	}
+	return interpret
+}
+
+// number evaluates the provided AST, converting the result to an int64, *big.Int or float64.
+func number(interpret parse.Interpreter[any], ast parse.AST) (any, error) {
+	val, err := interpret(ast)
+	if err != nil {
+		return nil, err
+	}
+	switch v := val.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return fromUint64(uint64(v)), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return fromUint64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case *big.Int:
+		if v == nil {
+			break
+		}
+		return normalize(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if i, ok := new(big.Int).SetString(string(v), 10); ok {
+			return i, nil
+		}
+		if f, err := v.Float64(); err == nil {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: cannot perform arithmetic on %T value '%v'", parse.ErrEval, val, val)
+}
+
+func fromUint64(u uint64) any {
+	if u > math.MaxInt64 {
+		return new(big.Int).SetUint64(u)
+	}
+	return int64(u)
+}
+
+// normalize returns the provided integer as an int64 if it fits.
+func normalize(i *big.Int) any {
+	if i.IsInt64() {
+		return i.Int64()
+	}
+	return i
+}
+
+// apply returns the result of lhs op rhs, where both are one of int64, *big.Int or float64.
+func apply(op Op, lhs, rhs any) (any, error) {
+	lf, lIsFloat := lhs.(float64)
+	rf, rIsFloat := rhs.(float64)
+	if lIsFloat || rIsFloat {
+		if !lIsFloat {
+			lf = toFloat(lhs)
+		}
+		if !rIsFloat {
+			rf = toFloat(rhs)
+		}
+		return applyFloat(op, lf, rf)
+	}
+	if l, ok := lhs.(int64); ok {
+		if r, ok := rhs.(int64); ok {
+			if result, ok, err := applyInt(op, l, r); ok || err != nil {
+				return result, err
+			}
+		}
+	}
+	return applyBig(op, toBig(lhs), toBig(rhs))
+}
+
+func applyFloat(op Op, lhs, rhs float64) (any, error) {
+	switch op {
+	case OpAdd:
+		return lhs + rhs, nil
+	case OpSubtract:
+		return lhs - rhs, nil
+	case OpMultiply:
+		return lhs * rhs, nil
+	case OpDivide:
+		if rhs == 0 {
+			return nil, fmt.Errorf("%w: division by zero", parse.ErrEval)
+		}
+		return lhs / rhs, nil
+	case OpModulo:
+		if rhs == 0 {
+			return nil, fmt.Errorf("%w: division by zero", parse.ErrEval)
+		}
+		return math.Mod(lhs, rhs), nil
+	case OpPower:
+		return math.Pow(lhs, rhs), nil
+	}
+	return nil, fmt.Errorf("%w: unexpected binary operator: %v", parse.ErrEval, op)
+}
+
+// applyInt returns the result of lhs op rhs. The boolean result is false if the result does not fit in an int64.
+func applyInt(op Op, lhs, rhs int64) (any, bool, error) {
+	switch op {
+	case OpAdd:
+		result := lhs + rhs
+		return result, (lhs^result)&(rhs^result) >= 0, nil
+	case OpSubtract:
+		result := lhs - rhs
+		return result, (lhs^rhs)&(lhs^result) >= 0, nil
+	case OpMultiply:
+		if lhs == 0 || rhs == 0 {
+			return int64(0), true, nil
+		}
+		result := lhs * rhs
+		overflow := result/rhs != lhs || (lhs == -1 && rhs == math.MinInt64) || (rhs == -1 && lhs == math.MinInt64)
+		return result, !overflow, nil
+	case OpDivide, OpModulo:
+		if rhs == 0 {
+			return nil, false, fmt.Errorf("%w: division by zero", parse.ErrEval)
+		}
+		if rhs == -1 { // avoid overflow when lhs is math.MinInt64
+			if op == OpModulo {
+				return int64(0), true, nil
+			}
+			return -lhs, lhs != math.MinInt64, nil
+		}
+		if op == OpModulo {
+			return lhs % rhs, true, nil
+		}
+		return lhs / rhs, true, nil
+	case OpPower:
+		if rhs < 0 {
+			return math.Pow(float64(lhs), float64(rhs)), true, nil
+		}
+		switch lhs {
+		case 0, 1:
+			if rhs == 0 {
+				return int64(1), true, nil
+			}
+			return lhs, true, nil
+		case -1:
+			if rhs%2 == 0 {
+				return int64(1), true, nil
+			}
+			return int64(-1), true, nil
+		}
+		// any other base overflows after at most 63 multiplications
+		result := int64(1)
+		for ; rhs > 0; rhs-- {
+			product, ok, _ := applyInt(OpMultiply, result, lhs)
+			if !ok {
+				return nil, false, nil
+			}
+			result = product.(int64)
+		}
+		return result, true, nil
+	}
+	return nil, false, fmt.Errorf("%w: unexpected binary operator: %v", parse.ErrEval, op)
+}
+
+func applyBig(op Op, lhs, rhs *big.Int) (any, error) {
+	result := new(big.Int)
+	switch op {
+	case OpAdd:
+		result.Add(lhs, rhs)
+	case OpSubtract:
+		result.Sub(lhs, rhs)
+	case OpMultiply:
+		result.Mul(lhs, rhs)
+	case OpDivide, OpModulo:
+		if rhs.Sign() == 0 {
+			return nil, fmt.Errorf("%w: division by zero", parse.ErrEval)
+		}
+		if op == OpModulo {
+			result.Rem(lhs, rhs)
+		} else {
+			result.Quo(lhs, rhs)
+		}
+	case OpPower:
+		if rhs.Sign() < 0 {
+			return math.Pow(toFloat(lhs), toFloat(rhs)), nil
+		}
+		if lhs.CmpAbs(big.NewInt(1)) > 0 && (!rhs.IsInt64() || rhs.Int64() > maxBits/int64(lhs.BitLen()-1)) {
+			return nil, fmt.Errorf("%w: result of %v ** %v is too large", parse.ErrEval, lhs, rhs)
+		}
+		result.Exp(lhs, rhs, nil)
+	default:
+		return nil, fmt.Errorf("%w: unexpected binary operator: %v", parse.ErrEval, op)
+	}
+	return normalize(result), nil
+}
+
+func negate(val any) any {
+	switch v := val.(type) {
+	case int64:
+		if v == math.MinInt64 {
+			return new(big.Int).Neg(big.NewInt(v))
+		}
+		return -v
+	case *big.Int:
+		return normalize(new(big.Int).Neg(v))
+	default:
+		return -val.(float64)
+	}
+}
+
+func toFloat(val any) float64 {
+	switch v := val.(type) {
+	case int64:
+		return float64(v)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	default:
+		return val.(float64)
+	}
+}
+
+func toBig(val any) *big.Int {
+	if i, ok := val.(int64); ok {
+		return big.NewInt(i)
+	}
+	return val.(*big.Int)
+}
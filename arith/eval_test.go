@@ -0,0 +1,133 @@
+package arith
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/big"
+	"strconv"
+	"testing"
+)
+
+// intInterpreter interprets single-token Unparsed nodes as integers.
+func intInterpreter(ast parse.AST) (any, error) {
+	unparsed, ok := ast.(parse.Unparsed)
+	if !ok || len(unparsed.Contents) != 1 {
+		return nil, fmt.Errorf("%w: %v", parse.ErrUnknownAST, ast)
+	}
+	return strconv.ParseInt(unparsed.Contents[0].Text, 10, 64)
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"i":    7,
+		"u":    uint8(3),
+		"f":    2.5,
+		"max":  int64(math.MaxInt64),
+		"min":  int64(math.MinInt64),
+		"huge": uint64(math.MaxUint64),
+		"big":  new(big.Int).Lsh(big.NewInt(1), 100),
+		"json": json.Number("12"),
+	}
+	bigInt := func(s string) *big.Int {
+		i, ok := new(big.Int).SetString(s, 10)
+		require.True(t, ok)
+		return i
+	}
+
+	tests := []struct {
+		input  string
+		output any
+	}{
+		{"i + u * 2", int64(13)},
+		{"i - u - 1", int64(3)},
+		{"i / u", int64(2)},
+		{"-i / u", int64(-2)},
+		{"-i % u", int64(-1)},
+		{"i * f", 17.5},
+		{"f ** 2", 6.25},
+		{"2 ** 10", int64(1024)},
+		{"2 ** -1", 0.5},
+		{"(-1) ** 1001", int64(-1)},
+		{"-2 ** 2", int64(-4)},
+		{"json * 2", int64(24)},
+		{"max + 1", bigInt("9223372036854775808")},
+		{"max + 1 - 1", int64(math.MaxInt64)},
+		{"min - 1", bigInt("-9223372036854775809")},
+		{"-min", bigInt("9223372036854775808")},
+		{"min / -1", bigInt("9223372036854775808")},
+		{"min % -1", int64(0)},
+		{"max * max", bigInt("85070591730234615847396907784232501249")},
+		{"2 ** 64", bigInt("18446744073709551616")},
+		{"huge - 1", bigInt("18446744073709551614")},
+		{"big / big", int64(1)},
+		{"big % 7", int64(2)},
+		{"big * 0.5", math.Pow(2, 99)},
+		{"3 ** 0", int64(1)},
+	}
+
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			result, err := Eval(ast, valueInterpreter(vars))
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, result)
+		})
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	vars := map[string]any{"s": "abc", "n": nil, "big": new(big.Int).Lsh(big.NewInt(1), 100)}
+	tests := []string{
+		"1 / 0",
+		"1 % 0",
+		"1.5 / 0",
+		"big / 0",
+		"s + 1",
+		"-n",
+		"x * 2",
+		"2 ** 1000000",
+		"big ** 1000000",
+	}
+
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			ast, err := p.ParseStr(tt)
+			require.NoError(t, err)
+			_, err = Eval(ast, valueInterpreter(vars))
+			assert.ErrorIs(t, err, parse.ErrEval)
+		})
+	}
+
+	_, err = Eval(add(un("1"), un("2")), nil)
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
+
+// valueInterpreter interprets single-token Unparsed nodes as numbers, or looks them up in the provided map.
+func valueInterpreter(vars map[string]any) parse.Interpreter[any] {
+	return func(ast parse.AST) (any, error) {
+		unparsed, ok := ast.(parse.Unparsed)
+		if !ok || len(unparsed.Contents) != 1 {
+			return nil, fmt.Errorf("%w: %v", parse.ErrUnknownAST, ast)
+		}
+		text := unparsed.Contents[0].Text
+		if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f, nil
+		}
+		if val, ok := vars[text]; ok {
+			return val, nil
+		}
+		return nil, fmt.Errorf("%w: unknown variable '%s'", parse.ErrEval, text)
+	}
+}
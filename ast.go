@@ -7,7 +7,7 @@ package parse
 import (
 	"errors"
 	"fmt"
-	"unicode"
+	"strings"
 )
 
 // ErrConfig is returned when an error occurs configuring a Parser.
@@ -19,6 +19,11 @@ var ErrParse = errors.New("error parsing")
 // ErrEval is returned when an error occurs during evaluation.
 var ErrEval = errors.New("eval error")
 
+// ErrNotFound is returned by Interpreters when a value referred to by an expression does not exist, such as a missing
+// variable. It matches ErrEval when checked using errors.Is. Operators which test for null, such as IS NULL in the comp
+// package, treat values which are not found as null.
+var ErrNotFound = fmt.Errorf("%w: variable not found", ErrEval)
+
 // ErrUnknownAST is returned by Interpreters to signal that they are unprepared to evaluate nodes of unknown type.
 var ErrUnknownAST = errors.New("unknown AST node")
 
@@ -29,12 +34,93 @@ type AST interface {
 	Parse(Parser) error
 }
 
+// Spanned is implemented by AST nodes and tokens which know their location in the source text. All ASTs provided by
+// this module implement Spanned.
+type Spanned interface {
+	Span() Span
+}
+
+// SpanOf returns the Span of the provided AST, or the zero Span if it does not implement Spanned.
+func SpanOf(ast AST) Span {
+	if s, ok := ast.(Spanned); ok {
+		return s.Span()
+	}
+	return Span{}
+}
+
+// Pos describes a position in the source text of an expression.
+type Pos struct {
+	Offset int // Offset is the byte offset of this position, starting from 0.
+	Rune   int // Rune is the rune offset of this position, starting from 0.
+	Line   int // Line is the line number of this position, starting from 1.
+	Col    int // Col is the column of this position, counted in runes and starting from 1.
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Span describes a range of source text. Start is inclusive, and End is exclusive.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+// Token is a single token taken from the source text of an expression.
+type Token struct {
+	Text  string // Text is the text of this token as found in the source.
+	Src   Span   // Src is the location of this token in the source text.
+	Quote rune   // Quote is the rune delimiting this token if it is a string literal, and 0 otherwise.
+}
+
+// Span returns the location of this token in the source text.
+func (t Token) Span() Span {
+	return t.Src
+}
+
+// NewTokens returns a list of tokens with the provided text and no location information. It is useful for passing
+// tokens which were not produced by a Tokenizer directly to a Parser. Any text which begins and ends with the same rune
+// from DefaultQuotes is marked as a string literal.
+func NewTokens(texts ...string) []Token {
+	result := make([]Token, 0, len(texts))
+	for _, text := range texts {
+		token := Token{Text: text}
+		if runes := []rune(text); len(runes) >= 2 && runes[0] == runes[len(runes)-1] {
+			for _, q := range DefaultQuotes {
+				if runes[0] == q {
+					token.Quote = q
+				}
+			}
+		}
+		result = append(result, token)
+	}
+	return result
+}
+
 // Unparsed represents a list of unparsed tokens in an expression.
 type Unparsed struct {
-	Contents []string // Contents is a list of tokens which could not be parsed as part of the expression.
+	Contents []Token // Contents is a list of tokens which could not be parsed as part of the expression.
+}
+
+// Span returns the location of the tokens in this node, from the start of the first to the end of the last.
+func (u Unparsed) Span() Span {
+	if len(u.Contents) == 0 {
+		return Span{}
+	}
+	return Span{Start: u.Contents[0].Src.Start, End: u.Contents[len(u.Contents)-1].Src.End}
+}
+
+// String returns the text of each token in this node, separated by spaces.
+func (u Unparsed) String() string {
+	texts := make([]string, 0, len(u.Contents))
+	for _, token := range u.Contents {
+		texts = append(texts, token.Text)
+	}
+	return strings.Join(texts, " ")
 }
 
-// Parse should never be called on an Unparsed node in a correct implementation. Doing so returns ErrParse.
+// Parse should never be called on an Unparsed node in a correct implementation. Doing so returns ErrParse. Use ParseAll
+// to parse an AST which may consist of a single Unparsed node.
 func (u Unparsed) Parse(p Parser) error {
 	// Parse calls should never make it to an Unparsed node.
 	return fmt.Errorf("%w: attempted to parse Unparsed node", ErrParse)
@@ -42,7 +128,31 @@ func (u Unparsed) Parse(p Parser) error {
 
 // A Parser knows how to turn a slice of tokens into AST nodes.
 type Parser interface {
-	Parse(tokens []string) (AST, error)
+	Parse(tokens []Token) (AST, error)
+}
+
+// ParseAll runs each of the provided parsers over the provided AST in order, replacing every Unparsed node found, and
+// returns the resulting AST. It is equivalent to calling ast.Parse with each parser in turn, except that an Unparsed
+// node found at the root of the AST is handled by passing its contents to the parser directly. For example, to parse
+// boolean expressions and then comparisons:
+//
+//	ast, err := parse.ParseAll(parse.Unparsed{Contents: tokens}, boolParser, compParser)
+func ParseAll(ast AST, parsers ...Parser) (AST, error) {
+	if ast == nil {
+		return nil, fmt.Errorf("%w: nil AST", ErrParse)
+	}
+	for _, p := range parsers {
+		if unparsed, ok := ast.(Unparsed); ok {
+			parsed, err := p.Parse(unparsed.Contents)
+			if err != nil {
+				return nil, err
+			}
+			ast = parsed
+		} else if err := ast.Parse(p); err != nil {
+			return nil, err
+		}
+	}
+	return ast, nil
 }
 
 // An Interpreter provides a way to interpret an AST, producing a value of type T. If an Interpreter ever
@@ -60,45 +170,3 @@ func (i Interpreter[T]) WithFallback(b Interpreter[T]) Interpreter[T] {
 		return a, err
 	}
 }
-
-// Tokenize is a general-purpose expression tokenizer which handles keywords according to the isKeyword func passed.
-// Open and close braces must be single runes and are handled according to the provided runes.
-func Tokenize(str string, open, close rune, keywordMatcher *KeywordTrie) []string {
-	runes := []rune(str)
-	var substr []rune
-	var result []string
-	push := func() { // push substr onto result
-		result = append(result, string(substr))
-		substr = nil
-	}
-
-	for i := 0; i < len(runes); i++ {
-		if runes[i] == open || runes[i] == close {
-			if len(substr) > 0 {
-				push()
-			}
-			result = append(result, string(runes[i]))
-			continue
-		}
-		if unicode.IsSpace(runes[i]) {
-			if len(substr) > 0 {
-				push()
-			}
-			continue
-		}
-		matched := keywordMatcher.Match(runes[i:])
-		if len(matched) > 0 {
-			if len(substr) > 0 {
-				push()
-			}
-			result = append(result, matched)
-			i += len(matched) - 1
-		} else {
-			substr = append(substr, runes[i])
-		}
-	}
-	if len(substr) > 0 {
-		push()
-	}
-	return result
-}
@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestUnparsed(t *testing.T) {
+	u := Unparsed{Contents: Tokenize("x  > 3", '(', ')', &KeywordTrie{})}
+
+	assert.Equal(t, "x > 3", u.String())
+	assert.Equal(t, Span{Start: Pos{Line: 1, Col: 1}, End: Pos{Offset: 6, Rune: 6, Line: 1, Col: 7}}, SpanOf(u))
+	assert.Equal(t, Span{}, SpanOf(Unparsed{}))
+}
+
+// pair is an AST node with two children, used for testing.
+type pair struct {
+	LHS, RHS AST
+}
+
+func (p *pair) Parse(parser Parser) error {
+	for _, child := range []*AST{&p.LHS, &p.RHS} {
+		if unparsed, ok := (*child).(Unparsed); ok {
+			parsed, err := parser.Parse(unparsed.Contents)
+			if err != nil {
+				return err
+			}
+			*child = parsed
+		} else if err := (*child).Parse(parser); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitParser splits its tokens in two at the first occurrence of its separator.
+type splitParser string
+
+func (s splitParser) Parse(tokens []Token) (AST, error) {
+	for i, token := range tokens {
+		if token.Text == string(s) {
+			if i == 0 || i == len(tokens)-1 {
+				return nil, NewSyntaxError(tokens, i, nil, "unexpected '%s'", s)
+			}
+			return &pair{LHS: Unparsed{Contents: tokens[:i]}, RHS: Unparsed{Contents: tokens[i+1:]}}, nil
+		}
+	}
+	return Unparsed{Contents: tokens}, nil
+}
+
+func TestParseAll(t *testing.T) {
+	root := Unparsed{Contents: NewTokens(strings.Fields("a + b * c + d")...)}
+
+	ast, err := ParseAll(root, splitParser("*"), splitParser("+"))
+	require.NoError(t, err)
+	assert.Equal(t, &pair{
+		LHS: &pair{LHS: Unparsed{Contents: NewTokens("a")}, RHS: Unparsed{Contents: NewTokens("b")}},
+		RHS: &pair{LHS: Unparsed{Contents: NewTokens("c")}, RHS: Unparsed{Contents: NewTokens("d")}},
+	}, ast)
+
+	ast, err = ParseAll(root, splitParser("-"))
+	require.NoError(t, err)
+	assert.Equal(t, root, ast)
+
+	ast, err = ParseAll(root)
+	require.NoError(t, err)
+	assert.Equal(t, root, ast)
+
+	_, err = ParseAll(Unparsed{Contents: NewTokens("+", "b")}, splitParser("+"))
+	assert.ErrorIs(t, err, ErrParse)
+
+	_, err = ParseAll(nil, splitParser("+"))
+	assert.ErrorIs(t, err, ErrParse)
+}
@@ -1,13 +1,32 @@
 // Package bools implements a recursive-descent parser for boolean expressions according to the following grammar.
 //
 //		expr     -> and
-//		and      -> or 'AND' and | or
-//		or       -> not 'OR' or | not
+//		and      -> or ( 'AND' or )*
+//		or       -> not ( 'OR' not )*
 //	    not      -> 'NOT' parens | parens
 //		parens   -> '(' expr ')' | unparsed
 //		unparsed -> '.*
 //
+// By default, OR binds more tightly than AND, for compatibility with earlier versions of this package. The
+// conventional precedence found in SQL and C, in which AND binds more tightly than OR, can be selected using
+// WithPrecedence, in which case the following grammar is used instead.
+//
+//		expr     -> or
+//		or       -> and ( 'OR' and )*
+//		and      -> not ( 'AND' not )*
+//	    not      -> 'NOT' parens | parens
+//		parens   -> '(' expr ')' | unparsed
+//		unparsed -> '.*
+//
+// Chains of AND and OR are parsed into left-associative trees of BinExpr nodes, so that a AND b AND c is parsed as
+// (a AND b) AND c. Parsing, evaluation and calls to BinExpr.Parse handle long chains iteratively, without recursing
+// once per clause.
+//
 // It leaves unparsed portions of the expression in parse.Unparsed nodes, for later consumption by other parsers.
+// Parenthesized groups which follow a term, as in len(x), are kept in the term, as are groups which begin a term
+// followed by more of it, as in (a + b) * 2, unless they contain AND, OR or NOT. Operators of other grammars which
+// contain keywords of this grammar, such as the NOT IN of x NOT IN (a, b), are also left in the term for later
+// consumption, as is the AND of a range test such as x BETWEEN 1 AND 10. See WithTermOperators for details.
 //
 // The syntax used by this parser is configurable at runtime, see NewParser for details. By default, this parser
 // provides a case-sensitive variety of ANSI SQL syntax.
@@ -18,9 +37,13 @@
 package bools
 
 import (
+	"errors"
 	"fmt"
 	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/spine"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // VarInterpreter provides an interpreter which looks up the value of every parsed.Unparsed node with a single token in
@@ -33,11 +56,11 @@ func VarInterpreter(variables map[string]bool) parse.Interpreter[bool] {
 		switch ast := ast.(type) {
 		case parse.Unparsed:
 			if len(ast.Contents) > 1 {
-				return false, fmt.Errorf("%w: cannot evaluate multi-word variables; found '%v'", parse.ErrEval, strings.Join(ast.Contents, " "))
+				return false, fmt.Errorf("%w: cannot evaluate multi-word variables; found '%v'", parse.ErrEval, ast)
 			}
-			val, ok := variables[ast.Contents[0]]
+			val, ok := variables[ast.Contents[0].Text]
 			if !ok {
-				return false, fmt.Errorf("%w: unknown variable '%s'", parse.ErrEval, ast.Contents[0])
+				return false, fmt.Errorf("%w: unknown variable '%s'", parse.ErrEval, ast.Contents[0].Text)
 			}
 			return val, nil
 		default:
@@ -46,98 +69,343 @@ func VarInterpreter(variables map[string]bool) parse.Interpreter[bool] {
 	}
 }
 
+// EvalOpt configures the behavior of Eval.
+type EvalOpt func(*evaluator)
+
+// WithShortCircuit sets whether Eval skips clauses which cannot affect the result of an expression. Short-circuit
+// evaluation is enabled by default, so that the right-hand side of an AND is skipped if the left-hand side is false,
+// and the right-hand side of an OR is skipped if the left-hand side is true. Disabling short-circuit evaluation causes
+// every clause to be evaluated, which is useful to validate that an expression can be evaluated at all.
+func WithShortCircuit(shortCircuit bool) EvalOpt {
+	return func(e *evaluator) {
+		e.fullEval = !shortCircuit
+	}
+}
+
+type evaluator struct {
+	interpreter parse.Interpreter[bool]
+	fullEval    bool
+}
+
 // Eval evaluates the provided AST node using the provided Interpreter, which must be capable of interpreting any nodes
-// not found in the bools package.
-func Eval(expr parse.AST, interpreter parse.Interpreter[bool]) (bool, error) {
+// not found in the bools package. Clauses are evaluated from left to right, and the first error encountered is
+// returned. By default, clauses which cannot affect the result are skipped; see WithShortCircuit.
+func Eval(expr parse.AST, interpreter parse.Interpreter[bool], opts ...EvalOpt) (bool, error) {
 	if interpreter == nil {
 		return false, fmt.Errorf("%w: nil Interpreter", parse.ErrEval)
 	}
+	e := &evaluator{interpreter: interpreter}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e.eval(expr)
+}
+
+func (e *evaluator) eval(expr parse.AST) (bool, error) {
 	if expr == nil {
 		return false, fmt.Errorf("%w: nil expression", parse.ErrEval)
 	}
 	switch expr := expr.(type) {
 	case *BinExpr:
-		if expr.Op != OpAnd && expr.Op != OpOr {
-			return false, fmt.Errorf("unexpected binary boolean operator: %v", expr.Op)
-		}
-		rhs, err := Eval(expr.RHS, interpreter)
+		var val bool
+		err := WalkChain(expr, func(b *BinExpr, deepest bool) error {
+			var err error
+			if deepest {
+				if val, err = e.eval(b.LHS); err != nil {
+					return err
+				}
+			}
+			val, err = e.apply(b.Op, val, b.RHS)
+			return err
+		})
 		if err != nil {
 			return false, err
 		}
-		lhs, err := Eval(expr.LHS, interpreter)
-		if err != nil {
-			return false, err
+		return val, nil
+	case *ListExpr:
+		if expr.Op != OpAnd && expr.Op != OpOr {
+			return false, fmt.Errorf("unexpected boolean list operator: %v", expr.Op)
 		}
-		switch expr.Op {
-		case OpAnd:
-			return rhs && lhs, nil
-		case OpOr:
-			return rhs || lhs, nil
+		val := expr.Op == OpAnd // the identity of the operator, so that empty lists are handled
+		for _, term := range expr.Terms {
+			var err error
+			if val, err = e.apply(expr.Op, val, term); err != nil {
+				return false, err
+			}
 		}
+		return val, nil
 	case *UnaryExpr:
 		if expr.Op != OpNot {
 			return false, fmt.Errorf("unexpected boolean unary operator: %v", expr.Op)
 		}
-		val, err := Eval(expr.Expr, interpreter)
+		val, err := e.eval(expr.Expr)
 		if err != nil {
 			return false, err
 		}
 		return !val, nil
 	default:
-		return interpreter(expr)
+		return e.interpreter(expr)
+	}
+}
+
+// apply returns the result of lhs op rhs, evaluating rhs only if it is needed or if short-circuiting is disabled.
+func (e *evaluator) apply(op Op, lhs bool, rhs parse.AST) (bool, error) {
+	switch op {
+	case OpAnd:
+		if !lhs && !e.fullEval {
+			return false, nil
+		}
+	case OpOr:
+		if lhs && !e.fullEval {
+			return true, nil
+		}
+	default:
+		return false, fmt.Errorf("unexpected binary boolean operator: %v", op)
 	}
-	// unreachable
-	return false, fmt.Errorf("unexpected expression")
+	val, err := e.eval(rhs)
+	if err != nil {
+		return false, err
+	}
+	if op == OpAnd {
+		return lhs && val, nil
+	}
+	return lhs || val, nil
 }
 
 // BinExpr represents a boolean expression consisting of clauses of one boolean operator.
 type BinExpr struct {
-	LHS parse.AST // LHS is the left-hand side
-	RHS parse.AST // RHS is the right-hand side
-	Op  Op
+	LHS parse.AST  // LHS is the left-hand side
+	RHS parse.AST  // RHS is the right-hand side
+	Op  Op         // Op is the boolean operator
+	Src parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (b *BinExpr) String() string {
+	return format(b)
+}
+
+// Span returns the location of this expression in the source text.
+func (b *BinExpr) Span() parse.Span {
+	return b.Src
 }
 
 // Parse runs the provided parse.Parser on all the unparsed nodes in this AST.
 func (b *BinExpr) Parse(p parse.Parser) error {
-	if unparsed, ok := b.LHS.(parse.Unparsed); ok {
-		newLHS, err := p.Parse(unparsed.Contents)
-		if err != nil {
-			return err
+	return WalkChain(b, func(b *BinExpr, deepest bool) error {
+		if deepest {
+			if err := parseChild(&b.LHS, p); err != nil {
+				return err
+			}
 		}
-		b.LHS = newLHS
-	} else if err := b.LHS.Parse(p); err != nil {
-		return err
-	}
-	if unparsed, ok := b.RHS.(parse.Unparsed); ok {
-		newRHS, err := p.Parse(unparsed.Contents)
-		if err != nil {
+		return parseChild(&b.RHS, p)
+	})
+}
+
+// Children returns the left-hand and right-hand sides of this expression.
+func (b *BinExpr) Children() []parse.AST {
+	return []parse.AST{b.LHS, b.RHS}
+}
+
+// WithChildren returns a copy of this expression with the provided left-hand and right-hand sides.
+func (b *BinExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("BinExpr", children, 2)
+	return &BinExpr{LHS: children[0], RHS: children[1], Op: b.Op, Src: b.Src}
+}
+
+// WalkChain calls visit for the provided BinExpr and every BinExpr found by repeatedly following its left-hand side,
+// starting from the deepest, for which deepest is true and whose left-hand side begins the chain. Long chains such as
+// a AND b AND c are visited iteratively and in order, without allocating. Walking stops at the first error from visit.
+func WalkChain(b *BinExpr, visit func(b *BinExpr, deepest bool) error) error {
+	return spine.Walk(b, leftBin, visit)
+}
+
+// leftBin returns the left-hand side of the provided BinExpr, if it is also a BinExpr.
+func leftBin(b *BinExpr) (*BinExpr, bool) {
+	lhs, ok := b.LHS.(*BinExpr)
+	return lhs, ok
+}
+
+// ListExpr represents a boolean expression consisting of any number of clauses joined by one boolean operator, such
+// as a AND b AND c. It is the flattened form of a chain of BinExpr nodes which share an operator; see Flatten and
+// Binarize.
+type ListExpr struct {
+	Op    Op          // Op is the boolean operator joining each term; either OpAnd or OpOr.
+	Terms []parse.AST // Terms lists the clauses joined by Op.
+	Src   parse.Span  // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (l *ListExpr) String() string {
+	return format(l)
+}
+
+// Span returns the location of this expression in the source text.
+func (l *ListExpr) Span() parse.Span {
+	return l.Src
+}
+
+// Parse runs the provided parse.Parser on all unparsed nodes in this AST.
+func (l *ListExpr) Parse(p parse.Parser) error {
+	for i := range l.Terms {
+		if err := parseChild(&l.Terms[i], p); err != nil {
 			return err
 		}
-		b.RHS = newRHS
-	} else if err := b.RHS.Parse(p); err != nil {
-		return err
 	}
 	return nil
 }
 
+// Children returns the terms of this expression.
+func (l *ListExpr) Children() []parse.AST {
+	return l.Terms
+}
+
+// WithChildren returns a copy of this expression with the provided terms.
+func (l *ListExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("ListExpr", children, len(l.Terms))
+	return &ListExpr{Op: l.Op, Terms: append([]parse.AST(nil), children...), Src: l.Src}
+}
+
+// Flatten returns an AST equivalent to the one provided, in which every chain of BinExpr nodes sharing an operator has
+// been replaced by a single ListExpr, so that (a AND b) AND (c AND d) becomes a ListExpr with four terms. Existing
+// ListExpr nodes are merged into the chains they belong to. Nodes from other packages are left as-is, and the
+// provided AST is not modified.
+func Flatten(ast parse.AST) parse.AST {
+	switch ast := ast.(type) {
+	case *BinExpr:
+		list := &ListExpr{Op: ast.Op, Src: ast.Src}
+		list.Terms = flattenTerms(ast.Op, ast, list.Terms)
+		return list
+	case *ListExpr:
+		list := &ListExpr{Op: ast.Op, Src: ast.Src}
+		list.Terms = flattenTerms(ast.Op, ast, list.Terms)
+		return list
+	case *UnaryExpr:
+		return &UnaryExpr{Op: ast.Op, Expr: Flatten(ast.Expr), Src: ast.Src}
+	default:
+		return ast
+	}
+}
+
+// flattenTerms appends the flattened terms of the provided chain of op to terms.
+func flattenTerms(op Op, ast parse.AST, terms []parse.AST) []parse.AST {
+	switch ast := ast.(type) {
+	case *BinExpr:
+		if ast.Op != op {
+			break
+		}
+		sameOp := func(b *BinExpr) (*BinExpr, bool) {
+			lhs, ok := leftBin(b)
+			return lhs, ok && lhs.Op == op
+		}
+		_ = spine.Walk(ast, sameOp, func(b *BinExpr, deepest bool) error {
+			if deepest {
+				terms = flattenTerms(op, b.LHS, terms)
+			}
+			terms = flattenTerms(op, b.RHS, terms)
+			return nil
+		})
+		return terms
+	case *ListExpr:
+		if ast.Op != op {
+			break
+		}
+		for _, term := range ast.Terms {
+			terms = flattenTerms(op, term, terms)
+		}
+		return terms
+	}
+	return append(terms, Flatten(ast))
+}
+
+// Binarize returns an AST equivalent to the one provided, in which every ListExpr has been replaced by a
+// left-associative chain of BinExpr nodes. A ListExpr with a single term is replaced by that term, while one with no
+// terms is left as-is. Nodes from other packages are left as-is, and the provided AST is not modified.
+func Binarize(ast parse.AST) parse.AST {
+	switch ast := ast.(type) {
+	case *ListExpr:
+		if len(ast.Terms) == 0 {
+			return ast
+		}
+		result := Binarize(ast.Terms[0])
+		for i, term := range ast.Terms[1:] {
+			rhs := Binarize(term)
+			span := parse.Span{Start: parse.SpanOf(result).Start, End: parse.SpanOf(rhs).End}
+			if i == len(ast.Terms)-2 {
+				span = ast.Src
+			}
+			result = &BinExpr{LHS: result, RHS: rhs, Op: ast.Op, Src: span}
+		}
+		return result
+	case *BinExpr:
+		var result parse.AST
+		_ = WalkChain(ast, func(b *BinExpr, deepest bool) error {
+			if deepest {
+				result = Binarize(b.LHS)
+			}
+			result = &BinExpr{LHS: result, RHS: Binarize(b.RHS), Op: b.Op, Src: b.Src}
+			return nil
+		})
+		return result
+	case *UnaryExpr:
+		return &UnaryExpr{Op: ast.Op, Expr: Binarize(ast.Expr), Src: ast.Src}
+	default:
+		return ast
+	}
+}
+
 // UnaryExpr represents a unary boolean expression.
 type UnaryExpr struct {
 	Op   Op
 	Expr parse.AST
+	Src  parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (u *UnaryExpr) String() string {
+	return format(u)
+}
+
+// Span returns the location of this expression in the source text.
+func (u *UnaryExpr) Span() parse.Span {
+	return u.Src
 }
 
 // Parse runs the provided parse.Parser on all unparsed nodes in this AST.
 func (u *UnaryExpr) Parse(p parse.Parser) error {
-	if unparsed, ok := u.Expr.(parse.Unparsed); ok {
-		newExpr, err := p.Parse(unparsed.Contents)
+	return parseChild(&u.Expr, p)
+}
+
+// Children returns the operand of this expression.
+func (u *UnaryExpr) Children() []parse.AST {
+	return []parse.AST{u.Expr}
+}
+
+// WithChildren returns a copy of this expression with the provided operand.
+func (u *UnaryExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("UnaryExpr", children, 1)
+	return &UnaryExpr{Op: u.Op, Expr: children[0], Src: u.Src}
+}
+
+// checkChildren panics if the number of children passed to WithChildren is not as expected.
+func checkChildren(node string, children []parse.AST, expected int) {
+	if len(children) != expected {
+		panic(fmt.Sprintf("bools: %s.WithChildren called with %d children; expected %d", node, len(children), expected))
+	}
+}
+
+// parseChild replaces the provided child with the result of parsing it if it is parse.Unparsed, and otherwise parses
+// it recursively.
+func parseChild(child *parse.AST, p parse.Parser) error {
+	if unparsed, ok := (*child).(parse.Unparsed); ok {
+		parsed, err := p.Parse(unparsed.Contents)
 		if err != nil {
 			return err
 		}
-		u.Expr = newExpr
-	} else if err := u.Expr.Parse(p); err != nil {
-		return err
+		*child = parsed
+		return nil
 	}
-	return nil
+	return (*child).Parse(p)
 }
 
 // Op represents a boolean operation recognized by this grammar.
@@ -173,15 +441,53 @@ const (
 	CloseParen                  // CloseParen represents the end of a sub-expression.
 )
 
+// termOp is an operator of another grammar which is kept within a term. Its tail holds the words which follow its
+// operand, if any, as in the AND of BETWEEN ... AND.
+type termOp struct {
+	head []string
+	tail []string
+}
+
+// Precedence determines whether AND or OR binds more tightly.
+type Precedence uint8
+
+const (
+	// PrecedenceLegacy causes OR to bind more tightly than AND, so that a OR b AND c is parsed as (a OR b) AND c. It is
+	// the default, for compatibility with earlier versions of this package.
+	PrecedenceLegacy Precedence = iota
+	// PrecedenceStandard causes AND to bind more tightly than OR as in SQL and C, so that a OR b AND c is parsed as
+	// a OR (b AND c).
+	PrecedenceStandard
+)
+
 type ParserOpt func(*Parser)
 
+// Parser parses this grammar. A Parser is safe for concurrent use by multiple goroutines.
 type Parser struct {
 	config          map[Token]string
 	caseInsensitive bool
+	wordBoundaries  bool
+	precedence      Precedence
+	quotes          []rune
+	termOps         []string
+	terms           []termOp // terms holds the words making up each of termOps.
 	matcher         *parse.KeywordTrie
+	tokenizer       parse.Tokenizer
+}
+
+// parser holds the state of a single call to Parser.Parse, so that a Parser can be used concurrently.
+type parser struct {
+	*Parser
+	tokens     []parse.Token
+	curr       int
+	expected   []Token // expected lists the tokens which failed to match at index expectedAt.
+	expectedAt int
 
-	tokens []string
-	curr   int
+	// ends holds the index of the CloseParen matching each OpenParen, or the number of tokens if it is not closed, and
+	// -1 for every other token. grouped reports whether each group directly contains an And, Or or Not. Both are
+	// computed once by matchParens, so that deeply nested groups are not scanned repeatedly.
+	ends    []int
+	grouped []bool
 }
 
 // WithTokens configures the syntax used by this parser using the provided token mapping. The provided map must contain
@@ -192,6 +498,48 @@ func WithTokens(config map[Token]string) ParserOpt {
 	}
 }
 
+// WithPrecedence sets the relative precedence of AND and OR. By default, PrecedenceLegacy is used.
+func WithPrecedence(precedence Precedence) ParserOpt {
+	return func(parser *Parser) {
+		parser.precedence = precedence
+	}
+}
+
+// WithWordBoundaries sets whether keywords which begin or end with a letter, digit or underscore are only recognized
+// at identifier boundaries when tokenizing. Symbolic keywords such as '&&' are recognized anywhere. Word boundaries
+// are required by default, so that, for instance, the identifier ORDER is not tokenized as the keyword OR followed by
+// DER unless this option is disabled.
+func WithWordBoundaries(wordBoundaries bool) ParserOpt {
+	return func(parser *Parser) {
+		parser.wordBoundaries = wordBoundaries
+	}
+}
+
+// WithQuotes sets the runes which delimit string literals. By default, the runes in parse.DefaultQuotes are used.
+// String literals are always treated as a single token, and are never interpreted as keywords, so that the expression
+// name == "x AND (y)" contains a single comparison. Calling WithQuotes with no arguments disables string literals.
+func WithQuotes(quotes ...rune) ParserOpt {
+	return func(parser *Parser) {
+		parser.quotes = quotes
+	}
+}
+
+// WithTermOperators sets the operators of other grammars which may appear within a term and contain keywords of this
+// grammar, such as NOT IN. Each operator is a sequence of words separated by whitespace. When an operator follows the
+// first token of a term, its words are kept in the term for later consumption, rather than being parsed as keywords.
+// A single ... between the words of an operator stands for an operand, so that the AND of x BETWEEN 1 AND 10 is kept
+// in the term given the operator BETWEEN ... AND. By default, the operators of the default comp.Parser which contain
+// keywords of the default Parser are recognized: NOT IN, IS NOT NULL, BETWEEN ... AND, and NOT BETWEEN ... AND.
+// Calling WithTermOperators with no arguments disables them, so that NOT is always parsed as a keyword. The operators
+// of a comp.Parser configured with other tokens are provided by its TermOperators method:
+//
+//	b, err := bools.NewParser(bools.WithTermOperators(compParser.TermOperators()...))
+func WithTermOperators(ops ...string) ParserOpt {
+	return func(parser *Parser) {
+		parser.termOps = ops
+	}
+}
+
 // WithCaseSensitive sets whether the configured parser is case-sensitive.
 func WithCaseSensitive(caseSensitive bool) ParserOpt {
 	return func(parser *Parser) {
@@ -199,6 +547,9 @@ func WithCaseSensitive(caseSensitive bool) ParserOpt {
 	}
 }
 
+// defaultTermOperators are the operators of the default comp.Parser which contain keywords of the default Parser.
+var defaultTermOperators = []string{"NOT IN", "IS NOT NULL", "BETWEEN ... AND", "NOT BETWEEN ... AND"}
+
 // NewParser returns a parser configured according to the provided options. If no options are configured, the default
 // parser is returned.
 func NewParser(opts ...ParserOpt) (*Parser, error) {
@@ -210,7 +561,10 @@ func NewParser(opts ...ParserOpt) (*Parser, error) {
 			OpenParen:  "(",
 			CloseParen: ")",
 		},
-		matcher: &parse.KeywordTrie{},
+		matcher:        &parse.KeywordTrie{},
+		quotes:         parse.DefaultQuotes,
+		wordBoundaries: true,
+		termOps:        defaultTermOperators,
 	}
 	for _, opt := range opts {
 		opt(p)
@@ -228,6 +582,23 @@ func (p *Parser) init() error {
 	if p.config[OpenParen] == p.config[CloseParen] {
 		return fmt.Errorf("%w: OpenParen and CloseParen must each be distinct", parse.ErrConfig)
 	}
+	p.tokenizer = parse.Tokenizer{
+		Open:           []rune(p.config[OpenParen])[0],
+		Close:          []rune(p.config[CloseParen])[0],
+		Keywords:       p.matcher,
+		Quotes:         p.quotes,
+		WordBoundaries: p.wordBoundaries,
+	}
+	for _, q := range p.quotes {
+		if unicode.IsSpace(q) || q == p.tokenizer.Open || q == p.tokenizer.Close {
+			return fmt.Errorf("%w: quote '%c' must not be whitespace, OpenParen or CloseParen", parse.ErrConfig, q)
+		}
+		for _, str := range p.config {
+			if strings.ContainsRune(str, q) {
+				return fmt.Errorf("%w: quote '%c' must not appear in any configured token", parse.ErrConfig, q)
+			}
+		}
+	}
 	if p.caseInsensitive {
 		newTokens := make(map[Token]string, len(p.config))
 		for token, str := range p.config {
@@ -238,131 +609,446 @@ func (p *Parser) init() error {
 	for _, str := range p.config {
 		p.matcher.Add(str)
 	}
+	p.terms = make([]termOp, len(p.termOps))
+	for i, op := range p.termOps {
+		if p.caseInsensitive {
+			op = strings.ToLower(op)
+		}
+		words := strings.Fields(op)
+		if len(words) == 0 {
+			return fmt.Errorf("%w: term operator %d is empty", parse.ErrConfig, i)
+		}
+		for j, word := range words {
+			if word != "..." {
+				continue
+			}
+			if j == 0 || j == len(words)-1 || p.terms[i].tail != nil {
+				return fmt.Errorf("%w: term operator '%s' must contain '...' only once, between other words",
+					parse.ErrConfig, op)
+			}
+			p.terms[i].tail = words[j+1:]
+			words = words[:j]
+		}
+		p.terms[i].head = words
+	}
+	if p.precedence != PrecedenceLegacy && p.precedence != PrecedenceStandard {
+		return fmt.Errorf("%w: unknown precedence %d", parse.ErrConfig, p.precedence)
+	}
 	if p.matcher.Count() != 5 {
 		return fmt.Errorf("%w: token collision detected; at least two of the configured tokens are identical", parse.ErrConfig)
 	}
 	return nil
 }
 
-// ParseStr tokenizes and parses the provided string.
+// ParseStr tokenizes and parses the provided string. Any *parse.SyntaxError returned includes the provided string as
+// its Source.
 func (p *Parser) ParseStr(str string) (parse.AST, error) {
-	return p.Parse(p.tokenize(str))
+	tokens, err := p.tokenize(str)
+	var ast parse.AST
+	if err == nil {
+		ast, err = p.Parse(tokens)
+	}
+	var syntaxErr *parse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Source = str
+	}
+	return ast, err
+}
+
+// Parse parses the provided list of tokens, producing a parse.AST. A *parse.SyntaxError is returned if the tokens
+// provided cannot be parsed.
+func (p *Parser) Parse(tokens []parse.Token) (parse.AST, error) {
+	return (&parser{Parser: p, tokens: tokens, expectedAt: -1}).parse()
 }
 
-// Parse parses the provided list of tokens, producing a parse.AST. An error is returned if the tokens provided cannot
-// be parsed.
-func (p *Parser) Parse(tokens []string) (parse.AST, error) {
-	p.curr = 0
-	p.tokens = tokens
+func (p *parser) parse() (parse.AST, error) {
+	p.matchParens()
 	ast, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
 	if p.curr != len(p.tokens) {
-		return nil, fmt.Errorf("%w: expected end of expression, found '%s'", parse.ErrParse, p.tokens[p.curr])
+		return nil, p.errorf("expected end of expression, found '%s'", p.peek().Text)
 	}
 	return ast, nil
 }
 
-func (p *Parser) tokenize(str string) []string {
-	openP, closeP := []rune(p.config[OpenParen])[0], []rune(p.config[CloseParen])[0]
-	return parse.Tokenize(str, openP, closeP, p.matcher)
+func (p *Parser) tokenize(str string) ([]parse.Token, error) {
+	return p.tokenizer.Tokenize(str)
+}
+
+func (p *parser) match(token Token) bool {
+	if p.is(p.curr, token) {
+		p.curr++
+		return true
+	}
+	p.expect(token)
+	return false
 }
 
-func (p *Parser) match(token Token) bool {
-	if p.curr == len(p.tokens) {
+// is reports whether the token at index i is the provided Token.
+func (p *parser) is(i int, token Token) bool {
+	if i >= len(p.tokens) || p.tokens[i].Quote != 0 {
 		return false
 	}
-	curr := p.tokens[p.curr]
+	curr := p.tokens[i].Text
 	if p.caseInsensitive {
 		curr = strings.ToLower(curr)
 	}
-	if curr == p.config[token] {
-		p.curr++
-		return true
+	return curr == p.config[token]
+}
+
+// matchParens computes ends and grouped in a single pass over the tokens.
+func (p *parser) matchParens() {
+	p.ends = make([]int, len(p.tokens))
+	p.grouped = make([]bool, len(p.tokens))
+	var open []int // open holds the indices of the groups enclosing the current token, innermost last.
+	for i := range p.tokens {
+		p.ends[i] = -1
+		switch {
+		case p.is(i, OpenParen):
+			open = append(open, i)
+		case p.is(i, CloseParen):
+			if len(open) > 0 {
+				p.ends[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		case len(open) > 0 && (p.is(i, And) || p.is(i, Or) || p.is(i, Not)):
+			p.grouped[open[len(open)-1]] = true
+		}
 	}
-	return false
+	for _, i := range open {
+		p.ends[i] = len(p.tokens)
+	}
+}
+
+// groupEnd returns the index of the CloseParen matching the OpenParen at index i, or the number of tokens if it is
+// not closed. If the token at index i is not an OpenParen, -1 is returned.
+func (p *parser) groupEnd(i int) int {
+	if i >= len(p.tokens) {
+		return -1
+	}
+	return p.ends[i]
+}
+
+// termOperator returns the term operator whose leading words are the longest match found at index i, or nil if there
+// is none.
+func (p *parser) termOperator(i int) *termOp {
+	var longest *termOp
+	for j, op := range p.terms {
+		if (longest == nil || len(op.head) > len(longest.head)) && p.matchWords(i, op.head) {
+			longest = &p.terms[j]
+		}
+	}
+	return longest
 }
 
-func (p *Parser) peek() string {
+// matchWords reports whether the tokens at index i are the provided words.
+func (p *parser) matchWords(i int, words []string) bool {
+	if i+len(words) > len(p.tokens) {
+		return false
+	}
+	for j, word := range words {
+		token := p.tokens[i+j]
+		text := token.Text
+		if p.caseInsensitive {
+			text = strings.ToLower(text)
+		}
+		if token.Quote != 0 || text != word {
+			return false
+		}
+	}
+	return true
+}
+
+// expect records that the provided token was expected at the current position, for use in error messages.
+func (p *parser) expect(token Token) {
+	if p.expectedAt != p.curr {
+		p.expected, p.expectedAt = p.expected[:0], p.curr
+	}
+	for _, t := range p.expected {
+		if t == token {
+			return
+		}
+	}
+	p.expected = append(p.expected, token)
+}
+
+// errorf returns a *parse.SyntaxError located at the current token.
+func (p *parser) errorf(format string, args ...any) error {
+	var expected []string
+	if p.expectedAt == p.curr {
+		for _, token := range p.expected {
+			expected = append(expected, p.config[token])
+		}
+	}
+	return parse.NewSyntaxError(p.tokens, p.curr, expected, format, args...)
+}
+
+func (p *parser) peek() parse.Token {
 	return p.tokens[p.curr]
 }
 
-func (p *Parser) isKeyword(str string) bool {
+// span returns the location of the tokens from start up to the current token.
+func (p *parser) span(start int) parse.Span {
+	return parse.Span{Start: p.tokens[start].Src.Start, End: p.tokens[p.curr-1].Src.End}
+}
+
+func (p *Parser) isKeyword(token parse.Token) bool {
+	if token.Quote != 0 {
+		return false
+	}
+	str := token.Text
 	if p.caseInsensitive {
 		str = strings.ToLower(str)
 	}
 	return p.matcher.Contains(str)
 }
 
-func (p *Parser) parseExpr() (parse.AST, error) {
+func (p *parser) parseExpr() (parse.AST, error) {
+	if p.precedence == PrecedenceStandard {
+		return p.parseOr()
+	}
 	return p.parseAnd()
 }
 
-func (p *Parser) parseAnd() (parse.AST, error) {
-	lhs, err := p.parseOr()
-	if err != nil {
-		return nil, err
+// parseAnd parses a conjunction, whose operands are disjunctions under PrecedenceLegacy, and negations otherwise.
+func (p *parser) parseAnd() (parse.AST, error) {
+	if p.precedence == PrecedenceStandard {
+		return p.parseChain(And, OpAnd, p.parseNot)
 	}
-	if p.match(And) {
-		rhs, err := p.parseAnd()
-		if err != nil {
-			return nil, err
-		}
-		return &BinExpr{LHS: lhs, RHS: rhs, Op: OpAnd}, nil
+	return p.parseChain(And, OpAnd, p.parseOr)
+}
+
+// parseOr parses a disjunction, whose operands are negations under PrecedenceLegacy, and conjunctions otherwise.
+func (p *parser) parseOr() (parse.AST, error) {
+	if p.precedence == PrecedenceStandard {
+		return p.parseChain(Or, OpOr, p.parseAnd)
 	}
-	return lhs, nil
+	return p.parseChain(Or, OpOr, p.parseNot)
 }
 
-func (p *Parser) parseOr() (parse.AST, error) {
-	lhs, err := p.parseNot()
+// parseChain parses one or more operands separated by the provided token, producing a left-associative chain of
+// BinExpr nodes. Operands are parsed iteratively, so that long chains do not recurse deeply.
+func (p *parser) parseChain(token Token, op Op, operand func() (parse.AST, error)) (parse.AST, error) {
+	start := p.curr
+	lhs, err := operand()
 	if err != nil {
 		return nil, err
 	}
-	if p.match(Or) {
-		rhs, err := p.parseOr()
+	for p.match(token) {
+		rhs, err := operand()
 		if err != nil {
 			return nil, err
 		}
-		return &BinExpr{LHS: lhs, RHS: rhs, Op: OpOr}, nil
+		lhs = &BinExpr{LHS: lhs, RHS: rhs, Op: op, Src: p.span(start)}
 	}
 	return lhs, nil
 }
 
-func (p *Parser) parseNot() (parse.AST, error) {
+func (p *parser) parseNot() (parse.AST, error) {
+	start := p.curr
 	if p.match(Not) {
 		rest, err := p.parseParens()
 		if err != nil {
 			return nil, err
 		}
-		return &UnaryExpr{Expr: rest, Op: OpNot}, nil
+		return &UnaryExpr{Expr: rest, Op: OpNot, Src: p.span(start)}, nil
 	}
 	return p.parseParens()
 }
 
 // parseParens parses parentheses, which must be correctly matched
-func (p *Parser) parseParens() (parse.AST, error) {
-	if p.match(OpenParen) {
-		ast, err := p.parseExpr()
-		if err != nil {
-			return nil, err
-		}
-		if !p.match(CloseParen) {
-			return nil, fmt.Errorf("%w: expected '%s'", parse.ErrParse, p.config[CloseParen])
+func (p *parser) parseParens() (parse.AST, error) {
+	// a group followed by more of the same term, as in (a + b) * c, is left for later consumption, unless it contains
+	// operators of this grammar, as in (a OR b) c, which must be parsed as a sub-expression
+	end := p.groupEnd(p.curr)
+	if end < 0 || end+1 >= len(p.tokens) || p.isKeyword(p.tokens[end+1]) && p.termOperator(end+1) == nil ||
+		p.grouped[p.curr] {
+		if p.match(OpenParen) {
+			ast, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.match(CloseParen) {
+				return nil, p.errorf("expected '%s'", p.config[CloseParen])
+			}
+			return ast, nil
 		}
-		return ast, nil
 	}
 	return p.parseRest()
 }
 
-func (p *Parser) parseRest() (parse.AST, error) {
-	var result []string
-	for p.curr < len(p.tokens) && !p.isKeyword(p.peek()) {
+func (p *parser) parseRest() (parse.AST, error) {
+	var result []parse.Token
+	var pending []string // pending holds the words which complete a term operator, as in the AND of BETWEEN ... AND.
+	for p.curr < len(p.tokens) {
+		if end := p.groupEnd(p.curr); end >= 0 && end < len(p.tokens) {
+			// keep balanced groups which form part of a term, such as f(x)
+			result = append(result, p.tokens[p.curr:end+1]...)
+			p.curr = end + 1
+			continue
+		}
+		if pending != nil && p.matchWords(p.curr, pending) {
+			result = append(result, p.tokens[p.curr:p.curr+len(pending)]...)
+			p.curr += len(pending)
+			pending = nil
+			continue
+		}
+		if result != nil {
+			if op := p.termOperator(p.curr); op != nil {
+				// an operator of another grammar within a term, as in x NOT IN (a, b), is left for later consumption
+				result = append(result, p.tokens[p.curr:p.curr+len(op.head)]...)
+				p.curr += len(op.head)
+				pending = op.tail
+				continue
+			}
+		}
+		if p.isKeyword(p.peek()) {
+			break
+		}
 		result = append(result, p.peek())
 		p.curr++
 	}
 	if result == nil {
-		return nil, fmt.Errorf("%w: unexpected end of expression", parse.ErrParse)
+		if p.curr < len(p.tokens) {
+			return nil, p.errorf("unexpected '%s'", p.peek().Text)
+		}
+		return nil, p.errorf("unexpected end of expression")
 	}
 	return parse.Unparsed{Contents: result}, nil
 }
+
+// Print implements parse.Printer, rendering BinExpr, ListExpr and UnaryExpr nodes using the syntax and precedence
+// configured for this Parser. Parentheses are only added where they are required, so that parsing the resulting text
+// using this Parser produces the original AST.
+func (p *Parser) Print(ast parse.AST, f *parse.Formatter) (string, int, error) {
+	switch ast := ast.(type) {
+	case *BinExpr:
+		return p.printChain(ast, f)
+	case *ListExpr:
+		if len(ast.Terms) == 0 {
+			return "", 0, fmt.Errorf("cannot print %v with no terms", ast.Op)
+		}
+		op, prec, err := p.printOp(ast.Op)
+		if err != nil {
+			return "", 0, err
+		}
+		if len(ast.Terms) == 1 {
+			return f.Print(ast.Terms[0])
+		}
+		var sb strings.Builder
+		for i, term := range ast.Terms {
+			min := prec
+			if i > 0 {
+				sb.WriteString(" " + op + " ")
+				min = prec + 1
+			}
+			text, err := f.Operand(term, min, p.config[OpenParen], p.config[CloseParen])
+			if err != nil {
+				return "", 0, err
+			}
+			sb.WriteString(text)
+		}
+		return sb.String(), prec, nil
+	case *UnaryExpr:
+		if ast.Op != OpNot {
+			return "", 0, fmt.Errorf("cannot print unknown unary operator: %v", ast.Op)
+		}
+		op, prec := p.config[Not], parse.PrecBools+30
+		text, err := f.Operand(ast.Expr, prec+1, p.config[OpenParen], p.config[CloseParen])
+		if err != nil {
+			return "", 0, err
+		}
+		// separate alphabetic operators from their operand
+		if r, _ := utf8.DecodeLastRuneInString(op); unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			op += " "
+		}
+		return op + text, prec, nil
+	default:
+		return "", 0, fmt.Errorf("%w: %T", parse.ErrUnknownAST, ast)
+	}
+}
+
+// printChain prints a chain of BinExpr nodes along the left-hand side iteratively, since it may be very long.
+func (p *Parser) printChain(b *BinExpr, f *parse.Formatter) (string, int, error) {
+	// parenthesizing the left-hand side of a BinExpr adds an open parenthesis to the start of the text, so all of them
+	// must be written first, while the operators are printed on a first pass along the chain.
+	var sb strings.Builder
+	var lhs string
+	var lhsPrec, prec int
+	var ops []string
+	var precs []int
+	err := WalkChain(b, func(b *BinExpr, deepest bool) error {
+		if deepest {
+			var err error
+			if lhs, lhsPrec, err = f.Print(b.LHS); err != nil {
+				return err
+			}
+			prec = lhsPrec
+		}
+		op, opPrec, err := p.printOp(b.Op)
+		if err != nil {
+			return err
+		}
+		if prec < opPrec {
+			sb.WriteString(p.config[OpenParen])
+		}
+		prec = opPrec
+		ops, precs = append(ops, op), append(precs, opPrec)
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	sb.WriteString(lhs)
+	prec = lhsPrec
+	i := 0
+	err = WalkChain(b, func(b *BinExpr, _ bool) error {
+		if prec < precs[i] {
+			sb.WriteString(p.config[CloseParen])
+		}
+		prec = precs[i]
+		rhs, err := f.Operand(b.RHS, precs[i]+1, p.config[OpenParen], p.config[CloseParen])
+		if err != nil {
+			return err
+		}
+		sb.WriteString(" " + ops[i] + " " + rhs)
+		i++
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return sb.String(), prec, nil
+}
+
+// printOp returns the configured token and the precedence of the provided binary operator.
+func (p *Parser) printOp(op Op) (string, int, error) {
+	switch op {
+	case OpAnd:
+		if p.precedence == PrecedenceStandard {
+			return p.config[And], parse.PrecBools + 20, nil
+		}
+		return p.config[And], parse.PrecBools + 10, nil
+	case OpOr:
+		if p.precedence == PrecedenceStandard {
+			return p.config[Or], parse.PrecBools + 10, nil
+		}
+		return p.config[Or], parse.PrecBools + 20, nil
+	}
+	return "", 0, fmt.Errorf("cannot print unknown binary operator: %v", op)
+}
+
+// defaultParser is used to implement the String method of each node.
+var defaultParser, _ = NewParser()
+
+// format returns the text of the provided AST in the default syntax, or a description of the error encountered.
+func format(ast parse.AST) string {
+	text, err := parse.Format(ast, defaultParser)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return text
+}
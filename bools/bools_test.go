@@ -3,9 +3,11 @@ package bools
 import (
 	"fmt"
 	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/asttest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -47,6 +49,180 @@ func TestParser_Eval(t *testing.T) {
 	assert.ErrorIs(t, err, parse.ErrEval)
 }
 
+func TestEval_ShortCircuit(t *testing.T) {
+	p, err := NewParser(WithPrecedence(PrecedenceStandard))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input     string
+		output    bool
+		evaluated []string
+	}{
+		{"f AND missing", false, []string{"f"}},
+		{"t OR missing", true, []string{"t"}},
+		{"f AND missing OR t", true, []string{"f", "t"}},
+		{"t AND (f OR t) AND NOT f", true, []string{"t", "f", "t", "f"}},
+		{"(t OR missing) AND (f AND missing)", false, []string{"t", "f"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+
+			for _, expr := range []parse.AST{ast, Flatten(ast)} {
+				var evaluated []string
+				interpreter := func(ast parse.AST) (bool, error) {
+					evaluated = append(evaluated, ast.(parse.Unparsed).String())
+					return VarInterpreter(map[string]bool{"t": true, "f": false})(ast)
+				}
+				result, err := Eval(expr, interpreter)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.output, result)
+				assert.Equal(t, tt.evaluated, evaluated)
+
+				_, err = Eval(expr, interpreter, WithShortCircuit(false))
+				if strings.Contains(tt.input, "missing") {
+					assert.ErrorIs(t, err, parse.ErrEval)
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+		})
+	}
+
+	ast, err := p.ParseStr("missing AND f")
+	require.NoError(t, err)
+	_, err = Eval(ast, VarInterpreter(map[string]bool{"f": false}))
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		input  parse.AST
+		output parse.AST
+	}{
+		{
+			and(and(un("a"), un("b")), un("c")),
+			list(OpAnd, un("a"), un("b"), un("c")),
+		},
+		{
+			and(and(un("a"), un("b")), and(un("c"), un("d"))),
+			list(OpAnd, un("a"), un("b"), un("c"), un("d")),
+		},
+		{
+			and(or(and(un("a"), un("b")), un("c")), un("d")),
+			list(OpAnd, list(OpOr, list(OpAnd, un("a"), un("b")), un("c")), un("d")),
+		},
+		{
+			or(not(and(un("a"), and(un("b"), un("c")))), list(OpOr, un("d"), un("e"))),
+			list(OpOr, not(list(OpAnd, un("a"), un("b"), un("c"))), un("d"), un("e")),
+		},
+		{
+			un("a"),
+			un("a"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.input), func(t *testing.T) {
+			assert.EqualValues(t, tt.output, Flatten(tt.input))
+		})
+	}
+}
+
+func TestBinarize(t *testing.T) {
+	tests := []struct {
+		input  parse.AST
+		output parse.AST
+	}{
+		{
+			list(OpAnd, un("a"), un("b"), un("c")),
+			and(and(un("a"), un("b")), un("c")),
+		},
+		{
+			list(OpOr, not(list(OpAnd, un("a"), un("b"))), or(un("c"), list(OpAnd, un("d"), un("e")))),
+			or(not(and(un("a"), un("b"))), or(un("c"), and(un("d"), un("e")))),
+		},
+		{
+			list(OpOr, un("a")),
+			un("a"),
+		},
+		{
+			list(OpOr),
+			list(OpOr),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.input), func(t *testing.T) {
+			assert.EqualValues(t, tt.output, Binarize(tt.input))
+		})
+	}
+
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.ParseStr("a AND b AND (c OR d OR e) AND NOT f")
+	require.NoError(t, err)
+	assert.EqualValues(t, asttest.StripSpans(ast), asttest.StripSpans(Binarize(Flatten(ast))))
+	assert.Equal(t, parse.SpanOf(ast), parse.SpanOf(Flatten(ast)))
+	assert.Equal(t, parse.SpanOf(ast), parse.SpanOf(Binarize(Flatten(ast))))
+}
+
+func TestWithChildren(t *testing.T) {
+	tests := []struct {
+		input    parse.AST
+		children []parse.AST
+		output   parse.AST
+	}{
+		{
+			and(un("a"), un("b")),
+			[]parse.AST{un("c"), un("d")},
+			and(un("c"), un("d")),
+		},
+		{
+			not(un("a")),
+			[]parse.AST{un("b")},
+			not(un("b")),
+		},
+		{
+			list(OpOr, un("a"), un("b"), un("c")),
+			[]parse.AST{un("d"), un("e"), un("f")},
+			list(OpOr, un("d"), un("e"), un("f")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.input), func(t *testing.T) {
+			branch, ok := tt.input.(parse.Branch)
+			require.True(t, ok)
+			assert.Len(t, branch.Children(), len(tt.children))
+			assert.EqualValues(t, tt.output, branch.WithChildren(tt.children))
+			assert.Panics(t, func() { branch.WithChildren(nil) })
+		})
+	}
+}
+
+func TestEval_ListExpr(t *testing.T) {
+	vars := map[string]bool{"t": true, "f": false}
+	tests := []struct {
+		input  parse.AST
+		output bool
+	}{
+		{list(OpAnd, un("t"), un("t"), un("t")), true},
+		{list(OpAnd, un("t"), un("f"), un("t")), false},
+		{list(OpOr, un("f"), un("f"), un("t")), true},
+		{list(OpOr, un("f"), not(un("t"))), false},
+		{list(OpAnd), true},
+		{list(OpOr), false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.input), func(t *testing.T) {
+			result, err := Eval(tt.input, VarInterpreter(vars))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.output, result)
+		})
+	}
+	_, err := Eval(list(OpNot, un("t")), VarInterpreter(vars))
+	assert.Error(t, err)
+}
+
 func TestParser_tokenize(t *testing.T) {
 	tests := []struct {
 		input  string
@@ -56,7 +232,7 @@ func TestParser_tokenize(t *testing.T) {
 			"", nil,
 		},
 		{
-			"xyzNOT OR abc", []string{"xyz", "NOT", "OR", "abc"},
+			"xyzNOT OR abc", []string{"xyzNOT", "OR", "abc"},
 		},
 		{
 			"x AND y OR z", []string{"x", "AND", "y", "OR", "z"},
@@ -73,13 +249,21 @@ func TestParser_tokenize(t *testing.T) {
 		{
 			"not note and andes", []string{"not", "note", "and", "andes"},
 		},
+		{
+			`name == "AND OR" AND msg == 'a (b)'`, []string{"name", "==", `"AND OR"`, "AND", "msg", "==", "'a (b)'"},
+		},
+		{
+			`x == "say \"hi\"" OR y`, []string{"x", "==", `"say \"hi\""`, "OR", "y"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
 			p, err := NewParser()
 			require.NoError(t, err)
-			assert.EqualValues(t, tt.output, p.tokenize(tt.input))
+			tokens, err := p.tokenize(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, texts(tokens))
 		})
 	}
 }
@@ -107,11 +291,11 @@ func TestParser_Parse(t *testing.T) {
 		},
 		{
 			"hello > 5 AND goodbye < 14 AND isAnything == 45",
-			and(un("hello", ">", "5"), and(un("goodbye", "<", "14"), un("isAnything", "==", "45"))),
+			and(and(un("hello", ">", "5"), un("goodbye", "<", "14")), un("isAnything", "==", "45")),
 		},
 		{
 			"a AND b AND c AND d OR x OR y OR z",
-			and(un("a"), and(un("b"), and(un("c"), or(un("d"), or(un("x"), or(un("y"), un("z"))))))),
+			and(and(and(un("a"), un("b")), un("c")), or(or(or(un("d"), un("x")), un("y")), un("z"))),
 		},
 		{
 			"x OR y AND z OR w",
@@ -123,12 +307,36 @@ func TestParser_Parse(t *testing.T) {
 		},
 		{
 			"x AND y OR z AND w",
-			and(un("x"), and(or(un("y"), un("z")), un("w"))),
+			and(and(un("x"), or(un("y"), un("z"))), un("w")),
 		},
 		{
 			"xyz == 5",
 			un("xyz", "==", "5"),
 		},
+		{
+			`name == "AND OR" AND NOT msg == 'a (b)'`,
+			and(un("name", "==", `"AND OR"`), not(un("msg", "==", "'a (b)'"))),
+		},
+		{
+			"(a + b) * 2 > 3 AND NOT (x) == (y)",
+			and(un("(", "a", "+", "b", ")", "*", "2", ">", "3"), not(un("(", "x", ")", "==", "(", "y", ")"))),
+		},
+		{
+			"len(x AND y) OR (z)",
+			or(un("len", "(", "x", "AND", "y", ")"), un("z")),
+		},
+		{
+			"x == `NOT` OR (y)",
+			or(un("x", "==", "`NOT`"), un("y")),
+		},
+		{
+			"x BETWEEN 1 AND 10 AND y NOT BETWEEN (a) AND b AND c",
+			and(and(un("x", "BETWEEN", "1", "AND", "10"), un("y", "NOT", "BETWEEN", "(", "a", ")", "AND", "b")), un("c")),
+		},
+		{
+			"x NOT IN (a, b) OR y IS NOT NULL",
+			or(un("x", "NOT", "IN", "(", "a,", "b", ")"), un("y", "IS", "NOT", "NULL")),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
@@ -136,11 +344,263 @@ func TestParser_Parse(t *testing.T) {
 			require.NoError(t, err)
 			ast, err := p.ParseStr(tt.input)
 			require.NoError(t, err)
-			assert.EqualValues(t, tt.output, ast)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+}
+
+func TestParser_Span(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.ParseStr("abc AND\n  NOT (def OR xyz)")
+	require.NoError(t, err)
+
+	offsets := func(ast parse.AST) [2]int {
+		span := parse.SpanOf(ast)
+		return [2]int{span.Start.Offset, span.End.Offset}
+	}
+
+	root := ast.(*BinExpr)
+	assert.Equal(t, [2]int{0, 26}, offsets(root))
+	assert.Equal(t, [2]int{0, 3}, offsets(root.LHS))
+	assert.Equal(t, [2]int{10, 26}, offsets(root.RHS))
+	inner := root.RHS.(*UnaryExpr).Expr
+	assert.Equal(t, [2]int{15, 25}, offsets(inner))
+	assert.Equal(t, [2]int{22, 25}, offsets(inner.(*BinExpr).RHS))
+	assert.Equal(t, parse.Pos{Offset: 10, Rune: 10, Line: 2, Col: 3}, parse.SpanOf(root.RHS).Start)
+}
+
+func TestParser_Print(t *testing.T) {
+	legacy, err := NewParser()
+	require.NoError(t, err)
+	standard, err := NewParser(WithPrecedence(PrecedenceStandard))
+	require.NoError(t, err)
+	symbolic, err := NewParser(WithTokens(map[Token]string{
+		And:        "&&",
+		Or:         "||",
+		Not:        "!",
+		OpenParen:  "[",
+		CloseParen: "]",
+	}), WithPrecedence(PrecedenceStandard))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input    parse.AST
+		legacy   string
+		standard string
+		symbolic string
+	}{
+		{
+			and(or(un("a"), un("b")), un("c")),
+			"a OR b AND c",
+			"(a OR b) AND c",
+			"[a || b] && c",
+		},
+		{
+			or(and(un("a"), un("b")), un("c")),
+			"(a AND b) OR c",
+			"a AND b OR c",
+			"a && b || c",
+		},
+		{
+			and(un("a"), and(un("b"), un("c"))),
+			"a AND (b AND c)",
+			"a AND (b AND c)",
+			"a && [b && c]",
+		},
+		{
+			and(and(or(un("a"), un("b")), un("c")), or(un("d"), un("e"))),
+			"a OR b AND c AND d OR e",
+			"(a OR b) AND c AND (d OR e)",
+			"[a || b] && c && [d || e]",
+		},
+		{
+			not(not(or(un("a", "==", "1"), un("b")))),
+			"NOT (NOT (a == 1 OR b))",
+			"NOT (NOT (a == 1 OR b))",
+			"![![a == 1 || b]]",
+		},
+		{
+			list(OpAnd, un("a"), or(un("b"), un("c")), list(OpAnd, un("d"), un("e"))),
+			"a AND b OR c AND (d AND e)",
+			"a AND (b OR c) AND (d AND e)",
+			"a && [b || c] && [d && e]",
+		},
+		{
+			list(OpOr, not(un("a"))),
+			"NOT a",
+			"NOT a",
+			"!a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.legacy, func(t *testing.T) {
+			for _, c := range []struct {
+				p        *Parser
+				expected string
+			}{{legacy, tt.legacy}, {standard, tt.standard}, {symbolic, tt.symbolic}} {
+				text, err := parse.Format(tt.input, c.p)
+				require.NoError(t, err)
+				assert.Equal(t, c.expected, text)
+
+				ast, err := c.p.ParseStr(text)
+				require.NoError(t, err)
+				assert.EqualValues(t, asttest.StripSpans(Binarize(tt.input)), asttest.StripSpans(Binarize(ast)))
+			}
+		})
+	}
+
+	assert.Equal(t, "a OR b AND c", and(or(un("a"), un("b")), un("c")).(fmt.Stringer).String())
+
+	_, err = parse.Format(list(OpAnd), legacy)
+	assert.Error(t, err)
+	_, err = parse.Format(not(&BinExpr{LHS: un("a"), RHS: un("b"), Op: OpNot}), legacy)
+	assert.Error(t, err)
+}
+
+func TestParser_LongChain(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	const n = 100000
+	clauses := make([]string, n)
+	for i := range clauses {
+		clauses[i] = fmt.Sprintf("c%d", i)
+	}
+	ast, err := p.ParseStr(strings.Join(clauses, " AND "))
+	require.NoError(t, err)
+
+	// the tree is left-associative, so every right-hand side is a single clause
+	depth := 0
+	for node := ast; ; depth++ {
+		bin, ok := node.(*BinExpr)
+		if !ok {
+			break
+		}
+		assert.IsType(t, parse.Unparsed{}, bin.RHS)
+		node = bin.LHS
+	}
+	assert.Equal(t, n-1, depth)
+
+	require.NoError(t, ast.Parse(p))
+
+	text, err := parse.Format(ast, p)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(clauses, " AND "), text)
+
+	vars := make(map[string]bool, n)
+	for i := 0; i < n; i++ {
+		vars[fmt.Sprintf("c%d", i)] = true
+	}
+	result, err := Eval(ast, VarInterpreter(vars))
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+
+func TestParser_DeepGroups(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	// matching parentheses are found once per parse, so deep nesting takes linear time
+	const n = 10000
+	ast, err := p.ParseStr(strings.Repeat("(", n) + "a OR b" + strings.Repeat(")", n) + " AND c")
+	require.NoError(t, err)
+	assert.EqualValues(t, and(or(un("a"), un("b")), un("c")), asttest.StripSpans(ast))
+
+	ast, err = p.ParseStr("f" + strings.Repeat("(", n) + "x" + strings.Repeat(")", n) + " OR y")
+	require.NoError(t, err)
+	want := append([]string{"f"}, strings.Split(strings.Repeat("(", n)+"x"+strings.Repeat(")", n), "")...)
+	assert.EqualValues(t, or(un(want...), un("y")), asttest.StripSpans(ast))
+}
+
+func BenchmarkParser_ParseStr(b *testing.B) {
+	p, err := NewParser()
+	require.NoError(b, err)
+	for _, n := range []int{100, 1000, 10000, 100000} {
+		expr := chain(n)
+		b.Run(fmt.Sprintf("clauses=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := p.ParseStr(expr); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	p, err := NewParser()
+	require.NoError(b, err)
+	for _, n := range []int{100, 1000, 10000, 100000} {
+		ast, err := p.ParseStr(chain(n))
+		require.NoError(b, err)
+		vars := make(map[string]bool, n)
+		for i := 0; i < n; i++ {
+			vars[fmt.Sprintf("c%d", i)] = i%2 == 0
+		}
+		interpreter := VarInterpreter(vars)
+		b.Run(fmt.Sprintf("clauses=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Eval(ast, interpreter); err != nil {
+					b.Fatal(err)
+				}
+			}
 		})
 	}
 }
 
+// chain returns an expression containing n clauses, joined by alternating runs of AND and OR.
+func chain(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if (i/10)%2 == 0 {
+				sb.WriteString(" AND ")
+			} else {
+				sb.WriteString(" OR ")
+			}
+		}
+		fmt.Fprintf(&sb, "c%d", i)
+	}
+	return sb.String()
+}
+
+func TestParser_Concurrent(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST // output is nil if an error is expected
+	}{
+		{"abc AND def OR xyz", and(un("abc"), or(un("def"), un("xyz")))},
+		{"abc AND NOT(def OR xyz)", and(un("abc"), not(or(un("def"), un("xyz"))))},
+		{`x == "a AND b" OR y`, or(un("x", "==", `"a AND b"`), un("y"))},
+		{"NOT (a AND b", nil},
+		{"AND 7", nil},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tt := tests[(g+i)%len(tests)]
+				ast, err := p.ParseStr(tt.input)
+				if tt.output == nil {
+					assert.ErrorIs(t, err, parse.ErrParse, tt.input)
+					continue
+				}
+				if assert.NoError(t, err, tt.input) {
+					assert.EqualValues(t, tt.output, asttest.StripSpans(ast), tt.input)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
 func TestParser_ParseError(t *testing.T) {
 	tests := []string{
 		"abc AND",
@@ -150,7 +610,9 @@ func TestParser_ParseError(t *testing.T) {
 		"((((((x > 5))))",
 		"()",
 		"AND 7",
-		"xyzNOT OR abc",
+		"(a OR b) c",
+		"(NOT a) * 2 AND b",
+		`x == "unterminated AND y`,
 	}
 	for _, tt := range tests {
 		t.Run(tt, func(t *testing.T) {
@@ -162,6 +624,36 @@ func TestParser_ParseError(t *testing.T) {
 	}
 }
 
+func TestParser_SyntaxError(t *testing.T) {
+	tests := []struct {
+		input    string
+		index    int
+		found    string
+		expected []string
+		caret    string
+	}{
+		{"abc AND", 2, "", []string{"NOT", "("}, "abc AND\n       ^"},
+		{"NOT (a AND b", 5, "", []string{"OR", "AND", ")"}, "NOT (a AND b\n            ^"},
+		{"x AND y)", 3, ")", []string{"OR", "AND"}, "x AND y)\n       ^"},
+		{"AND 7", 0, "AND", []string{"NOT", "("}, "AND 7\n^^^"},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := p.ParseStr(tt.input)
+			var syntaxErr *parse.SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.ErrorIs(t, err, parse.ErrParse)
+			assert.Equal(t, tt.index, syntaxErr.Index)
+			assert.Equal(t, tt.found, syntaxErr.Found)
+			assert.Equal(t, tt.expected, syntaxErr.Expected)
+			assert.Equal(t, tt.input, syntaxErr.Source)
+			assert.Equal(t, tt.caret, syntaxErr.Caret())
+		})
+	}
+}
+
 func TestWithTokens(t *testing.T) {
 	p, err := NewParser(WithTokens(map[Token]string{
 		And:        "&&",
@@ -189,12 +681,13 @@ func TestWithTokens(t *testing.T) {
 		t.Run(tt.input, func(t *testing.T) {
 			ast, err := p.ParseStr(tt.input)
 			assert.NoError(t, err)
-			assert.EqualValues(t, tt.output, ast)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
 		})
 	}
 
 	testWithErrors := []map[Token]string{
 		{And: "&&", Or: "&&", Not: "!", OpenParen: "[", CloseParen: "]"},
+		{And: "&&", Or: "||", Not: "'", OpenParen: "(", CloseParen: ")"},
 		{And: "&&", Or: "||", Not: "!", OpenParen: "::", CloseParen: "::"},
 		{And: "&&", Or: "||", Not: "!", OpenParen: "_", CloseParen: "_"},
 	}
@@ -206,6 +699,200 @@ func TestWithTokens(t *testing.T) {
 	}
 }
 
+func TestWithPrecedence(t *testing.T) {
+	p, err := NewParser(WithPrecedence(PrecedenceStandard))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{
+			"a OR b AND c",
+			or(un("a"), and(un("b"), un("c"))),
+		},
+		{
+			"a AND b OR c",
+			or(and(un("a"), un("b")), un("c")),
+		},
+		{
+			"a AND b OR c AND d",
+			or(and(un("a"), un("b")), and(un("c"), un("d"))),
+		},
+		{
+			"NOT a AND b OR NOT c",
+			or(and(not(un("a")), un("b")), not(un("c"))),
+		},
+		{
+			"(a OR b) AND c",
+			and(or(un("a"), un("b")), un("c")),
+		},
+		{
+			"a OR b OR c AND d AND e",
+			or(or(un("a"), un("b")), and(and(un("c"), un("d")), un("e"))),
+		},
+		{
+			"x OR y AND z OR w",
+			or(or(un("x"), and(un("y"), un("z"))), un("w")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+
+	legacy, err := NewParser(WithPrecedence(PrecedenceLegacy))
+	require.NoError(t, err)
+	ast, err := legacy.ParseStr("a OR b AND c")
+	require.NoError(t, err)
+	assert.EqualValues(t, and(or(un("a"), un("b")), un("c")), asttest.StripSpans(ast))
+
+	_, err = NewParser(WithPrecedence(7))
+	assert.ErrorIs(t, err, parse.ErrConfig)
+}
+
+func TestWithWordBoundaries(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{
+			"ORDER > 3 AND ANDROID OR NOTE",
+			and(un("ORDER", ">", "3"), or(un("ANDROID"), un("NOTE"))),
+		},
+		{
+			"COLOR AND NOT(x.ORDER)",
+			and(un("COLOR"), not(un("x.ORDER"))),
+		},
+		{
+			"xyzNOT OR abc",
+			or(un("xyzNOT"), un("abc")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+
+	p, err = NewParser(WithWordBoundaries(true), WithTokens(map[Token]string{
+		And:        "&&",
+		Or:         "||",
+		Not:        "!",
+		OpenParen:  "(",
+		CloseParen: ")",
+	}))
+	require.NoError(t, err)
+	ast, err := p.ParseStr("x==3&&!y||z")
+	require.NoError(t, err)
+	assert.EqualValues(t, and(un("x==3"), or(not(un("y")), un("z"))), asttest.StripSpans(ast))
+
+	p, err = NewParser(WithWordBoundaries(false))
+	require.NoError(t, err)
+	ast, err = p.ParseStr("x ORDER AND NOTE")
+	require.NoError(t, err)
+	assert.EqualValues(t, and(or(un("x"), un("DER")), not(un("E"))), asttest.StripSpans(ast))
+	for _, input := range []string{"ORDER", "xyzNOT OR abc"} {
+		_, err = p.ParseStr(input)
+		assert.ErrorIs(t, err, parse.ErrParse, input)
+	}
+}
+
+func TestWithQuotes(t *testing.T) {
+	p, err := NewParser(WithQuotes())
+	require.NoError(t, err)
+	ast, err := p.ParseStr(`x == "a AND b"`)
+	require.NoError(t, err)
+	assert.EqualValues(t, and(un("x", "==", `"a`), un(`b"`)), asttest.StripSpans(ast))
+
+	p, err = NewParser(WithQuotes('|'))
+	require.NoError(t, err)
+	ast, err = p.ParseStr(`x == |a AND b| AND it's`)
+	require.NoError(t, err)
+	literal := parse.Token{Text: "|a AND b|", Quote: '|'}
+	expected := and(parse.Unparsed{Contents: append(parse.NewTokens("x", "=="), literal)}, un("it's"))
+	assert.EqualValues(t, expected, asttest.StripSpans(ast))
+
+	_, err = NewParser(WithQuotes('('))
+	assert.ErrorIs(t, err, parse.ErrConfig)
+	_, err = NewParser(WithQuotes(' '))
+	assert.ErrorIs(t, err, parse.ErrConfig)
+}
+
+func TestWithTermOperators(t *testing.T) {
+	p, err := NewParser(
+		WithTermOperators("NOT IN", "IS NOT  NULL", "BETWEEN ... AND", "NOT BETWEEN ... AND"), WithCaseSensitive(false),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{
+			"x NOT IN (a) AND NOT y",
+			and(un("x", "NOT", "IN", "(", "a", ")"), not(un("y"))),
+		},
+		{
+			"x is not null OR NOT IN",
+			or(un("x", "is", "not", "null"), not(un("IN"))),
+		},
+		{
+			"(x) NOT IN y",
+			un("(", "x", ")", "NOT", "IN", "y"),
+		},
+		{
+			"x IS OR NOT NULL",
+			or(un("x", "IS"), not(un("NULL"))),
+		},
+		{
+			"x 'NOT' IN y",
+			un("x", "'NOT'", "IN", "y"),
+		},
+		{
+			"x NOT BETWEEN 1 AND 10 OR y",
+			or(un("x", "NOT", "BETWEEN", "1", "AND", "10"), un("y")),
+		},
+		{
+			"x BETWEEN 1 AND 10 AND y between (a) and b AND c",
+			and(and(un("x", "BETWEEN", "1", "AND", "10"), un("y", "between", "(", "a", ")", "and", "b")), un("c")),
+		},
+		{
+			"BETWEEN AND x",
+			and(un("BETWEEN"), un("x")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+
+	// calling WithTermOperators with no arguments disables the default operators
+	p, err = NewParser(WithTermOperators())
+	require.NoError(t, err)
+	_, err = p.ParseStr("x NOT IN y")
+	assert.ErrorIs(t, err, parse.ErrParse)
+	ast, err := p.ParseStr("x BETWEEN 1 AND 10 OR y")
+	require.NoError(t, err)
+	assert.EqualValues(t, and(un("x", "BETWEEN", "1"), or(un("10"), un("y"))), asttest.StripSpans(ast))
+
+	for _, op := range []string{" ", "... AND", "BETWEEN ...", "BETWEEN ... AND ... AND"} {
+		_, err = NewParser(WithTermOperators("NOT IN", op))
+		assert.ErrorIs(t, err, parse.ErrConfig, op)
+	}
+}
+
 func TestWithCaseSensitive(t *testing.T) {
 	p, err := NewParser(WithCaseSensitive(false))
 	require.NoError(t, err)
@@ -231,7 +918,7 @@ func TestWithCaseSensitive(t *testing.T) {
 		t.Run(tt.input, func(t *testing.T) {
 			ast, err := p.ParseStr(tt.input)
 			assert.NoError(t, err)
-			assert.EqualValues(t, tt.output, ast)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
 		})
 	}
 }
@@ -244,11 +931,24 @@ func and(lhs parse.AST, rhs parse.AST) parse.AST {
 	return &BinExpr{LHS: lhs, RHS: rhs, Op: OpAnd}
 }
 
+func list(op Op, terms ...parse.AST) parse.AST {
+	return &ListExpr{Op: op, Terms: terms}
+}
+
 func not(inside parse.AST) parse.AST {
 	return &UnaryExpr{Expr: inside, Op: OpNot}
 }
 
 // un stands for unparsed and returns a parse.Unparsed
 func un(tokens ...string) parse.AST {
-	return parse.Unparsed{Contents: tokens}
+	return parse.Unparsed{Contents: parse.NewTokens(tokens...)}
+}
+
+// texts returns the text of each of the provided tokens.
+func texts(tokens []parse.Token) []string {
+	var result []string
+	for _, token := range tokens {
+		result = append(result, token.Text)
+	}
+	return result
 }
@@ -0,0 +1,401 @@
+// Package coalesce implements a recursive-descent parser for the null-coalescing operator according to the following
+// grammar.
+//
+//	expr     -> term ( '??' term )*
+//	term     -> '(' expr ')' | unparsed
+//	unparsed -> .*
+//
+// Expressions such as ${task.output.result} ?? 'none' are parsed into CoalesceExpr nodes, whose value is that of the
+// first operand which is neither null nor missing. The syntax used by this parser is configurable at runtime, see
+// NewParser for details.
+//
+// The operator binds more tightly than comparisons and less tightly than arithmetic, so that ${a} ?? 0 + 1 > 2 compares
+// ${a} ?? (0 + 1) with 2. This parser should therefore run after the comp package and before the arith package:
+//
+//	ast, err := parse.ParseAll(ast, compParser, coalesceParser, varsParser, literalParser,
+//		arithParser, varsParser, literalParser)
+//
+// It leaves unparsed portions of the expression in parse.Unparsed nodes, for later consumption.
+package coalesce
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"strings"
+	"unicode"
+)
+
+// CoalesceExpr represents a chain of operands separated by the null-coalescing operator, as in a ?? b ?? c.
+type CoalesceExpr struct {
+	Operands []parse.AST // Operands lists the operands of this expression in order; there are always at least two.
+	Src      parse.Span  // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (c *CoalesceExpr) String() string {
+	return format(c)
+}
+
+// Span returns the location of this expression in the source text.
+func (c *CoalesceExpr) Span() parse.Span {
+	return c.Src
+}
+
+// Parse runs the provided parse.Parser on all unparsed nodes in this AST.
+func (c *CoalesceExpr) Parse(p parse.Parser) error {
+	for i := range c.Operands {
+		if err := parseChild(&c.Operands[i], p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Children returns the operands of this expression.
+func (c *CoalesceExpr) Children() []parse.AST {
+	return c.Operands
+}
+
+// WithChildren returns a copy of this expression with the provided operands.
+func (c *CoalesceExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("CoalesceExpr", children, len(c.Operands))
+	return &CoalesceExpr{Operands: append([]parse.AST(nil), children...), Src: c.Src}
+}
+
+// checkChildren panics if the number of children passed to WithChildren is not as expected.
+func checkChildren(node string, children []parse.AST, expected int) {
+	if len(children) != expected {
+		panic(fmt.Sprintf("coalesce: %s.WithChildren called with %d children; expected %d", node, len(children), expected))
+	}
+}
+
+// parseChild replaces the provided child with the result of parsing it if it is parse.Unparsed, and otherwise parses
+// it recursively.
+func parseChild(child *parse.AST, p parse.Parser) error {
+	if unparsed, ok := (*child).(parse.Unparsed); ok {
+		parsed, err := p.Parse(unparsed.Contents)
+		if err != nil {
+			return err
+		}
+		*child = parsed
+		return nil
+	}
+	return (*child).Parse(p)
+}
+
+// Token is a token required by this grammar.
+type Token uint8
+
+const (
+	Coalesce   Token = iota + 1 // Coalesce represents the null-coalescing operator.
+	OpenParen                   // OpenParen represents the start of a sub-expression.
+	CloseParen                  // CloseParen represents the end of a sub-expression.
+)
+
+type ParserOpt func(*Parser)
+
+// Parser parses this grammar. A Parser is safe for concurrent use by multiple goroutines.
+type Parser struct {
+	config         map[Token]string
+	wordBoundaries bool
+	quotes         []rune
+
+	matcher   *parse.KeywordTrie
+	tokenizer parse.Tokenizer
+}
+
+// parser holds the state of a single call to Parser.Parse, so that a Parser can be used concurrently.
+type parser struct {
+	*Parser
+	tokens     []parse.Token
+	curr       int
+	expected   []Token // expected lists the tokens which failed to match at index expectedAt.
+	expectedAt int
+}
+
+// WithTokens configures the syntax used by this parser using the provided token mapping. The provided map must contain
+// distinct entries for each Token provided in this package: Coalesce, OpenParen and CloseParen. Tokens must not contain
+// whitespace.
+func WithTokens(config map[Token]string) ParserOpt {
+	return func(parser *Parser) {
+		parser.config = config
+	}
+}
+
+// WithWordBoundaries sets whether an alphabetic Coalesce token, such as ORELSE, is only recognized at identifier
+// boundaries when tokenizing. Word boundaries are required by default.
+func WithWordBoundaries(wordBoundaries bool) ParserOpt {
+	return func(parser *Parser) {
+		parser.wordBoundaries = wordBoundaries
+	}
+}
+
+// WithQuotes sets the runes which delimit string literals. By default, the runes in parse.DefaultQuotes are used.
+// String literals are always treated as a single token, and are never interpreted as keywords, so that the expression
+// a ?? "??" contains a single operator. Calling WithQuotes with no arguments disables string literals.
+func WithQuotes(quotes ...rune) ParserOpt {
+	return func(parser *Parser) {
+		parser.quotes = quotes
+	}
+}
+
+// NewParser returns a parser configured according to the provided options. If no options are configured, the default
+// parser is returned.
+func NewParser(opts ...ParserOpt) (*Parser, error) {
+	p := &Parser{
+		config: map[Token]string{
+			Coalesce:   "??",
+			OpenParen:  "(",
+			CloseParen: ")",
+		},
+		matcher:        &parse.KeywordTrie{},
+		quotes:         parse.DefaultQuotes,
+		wordBoundaries: true,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Parser) init() error {
+	if len(p.config[OpenParen]) != 1 || len(p.config[CloseParen]) != 1 {
+		return fmt.Errorf("%w: OpenParen and CloseParen must each have length 1", parse.ErrConfig)
+	}
+	if p.config[OpenParen] == p.config[CloseParen] {
+		return fmt.Errorf("%w: OpenParen and CloseParen must each be distinct", parse.ErrConfig)
+	}
+	if str := p.config[Coalesce]; str == "" || strings.IndexFunc(str, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("%w: Coalesce must be non-empty and must not contain whitespace", parse.ErrConfig)
+	}
+	p.tokenizer = parse.Tokenizer{
+		Open:           []rune(p.config[OpenParen])[0],
+		Close:          []rune(p.config[CloseParen])[0],
+		Keywords:       p.matcher,
+		Quotes:         p.quotes,
+		WordBoundaries: p.wordBoundaries,
+	}
+	for _, q := range p.quotes {
+		if unicode.IsSpace(q) || q == p.tokenizer.Open || q == p.tokenizer.Close {
+			return fmt.Errorf("%w: quote '%c' must not be whitespace, OpenParen or CloseParen", parse.ErrConfig, q)
+		}
+		for _, str := range p.config {
+			if strings.ContainsRune(str, q) {
+				return fmt.Errorf("%w: quote '%c' must not appear in any configured token", parse.ErrConfig, q)
+			}
+		}
+	}
+	for _, str := range p.config {
+		p.matcher.Add(str)
+	}
+	if p.matcher.Count() != 3 {
+		return fmt.Errorf("%w: token collision detected; at least two of the provided tokens are identical", parse.ErrConfig)
+	}
+	return nil
+}
+
+// ParseStr tokenizes and parses the provided string. See Parser.Parse for details. Any *parse.SyntaxError returned
+// includes the provided string as its Source.
+func (p *Parser) ParseStr(str string) (parse.AST, error) {
+	tokens, err := p.tokenizer.Tokenize(str)
+	var ast parse.AST
+	if err == nil {
+		ast, err = p.Parse(tokens)
+	}
+	var syntaxErr *parse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Source = str
+	}
+	return ast, err
+}
+
+// Parse parses the provided list of tokens, producing a parse.AST. The provided tokens are first split further at
+// every operator, since tokens produced by other parsers may not separate them from their operands, as in a??b. A
+// *parse.SyntaxError is returned if the provided tokens do not conform to the grammar specified in this package.
+func (p *Parser) Parse(tokens []parse.Token) (parse.AST, error) {
+	tokens, err := p.tokenizer.Retokenize(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return (&parser{Parser: p, tokens: tokens, expectedAt: -1}).parse()
+}
+
+func (p *parser) parse() (parse.AST, error) {
+	ast, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.curr != len(p.tokens) {
+		return nil, p.errorf("expected end of expression; found '%s'", p.peek().Text)
+	}
+	return ast, nil
+}
+
+func (p *Parser) isKeyword(token parse.Token) bool {
+	return token.Quote == 0 && p.matcher.Contains(token.Text)
+}
+
+func (p *parser) match(token Token) bool {
+	if p.is(p.curr, token) {
+		p.curr++
+		return true
+	}
+	p.expect(token)
+	return false
+}
+
+// is reports whether the token at index i is the provided Token.
+func (p *parser) is(i int, token Token) bool {
+	return i < len(p.tokens) && p.tokens[i].Quote == 0 && p.tokens[i].Text == p.config[token]
+}
+
+// groupEnd returns the index of the CloseParen matching the OpenParen at index i, or the number of tokens if it is
+// not closed. If the token at index i is not an OpenParen, -1 is returned.
+func (p *parser) groupEnd(i int) int {
+	if !p.is(i, OpenParen) {
+		return -1
+	}
+	depth := 0
+	for ; i < len(p.tokens); i++ {
+		if p.is(i, OpenParen) {
+			depth++
+		} else if p.is(i, CloseParen) {
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(p.tokens)
+}
+
+// expect records that the provided token was expected at the current position, for use in error messages.
+func (p *parser) expect(token Token) {
+	if p.expectedAt != p.curr {
+		p.expected, p.expectedAt = p.expected[:0], p.curr
+	}
+	for _, t := range p.expected {
+		if t == token {
+			return
+		}
+	}
+	p.expected = append(p.expected, token)
+}
+
+// errorf returns a *parse.SyntaxError located at the current token.
+func (p *parser) errorf(format string, args ...any) error {
+	var expected []string
+	if p.expectedAt == p.curr {
+		for _, token := range p.expected {
+			expected = append(expected, p.config[token])
+		}
+	}
+	return parse.NewSyntaxError(p.tokens, p.curr, expected, format, args...)
+}
+
+func (p *parser) peek() parse.Token {
+	return p.tokens[p.curr]
+}
+
+// span returns the location of the tokens from start up to the current token.
+func (p *parser) span(start int) parse.Span {
+	return parse.Span{Start: p.tokens[start].Src.Start, End: p.tokens[p.curr-1].Src.End}
+}
+
+func (p *parser) parseExpr() (parse.AST, error) {
+	start := p.curr
+	first, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	operands := []parse.AST{first}
+	for p.match(Coalesce) {
+		operand, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &CoalesceExpr{Operands: operands, Src: p.span(start)}, nil
+}
+
+func (p *parser) parseTerm() (parse.AST, error) {
+	// a group followed by more of the same term, as in (a + b) * c, is left for later consumption
+	if end := p.groupEnd(p.curr); end < 0 || end+1 >= len(p.tokens) || p.isKeyword(p.tokens[end+1]) {
+		if p.match(OpenParen) {
+			ast, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.match(CloseParen) {
+				return nil, p.errorf("expected '%s'", p.config[CloseParen])
+			}
+			return ast, nil
+		}
+	}
+	return p.parseRest()
+}
+
+func (p *parser) parseRest() (parse.AST, error) {
+	var result []parse.Token
+	for p.curr < len(p.tokens) {
+		if end := p.groupEnd(p.curr); end >= 0 && end < len(p.tokens) {
+			// keep balanced groups which form part of a term, such as f(x)
+			result = append(result, p.tokens[p.curr:end+1]...)
+			p.curr = end + 1
+			continue
+		}
+		if p.isKeyword(p.peek()) {
+			break
+		}
+		result = append(result, p.peek())
+		p.curr++
+	}
+	if result == nil {
+		if p.curr < len(p.tokens) {
+			return nil, p.errorf("unexpected '%s'", p.peek().Text)
+		}
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return parse.Unparsed{Contents: result}, nil
+}
+
+// Print implements parse.Printer, rendering CoalesceExpr nodes using the syntax configured for this Parser. Operands
+// which are themselves CoalesceExpr nodes are parenthesized, so that parsing the resulting text using this Parser
+// produces the original AST.
+func (p *Parser) Print(ast parse.AST, f *parse.Formatter) (string, int, error) {
+	c, ok := ast.(*CoalesceExpr)
+	if !ok {
+		return "", 0, fmt.Errorf("%w: %T", parse.ErrUnknownAST, ast)
+	}
+	if len(c.Operands) < 2 {
+		return "", 0, fmt.Errorf("cannot print CoalesceExpr with %d operands", len(c.Operands))
+	}
+	operands := make([]string, len(c.Operands))
+	for i, operand := range c.Operands {
+		text, err := f.Operand(operand, parse.PrecCoalesce+1, p.config[OpenParen], p.config[CloseParen])
+		if err != nil {
+			return "", 0, err
+		}
+		operands[i] = text
+	}
+	return strings.Join(operands, " "+p.config[Coalesce]+" "), parse.PrecCoalesce, nil
+}
+
+// defaultParser is used to implement the String method of each node.
+var defaultParser, _ = NewParser()
+
+// format returns the text of the provided AST in the default syntax, or a description of the error encountered.
+func format(ast parse.AST) string {
+	text, err := parse.Format(ast, defaultParser)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return text
+}
@@ -0,0 +1,179 @@
+package coalesce
+
+import (
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/asttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestParser_Parse(t *testing.T) {
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"a ?? b", co(un("a"), un("b"))},
+		{"a??b", co(un("a"), un("b"))},
+		{"${a?.b} ?? 'none'", co(un("${a?.b}"), un("'none'"))},
+		{"a ?? b ?? c", co(un("a"), un("b"), un("c"))},
+		{"(a ?? b) ?? c", co(co(un("a"), un("b")), un("c"))},
+		{"a ?? 0 + 1", co(un("a"), un("0", "+", "1"))},
+		{"(a ?? b) + 1", un("(", "a", "??", "b", ")", "+", "1")},
+		{"f(a, b) ?? (c)", co(un("f", "(", "a,", "b", ")"), un("c"))},
+		{`a ?? "??"`, co(un("a"), un(`"??"`))},
+		{"(a)", un("a")},
+		{"a", un("a")},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+}
+
+func TestParser_Span(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.ParseStr(" a ?? b ?? c ")
+	require.NoError(t, err)
+	assert.Equal(t, 1, ast.(*CoalesceExpr).Span().Start.Offset)
+	assert.Equal(t, 12, ast.(*CoalesceExpr).Span().End.Offset)
+}
+
+func TestParser_ParseError(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"a ??", "unexpected end of expression"},
+		{"?? b", "unexpected '??'"},
+		{"a ?? ?? b", "unexpected '??'"},
+		{"(a ?? b", "expected ')'"},
+		{"(a ?? b))", "expected end of expression; found ')'"},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := p.ParseStr(tt.input)
+			var syntaxErr *parse.SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.Contains(t, err.Error(), tt.msg)
+			assert.Equal(t, tt.input, syntaxErr.Source)
+		})
+	}
+}
+
+func TestParser_Concurrent(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"a ?? b", co(un("a"), un("b"))},
+		{"(a ?? b) ?? c", co(co(un("a"), un("b")), un("c"))},
+		{"x", un("x")},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tt := tests[(g+i)%len(tests)]
+				ast, err := p.ParseStr(tt.input)
+				if assert.NoError(t, err, tt.input) {
+					assert.EqualValues(t, tt.output, asttest.StripSpans(ast), tt.input)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestParser_Print(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	custom, err := NewParser(WithTokens(map[Token]string{Coalesce: "ORELSE", OpenParen: "[", CloseParen: "]"}))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  parse.AST
+		output string
+		custom string
+	}{
+		{co(un("a"), un("b")), "a ?? b", "a ORELSE b"},
+		{co(un("a"), un("b"), un("c")), "a ?? b ?? c", "a ORELSE b ORELSE c"},
+		{co(un("a"), co(un("b"), un("c"))), "a ?? (b ?? c)", "a ORELSE [b ORELSE c]"},
+		{co(un("a", "+", "1"), un("'x'")), "a + 1 ?? 'x'", "a + 1 ORELSE 'x'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			for _, c := range []struct {
+				p        *Parser
+				expected string
+			}{{p, tt.output}, {custom, tt.custom}} {
+				text, err := parse.Format(tt.input, c.p)
+				require.NoError(t, err)
+				assert.Equal(t, c.expected, text)
+
+				ast, err := c.p.ParseStr(text)
+				require.NoError(t, err)
+				assert.EqualValues(t, tt.input, asttest.StripSpans(ast))
+			}
+			assert.Equal(t, tt.output, tt.input.(fmt.Stringer).String())
+		})
+	}
+
+	_, err = parse.Format(co(un("a")), p)
+	assert.Error(t, err)
+}
+
+func TestWithTokens(t *testing.T) {
+	p, err := NewParser(WithTokens(map[Token]string{Coalesce: "ORELSE", OpenParen: "(", CloseParen: ")"}))
+	require.NoError(t, err)
+	ast, err := p.ParseStr("a ORELSE ORELSEWHERE")
+	require.NoError(t, err)
+	assert.EqualValues(t, co(un("a"), un("ORELSEWHERE")), asttest.StripSpans(ast))
+
+	testWithErrors := []map[Token]string{
+		{OpenParen: "(", CloseParen: ")"},
+		{Coalesce: "OR ELSE", OpenParen: "(", CloseParen: ")"},
+		{Coalesce: "(", OpenParen: "(", CloseParen: ")"},
+		{Coalesce: "??", OpenParen: "((", CloseParen: ")"},
+		{Coalesce: "??", OpenParen: "(", CloseParen: "("},
+		{Coalesce: "?'", OpenParen: "(", CloseParen: ")"},
+	}
+	for idx, config := range testWithErrors {
+		t.Run(fmt.Sprintf("error case %d", idx), func(t *testing.T) {
+			_, err := NewParser(WithTokens(config))
+			assert.ErrorIs(t, err, parse.ErrConfig)
+		})
+	}
+}
+
+func TestWithChildren(t *testing.T) {
+	var branch parse.Branch = co(un("a"), un("b"))
+	children := []parse.AST{un("x"), un("y")}
+	assert.Equal(t, children, parse.ChildrenOf(branch.WithChildren(children)))
+	assert.Panics(t, func() { branch.WithChildren(nil) })
+}
+
+func co(operands ...parse.AST) *CoalesceExpr {
+	return &CoalesceExpr{Operands: operands}
+}
+
+// un stands for unparsed and returns a parse.Unparsed
+func un(tokens ...string) parse.AST {
+	return parse.Unparsed{Contents: parse.NewTokens(tokens...)}
+}
@@ -0,0 +1,52 @@
+package coalesce
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+)
+
+// Eval evaluates the provided expression, using the provided Interpreter to find the value of each operand which is
+// not itself a CoalesceExpr. See Interpreter for details.
+func Eval(expr parse.AST, values parse.Interpreter[any]) (any, error) {
+	return Interpreter(values)(expr)
+}
+
+// Interpreter returns a parse.Interpreter which evaluates CoalesceExpr nodes. Operands are evaluated in order, and the
+// value of the first one which is not nil is returned, without evaluating the rest. Operands whose evaluation results
+// in an error matching parse.ErrNotFound, such as a reference to a missing variable, are treated as nil, except for
+// the last operand, whose result is returned as-is.
+//
+// The value of each operand which is not itself a CoalesceExpr is found using the provided Interpreter, as is the
+// value of any other node passed to the returned Interpreter. This allows the returned Interpreter to be used as the
+// values Interpreter passed to comp.Interpreter.
+func Interpreter(values parse.Interpreter[any]) parse.Interpreter[any] {
+	var interpret parse.Interpreter[any]
+	interpret = func(ast parse.AST) (any, error) {
+		if values == nil {
+			return nil, fmt.Errorf("%w: nil Interpreter", parse.ErrEval)
+		}
+		c, ok := ast.(*CoalesceExpr)
+		if !ok {
+			if ast == nil {
+				return nil, fmt.Errorf("%w: nil expression", parse.ErrEval)
+			}
+			return values(ast)
+		}
+		if len(c.Operands) == 0 {
+			return nil, fmt.Errorf("%w: CoalesceExpr has no operands", parse.ErrEval)
+		}
+		last := len(c.Operands) - 1
+		for _, operand := range c.Operands[:last] {
+			val, err := interpret(operand)
+			if err != nil && !errors.Is(err, parse.ErrNotFound) {
+				return nil, err
+			}
+			if err == nil && val != nil {
+				return val, nil
+			}
+		}
+		return interpret(c.Operands[last])
+	}
+	return interpret
+}
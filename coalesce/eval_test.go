@@ -0,0 +1,96 @@
+package coalesce
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// valueInterpreter interprets single-token Unparsed nodes as the named variables, recording the name of each one
+// evaluated.
+func valueInterpreter(vars map[string]any, evaluated *[]string) parse.Interpreter[any] {
+	return func(ast parse.AST) (any, error) {
+		unparsed, ok := ast.(parse.Unparsed)
+		if !ok || len(unparsed.Contents) != 1 {
+			return nil, fmt.Errorf("%w: %v", parse.ErrUnknownAST, ast)
+		}
+		name := unparsed.Contents[0].Text
+		*evaluated = append(*evaluated, name)
+		if name == "fail" {
+			return nil, fmt.Errorf("%w: failed", parse.ErrEval)
+		}
+		val, ok := vars[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", parse.ErrNotFound, name)
+		}
+		return val, nil
+	}
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"null":  nil,
+		"zero":  0,
+		"empty": "",
+		"a":     "a",
+	}
+	tests := []struct {
+		input     string
+		output    any
+		evaluated []string
+	}{
+		{"a ?? missing", "a", []string{"a"}},
+		{"null ?? a", "a", []string{"null", "a"}},
+		{"missing ?? null ?? a", "a", []string{"missing", "null", "a"}},
+		{"zero ?? a", 0, []string{"zero"}},
+		{"empty ?? a", "", []string{"empty"}},
+		{"missing ?? null", nil, []string{"missing", "null"}},
+		{"(missing ?? null) ?? a", "a", []string{"missing", "null", "a"}},
+		{"a", "a", []string{"a"}},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			var evaluated []string
+			result, err := Eval(ast, valueInterpreter(vars, &evaluated))
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, result)
+			assert.Equal(t, tt.evaluated, evaluated)
+		})
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	tests := []struct {
+		input string
+		err   error
+	}{
+		{"null ?? missing", parse.ErrNotFound},
+		{"fail ?? a", parse.ErrEval},
+		{"null ?? fail", parse.ErrEval},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			var evaluated []string
+			_, err = Eval(ast, valueInterpreter(map[string]any{"null": nil, "a": "a"}, &evaluated))
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	_, err = Eval(co(), func(parse.AST) (any, error) { return nil, nil })
+	assert.ErrorIs(t, err, parse.ErrEval)
+	_, err = Eval(un("a"), nil)
+	assert.ErrorIs(t, err, parse.ErrEval)
+	_, err = Eval(nil, func(parse.AST) (any, error) { return nil, errors.New("unreachable") })
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
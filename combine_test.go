@@ -1,12 +1,24 @@
 package parse_test
 
 import (
+	"encoding/json"
 	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/arith"
 	"github.com/orkes-io/go-parse/bools"
+	"github.com/orkes-io/go-parse/coalesce"
 	"github.com/orkes-io/go-parse/comp"
+	"github.com/orkes-io/go-parse/cond"
+	"github.com/orkes-io/go-parse/funcs"
+	"github.com/orkes-io/go-parse/internal/asttest"
+	"github.com/orkes-io/go-parse/literal"
+	"github.com/orkes-io/go-parse/vars"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"math/rand"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestBoolComp(t *testing.T) {
@@ -37,12 +49,788 @@ func TestBoolComp(t *testing.T) {
 			err = ast.Parse(c)
 			assert.NoError(t, err)
 
-			assert.EqualValues(t, tt.output, ast)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
 		})
 	}
 
 }
 
+func TestParseAll(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{
+			"x > 3",
+			gt(un("x"), un("3")),
+		},
+		{
+			"(y == 5)",
+			eq(un("y"), un("5")),
+		},
+		{
+			"x > 3 AND NOT y",
+			and(gt(un("x"), un("3")), not(un("y"))),
+		},
+		{
+			"x",
+			un("x"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens, err := (&parse.Tokenizer{Open: '(', Close: ')', Keywords: &parse.KeywordTrie{}}).Tokenize(tt.input)
+			require.NoError(t, err)
+			ast, err := parse.ParseAll(parse.Unparsed{Contents: tokens}, b, c)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+
+	ast, err := b.ParseStr("NOT (x == 3) OR (NOT (y == 4) AND z)")
+	require.NoError(t, err)
+	require.NoError(t, ast.Parse(c))
+
+	// replace every NOT (a == b) with a != b
+	result, err := parse.Rewrite(ast, func(ast parse.AST) (parse.AST, error) {
+		if not, ok := ast.(*bools.UnaryExpr); ok {
+			if eq, ok := not.Expr.(*comp.EqualExpr); ok && eq.Op == comp.OpEqual {
+				return &comp.EqualExpr{LHS: eq.LHS, RHS: eq.RHS, Op: comp.OpNotEqual, Src: not.Src}, nil
+			}
+		}
+		return ast, nil
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, or(neq(un("x"), un("3")), and(neq(un("y"), un("4")), un("z"))), asttest.StripSpans(result))
+
+	var vars []string
+	parse.Inspect(result, func(ast parse.AST) bool {
+		if unparsed, ok := ast.(parse.Unparsed); ok {
+			vars = append(vars, unparsed.String())
+		}
+		return true
+	})
+	assert.Equal(t, []string{"x", "3", "y", "4", "z"}, vars)
+}
+
+func TestBoolCompArith(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+	a, err := arith.NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+		result bool
+	}{
+		{
+			"${a} + ${b} * 2 > 10",
+			gt(&arith.BinExpr{LHS: un("${a}"), RHS: &arith.BinExpr{LHS: un("${b}"), RHS: un("2"), Op: arith.OpMultiply}, Op: arith.OpAdd}, un("10")),
+			true,
+		},
+		{
+			"(${a} + ${b}) * 2 > 10 AND NOT ${a}%2 == 0",
+			and(
+				gt(&arith.BinExpr{LHS: &arith.BinExpr{LHS: un("${a}"), RHS: un("${b}"), Op: arith.OpAdd}, RHS: un("2"), Op: arith.OpMultiply}, un("10")),
+				not(eq(&arith.BinExpr{LHS: un("${a}"), RHS: un("2"), Op: arith.OpModulo}, un("0"))),
+			),
+			true,
+		},
+		{
+			"${a} > -3 AND ${a} - 1 < 3 / 2",
+			and(gt(un("${a}"), &arith.UnaryExpr{Expr: un("3"), Op: arith.OpNegate}), lt(&arith.BinExpr{LHS: un("${a}"), RHS: un("1"), Op: arith.OpSubtract}, &arith.BinExpr{LHS: un("3"), RHS: un("2"), Op: arith.OpDivide})),
+			false,
+		},
+	}
+	values := comp.ValueInterpreter(map[string]any{"${a}": 3, "${b}": 4})
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := b.ParseStr(tt.input)
+			require.NoError(t, err)
+			ast, err = parse.ParseAll(ast, c, a)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+
+			result, err := bools.Eval(ast, comp.Interpreter(arith.Interpreter(values)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+		})
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+	a, err := arith.NewParser()
+	require.NoError(t, err)
+	l, err := literal.NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+		result bool
+	}{
+		{
+			`${a} * 1.5 > -3 AND ${name} == "x (y)"`,
+			and(
+				gt(&arith.BinExpr{LHS: un("${a}"), RHS: lit(literal.KindFloat, 1.5), Op: arith.OpMultiply}, lit(literal.KindInt, int64(-3))),
+				eq(un("${name}"), lit(literal.KindString, "x (y)")),
+			),
+			true,
+		},
+		{
+			"${a} - -2 == 5 OR ${elapsed} >= 1m30s",
+			or(
+				eq(&arith.BinExpr{LHS: un("${a}"), RHS: &arith.UnaryExpr{Expr: lit(literal.KindInt, int64(2)), Op: arith.OpNegate}, Op: arith.OpSubtract}, lit(literal.KindInt, int64(5))),
+				gte(un("${elapsed}"), lit(literal.KindDuration, 90*time.Second)),
+			),
+			true,
+		},
+		{
+			`${name} LIKE "x (%" AND ${name} =~ "\\(y\\)$"`,
+			and(
+				&comp.MatchExpr{LHS: un("${name}"), RHS: lit(literal.KindString, "x (%"), Op: comp.OpLike, Regexp: regexp.MustCompile(`(?s)^x \(.*$`)},
+				&comp.MatchExpr{LHS: un("${name}"), RHS: lit(literal.KindString, `\(y\)$`), Op: comp.OpMatches, Regexp: regexp.MustCompile(`\(y\)$`)},
+			),
+			true,
+		},
+		{
+			"${a} > 1.5e-05 * 2",
+			gt(un("${a}"), &arith.BinExpr{LHS: lit(literal.KindFloat, 1.5e-5), RHS: lit(literal.KindInt, int64(2)), Op: arith.OpMultiply}),
+			true,
+		},
+		{
+			"${name} != null AND NOT ${flag} == true",
+			and(neq(un("${name}"), lit(literal.KindNull, nil)), not(eq(un("${flag}"), lit(literal.KindBool, true)))),
+			true,
+		},
+	}
+	values := comp.ValueInterpreter(map[string]any{"${a}": 3, "${name}": "x (y)", "${elapsed}": time.Minute, "${flag}": false})
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := b.ParseStr(tt.input)
+			require.NoError(t, err)
+			ast, err = parse.ParseAll(ast, c, l, a, l)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+
+			result, err := bools.Eval(ast, comp.Interpreter(arith.Interpreter(literal.Interpreter().WithFallback(values))))
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+
+			text, err := parse.Format(ast, b, c, a, l)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, text)
+		})
+	}
+}
+
+func TestVars(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+	a, err := arith.NewParser()
+	require.NoError(t, err)
+	l, err := literal.NewParser()
+	require.NoError(t, err)
+	v, err := vars.NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+		result bool
+	}{
+		{
+			`${workflow.input.items[0].price} * 2 > ${workflow.input["min-price"]}`,
+			gt(
+				&arith.BinExpr{LHS: ref(vars.Field("workflow"), vars.Field("input"), vars.Field("items"), vars.Index(0), vars.Field("price")), RHS: lit(literal.KindInt, int64(2)), Op: arith.OpMultiply},
+				ref(vars.Field("workflow"), vars.Field("input"), vars.Field("min-price")),
+			),
+			true,
+		},
+		{
+			`${workflow.input.items[-1].name} == "b" AND NOT ${workflow.input.enabled}`,
+			and(
+				eq(ref(vars.Field("workflow"), vars.Field("input"), vars.Field("items"), vars.Index(-1), vars.Field("name")), lit(literal.KindString, "b")),
+				not(ref(vars.Field("workflow"), vars.Field("input"), vars.Field("enabled"))),
+			),
+			true,
+		},
+	}
+	doc := json.RawMessage(`{"workflow": {"input": {"items": [{"price": 3}, {"name": "b"}], "min-price": 5, "enabled": false}}}`)
+	values := literal.Interpreter().WithFallback(vars.Interpreter(doc))
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := b.ParseStr(tt.input)
+			require.NoError(t, err)
+			ast, err = parse.ParseAll(ast, c, v, l, a, v, l)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+
+			interpreter := comp.Interpreter(arith.Interpreter(values)).WithFallback(func(ast parse.AST) (bool, error) {
+				val, err := values(ast)
+				if err != nil {
+					return false, err
+				}
+				return val.(bool), nil
+			})
+			result, err := bools.Eval(ast, interpreter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+
+			text, err := parse.Format(ast, b, c, a, l, v)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, text)
+		})
+	}
+}
+
+func TestMembership(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+	l, err := literal.NewParser()
+	require.NoError(t, err)
+	v, err := vars.NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+		result bool
+	}{
+		{
+			"${status} NOT IN ('FAILED','TIMED_OUT') AND NOT ${retries} IN (1, 2)",
+			and(
+				&comp.InExpr{LHS: ref(vars.Field("status")), RHS: &comp.List{Elems: []parse.AST{lit(literal.KindString, "FAILED"), lit(literal.KindString, "TIMED_OUT")}}, Op: comp.OpNotIn},
+				not(&comp.InExpr{LHS: ref(vars.Field("retries")), RHS: &comp.List{Elems: []parse.AST{lit(literal.KindInt, int64(1)), lit(literal.KindInt, int64(2))}}, Op: comp.OpIn}),
+			),
+			true,
+		},
+		{
+			"(${status} IN ${allowed}) OR false",
+			or(&comp.InExpr{LHS: ref(vars.Field("status")), RHS: ref(vars.Field("allowed")), Op: comp.OpIn}, lit(literal.KindBool, false)),
+			true,
+		},
+	}
+	doc := map[string]any{"status": "COMPLETED", "retries": 3, "allowed": []string{"COMPLETED", "SKIPPED"}}
+	values := literal.Interpreter().WithFallback(vars.Interpreter(doc))
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := b.ParseStr(tt.input)
+			require.NoError(t, err)
+			ast, err = parse.ParseAll(ast, c, v, l)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+
+			interpreter := comp.Interpreter(values).WithFallback(func(ast parse.AST) (bool, error) {
+				val, err := values(ast)
+				if err != nil {
+					return false, err
+				}
+				return val.(bool), nil
+			})
+			result, err := bools.Eval(ast, interpreter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+		})
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+	a, err := arith.NewParser()
+	require.NoError(t, err)
+	f, err := funcs.NewParser(funcs.WithFuncs(funcs.Std()))
+	require.NoError(t, err)
+	l, err := literal.NewParser()
+	require.NoError(t, err)
+	v, err := vars.NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+		result bool
+	}{
+		{
+			`len(${items}) > 3 AND lower(${name}) == "x"`,
+			and(
+				gt(call("len", ref(vars.Field("items"))), lit(literal.KindInt, int64(3))),
+				eq(call("lower", ref(vars.Field("name"))), lit(literal.KindString, "x")),
+			),
+			true,
+		},
+		{
+			"max(${a}, ${b} + 1) * 2 >= 10",
+			gte(
+				&arith.BinExpr{
+					LHS: call("max", ref(vars.Field("a")), &arith.BinExpr{LHS: ref(vars.Field("b")), RHS: lit(literal.KindInt, int64(1)), Op: arith.OpAdd}),
+					RHS: lit(literal.KindInt, int64(2)),
+					Op:  arith.OpMultiply,
+				},
+				lit(literal.KindInt, int64(10)),
+			),
+			true,
+		},
+		{
+			"abs(len(${items}) - 10) < 5 OR coalesce(${missing}, true)",
+			or(
+				lt(call("abs", &arith.BinExpr{LHS: call("len", ref(vars.Field("items"))), RHS: lit(literal.KindInt, int64(10)), Op: arith.OpSubtract}), lit(literal.KindInt, int64(5))),
+				call("coalesce", ref(vars.Field("missing")), lit(literal.KindBool, true)),
+			),
+			true,
+		},
+	}
+	doc := map[string]any{"items": []any{1, 2, 3, 4}, "name": "X", "a": 2, "b": 4, "missing": nil}
+	var values parse.Interpreter[any]
+	calls := funcs.Interpreter(funcs.Std(), func(ast parse.AST) (any, error) { return values(ast) })
+	values = arith.Interpreter(calls.WithFallback(literal.Interpreter()).WithFallback(vars.Interpreter(doc)))
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := b.ParseStr(tt.input)
+			require.NoError(t, err)
+			ast, err = parse.ParseAll(ast, c, f, v, l, a, f, a, v, l)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+
+			interpreter := comp.Interpreter(values).WithFallback(func(ast parse.AST) (bool, error) {
+				val, err := values(ast)
+				if err != nil {
+					return false, err
+				}
+				return val.(bool), nil
+			})
+			result, err := bools.Eval(ast, interpreter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+
+			text, err := parse.Format(ast, b, c, a, f, l, v)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, text)
+		})
+	}
+}
+
+func TestTermOperators(t *testing.T) {
+	// the default bools parser keeps every operator of the default comp parser within a term
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+	for _, op := range c.TermOperators() {
+		input := "x " + strings.Replace(op, "...", "1", 1) + " y"
+		ast, err := b.ParseStr(input)
+		require.NoError(t, err, input)
+		assert.IsType(t, parse.Unparsed{}, ast, input)
+	}
+}
+
+func TestRanges(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser(comp.WithChainedComparisons(true))
+	require.NoError(t, err)
+	a, err := arith.NewParser()
+	require.NoError(t, err)
+	l, err := literal.NewParser()
+	require.NoError(t, err)
+	v, err := vars.NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+		result bool
+	}{
+		{
+			"${retries} BETWEEN 1 AND 3 AND ${status} == \"FAILED\"",
+			and(
+				&comp.BetweenExpr{Expr: ref(vars.Field("retries")), Lower: lit(literal.KindInt, int64(1)), Upper: lit(literal.KindInt, int64(3)), Op: comp.OpBetween},
+				eq(ref(vars.Field("status")), lit(literal.KindString, "FAILED")),
+			),
+			true,
+		},
+		{
+			"0 < ${retries} * 2 <= 10 OR ${retries} NOT BETWEEN 0 AND 5",
+			or(
+				&comp.ChainExpr{
+					Operands: []parse.AST{lit(literal.KindInt, int64(0)), &arith.BinExpr{LHS: ref(vars.Field("retries")), RHS: lit(literal.KindInt, int64(2)), Op: arith.OpMultiply}, lit(literal.KindInt, int64(10))},
+					Ops:      []comp.Op{comp.OpLess, comp.OpLessOrEqual},
+				},
+				&comp.BetweenExpr{Expr: ref(vars.Field("retries")), Lower: lit(literal.KindInt, int64(0)), Upper: lit(literal.KindInt, int64(5)), Op: comp.OpNotBetween},
+			),
+			true,
+		},
+	}
+	values := literal.Interpreter().WithFallback(vars.Interpreter(map[string]any{"retries": 2, "status": "FAILED"}))
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := b.ParseStr(tt.input)
+			require.NoError(t, err)
+			ast, err = parse.ParseAll(ast, c, v, l, a, v, l)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+
+			result, err := bools.Eval(ast, comp.Interpreter(arith.Interpreter(values)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+
+			text, err := parse.Format(ast, b, c, a, l, v)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, text)
+		})
+	}
+}
+
+func TestConditional(t *testing.T) {
+	k, err := cond.NewParser()
+	require.NoError(t, err)
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+	a, err := arith.NewParser()
+	require.NoError(t, err)
+	l, err := literal.NewParser()
+	require.NoError(t, err)
+	v, err := vars.NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+		result any
+	}{
+		{
+			"${priority} > 5 ? \"fast\" : \"slow\"",
+			&cond.CondExpr{
+				Cond: gt(ref(vars.Field("priority")), lit(literal.KindInt, int64(5))),
+				Then: lit(literal.KindString, "fast"),
+				Else: lit(literal.KindString, "slow"),
+			},
+			"fast",
+		},
+		{
+			"${priority} > 9 AND ${retry} ? 0 : ${priority} * 2 + 1",
+			&cond.CondExpr{
+				Cond: and(gt(ref(vars.Field("priority")), lit(literal.KindInt, int64(9))), ref(vars.Field("retry"))),
+				Then: lit(literal.KindInt, int64(0)),
+				Else: &arith.BinExpr{
+					LHS: &arith.BinExpr{LHS: ref(vars.Field("priority")), RHS: lit(literal.KindInt, int64(2)), Op: arith.OpMultiply},
+					RHS: lit(literal.KindInt, int64(1)),
+					Op:  arith.OpAdd,
+				},
+			},
+			int64(15),
+		},
+		{
+			"${priority} < 5 ? ${missing} : ${priority} == 7 ? \"seven\" : ${missing}",
+			&cond.CondExpr{
+				Cond: lt(ref(vars.Field("priority")), lit(literal.KindInt, int64(5))),
+				Then: ref(vars.Field("missing")),
+				Else: &cond.CondExpr{
+					Cond: eq(ref(vars.Field("priority")), lit(literal.KindInt, int64(7))),
+					Then: lit(literal.KindString, "seven"),
+					Else: ref(vars.Field("missing")),
+				},
+			},
+			"seven",
+		},
+	}
+	values := arith.Interpreter(literal.Interpreter().WithFallback(vars.Interpreter(map[string]any{"priority": 7, "retry": false})))
+	conds := func(ast parse.AST) (bool, error) {
+		return bools.Eval(ast, comp.Interpreter(values).WithFallback(func(ast parse.AST) (bool, error) {
+			val, err := values(ast)
+			if err != nil {
+				return false, err
+			}
+			b, _ := val.(bool)
+			return b, nil
+		}))
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := k.ParseStr(tt.input)
+			require.NoError(t, err)
+			ast, err = parse.ParseAll(ast, b, c, v, l, a, v, l)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+
+			result, err := cond.Eval(ast, conds, values)
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+
+			text, err := parse.Format(ast, k, b, c, a, l, v)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, text)
+		})
+	}
+}
+
+func TestNullSafe(t *testing.T) {
+	k, err := cond.NewParser()
+	require.NoError(t, err)
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+	co, err := coalesce.NewParser()
+	require.NoError(t, err)
+	a, err := arith.NewParser()
+	require.NoError(t, err)
+	l, err := literal.NewParser()
+	require.NoError(t, err)
+	v, err := vars.NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+		result any
+	}{
+		{
+			`${task?.output?.result} ?? "none" == "none"`,
+			eq(
+				&coalesce.CoalesceExpr{Operands: []parse.AST{
+					ref(vars.Field("task"), vars.NullSafe(vars.Field("output")), vars.NullSafe(vars.Field("result"))),
+					lit(literal.KindString, "none"),
+				}},
+				lit(literal.KindString, "none"),
+			),
+			true,
+		},
+		{
+			"${retries} IS NULL OR ${retries} < 3",
+			or(&comp.NullExpr{Expr: ref(vars.Field("retries")), Op: comp.OpIsNull}, lt(ref(vars.Field("retries")), lit(literal.KindInt, int64(3)))),
+			true,
+		},
+		{
+			"${task?.output} IS NOT NULL ? ${task.output.result} : ${fallback} ?? ${limit} - 1",
+			&cond.CondExpr{
+				Cond: &comp.NullExpr{Expr: ref(vars.Field("task"), vars.NullSafe(vars.Field("output"))), Op: comp.OpIsNotNull},
+				Then: ref(vars.Field("task"), vars.Field("output"), vars.Field("result")),
+				Else: &coalesce.CoalesceExpr{Operands: []parse.AST{
+					ref(vars.Field("fallback")),
+					&arith.BinExpr{LHS: ref(vars.Field("limit")), RHS: lit(literal.KindInt, int64(1)), Op: arith.OpSubtract},
+				}},
+			},
+			int64(9),
+		},
+	}
+	values := coalesce.Interpreter(arith.Interpreter(literal.Interpreter().WithFallback(vars.Interpreter(map[string]any{
+		"task":  map[string]any{"output": nil},
+		"limit": 10,
+	}))))
+	conds := func(ast parse.AST) (bool, error) {
+		return bools.Eval(ast, comp.Interpreter(values))
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := k.ParseStr(tt.input)
+			require.NoError(t, err)
+			ast, err = parse.ParseAll(ast, b, c, co, v, l, a, v, l)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+
+			result, err := cond.Eval(ast, conds, values.WithFallback(func(ast parse.AST) (any, error) {
+				return conds(ast)
+			}))
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+
+			text, err := parse.Format(ast, k, b, c, co, a, l, v)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, text)
+		})
+	}
+}
+
+func TestBoolCompListExpr(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+
+	ast, err := b.ParseStr("x > 3 AND y == 5 AND z != 3")
+	require.NoError(t, err)
+	ast = bools.Flatten(ast)
+	require.NoError(t, ast.Parse(c))
+
+	expected := &bools.ListExpr{Op: bools.OpAnd, Terms: []parse.AST{gt(un("x"), un("3")), eq(un("y"), un("5")), neq(un("z"), un("3"))}}
+	assert.EqualValues(t, expected, asttest.StripSpans(ast))
+
+	result, err := bools.Eval(ast, comp.Interpreter(comp.ValueInterpreter(map[string]any{"x": 4, "y": 5, "z": 4})))
+	assert.NoError(t, err)
+	assert.True(t, result)
+}
+
+func TestBoolCompEval(t *testing.T) {
+	b, err := bools.NewParser()
+	require.NoError(t, err)
+	c, err := comp.NewParser()
+	require.NoError(t, err)
+
+	ast, err := b.ParseStr("x > 3 AND NOT(name == 'foo' OR enabled)")
+	require.NoError(t, err)
+	require.NoError(t, ast.Parse(c))
+
+	values := map[string]any{"x": 4, "name": "bar"}
+	interpreter := comp.Interpreter(comp.ValueInterpreter(values)).WithFallback(bools.VarInterpreter(map[string]bool{"enabled": false}))
+
+	result, err := bools.Eval(ast, interpreter)
+	assert.NoError(t, err)
+	assert.True(t, result)
+
+	values["name"] = "foo"
+	result, err = bools.Eval(ast, interpreter)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	// the right-hand side would fail to evaluate, but is never reached
+	ast, err = b.ParseStr("x != null AND x > 3")
+	require.NoError(t, err)
+	require.NoError(t, ast.Parse(c))
+	values["x"] = nil
+	result, err = bools.Eval(ast, interpreter)
+	assert.NoError(t, err)
+	assert.False(t, result)
+
+	_, err = bools.Eval(ast, interpreter, bools.WithShortCircuit(false))
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	configs := []struct {
+		name  string
+		bools []bools.ParserOpt
+		comp  []comp.ParserOpt
+	}{
+		{name: "default"},
+		{
+			name: "standard",
+			bools: []bools.ParserOpt{
+				bools.WithPrecedence(bools.PrecedenceStandard),
+				bools.WithTokens(map[bools.Token]string{
+					bools.And: "&&", bools.Or: "||", bools.Not: "~", bools.OpenParen: "(", bools.CloseParen: ")",
+				}),
+			},
+		},
+		{
+			name:  "keywords",
+			bools: []bools.ParserOpt{bools.WithPrecedence(bools.PrecedenceStandard), bools.WithWordBoundaries(true)},
+			comp: []comp.ParserOpt{comp.WithTokens(map[comp.Token]string{
+				comp.Equal: "eq", comp.NotEqual: "ne", comp.Greater: "gt", comp.GreaterOrEqual: "ge",
+				comp.Less: "lt", comp.LessOrEqual: "le", comp.OpenParen: "(", comp.CloseParen: ")",
+				comp.In: "in", comp.NotIn: "not in", comp.Comma: ",", comp.Between: "between", comp.And: "and",
+			})},
+		},
+	}
+	for _, config := range configs {
+		t.Run(config.name, func(t *testing.T) {
+			b, err := bools.NewParser(config.bools...)
+			require.NoError(t, err)
+			c, err := comp.NewParser(config.comp...)
+			require.NoError(t, err)
+			a, err := arith.NewParser()
+			require.NoError(t, err)
+
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 1000; i++ {
+				ast := randomBools(rng, 5)
+				text, err := parse.Format(ast, b, c, a)
+				require.NoError(t, err)
+
+				parsed, err := b.ParseStr(text)
+				require.NoError(t, err, text)
+				parsed, err = parse.ParseAll(parsed, c, a)
+				require.NoError(t, err, text)
+				require.EqualValues(t, ast, asttest.StripSpans(parsed), text)
+			}
+		})
+	}
+}
+
+// randomBools returns a random boolean expression whose clauses are comparisons or unparsed values.
+func randomBools(rng *rand.Rand, depth int) parse.AST {
+	if depth == 0 {
+		return randomComp(rng)
+	}
+	switch rng.Intn(4) {
+	case 0:
+		return and(randomBools(rng, depth-1), randomBools(rng, depth-1))
+	case 1:
+		return or(randomBools(rng, depth-1), randomBools(rng, depth-1))
+	case 2:
+		return not(randomBools(rng, depth-1))
+	default:
+		return randomComp(rng)
+	}
+}
+
+// randomComp returns a random comparison between arithmetic expressions, or an arithmetic expression.
+func randomComp(rng *rand.Rand) parse.AST {
+	ops := []func(a, b parse.AST) parse.AST{eq, neq, gt, lt, gte, lte}
+	switch rng.Intn(9) {
+	case 0, 1:
+		return randomArith(rng, 2)
+	case 3:
+		return &comp.BetweenExpr{Expr: randomArith(rng, 2), Lower: randomArith(rng, 1), Upper: randomArith(rng, 1), Op: comp.OpBetween}
+	case 2:
+		var elems []parse.AST
+		for n := rng.Intn(4); len(elems) < n; {
+			elems = append(elems, randomArith(rng, 1))
+		}
+		op := comp.OpIn
+		if rng.Intn(2) == 0 {
+			op = comp.OpNotIn
+		}
+		return &comp.InExpr{LHS: randomArith(rng, 2), RHS: &comp.List{Elems: elems}, Op: op}
+	}
+	return ops[rng.Intn(len(ops))](randomArith(rng, 2), randomArith(rng, 2))
+}
+
+// randomArith returns a random arithmetic expression whose operands are unparsed values.
+func randomArith(rng *rand.Rand, depth int) parse.AST {
+	if depth == 0 || rng.Intn(2) == 0 {
+		return randomValue(rng)
+	}
+	ops := []arith.Op{arith.OpAdd, arith.OpSubtract, arith.OpMultiply, arith.OpDivide, arith.OpModulo, arith.OpPower}
+	if rng.Intn(7) == 0 {
+		return &arith.UnaryExpr{Op: arith.OpNegate, Expr: randomArith(rng, depth-1)}
+	}
+	return &arith.BinExpr{LHS: randomArith(rng, depth-1), RHS: randomArith(rng, depth-1), Op: ops[rng.Intn(len(ops))]}
+}
+
+func randomValue(rng *rand.Rand) parse.AST {
+	values := [][]string{{"x"}, {"count"}, {"42"}, {"a", "b"}, {"'AND OR'"}, {`"x (y)"`}, {"`NOT`"}}
+	return un(values[rng.Intn(len(values))]...)
+}
+
 func eq(a, b parse.AST) parse.AST {
 	return &comp.EqualExpr{LHS: a, RHS: b, Op: comp.OpEqual}
 }
@@ -77,7 +865,20 @@ func not(inside parse.AST) parse.AST {
 	return &bools.UnaryExpr{Expr: inside, Op: bools.OpNot}
 }
 
+func lit(kind literal.Kind, value any) parse.AST {
+	return &literal.Lit{Kind: kind, Value: value}
+}
+
+func ref(path ...vars.Segment) parse.AST {
+	return &vars.Ref{Path: path}
+}
+
+// call returns a CallExpr calling the named function with the provided arguments
+func call(name string, args ...parse.AST) parse.AST {
+	return &funcs.CallExpr{Name: name, Args: args}
+}
+
 // un stands for unparsed and returns a Unparsed
 func un(tokens ...string) parse.AST {
-	return parse.Unparsed{Contents: tokens}
+	return parse.Unparsed{Contents: parse.NewTokens(tokens...)}
 }
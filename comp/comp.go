@@ -2,25 +2,60 @@
 //
 //	expr    -> equal
 //	equal   -> ordinal ( '!=' | '==' ) ordinal | ordinal
-//	ordinal -> term ( '>=' | '>' | '<' | '<=' ) term | term
+//	ordinal -> term ( ordop term )+ | term ( 'IN' | 'NOT IN' ) list | term match term | term range | term null | term
+//	ordop   -> '>=' | '>' | '<' | '<='
+//	match   -> 'LIKE' | '=~' | 'CONTAINS' | 'STARTS WITH' | 'ENDS WITH'
+//	range   -> ( 'BETWEEN' | 'NOT BETWEEN' ) term 'AND' term
+//	null    -> 'IS NULL' | 'IS NOT NULL'
+//	list    -> '(' ( term ( ',' term )* )? ')' | term
 //	term    -> '(' expr ')' | unparsed
 //	unparsed -> .*
 //
 // It leaves unparsed portions of the expression in parse.Unparsed nodes, for later consumption. Terms in the default
-// syntax match C-like languages.
+// syntax match C-like languages, while membership tests use SQL syntax, as in status IN ('A', 'B').
+//
+// String predicates are parsed into MatchExpr nodes. LIKE matches SQL patterns, in which % matches any sequence of
+// runes and _ matches a single rune, while =~ matches Go regular expressions, as in name =~ '^task_[0-9]+$'. Patterns
+// which are string literals are compiled while parsing, so that invalid patterns result in a *parse.SyntaxError.
+//
+// By default, an ordinal comparison has a single operator, so that a < b < c is a syntax error. Chains of ordinal
+// comparisons such as 0 < x <= 10 are recognized by parsers configured using WithChainedComparisons, and parsed into
+// ChainExpr nodes. Range tests such as x BETWEEN 1 AND 10 are parsed into BetweenExpr nodes.
+//
+// Tests for null, such as ${task.output} IS NULL, are parsed into NullExpr nodes. When evaluated, values which are
+// not found, such as missing variables, are treated as null.
+//
+// Operators such as NOT IN and BETWEEN ... AND contain keywords of the bools package, which keeps the default operators
+// of this package within its terms. Parsers configured with other tokens should be combined with a bools.Parser
+// configured using bools.WithTermOperators with the operators returned by Parser.TermOperators.
 package comp
 
 import (
+	"errors"
 	"fmt"
 	"github.com/orkes-io/go-parse"
+	"regexp"
+	"sort"
 	"strings"
+	"unicode"
 )
 
 // EqualExpr represents an equality comparison.
 type EqualExpr struct {
 	LHS parse.AST
 	RHS parse.AST
-	Op  Op // Op can only be one of OpEqual or OpNotEqual
+	Op  Op         // Op can only be one of OpEqual or OpNotEqual
+	Src parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (e *EqualExpr) String() string {
+	return format(e)
+}
+
+// Span returns the location of this expression in the source text.
+func (e *EqualExpr) Span() parse.Span {
+	return e.Src
 }
 
 func (e *EqualExpr) Parse(p parse.Parser) error {
@@ -45,11 +80,33 @@ func (e *EqualExpr) Parse(p parse.Parser) error {
 	return nil
 }
 
+// Children returns the left-hand and right-hand sides of this expression.
+func (e *EqualExpr) Children() []parse.AST {
+	return []parse.AST{e.LHS, e.RHS}
+}
+
+// WithChildren returns a copy of this expression with the provided left-hand and right-hand sides.
+func (e *EqualExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("EqualExpr", children, 2)
+	return &EqualExpr{LHS: children[0], RHS: children[1], Op: e.Op, Src: e.Src}
+}
+
 // OrdinalExpr represents a ordinal expression.
 type OrdinalExpr struct {
 	LHS parse.AST
 	RHS parse.AST
-	Op  Op // Op can only be one of OpGreater, OpLess, OpGreaterOrEqual, or OpLessOrEqual
+	Op  Op         // Op can only be one of OpGreater, OpLess, OpGreaterOrEqual, or OpLessOrEqual
+	Src parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (e *OrdinalExpr) String() string {
+	return format(e)
+}
+
+// Span returns the location of this expression in the source text.
+func (e *OrdinalExpr) Span() parse.Span {
+	return e.Src
 }
 
 func (e *OrdinalExpr) Parse(p parse.Parser) error {
@@ -74,7 +131,358 @@ func (e *OrdinalExpr) Parse(p parse.Parser) error {
 	return nil
 }
 
-// Op represents one of six possible comparison operations recognized by this grammar.
+// Children returns the left-hand and right-hand sides of this expression.
+func (e *OrdinalExpr) Children() []parse.AST {
+	return []parse.AST{e.LHS, e.RHS}
+}
+
+// WithChildren returns a copy of this expression with the provided left-hand and right-hand sides.
+func (e *OrdinalExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("OrdinalExpr", children, 2)
+	return &OrdinalExpr{LHS: children[0], RHS: children[1], Op: e.Op, Src: e.Src}
+}
+
+// InExpr represents a membership test, such as x IN (a, b, c).
+type InExpr struct {
+	LHS parse.AST
+	RHS parse.AST  // RHS is usually a *List, but may be any term whose value is a list.
+	Op  Op         // Op can only be one of OpIn or OpNotIn
+	Src parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (e *InExpr) String() string {
+	return format(e)
+}
+
+// Span returns the location of this expression in the source text.
+func (e *InExpr) Span() parse.Span {
+	return e.Src
+}
+
+func (e *InExpr) Parse(p parse.Parser) error {
+	if unparsed, ok := e.LHS.(parse.Unparsed); ok {
+		newLHS, err := p.Parse(unparsed.Contents)
+		if err != nil {
+			return err
+		}
+		e.LHS = newLHS
+	} else if err := e.LHS.Parse(p); err != nil {
+		return err
+	}
+	if unparsed, ok := e.RHS.(parse.Unparsed); ok {
+		newRHS, err := p.Parse(unparsed.Contents)
+		if err != nil {
+			return err
+		}
+		e.RHS = newRHS
+	} else if err := e.RHS.Parse(p); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Children returns the left-hand and right-hand sides of this expression.
+func (e *InExpr) Children() []parse.AST {
+	return []parse.AST{e.LHS, e.RHS}
+}
+
+// WithChildren returns a copy of this expression with the provided left-hand and right-hand sides.
+func (e *InExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("InExpr", children, 2)
+	return &InExpr{LHS: children[0], RHS: children[1], Op: e.Op, Src: e.Src}
+}
+
+// List represents a parenthesized list of terms, such as the right-hand side of x IN (a, b, c).
+type List struct {
+	Elems []parse.AST
+	Src   parse.Span // Src is the location of this list in the source text, including its parentheses.
+}
+
+// String returns this list in the default syntax of this package.
+func (l *List) String() string {
+	return format(l)
+}
+
+// Span returns the location of this list in the source text.
+func (l *List) Span() parse.Span {
+	return l.Src
+}
+
+func (l *List) Parse(p parse.Parser) error {
+	for i, elem := range l.Elems {
+		if unparsed, ok := elem.(parse.Unparsed); ok {
+			newElem, err := p.Parse(unparsed.Contents)
+			if err != nil {
+				return err
+			}
+			l.Elems[i] = newElem
+		} else if err := elem.Parse(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Children returns the elements of this list.
+func (l *List) Children() []parse.AST {
+	return l.Elems
+}
+
+// WithChildren returns a copy of this list with the provided elements.
+func (l *List) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("List", children, len(l.Elems))
+	return &List{Elems: append([]parse.AST(nil), children...), Src: l.Src}
+}
+
+// MatchExpr represents a string predicate, such as name LIKE 'task_%'.
+type MatchExpr struct {
+	LHS parse.AST
+	RHS parse.AST  // RHS is the pattern or substring to match against.
+	Op  Op         // Op can only be one of OpLike, OpMatches, OpContains, OpStartsWith or OpEndsWith
+	Src parse.Span // Src is the location of this expression in the source text.
+
+	// Regexp is the compiled pattern of a LIKE or =~ expression whose pattern is a string literal. It is set by
+	// Parser.Parse, and is nil if the pattern is only known during evaluation, in which case it is compiled then.
+	Regexp *regexp.Regexp
+
+	err error // err is the error from compiling the pattern in WithChildren, which is reported by Validate.
+}
+
+// String returns this expression in the default syntax of this package.
+func (e *MatchExpr) String() string {
+	return format(e)
+}
+
+// Span returns the location of this expression in the source text.
+func (e *MatchExpr) Span() parse.Span {
+	return e.Src
+}
+
+func (e *MatchExpr) Parse(p parse.Parser) error {
+	if unparsed, ok := e.LHS.(parse.Unparsed); ok {
+		newLHS, err := p.Parse(unparsed.Contents)
+		if err != nil {
+			return err
+		}
+		e.LHS = newLHS
+	} else if err := e.LHS.Parse(p); err != nil {
+		return err
+	}
+	if unparsed, ok := e.RHS.(parse.Unparsed); ok {
+		newRHS, err := p.Parse(unparsed.Contents)
+		if err != nil {
+			return err
+		}
+		e.RHS = newRHS
+	} else if err := e.RHS.Parse(p); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Children returns the left-hand and right-hand sides of this expression.
+func (e *MatchExpr) Children() []parse.AST {
+	return []parse.AST{e.LHS, e.RHS}
+}
+
+// WithChildren returns a copy of this expression with the provided left-hand and right-hand sides. The pattern is
+// compiled again if the new right-hand side is a string literal, and an invalid pattern is reported by Validate.
+func (e *MatchExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("MatchExpr", children, 2)
+	result := &MatchExpr{LHS: children[0], RHS: children[1], Op: e.Op, Src: e.Src}
+	if err := result.compile(); err != nil {
+		pattern := result.RHS.(parse.Unparsed).Contents[0].Text
+		result.err = fmt.Errorf("%w: invalid pattern %s: %v", parse.ErrParse, pattern, err)
+	}
+	return result
+}
+
+// Validate implements parse.Validator, returning an error if the pattern of an expression returned by WithChildren
+// could not be compiled.
+func (e *MatchExpr) Validate() error {
+	return e.err
+}
+
+// ChainExpr represents a chain of ordinal comparisons, such as 0 < x <= 10, which holds iff each comparison between
+// adjacent operands holds. Chains are only produced by parsers configured using WithChainedComparisons.
+type ChainExpr struct {
+	Operands []parse.AST
+	Ops      []Op       // Ops[i] compares Operands[i] with Operands[i+1], and is one of the ordinal operators.
+	Src      parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (e *ChainExpr) String() string {
+	return format(e)
+}
+
+// Span returns the location of this expression in the source text.
+func (e *ChainExpr) Span() parse.Span {
+	return e.Src
+}
+
+func (e *ChainExpr) Parse(p parse.Parser) error {
+	for i, operand := range e.Operands {
+		if unparsed, ok := operand.(parse.Unparsed); ok {
+			newOperand, err := p.Parse(unparsed.Contents)
+			if err != nil {
+				return err
+			}
+			e.Operands[i] = newOperand
+		} else if err := operand.Parse(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Children returns the operands of this expression.
+func (e *ChainExpr) Children() []parse.AST {
+	return e.Operands
+}
+
+// WithChildren returns a copy of this expression with the provided operands.
+func (e *ChainExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("ChainExpr", children, len(e.Operands))
+	return &ChainExpr{Operands: append([]parse.AST(nil), children...), Ops: append([]Op(nil), e.Ops...), Src: e.Src}
+}
+
+// BetweenExpr represents a range test, such as x BETWEEN 1 AND 10, which holds iff Lower <= Expr <= Upper.
+type BetweenExpr struct {
+	Expr  parse.AST
+	Lower parse.AST
+	Upper parse.AST
+	Op    Op         // Op can only be one of OpBetween or OpNotBetween
+	Src   parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (e *BetweenExpr) String() string {
+	return format(e)
+}
+
+// Span returns the location of this expression in the source text.
+func (e *BetweenExpr) Span() parse.Span {
+	return e.Src
+}
+
+func (e *BetweenExpr) Parse(p parse.Parser) error {
+	for _, child := range []*parse.AST{&e.Expr, &e.Lower, &e.Upper} {
+		if unparsed, ok := (*child).(parse.Unparsed); ok {
+			newChild, err := p.Parse(unparsed.Contents)
+			if err != nil {
+				return err
+			}
+			*child = newChild
+		} else if err := (*child).Parse(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Children returns the tested expression, followed by the lower and upper bounds of this expression.
+func (e *BetweenExpr) Children() []parse.AST {
+	return []parse.AST{e.Expr, e.Lower, e.Upper}
+}
+
+// WithChildren returns a copy of this expression with the provided tested expression, lower and upper bounds.
+func (e *BetweenExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("BetweenExpr", children, 3)
+	return &BetweenExpr{Expr: children[0], Lower: children[1], Upper: children[2], Op: e.Op, Src: e.Src}
+}
+
+// NullExpr represents a test for null, such as x IS NULL.
+type NullExpr struct {
+	Expr parse.AST
+	Op   Op         // Op can only be one of OpIsNull or OpIsNotNull
+	Src  parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (e *NullExpr) String() string {
+	return format(e)
+}
+
+// Span returns the location of this expression in the source text.
+func (e *NullExpr) Span() parse.Span {
+	return e.Src
+}
+
+func (e *NullExpr) Parse(p parse.Parser) error {
+	if unparsed, ok := e.Expr.(parse.Unparsed); ok {
+		newExpr, err := p.Parse(unparsed.Contents)
+		if err != nil {
+			return err
+		}
+		e.Expr = newExpr
+		return nil
+	}
+	return e.Expr.Parse(p)
+}
+
+// Children returns the tested expression.
+func (e *NullExpr) Children() []parse.AST {
+	return []parse.AST{e.Expr}
+}
+
+// WithChildren returns a copy of this expression with the provided tested expression.
+func (e *NullExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("NullExpr", children, 1)
+	return &NullExpr{Expr: children[0], Op: e.Op, Src: e.Src}
+}
+
+// compile sets Regexp if this is a LIKE or =~ expression whose pattern is a string literal.
+func (e *MatchExpr) compile() error {
+	unparsed, ok := e.RHS.(parse.Unparsed)
+	if !ok || len(unparsed.Contents) != 1 || unparsed.Contents[0].Quote == 0 || (e.Op != OpLike && e.Op != OpMatches) {
+		return nil
+	}
+	pattern, err := parse.Unquote(unparsed.Contents[0].Text)
+	if err != nil {
+		return err
+	}
+	e.Regexp, err = CompilePattern(e.Op, pattern)
+	return err
+}
+
+// CompilePattern compiles the pattern of a LIKE or =~ expression into a regular expression which matches the strings
+// for which the expression holds. Patterns used with any other Op are compiled as LIKE patterns.
+func CompilePattern(op Op, pattern string) (*regexp.Regexp, error) {
+	if op == OpMatches {
+		return regexp.Compile(pattern)
+	}
+	var sb strings.Builder
+	sb.WriteString("(?s)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch runes[i] {
+		case '%':
+			sb.WriteString(".*")
+		case '_':
+			sb.WriteString(".")
+		case '\\':
+			if i++; i == len(runes) {
+				return nil, errors.New("pattern ends with an escape character")
+			}
+			sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// checkChildren panics if the number of children passed to WithChildren is not as expected.
+func checkChildren(node string, children []parse.AST, expected int) {
+	if len(children) != expected {
+		panic(fmt.Sprintf("comp: %s.WithChildren called with %d children; expected %d", node, len(children), expected))
+	}
+}
+
+// Op represents one of the comparison operations recognized by this grammar.
 type Op uint8
 
 const (
@@ -84,6 +492,17 @@ const (
 	OpGreater
 	OpLessOrEqual
 	OpLess
+	OpIn
+	OpNotIn
+	OpLike
+	OpMatches
+	OpContains
+	OpStartsWith
+	OpEndsWith
+	OpBetween
+	OpNotBetween
+	OpIsNull
+	OpIsNotNull
 )
 
 func (o Op) String() string {
@@ -100,6 +519,28 @@ func (o Op) String() string {
 		return "<"
 	case OpLessOrEqual:
 		return "<="
+	case OpIn:
+		return "IN"
+	case OpNotIn:
+		return "NOT IN"
+	case OpLike:
+		return "LIKE"
+	case OpMatches:
+		return "=~"
+	case OpContains:
+		return "CONTAINS"
+	case OpStartsWith:
+		return "STARTS WITH"
+	case OpEndsWith:
+		return "ENDS WITH"
+	case OpBetween:
+		return "BETWEEN"
+	case OpNotBetween:
+		return "NOT BETWEEN"
+	case OpIsNull:
+		return "IS NULL"
+	case OpIsNotNull:
+		return "IS NOT NULL"
 	default:
 		return "unknown op"
 	}
@@ -117,29 +558,91 @@ const (
 	Less
 	OpenParen
 	CloseParen
+	In
+	NotIn
+	Comma
+	Like
+	Matches
+	Contains
+	StartsWith
+	EndsWith
+	Between
+	NotBetween
+	And
+	IsNull
+	IsNotNull
 )
 
 type ParserOpt func(*Parser)
 
-// Parser parses this grammar.
+// Parser parses this grammar. A Parser is safe for concurrent use by multiple goroutines.
 type Parser struct {
 	config          map[Token]string
 	caseInsensitive bool
+	wordBoundaries  bool
+	chained         bool
+	quotes          []rune
+
+	words     map[Token][]string // words holds the words making up each configured Token.
+	phrases   []Token            // phrases lists the configured Tokens which consist of several words.
+	matcher   *parse.KeywordTrie
+	tokenizer parse.Tokenizer
+}
 
-	matcher *parse.KeywordTrie
-	tokens  []string
-	curr    int
+// parser holds the state of a single call to Parser.Parse, so that a Parser can be used concurrently.
+type parser struct {
+	*Parser
+	tokens     []parse.Token
+	curr       int
+	expected   []Token // expected lists the tokens which failed to match at index expectedAt.
+	expectedAt int
 }
 
 // WithTokens configures the syntax used by this parser using the provided token mapping. The provided map must contain
-// distinct entries for each Token provided in this package: Equal, NotEqual, Greater, GreaterOrEqual, Less,
-// LessOrEqual, OpenParen, and CloseParen.
+// distinct entries for each of the following Tokens: Equal, NotEqual, Greater, GreaterOrEqual, Less, LessOrEqual,
+// OpenParen, and CloseParen.
+//
+// Membership tests are only recognized if the map also contains In, in which case Comma is required, and NotIn is
+// optional. Like, Matches, Contains, StartsWith and EndsWith are also optional; each string predicate is only
+// recognized if its Token is present. Range tests are only recognized if the map contains Between, in which case And
+// is required, and NotBetween is optional. IsNull and IsNotNull are also optional.
+//
+// Tokens may consist of several words separated by whitespace, such as NOT IN, which then match a sequence of tokens.
+// The words of such Tokens are only recognized together, so that NOT alone is an ordinary operand.
 func WithTokens(config map[Token]string) ParserOpt {
 	return func(parser *Parser) {
 		parser.config = config
 	}
 }
 
+// WithChainedComparisons sets whether chains of ordinal comparisons, such as 0 < x <= 10, are recognized. Chains are
+// parsed into ChainExpr nodes, which hold iff every comparison in the chain holds, as in Python. They are not
+// recognized by default, so that a < b < c is a syntax error.
+func WithChainedComparisons(chained bool) ParserOpt {
+	return func(parser *Parser) {
+		parser.chained = chained
+	}
+}
+
+// WithWordBoundaries sets whether keywords which begin or end with a letter, digit or underscore are only recognized
+// at identifier boundaries when tokenizing. Symbolic keywords such as '==' are recognized anywhere. Word boundaries
+// are required by default, so that, for instance, the identifier INPUT is not tokenized as the keyword IN followed by
+// PUT unless this option is disabled.
+func WithWordBoundaries(wordBoundaries bool) ParserOpt {
+	return func(parser *Parser) {
+		parser.wordBoundaries = wordBoundaries
+	}
+}
+
+// WithQuotes sets the runes which delimit string literals. By default, the runes in parse.DefaultQuotes are used.
+// String literals are always treated as a single token, and are never interpreted as keywords, so that the expression
+// name == "x AND (y)" contains a single comparison. Calling WithQuotes with no arguments disables string literals.
+func WithQuotes(quotes ...rune) ParserOpt {
+	return func(parser *Parser) {
+		parser.quotes = quotes
+	}
+}
+
 // WithCaseSensitive can be used to set whether this parser is case sensitive.
 func WithCaseSensitive(caseSensitive bool) ParserOpt {
 	return func(parser *Parser) {
@@ -160,8 +663,23 @@ func NewParser(opts ...ParserOpt) (*Parser, error) {
 			LessOrEqual:    "<=",
 			OpenParen:      "(",
 			CloseParen:     ")",
+			In:             "IN",
+			NotIn:          "NOT IN",
+			Comma:          ",",
+			Like:           "LIKE",
+			Matches:        "=~",
+			Contains:       "CONTAINS",
+			StartsWith:     "STARTS WITH",
+			EndsWith:       "ENDS WITH",
+			Between:        "BETWEEN",
+			NotBetween:     "NOT BETWEEN",
+			And:            "AND",
+			IsNull:         "IS NULL",
+			IsNotNull:      "IS NOT NULL",
 		},
-		matcher: &parse.KeywordTrie{},
+		matcher:        &parse.KeywordTrie{},
+		quotes:         parse.DefaultQuotes,
+		wordBoundaries: true,
 	}
 	for _, opt := range opts {
 		opt(p)
@@ -179,6 +697,40 @@ func (p *Parser) init() error {
 	if p.config[OpenParen] == p.config[CloseParen] {
 		return fmt.Errorf("%w: OpenParen and CloseParen must each be distinct", parse.ErrConfig)
 	}
+	for token := Equal; token <= CloseParen; token++ {
+		if p.config[token] == "" {
+			return fmt.Errorf("%w: missing token %d", parse.ErrConfig, token)
+		}
+	}
+	if _, ok := p.config[In]; ok && p.config[Comma] == "" {
+		return fmt.Errorf("%w: Comma is required when In is configured", parse.ErrConfig)
+	}
+	if _, ok := p.config[In]; !ok && p.config[NotIn] != "" {
+		return fmt.Errorf("%w: NotIn requires In to be configured", parse.ErrConfig)
+	}
+	if _, ok := p.config[Between]; ok && p.config[And] == "" {
+		return fmt.Errorf("%w: And is required when Between is configured", parse.ErrConfig)
+	}
+	if _, ok := p.config[Between]; !ok && p.config[NotBetween] != "" {
+		return fmt.Errorf("%w: NotBetween requires Between to be configured", parse.ErrConfig)
+	}
+	p.tokenizer = parse.Tokenizer{
+		Open:           []rune(p.config[OpenParen])[0],
+		Close:          []rune(p.config[CloseParen])[0],
+		Keywords:       p.matcher,
+		Quotes:         p.quotes,
+		WordBoundaries: p.wordBoundaries,
+	}
+	for _, q := range p.quotes {
+		if unicode.IsSpace(q) || q == p.tokenizer.Open || q == p.tokenizer.Close {
+			return fmt.Errorf("%w: quote '%c' must not be whitespace, OpenParen or CloseParen", parse.ErrConfig, q)
+		}
+		for _, str := range p.config {
+			if strings.ContainsRune(str, q) {
+				return fmt.Errorf("%w: quote '%c' must not appear in any configured token", parse.ErrConfig, q)
+			}
+		}
+	}
 	if p.caseInsensitive {
 		newTokens := make(map[Token]string, len(p.config))
 		for token, str := range p.config {
@@ -186,71 +738,212 @@ func (p *Parser) init() error {
 		}
 		p.config = newTokens
 	}
-	for _, str := range p.config {
-		p.matcher.Add(str)
-	}
-	if p.matcher.Count() != 8 {
-		return fmt.Errorf("%w: token collision detected; at least two of the provided tokens are identical", parse.ErrConfig)
+	seen := make(map[string]bool, len(p.config))
+	p.words = make(map[Token][]string, len(p.config))
+	for token, str := range p.config {
+		words := strings.Fields(str)
+		if len(words) == 0 {
+			return fmt.Errorf("%w: token %d is empty", parse.ErrConfig, token)
+		}
+		key := strings.Join(words, " ")
+		if seen[key] {
+			return fmt.Errorf("%w: token collision detected; at least two of the provided tokens are identical", parse.ErrConfig)
+		}
+		seen[key] = true
+		p.words[token] = words
+		if len(words) > 1 {
+			// the words of a phrase such as NOT IN are only keywords when they appear together
+			p.phrases = append(p.phrases, token)
+		} else {
+			p.matcher.Add(str)
+		}
 	}
+	sort.Slice(p.phrases, func(i, j int) bool {
+		return p.phrases[i] < p.phrases[j]
+	})
 	return nil
 }
 
-// ParseStr tokenizes and parses the provided string. See Parser.Parse for details.
+// TermOperators returns the configured operators which consist of several words, such as NOT IN, and so may contain
+// keywords of other grammars. Range tests are included as BETWEEN ... AND, where ... stands for the lower bound. They
+// are intended for use with bools.WithTermOperators, so that the bools package leaves such operators within a term for
+// this parser to consume.
+func (p *Parser) TermOperators() []string {
+	ops := make([]string, 0, len(p.phrases)+2)
+	for _, token := range p.phrases {
+		if token != NotBetween {
+			ops = append(ops, strings.Join(p.words[token], " "))
+		}
+	}
+	for _, token := range []Token{Between, NotBetween} {
+		if _, ok := p.words[token]; ok {
+			ops = append(ops, strings.Join(p.words[token], " ")+" ... "+strings.Join(p.words[And], " "))
+		}
+	}
+	return ops
+}
+
+// ParseStr tokenizes and parses the provided string. See Parser.Parse for details. Any *parse.SyntaxError returned
+// includes the provided string as its Source.
 func (p *Parser) ParseStr(str string) (parse.AST, error) {
-	return p.Parse(p.tokenize(str))
+	tokens, err := p.tokenize(str)
+	var ast parse.AST
+	if err == nil {
+		ast, err = (&parser{Parser: p, tokens: tokens, expectedAt: -1}).parse()
+	}
+	var syntaxErr *parse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Source = str
+	}
+	return ast, err
+}
+
+// Parse parses the provided list of tokens, producing a parse.AST. The tokens are first split further at each keyword
+// of this grammar, since they may have been produced by another parser's tokenizer. A *parse.SyntaxError is returned
+// if the provided tokens do not conform to the grammar specified in this package.
+func (p *Parser) Parse(tokens []parse.Token) (parse.AST, error) {
+	tokens, err := p.tokenizer.Retokenize(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return (&parser{Parser: p, tokens: tokens, expectedAt: -1}).parse()
 }
 
-// Parse parses the provided list of tokens, producing a parse.AST. An error is returned if the provided tokens do not
-// conform to the grammar specified in this package.
-func (p *Parser) Parse(tokens []string) (parse.AST, error) {
-	p.curr = 0
-	p.tokens = tokens
+func (p *parser) parse() (parse.AST, error) {
 	ast, err := p.parseExpr()
 	if err != nil {
 		return nil, err
 	}
 	if p.curr != len(p.tokens) {
-		return nil, fmt.Errorf("%w: expected end of expression; found '%s'", parse.ErrParse, p.tokens[p.curr])
+		return nil, p.errorf("expected end of expression; found '%s'", p.peek().Text)
 	}
 	return ast, nil
 }
 
-func (p *Parser) tokenize(str string) []string {
-	openP, closeP := []rune(p.config[OpenParen])[0], []rune(p.config[CloseParen])[0]
-	return parse.Tokenize(str, openP, closeP, p.matcher)
+func (p *Parser) tokenize(str string) ([]parse.Token, error) {
+	return p.tokenizer.Tokenize(str)
 }
 
-func (p *Parser) isKeyword(str string) bool {
-	if p.caseInsensitive {
-		str = strings.ToLower(str)
+// isKeyword reports whether the token at index i is a keyword, or begins a Token consisting of several words.
+func (p *parser) isKeyword(i int) bool {
+	if i >= len(p.tokens) {
+		return false
 	}
-	return p.matcher.Contains(str)
+	if p.isWord(p.tokens[i]) {
+		return true
+	}
+	for _, token := range p.phrases {
+		if p.is(i, token) {
+			return true
+		}
+	}
+	return false
 }
 
-func (p *Parser) match(token Token) bool {
-	if p.curr == len(p.tokens) {
+// isWord reports whether the provided token is a keyword consisting of a single word.
+func (p *Parser) isWord(token parse.Token) bool {
+	if token.Quote != 0 {
 		return false
 	}
-	curr := p.tokens[p.curr]
+	str := token.Text
 	if p.caseInsensitive {
-		curr = strings.ToLower(curr)
+		str = strings.ToLower(str)
 	}
-	if curr == p.config[token] {
-		p.curr++
+	return p.matcher.Contains(str)
+}
+
+func (p *parser) match(token Token) bool {
+	if n := p.width(p.curr, token); n > 0 {
+		p.curr += n
 		return true
 	}
+	if p.words[token] != nil {
+		p.expect(token)
+	}
 	return false
 }
 
-func (p *Parser) peek() string {
+// is reports whether the tokens at index i make up the provided Token.
+func (p *parser) is(i int, token Token) bool {
+	return p.width(i, token) > 0
+}
+
+// width returns the number of tokens at index i which make up the provided Token, or 0 if they do not match it.
+func (p *parser) width(i int, token Token) int {
+	words := p.words[token]
+	for j, word := range words {
+		if i+j >= len(p.tokens) || p.tokens[i+j].Quote != 0 {
+			return 0
+		}
+		curr := p.tokens[i+j].Text
+		if p.caseInsensitive {
+			curr = strings.ToLower(curr)
+		}
+		if curr != word {
+			return 0
+		}
+	}
+	return len(words)
+}
+
+// groupEnd returns the index of the CloseParen matching the OpenParen at index i, or the number of tokens if it is
+// not closed. If the token at index i is not an OpenParen, -1 is returned.
+func (p *parser) groupEnd(i int) int {
+	if !p.is(i, OpenParen) {
+		return -1
+	}
+	depth := 0
+	for ; i < len(p.tokens); i++ {
+		if p.is(i, OpenParen) {
+			depth++
+		} else if p.is(i, CloseParen) {
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(p.tokens)
+}
+
+// expect records that the provided token was expected at the current position, for use in error messages.
+func (p *parser) expect(token Token) {
+	if p.expectedAt != p.curr {
+		p.expected, p.expectedAt = p.expected[:0], p.curr
+	}
+	for _, t := range p.expected {
+		if t == token {
+			return
+		}
+	}
+	p.expected = append(p.expected, token)
+}
+
+// errorf returns a *parse.SyntaxError located at the current token.
+func (p *parser) errorf(format string, args ...any) error {
+	var expected []string
+	if p.expectedAt == p.curr {
+		for _, token := range p.expected {
+			expected = append(expected, p.config[token])
+		}
+	}
+	return parse.NewSyntaxError(p.tokens, p.curr, expected, format, args...)
+}
+
+func (p *parser) peek() parse.Token {
 	return p.tokens[p.curr]
 }
 
-func (p *Parser) parseExpr() (parse.AST, error) {
+// span returns the location of the tokens from start up to the current token.
+func (p *parser) span(start int) parse.Span {
+	return parse.Span{Start: p.tokens[start].Src.Start, End: p.tokens[p.curr-1].Src.End}
+}
+
+func (p *parser) parseExpr() (parse.AST, error) {
 	return p.parseEqual()
 }
 
-func (p *Parser) parseEqual() (parse.AST, error) {
+func (p *parser) parseEqual() (parse.AST, error) {
+	start := p.curr
 	lhs, err := p.parseOrdinal()
 	if err != nil {
 		return nil, err
@@ -260,54 +953,147 @@ func (p *Parser) parseEqual() (parse.AST, error) {
 		if err != nil {
 			return nil, err
 		}
-		return &EqualExpr{LHS: lhs, RHS: rhs, Op: tokenToOp(op)}, nil
+		return &EqualExpr{LHS: lhs, RHS: rhs, Op: tokenToOp(op), Src: p.span(start)}, nil
 	}
 	return lhs, nil
 }
 
-func (p *Parser) parseOrdinal() (parse.AST, error) {
+func (p *parser) parseOrdinal() (parse.AST, error) {
+	start := p.curr
 	lhs, err := p.parseTerm()
 	if err != nil {
 		return nil, err
 	}
-	if op := p.matchOps(GreaterOrEqual, LessOrEqual, Greater, Less); op != 0 {
+	if op := p.matchOrdinal(); op != 0 {
 		rhs, err := p.parseTerm()
 		if err != nil {
 			return nil, err
 		}
-		return &OrdinalExpr{LHS: lhs, RHS: rhs, Op: tokenToOp(op)}, nil
+		if !p.chained {
+			return &OrdinalExpr{LHS: lhs, RHS: rhs, Op: tokenToOp(op), Src: p.span(start)}, nil
+		}
+		return p.parseChain(start, lhs, rhs, tokenToOp(op))
+	}
+	if op := p.matchOps(Between, NotBetween); op != 0 {
+		lower, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		if !p.match(And) {
+			return nil, p.errorf("expected '%s'", p.config[And])
+		}
+		upper, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{Expr: lhs, Lower: lower, Upper: upper, Op: tokenToOp(op), Src: p.span(start)}, nil
+	}
+	if op := p.matchOps(In, NotIn); op != 0 {
+		rhs, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &InExpr{LHS: lhs, RHS: rhs, Op: tokenToOp(op), Src: p.span(start)}, nil
+	}
+	if op := p.matchOps(Like, Matches, Contains, StartsWith, EndsWith); op != 0 {
+		rhsStart := p.curr
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		expr := &MatchExpr{LHS: lhs, RHS: rhs, Op: tokenToOp(op), Src: p.span(start)}
+		if err := expr.compile(); err != nil {
+			return nil, parse.NewSyntaxError(p.tokens, rhsStart, nil, "invalid pattern %s: %v", p.tokens[rhsStart].Text, err)
+		}
+		return expr, nil
+	}
+	if op := p.matchOps(IsNull, IsNotNull); op != 0 {
+		return &NullExpr{Expr: lhs, Op: tokenToOp(op), Src: p.span(start)}, nil
 	}
 	return lhs, nil
 }
 
-func (p *Parser) parseTerm() (parse.AST, error) {
-	if p.match(OpenParen) {
-		ast, err := p.parseExpr()
+// parseChain parses the rest of a chain of ordinal comparisons whose first comparison has been parsed. A single
+// comparison is returned as an OrdinalExpr.
+func (p *parser) parseChain(start int, lhs, rhs parse.AST, op Op) (parse.AST, error) {
+	operands, ops := []parse.AST{lhs, rhs}, []Op{op}
+	for token := p.matchOrdinal(); token != 0; token = p.matchOrdinal() {
+		operand, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		operands, ops = append(operands, operand), append(ops, tokenToOp(token))
+	}
+	if len(ops) == 1 {
+		return &OrdinalExpr{LHS: lhs, RHS: rhs, Op: op, Src: p.span(start)}, nil
+	}
+	return &ChainExpr{Operands: operands, Ops: ops, Src: p.span(start)}, nil
+}
+
+func (p *parser) parseList() (parse.AST, error) {
+	// as in parseTerm, a group followed by more of the same term, as in (a + b) * c, is left for later consumption
+	if end := p.groupEnd(p.curr); end < 0 || end+1 < len(p.tokens) && !p.isKeyword(end+1) {
+		return p.parseTerm()
+	}
+	start := p.curr
+	p.match(OpenParen)
+	var elems []parse.AST
+	for !p.match(CloseParen) {
+		if len(elems) > 0 && !p.match(Comma) {
+			return nil, p.errorf("expected '%s' or '%s'", p.config[Comma], p.config[CloseParen])
+		}
+		elem, err := p.parseTerm()
 		if err != nil {
 			return nil, err
 		}
-		if !p.match(CloseParen) {
-			return nil, fmt.Errorf("%w: expected '%s'", parse.ErrParse, p.config[CloseParen])
+		elems = append(elems, elem)
+	}
+	return &List{Elems: elems, Src: p.span(start)}, nil
+}
+
+func (p *parser) parseTerm() (parse.AST, error) {
+	// a group followed by more of the same term, as in (a + b) * c, is left for later consumption
+	if end := p.groupEnd(p.curr); end < 0 || end+1 >= len(p.tokens) || p.isKeyword(end+1) {
+		if p.match(OpenParen) {
+			ast, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.match(CloseParen) {
+				return nil, p.errorf("expected '%s'", p.config[CloseParen])
+			}
+			return ast, nil
 		}
-		return ast, nil
 	}
 	return p.parseRest()
 }
 
-func (p *Parser) parseRest() (parse.AST, error) {
-	var result []string
-	for p.curr < len(p.tokens) && !p.isKeyword(p.peek()) {
+func (p *parser) parseRest() (parse.AST, error) {
+	var result []parse.Token
+	for p.curr < len(p.tokens) {
+		if end := p.groupEnd(p.curr); end >= 0 && end < len(p.tokens) {
+			// keep balanced groups which form part of a term, such as f(x)
+			result = append(result, p.tokens[p.curr:end+1]...)
+			p.curr = end + 1
+			continue
+		}
+		if p.isKeyword(p.curr) {
+			break
+		}
 		result = append(result, p.peek())
 		p.curr++
 	}
 	if result == nil {
-		return nil, fmt.Errorf("%w: unexpected end of expression", parse.ErrParse)
+		if p.curr < len(p.tokens) {
+			return nil, p.errorf("unexpected '%s'", p.peek().Text)
+		}
+		return nil, p.errorf("unexpected end of expression")
 	}
 	return parse.Unparsed{Contents: result}, nil
 }
 
 // matchOps attempts to match all of the provided ops in order, returning the first one matched. If none match, 0 is returned.
-func (p *Parser) matchOps(ops ...Token) Token {
+func (p *parser) matchOps(ops ...Token) Token {
 	for _, op := range ops {
 		if p.match(op) {
 			return op
@@ -316,6 +1102,11 @@ func (p *Parser) matchOps(ops ...Token) Token {
 	return 0
 }
 
+// matchOrdinal attempts to match an ordinal comparison operator, returning 0 if there is none.
+func (p *parser) matchOrdinal() Token {
+	return p.matchOps(GreaterOrEqual, LessOrEqual, Greater, Less)
+}
+
 func tokenToOp(t Token) Op {
 	switch t {
 	case Equal:
@@ -330,6 +1121,198 @@ func tokenToOp(t Token) Op {
 		return OpLess
 	case LessOrEqual:
 		return OpLessOrEqual
+	case In:
+		return OpIn
+	case NotIn:
+		return OpNotIn
+	case Like:
+		return OpLike
+	case Matches:
+		return OpMatches
+	case Contains:
+		return OpContains
+	case StartsWith:
+		return OpStartsWith
+	case EndsWith:
+		return OpEndsWith
+	case Between:
+		return OpBetween
+	case NotBetween:
+		return OpNotBetween
+	case IsNull:
+		return OpIsNull
+	case IsNotNull:
+		return OpIsNotNull
+	}
+	return 0
+}
+
+func opToToken(op Op) Token {
+	switch op {
+	case OpEqual:
+		return Equal
+	case OpNotEqual:
+		return NotEqual
+	case OpGreater:
+		return Greater
+	case OpGreaterOrEqual:
+		return GreaterOrEqual
+	case OpLess:
+		return Less
+	case OpLessOrEqual:
+		return LessOrEqual
+	case OpIn:
+		return In
+	case OpNotIn:
+		return NotIn
+	case OpLike:
+		return Like
+	case OpMatches:
+		return Matches
+	case OpContains:
+		return Contains
+	case OpStartsWith:
+		return StartsWith
+	case OpEndsWith:
+		return EndsWith
+	case OpBetween:
+		return Between
+	case OpNotBetween:
+		return NotBetween
+	case OpIsNull:
+		return IsNull
+	case OpIsNotNull:
+		return IsNotNull
 	}
 	return 0
 }
+
+// precOrdinal is the precedence of every comparison other than equality, which binds less tightly.
+const precOrdinal = parse.PrecComp + 10
+
+// Print implements parse.Printer, rendering the nodes of this package using the syntax configured for this Parser.
+// Parentheses are only added where they are required, so that parsing the resulting text using this Parser produces
+// the original AST.
+func (p *Parser) Print(ast parse.AST, f *parse.Formatter) (string, int, error) {
+	switch ast := ast.(type) {
+	case *EqualExpr:
+		return p.printBinary(ast.LHS, ast.RHS, ast.Op, parse.PrecComp, f)
+	case *OrdinalExpr:
+		return p.printBinary(ast.LHS, ast.RHS, ast.Op, precOrdinal, f)
+	case *MatchExpr:
+		return p.printBinary(ast.LHS, ast.RHS, ast.Op, precOrdinal, f)
+	case *ChainExpr:
+		return p.printChain(ast, f)
+	case *BetweenExpr:
+		token := opToToken(ast.Op)
+		if (ast.Op != OpBetween && ast.Op != OpNotBetween) || p.words[token] == nil {
+			return "", 0, fmt.Errorf("cannot print unknown operator: %v", ast.Op)
+		}
+		var texts [3]string
+		for i, child := range ast.Children() {
+			text, err := f.Operand(child, precOrdinal+1, p.config[OpenParen], p.config[CloseParen])
+			if err != nil {
+				return "", 0, err
+			}
+			texts[i] = text
+		}
+		text := texts[0] + " " + p.config[token] + " " + texts[1] + " " + p.config[And] + " " + texts[2]
+		return text, precOrdinal, nil
+	case *NullExpr:
+		token := opToToken(ast.Op)
+		if (ast.Op != OpIsNull && ast.Op != OpIsNotNull) || p.words[token] == nil {
+			return "", 0, fmt.Errorf("cannot print unknown operator: %v", ast.Op)
+		}
+		text, err := f.Operand(ast.Expr, precOrdinal+1, p.config[OpenParen], p.config[CloseParen])
+		if err != nil {
+			return "", 0, err
+		}
+		return text + " " + p.config[token], precOrdinal, nil
+	case *InExpr:
+		if _, ok := ast.RHS.(*List); !ok {
+			// a parenthesized right-hand side would be read as a list
+			if _, prec, err := f.Print(ast.RHS); err != nil || prec <= precOrdinal {
+				return "", 0, fmt.Errorf("cannot print %v with right-hand side %v", ast.Op, ast.RHS)
+			}
+		}
+		return p.printBinary(ast.LHS, ast.RHS, ast.Op, precOrdinal, f)
+	case *List:
+		if p.words[Comma] == nil {
+			return "", 0, errors.New("cannot print list without a configured Comma")
+		}
+		var sb strings.Builder
+		sb.WriteString(p.config[OpenParen])
+		for i, elem := range ast.Elems {
+			if i > 0 {
+				sb.WriteString(p.config[Comma] + " ")
+			}
+			text, err := f.Operand(elem, precOrdinal+1, p.config[OpenParen], p.config[CloseParen])
+			if err != nil {
+				return "", 0, err
+			}
+			sb.WriteString(text)
+		}
+		sb.WriteString(p.config[CloseParen])
+		return sb.String(), parse.PrecAtom, nil
+	default:
+		return "", 0, fmt.Errorf("%w: %T", parse.ErrUnknownAST, ast)
+	}
+}
+
+// printChain prints a chain of ordinal comparisons, which can only be parsed if chained comparisons are enabled.
+func (p *Parser) printChain(chain *ChainExpr, f *parse.Formatter) (string, int, error) {
+	if !p.chained {
+		return "", 0, errors.New("cannot print chained comparison without WithChainedComparisons")
+	}
+	if len(chain.Operands) != len(chain.Ops)+1 || len(chain.Ops) == 0 {
+		return "", 0, fmt.Errorf("cannot print chain of %d operands and %d operators", len(chain.Operands), len(chain.Ops))
+	}
+	open, close := p.config[OpenParen], p.config[CloseParen]
+	var sb strings.Builder
+	for i, operand := range chain.Operands {
+		if i > 0 {
+			op := chain.Ops[i-1]
+			if op != OpGreater && op != OpGreaterOrEqual && op != OpLess && op != OpLessOrEqual {
+				return "", 0, fmt.Errorf("cannot print chain containing operator: %v", op)
+			}
+			sb.WriteString(" " + p.config[opToToken(op)] + " ")
+		}
+		text, err := f.Operand(operand, precOrdinal+1, open, close)
+		if err != nil {
+			return "", 0, err
+		}
+		sb.WriteString(text)
+	}
+	return sb.String(), precOrdinal, nil
+}
+
+// printBinary prints a comparison with the provided precedence. Comparisons are not associative, so both operands
+// must bind more tightly than the comparison itself.
+func (p *Parser) printBinary(lhs, rhs parse.AST, op Op, prec int, f *parse.Formatter) (string, int, error) {
+	token := opToToken(op)
+	if token == 0 || p.words[token] == nil {
+		return "", 0, fmt.Errorf("cannot print unknown operator: %v", op)
+	}
+	open, close := p.config[OpenParen], p.config[CloseParen]
+	lhsText, err := f.Operand(lhs, prec+1, open, close)
+	if err != nil {
+		return "", 0, err
+	}
+	rhsText, err := f.Operand(rhs, prec+1, open, close)
+	if err != nil {
+		return "", 0, err
+	}
+	return lhsText + " " + p.config[token] + " " + rhsText, prec, nil
+}
+
+// defaultParser is used to implement the String method of each node.
+var defaultParser, _ = NewParser()
+
+// format returns the text of the provided AST in the default syntax, or a description of the error encountered.
+func format(ast parse.AST) string {
+	text, err := parse.Format(ast, defaultParser)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return text
+}
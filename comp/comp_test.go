@@ -3,8 +3,10 @@ package comp
 import (
 	"fmt"
 	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/asttest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
 )
 
@@ -31,13 +33,27 @@ func TestParser_tokenize(t *testing.T) {
 		{
 			"x > 3 > t > 7", []string{"x", ">", "3", ">", "t", ">", "7"},
 		},
+		{
+			`msg == 'a (b) == c'`, []string{"msg", "==", "'a (b) == c'"},
+		},
+		{
+			"x NOT IN('a','b')", []string{"x", "NOT", "IN", "(", "'a'", ",", "'b'", ")"},
+		},
+		{
+			"INPUT IN INDEX", []string{"INPUT", "IN", "INDEX"},
+		},
+		{
+			"x=~'a+' AND x STARTS WITH y", []string{"x", "=~", "'a+'", "AND", "x", "STARTS", "WITH", "y"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
 			p, err := NewParser()
 			require.NoError(t, err)
-			assert.EqualValues(t, tt.output, p.tokenize(tt.input))
+			tokens, err := p.tokenize(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, texts(tokens))
 		})
 	}
 }
@@ -55,6 +71,26 @@ func TestParser_Parse(t *testing.T) {
 			"abc == (x > 3)",
 			eq(un("abc"), gt(un("x"), un("3"))),
 		},
+		{
+			"x == NOT",
+			eq(un("x"), un("NOT")),
+		},
+		{
+			"IS == 1",
+			eq(un("IS"), un("1")),
+		},
+		{
+			"x STARTS y != NULL",
+			neq(un("x", "STARTS", "y"), un("NULL")),
+		},
+		{
+			"x NOT y == WITH",
+			eq(un("x", "NOT", "y"), un("WITH")),
+		},
+		{
+			"x NOT IN y == (z STARTS WITH w)",
+			eq(&InExpr{LHS: un("x"), RHS: un("y"), Op: OpNotIn}, &MatchExpr{LHS: un("z"), RHS: un("w"), Op: OpStartsWith}),
+		},
 		{
 			"x == (y == 3)",
 			eq(un("x"), eq(un("y"), un("3"))),
@@ -63,10 +99,78 @@ func TestParser_Parse(t *testing.T) {
 			"x > (y > 3)",
 			gt(un("x"), gt(un("y"), un("3"))),
 		},
+		{
+			`("a)" >= 'b<=c') == x`,
+			eq(gte(un(`"a)"`), un("'b<=c'")), un("x")),
+		},
 		{
 			"(x > 3) == ((y == 3) != ((z < 8) == (y <= 4)))",
 			eq(gt(un("x"), un("3")), neq(eq(un("y"), un("3")), eq(lt(un("z"), un("8")), lte(un("y"), un("4"))))),
 		},
+		{
+			"(a + b) * 2 > f(x, (y))",
+			gt(un("(", "a", "+", "b", ")", "*", "2"), un("f", "(", "x", ",", "(", "y", ")", ")")),
+		},
+		{
+			"((a) + 1 == b)",
+			eq(un("(", "a", ")", "+", "1"), un("b")),
+		},
+		{
+			"status IN ('A', 'B', 'C')",
+			in(un("status"), list(un("'A'"), un("'B'"), un("'C'"))),
+		},
+		{
+			"x NOT IN (a + 1, f(b, c), (y))",
+			notIn(un("x"), list(un("a", "+", "1"), un("f", "(", "b", ",", "c", ")"), un("y"))),
+		},
+		{
+			"x IN () == (y IN (1))",
+			eq(in(un("x"), list()), in(un("y"), list(un("1")))),
+		},
+		{
+			"x IN ((a > 1), (b))",
+			in(un("x"), list(gt(un("a"), un("1")), un("b"))),
+		},
+		{
+			"x IN ${values}",
+			in(un("x"), un("${values}")),
+		},
+		{
+			"x IN (a + b) * 2",
+			in(un("x"), un("(", "a", "+", "b", ")", "*", "2")),
+		},
+		{
+			"(x IN (1)) != y",
+			neq(in(un("x"), list(un("1"))), un("y")),
+		},
+		{
+			`name LIKE 'task\\_%'`,
+			pred(OpLike, un("name"), un(`'task\\_%'`)),
+		},
+		{
+			"name =~ `^t[0-9]+$` == (x CONTAINS y)",
+			eq(pred(OpMatches, un("name"), un("`^t[0-9]+$`")), pred(OpContains, un("x"), un("y"))),
+		},
+		{
+			"f(x) STARTS WITH 'a' != name ENDS WITH ${suffix}",
+			neq(pred(OpStartsWith, un("f", "(", "x", ")"), un("'a'")), pred(OpEndsWith, un("name"), un("${suffix}"))),
+		},
+		{
+			"x BETWEEN 1 AND 10",
+			between(un("x"), un("1"), un("10")),
+		},
+		{
+			"x NOT BETWEEN a + 1 AND f(b) == (y BETWEEN (a) AND (b > c))",
+			eq(notBetween(un("x"), un("a", "+", "1"), un("f", "(", "b", ")")), between(un("y"), un("a"), gt(un("b"), un("c")))),
+		},
+		{
+			"${task?.output} IS NULL",
+			isNull(un("${task?.output}")),
+		},
+		{
+			"f(x) IS NOT NULL == (y IS NULL)",
+			eq(isNotNull(un("f", "(", "x", ")")), isNull(un("y"))),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
@@ -74,11 +178,60 @@ func TestParser_Parse(t *testing.T) {
 			require.NoError(t, err)
 			ast, err := p.ParseStr(tt.input)
 			require.NoError(t, err)
-			assert.EqualValues(t, tt.output, ast)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
 		})
 	}
 }
 
+func TestParser_Span(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.ParseStr("abc == (x >= 3)")
+	require.NoError(t, err)
+
+	root := ast.(*EqualExpr)
+	assert.Equal(t, 0, root.Span().Start.Offset)
+	assert.Equal(t, 15, root.Span().End.Offset)
+	assert.Equal(t, parse.Span{Start: parse.Pos{Offset: 8, Rune: 8, Line: 1, Col: 9}, End: parse.Pos{Offset: 14, Rune: 14, Line: 1, Col: 15}}, parse.SpanOf(root.RHS))
+	assert.Equal(t, "x", root.RHS.(*OrdinalExpr).LHS.(parse.Unparsed).Contents[0].Text)
+}
+
+func TestParser_Concurrent(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST // output is nil if an error is expected
+	}{
+		{"abc >= def", gte(un("abc"), un("def"))},
+		{"abc == (x > 3)", eq(un("abc"), gt(un("x"), un("3")))},
+		{`x != "a == b"`, neq(un("x"), un(`"a == b"`))},
+		{"(x > (7 == 5) < 12)", nil},
+		{"!= > 7", nil},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tt := tests[(g+i)%len(tests)]
+				ast, err := p.ParseStr(tt.input)
+				if tt.output == nil {
+					assert.ErrorIs(t, err, parse.ErrParse, tt.input)
+					continue
+				}
+				if assert.NoError(t, err, tt.input) {
+					assert.EqualValues(t, tt.output, asttest.StripSpans(ast), tt.input)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
 func TestParser_ParseError(t *testing.T) {
 	tests := []string{
 		"x >",
@@ -88,6 +241,21 @@ func TestParser_ParseError(t *testing.T) {
 		"(((x > 5))",
 		"(x > (7 == 5) < 12)",
 		"==!",
+		"x IN",
+		"x IN (a,)",
+		"x IN (a b",
+		"x IN a, b",
+		"a, b",
+		"x LIKE",
+		"x =~ '('",
+		`x LIKE 'abc\\'`,
+		"x BETWEEN 1",
+		"x BETWEEN 1 10",
+		"x BETWEEN AND 10",
+		"x BETWEEN 1 AND",
+		"0 < x <= 10",
+		"IS NULL",
+		"x IS NULL y",
 	}
 	for _, tt := range tests {
 		t.Run(tt, func(t *testing.T) {
@@ -99,6 +267,25 @@ func TestParser_ParseError(t *testing.T) {
 	}
 }
 
+func TestParser_SyntaxError(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	_, err = p.ParseStr("(x > (7 == 5) < 12)")
+	var syntaxErr *parse.SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 8, syntaxErr.Index)
+	assert.Equal(t, "<", syntaxErr.Found)
+	assert.Equal(t, []string{"==", "!=", ")"}, syntaxErr.Expected)
+	assert.Equal(t, "error parsing: expected ')' at 1:15", err.Error())
+	assert.Equal(t, "(x > (7 == 5) < 12)\n              ^", syntaxErr.Caret())
+
+	_, err = p.ParseStr("name =~ 'a(b'")
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 2, syntaxErr.Index)
+	assert.Equal(t, "error parsing: invalid pattern 'a(b': error parsing regexp: missing closing ): `a(b` at 1:9", err.Error())
+}
+
 func TestWithTokens(t *testing.T) {
 	p, err := NewParser(WithTokens(map[Token]string{
 		Equal:          "EQ",
@@ -129,14 +316,73 @@ func TestWithTokens(t *testing.T) {
 		t.Run(tt.input, func(t *testing.T) {
 			ast, err := p.ParseStr(tt.input)
 			assert.NoError(t, err)
-			assert.EqualValues(t, tt.output, ast)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
 		})
 	}
 
+	p, err = NewParser(WithWordBoundaries(true), WithTokens(map[Token]string{
+		Equal:          "EQ",
+		NotEqual:       "NE",
+		Greater:        "GT",
+		GreaterOrEqual: "GE",
+		Less:           "LT",
+		LessOrEqual:    "LE",
+		OpenParen:      "(",
+		CloseParen:     ")",
+	}))
+	require.NoError(t, err)
+	ast, err := p.ParseStr("EQUIPMENT EQ (NEST GT(LTE))")
+	require.NoError(t, err)
+	assert.EqualValues(t, eq(un("EQUIPMENT"), gt(un("NEST"), un("LTE"))), asttest.StripSpans(ast))
+
+	p, err = NewParser(WithCaseSensitive(false), WithTokens(map[Token]string{
+		Equal:          "=",
+		NotEqual:       "<>",
+		Greater:        ">",
+		GreaterOrEqual: ">=",
+		Less:           "<",
+		LessOrEqual:    "<=",
+		OpenParen:      "(",
+		CloseParen:     ")",
+		In:             "ONE OF",
+		NotIn:          "NONE  OF",
+		Comma:          ";",
+	}))
+	require.NoError(t, err)
+	ast, err = p.ParseStr("x one of (1; 2) <> (y none of (3))")
+	require.NoError(t, err)
+	assert.EqualValues(t, neq(in(un("x"), list(un("1"), un("2"))), notIn(un("y"), list(un("3")))), asttest.StripSpans(ast))
+
+	// membership tests are disabled unless In is configured
+	p, err = NewParser(WithTokens(map[Token]string{
+		Equal:          "==",
+		NotEqual:       "!=",
+		Greater:        ">",
+		GreaterOrEqual: ">=",
+		Less:           "<",
+		LessOrEqual:    "<=",
+		OpenParen:      "(",
+		CloseParen:     ")",
+	}))
+	require.NoError(t, err)
+	ast, err = p.ParseStr("x IN (a, b) == y")
+	require.NoError(t, err)
+	assert.EqualValues(t, eq(un("x", "IN", "(", "a,", "b", ")"), un("y")), asttest.StripSpans(ast))
+	_, err = parse.Format(in(un("x"), list(un("a"))), p)
+	assert.Error(t, err)
+	_, err = parse.Format(pred(OpLike, un("x"), un("'a'")), p)
+	assert.Error(t, err)
+
 	testWithErrors := []map[Token]string{
 		{Equal: "=="},
+		{Equal: "==", NotEqual: "!=", LessOrEqual: "<=", Less: "<", Greater: ">", GreaterOrEqual: ">=", OpenParen: "(", CloseParen: ")", In: "IN"},
+		{Equal: "==", NotEqual: "!=", LessOrEqual: "<=", Less: "<", Greater: ">", GreaterOrEqual: ">=", OpenParen: "(", CloseParen: ")", NotIn: "NOT IN", Comma: ","},
+		{Equal: "==", NotEqual: "!=", LessOrEqual: "<=", Less: "<", Greater: ">", GreaterOrEqual: ">=", OpenParen: "(", CloseParen: ")", In: "IN", NotIn: "IN", Comma: ","},
+		{Equal: "==", NotEqual: "!=", LessOrEqual: "<=", Less: "<", Greater: ">", GreaterOrEqual: ">=", OpenParen: "(", CloseParen: ")", In: " ", Comma: ","},
 		{Equal: "==", NotEqual: "==", LessOrEqual: "<=", Less: "<", Greater: ">", GreaterOrEqual: ">=", OpenParen: "(", CloseParen: ")"},
 		{Equal: "==", NotEqual: "!=", LessOrEqual: "<=", Less: "<", Greater: ">", GreaterOrEqual: ">=", OpenParen: ":", CloseParen: ":"},
+		{Equal: "==", NotEqual: "!=", LessOrEqual: "<=", Less: "<", Greater: ">", GreaterOrEqual: ">=", OpenParen: "(", CloseParen: ")", Between: "BETWEEN"},
+		{Equal: "==", NotEqual: "!=", LessOrEqual: "<=", Less: "<", Greater: ">", GreaterOrEqual: ">=", OpenParen: "(", CloseParen: ")", NotBetween: "NOT BETWEEN", And: "AND"},
 	}
 	for idx, config := range testWithErrors {
 		t.Run(fmt.Sprintf("error case %d", idx), func(t *testing.T) {
@@ -146,6 +392,199 @@ func TestWithTokens(t *testing.T) {
 	}
 }
 
+func TestParser_TermOperators(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"NOT IN", "STARTS WITH", "ENDS WITH", "IS NULL", "IS NOT NULL", "BETWEEN ... AND", "NOT BETWEEN ... AND",
+	}, p.TermOperators())
+
+	p, err = NewParser(WithTokens(map[Token]string{
+		Equal: "==", NotEqual: "!=", Greater: ">", GreaterOrEqual: ">=", Less: "<", LessOrEqual: "<=",
+		OpenParen: "(", CloseParen: ")", In: "in", NotIn: "not  in", Comma: ",",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"not in"}, p.TermOperators())
+}
+
+func TestParser_Print(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	custom, err := NewParser(WithTokens(map[Token]string{
+		Equal:          "=",
+		NotEqual:       "<>",
+		Greater:        "gt",
+		GreaterOrEqual: "ge",
+		Less:           "lt",
+		LessOrEqual:    "le",
+		OpenParen:      "[",
+		CloseParen:     "]",
+		In:             "in",
+		NotIn:          "not in",
+		Comma:          ";",
+		Like:           "like",
+		Matches:        "~",
+		Contains:       "contains",
+		StartsWith:     "starts with",
+		EndsWith:       "ends with",
+		Between:        "between",
+		NotBetween:     "not between",
+		And:            "and",
+		IsNull:         "is null",
+		IsNotNull:      "is not null",
+	}))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  parse.AST
+		output string
+		custom string
+	}{
+		{eq(un("a"), un("b")), "a == b", "a = b"},
+		{neq(lt(un("a"), un("b")), un("c")), "a < b != c", "a lt b <> c"},
+		{eq(un("a"), gte(un("b"), un("c"))), "a == b >= c", "a = b ge c"},
+		{eq(eq(un("a"), un("b")), un("c")), "(a == b) == c", "[a = b] = c"},
+		{lte(gt(un("a"), un("b")), un("c")), "(a > b) <= c", "[a gt b] le c"},
+		{gt(un("a"), neq(un("b"), un("'c d'"))), "a > (b != 'c d')", "a gt [b <> 'c d']"},
+		{in(un("a"), list(un("1"), un("'x'"))), "a IN (1, 'x')", "a in [1; 'x']"},
+		{eq(notIn(un("a"), list()), un("b")), "a NOT IN () == b", "a not in [] = b"},
+		{in(eq(un("a"), un("b")), list(gt(un("c"), un("d")), un("e"))), "(a == b) IN ((c > d), e)", "[a = b] in [[c gt d]; e]"},
+		{notIn(un("a"), un("${b}")), "a NOT IN ${b}", "a not in ${b}"},
+		{pred(OpLike, un("a"), un("'%b'")), "a LIKE '%b'", "a like '%b'"},
+		{eq(pred(OpMatches, un("a"), un("'b'")), pred(OpStartsWith, un("c"), un("d"))), "a =~ 'b' == c STARTS WITH d", "a ~ 'b' = c starts with d"},
+		{pred(OpEndsWith, eq(un("a"), un("b")), pred(OpContains, un("c"), un("d"))), "(a == b) ENDS WITH (c CONTAINS d)", "[a = b] ends with [c contains d]"},
+		{between(un("a"), un("1"), un("2")), "a BETWEEN 1 AND 2", "a between 1 and 2"},
+		{neq(notBetween(eq(un("a"), un("b")), lt(un("c"), un("d")), un("e")), un("f")), "(a == b) NOT BETWEEN (c < d) AND e != f", "[a = b] not between [c lt d] and e <> f"},
+		{isNull(un("a")), "a IS NULL", "a is null"},
+		{eq(isNotNull(eq(un("a"), un("b"))), un("c")), "(a == b) IS NOT NULL == c", "[a = b] is not null = c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			for _, c := range []struct {
+				p        *Parser
+				expected string
+			}{{p, tt.output}, {custom, tt.custom}} {
+				text, err := parse.Format(tt.input, c.p)
+				require.NoError(t, err)
+				assert.Equal(t, c.expected, text)
+
+				ast, err := c.p.ParseStr(text)
+				require.NoError(t, err)
+				assert.EqualValues(t, tt.input, asttest.StripSpans(ast))
+			}
+			assert.Equal(t, tt.output, tt.input.(fmt.Stringer).String())
+		})
+	}
+
+	_, err = parse.Format(&EqualExpr{LHS: un("a"), RHS: un("b")}, p)
+	assert.Error(t, err)
+	_, err = parse.Format(in(un("a"), eq(un("b"), un("c"))), p)
+	assert.Error(t, err)
+	_, err = parse.Format(chain(un("a"), OpLess, un("b"), OpLess, un("c")), p)
+	assert.Error(t, err)
+	_, err = parse.Format(&BetweenExpr{Expr: un("a"), Lower: un("b"), Upper: un("c"), Op: OpIn}, p)
+	assert.Error(t, err)
+	_, err = parse.Format(&NullExpr{Expr: un("a"), Op: OpEqual}, p)
+	assert.Error(t, err)
+}
+
+func TestWithChainedComparisons(t *testing.T) {
+	p, err := NewParser(WithChainedComparisons(true))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"a < b", lt(un("a"), un("b"))},
+		{"0 < x <= 10", chain(un("0"), OpLess, un("x"), OpLessOrEqual, un("10"))},
+		{"a > b >= c > d", chain(un("a"), OpGreater, un("b"), OpGreaterOrEqual, un("c"), OpGreater, un("d"))},
+		{"a < b > c == d <= e", eq(chain(un("a"), OpLess, un("b"), OpGreater, un("c")), lte(un("d"), un("e")))},
+		{"(a < b) < c", lt(lt(un("a"), un("b")), un("c"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+
+			text, err := parse.Format(ast, p)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, text)
+		})
+	}
+
+	for _, input := range []string{"a < b <", "a < b < c < (d"} {
+		_, err := p.ParseStr(input)
+		assert.ErrorIs(t, err, parse.ErrParse, input)
+	}
+	for _, input := range []parse.AST{
+		chain(un("a"), OpLess, un("b"), OpEqual, un("c")),
+		&ChainExpr{Operands: []parse.AST{un("a"), un("b")}, Ops: []Op{OpLess, OpLess}},
+		&ChainExpr{},
+	} {
+		_, err := parse.Format(input, p)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestWithChildren(t *testing.T) {
+	for _, ast := range []parse.AST{eq(un("a"), un("b")), lte(un("a"), un("b")), in(un("a"), un("b")), pred(OpContains, un("a"), un("b")), chain(un("a"), OpLess, un("b"))} {
+		branch, ok := ast.(parse.Branch)
+		require.True(t, ok)
+		assert.Equal(t, []parse.AST{un("a"), un("b")}, branch.Children())
+
+		result := branch.WithChildren([]parse.AST{un("c"), un("d")})
+		assert.Equal(t, []parse.AST{un("c"), un("d")}, parse.ChildrenOf(result))
+		assert.Equal(t, []parse.AST{un("a"), un("b")}, branch.Children())
+		assert.Panics(t, func() { branch.WithChildren([]parse.AST{un("c")}) })
+	}
+
+	m := pred(OpLike, un("a"), un("'b%'")).(parse.Branch).WithChildren([]parse.AST{un("a"), un("'c_'")}).(*MatchExpr)
+	require.NotNil(t, m.Regexp)
+	assert.True(t, m.Regexp.MatchString("cd"))
+	m = m.WithChildren([]parse.AST{un("a"), un("b")}).(*MatchExpr)
+	assert.Nil(t, m.Regexp)
+	assert.NoError(t, m.Validate())
+
+	// an invalid pattern is reported by Validate, and so by parse.Rewrite
+	m = pred(OpMatches, un("a"), un("'b'")).(*MatchExpr)
+	assert.ErrorIs(t, m.WithChildren([]parse.AST{un("a"), un("'a(b'")}).(*MatchExpr).Validate(), parse.ErrParse)
+	_, err := parse.Rewrite(m, func(ast parse.AST) (parse.AST, error) {
+		if u, ok := ast.(parse.Unparsed); ok && u.Contents[0].Text == "'b'" {
+			return un("'a(b'"), nil
+		}
+		return ast, nil
+	})
+	assert.ErrorIs(t, err, parse.ErrParse)
+
+	b := between(un("a"), un("b"), un("c"))
+	assert.Equal(t, []parse.AST{un("a"), un("b"), un("c")}, parse.ChildrenOf(b))
+	result := b.(parse.Branch).WithChildren([]parse.AST{un("d"), un("e"), un("f")})
+	assert.EqualValues(t, between(un("d"), un("e"), un("f")), result)
+	assert.Panics(t, func() { b.(parse.Branch).WithChildren([]parse.AST{un("d")}) })
+
+	n := isNull(un("a"))
+	result = n.(parse.Branch).WithChildren([]parse.AST{un("b")})
+	assert.EqualValues(t, isNull(un("b")), result)
+	assert.EqualValues(t, isNull(un("a")), n)
+	assert.Panics(t, func() { n.(parse.Branch).WithChildren(nil) })
+
+	l := list(un("a"), un("b"))
+	children := []parse.AST{un("c"), un("d")}
+	result = l.(parse.Branch).WithChildren(children)
+	children[0] = un("e")
+	assert.Equal(t, []parse.AST{un("c"), un("d")}, parse.ChildrenOf(result))
+	assert.Equal(t, []parse.AST{un("a"), un("b")}, parse.ChildrenOf(l))
+	assert.Panics(t, func() { l.(parse.Branch).WithChildren([]parse.AST{un("c")}) })
+
+	// the copy does not share its operators with the original
+	c := chain(un("a"), OpLess, un("b"), OpLessOrEqual, un("c")).(*ChainExpr)
+	copied := c.WithChildren([]parse.AST{un("d"), un("e"), un("f")}).(*ChainExpr)
+	copied.Ops[0] = OpGreater
+	assert.Equal(t, []Op{OpLess, OpLessOrEqual}, c.Ops)
+}
+
 func eq(a, b parse.AST) parse.AST {
 	return &EqualExpr{LHS: a, RHS: b, Op: OpEqual}
 }
@@ -167,7 +606,64 @@ func lte(a, b parse.AST) parse.AST {
 	return &OrdinalExpr{LHS: a, RHS: b, Op: OpLessOrEqual}
 }
 
+func in(a, b parse.AST) parse.AST {
+	return &InExpr{LHS: a, RHS: b, Op: OpIn}
+}
+func notIn(a, b parse.AST) parse.AST {
+	return &InExpr{LHS: a, RHS: b, Op: OpNotIn}
+}
+func list(elems ...parse.AST) parse.AST {
+	return &List{Elems: elems}
+}
+
+func between(x, lower, upper parse.AST) parse.AST {
+	return &BetweenExpr{Expr: x, Lower: lower, Upper: upper, Op: OpBetween}
+}
+
+func notBetween(x, lower, upper parse.AST) parse.AST {
+	return &BetweenExpr{Expr: x, Lower: lower, Upper: upper, Op: OpNotBetween}
+}
+
+// chain returns a ChainExpr from alternating operands and operators, starting with an operand.
+func isNull(x parse.AST) parse.AST {
+	return &NullExpr{Expr: x, Op: OpIsNull}
+}
+
+func isNotNull(x parse.AST) parse.AST {
+	return &NullExpr{Expr: x, Op: OpIsNotNull}
+}
+
+func chain(items ...any) parse.AST {
+	expr := &ChainExpr{}
+	for i, item := range items {
+		if i%2 == 0 {
+			expr.Operands = append(expr.Operands, item.(parse.AST))
+		} else {
+			expr.Ops = append(expr.Ops, item.(Op))
+		}
+	}
+	return expr
+}
+
+// pred returns a MatchExpr whose pattern is compiled as it would be by Parser.Parse.
+func pred(op Op, a, b parse.AST) parse.AST {
+	expr := &MatchExpr{LHS: a, RHS: b, Op: op}
+	if err := expr.compile(); err != nil {
+		panic(err)
+	}
+	return expr
+}
+
 // un stands for unparsed and returns a parse.Unparsed
 func un(tokens ...string) parse.AST {
-	return parse.Unparsed{Contents: tokens}
+	return parse.Unparsed{Contents: parse.NewTokens(tokens...)}
+}
+
+// texts returns the text of each of the provided tokens.
+func texts(tokens []parse.Token) []string {
+	var result []string
+	for _, token := range tokens {
+		result = append(result, token.Text)
+	}
+	return result
 }
@@ -0,0 +1,313 @@
+package comp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"strconv"
+	"strings"
+)
+
+// Eval evaluates the provided comparison, using the provided Interpreter to find the value of each operand which is not
+// itself a comparison. See Interpreter for details.
+func Eval(expr parse.AST, values parse.Interpreter[any]) (bool, error) {
+	return Interpreter(values)(expr)
+}
+
+// Interpreter returns a parse.Interpreter which evaluates EqualExpr, OrdinalExpr, ChainExpr, InExpr, MatchExpr,
+// BetweenExpr and NullExpr nodes. The value of each operand which is not itself a comparison is found using the
+// provided values Interpreter; see ValueInterpreter for a simple implementation. Nodes of any other type result in
+// parse.ErrUnknownAST, so the returned Interpreter is suitable for use as the Interpreter passed to bools.Eval.
+//
+// The value of each operand is converted to a parse.Value using parse.ValueOf, and operands are compared using
+// parse.Equal and parse.Compare, according to the following rules.
+//   - Numbers of any Go numeric type are compared by numeric value, so that int64(3) == float64(3.0). Integers which
+//     do not fit in an int64 result in parse.ErrEval.
+//   - Strings are ordered lexicographically by byte. Booleans, time.Time and time.Duration values may also be compared.
+//   - nil is only equal to nil, and cannot be ordered.
+//   - Values of distinct types are never equal. Attempting to order them results in parse.ErrEval, as does an operand
+//     which parse.ValueOf cannot convert.
+//   - x IN list is true iff x is equal to an element of the list. The elements of a List are evaluated in order, until
+//     one is found to be equal. Any other right-hand side must evaluate to a slice or array.
+//   - A chain such as a < b <= c holds iff a < b and b <= c. Operands are evaluated once each, from left to right,
+//     and evaluation stops at the first comparison which does not hold.
+//   - x BETWEEN a AND b holds iff a <= x and x <= b. All three operands are evaluated once each, in that order.
+//   - x IS NULL holds iff x is nil, or its evaluation results in an error matching parse.ErrNotFound, such as a
+//     reference to a missing variable.
+//   - String predicates require both operands to be strings. =~ is true if the regular expression matches any part of
+//     the string, while LIKE patterns must match the entire string. Invalid patterns result in parse.ErrEval.
+func Interpreter(values parse.Interpreter[any]) parse.Interpreter[bool] {
+	var interpret parse.Interpreter[bool]
+	operand := func(ast parse.AST) (parse.Value, error) {
+		switch ast.(type) {
+		case *EqualExpr, *OrdinalExpr, *ChainExpr, *InExpr, *MatchExpr, *BetweenExpr, *NullExpr:
+			ok, err := interpret(ast)
+			if err != nil {
+				return nil, err
+			}
+			return parse.Bool(ok), nil
+		default:
+			val, err := values(ast)
+			if err != nil {
+				return nil, err
+			}
+			return parse.ValueOf(val)
+		}
+	}
+	interpret = func(ast parse.AST) (bool, error) {
+		if values == nil {
+			return false, fmt.Errorf("%w: nil Interpreter", parse.ErrEval)
+		}
+		return Evaluate(ast, operand)
+	}
+	return interpret
+}
+
+// Evaluate evaluates the provided comparison according to the rules documented on Interpreter, using the provided
+// function to find the value of each of its operands. It allows interpreters of other grammars, such as those provided
+// by the eval package, to evaluate comparisons in the same way as this package. Nodes of any other type result in
+// parse.ErrUnknownAST.
+func Evaluate(ast parse.AST, operand func(parse.AST) (parse.Value, error)) (bool, error) {
+	switch ast := ast.(type) {
+	case *EqualExpr:
+		lhs, rhs, err := evalOperands(ast.LHS, ast.RHS, operand)
+		if err != nil {
+			return false, err
+		}
+		switch ast.Op {
+		case OpEqual:
+			return parse.Equal(lhs, rhs), nil
+		case OpNotEqual:
+			return !parse.Equal(lhs, rhs), nil
+		}
+		return false, fmt.Errorf("%w: unexpected equality operator: %v", parse.ErrEval, ast.Op)
+	case *OrdinalExpr:
+		lhs, rhs, err := evalOperands(ast.LHS, ast.RHS, operand)
+		if err != nil {
+			return false, err
+		}
+		return Order(ast.Op, lhs, rhs)
+	case *ChainExpr:
+		if len(ast.Operands) != len(ast.Ops)+1 {
+			return false, fmt.Errorf("%w: chain of %d operands and %d operators",
+				parse.ErrEval, len(ast.Operands), len(ast.Ops))
+		}
+		lhs, err := operand(ast.Operands[0])
+		if err != nil {
+			return false, err
+		}
+		for i, op := range ast.Ops {
+			rhs, err := operand(ast.Operands[i+1])
+			if err != nil {
+				return false, err
+			}
+			if ok, err := Order(op, lhs, rhs); !ok || err != nil {
+				return false, err
+			}
+			lhs = rhs
+		}
+		return true, nil
+	case *BetweenExpr:
+		if ast.Op != OpBetween && ast.Op != OpNotBetween {
+			return false, fmt.Errorf("%w: unexpected range operator: %v", parse.ErrEval, ast.Op)
+		}
+		val, lower, err := evalOperands(ast.Expr, ast.Lower, operand)
+		if err != nil {
+			return false, err
+		}
+		upper, err := operand(ast.Upper)
+		if err != nil {
+			return false, err
+		}
+		above, err := Order(OpGreaterOrEqual, val, lower)
+		if err != nil {
+			return false, err
+		}
+		below, err := Order(OpLessOrEqual, val, upper)
+		if err != nil {
+			return false, err
+		}
+		return (above && below) == (ast.Op == OpBetween), nil
+	case *InExpr:
+		if ast.Op != OpIn && ast.Op != OpNotIn {
+			return false, fmt.Errorf("%w: unexpected membership operator: %v", parse.ErrEval, ast.Op)
+		}
+		lhs, err := operand(ast.LHS)
+		if err != nil {
+			return false, err
+		}
+		found, err := contains(ast.RHS, lhs, operand)
+		if err != nil {
+			return false, err
+		}
+		return found == (ast.Op == OpIn), nil
+	case *MatchExpr:
+		return evalMatch(ast, operand)
+	case *NullExpr:
+		if ast.Op != OpIsNull && ast.Op != OpIsNotNull {
+			return false, fmt.Errorf("%w: unexpected null operator: %v", parse.ErrEval, ast.Op)
+		}
+		val, err := operand(ast.Expr)
+		if err != nil && !errors.Is(err, parse.ErrNotFound) {
+			return false, err
+		}
+		return (err != nil || val.Kind() == parse.KindNull) == (ast.Op == OpIsNull), nil
+	case nil:
+		return false, fmt.Errorf("%w: nil expression", parse.ErrEval)
+	default:
+		return false, fmt.Errorf("%w: unknown AST node: %v", parse.ErrUnknownAST, ast)
+	}
+}
+
+// ValueInterpreter provides an Interpreter which finds the value of every parse.Unparsed node with a single token. The
+// token is interpreted as a literal if possible, and otherwise looked up in the provided map. Literals are recognized
+// as follows. Variables which are not in the map result in parse.ErrNotFound.
+//   - Integers such as 42 or -7 are returned as int64, other numbers such as 3.5 or 1e9 are returned as float64.
+//   - String literals are returned as string, after decoding any escape sequences using parse.Unquote.
+//   - true and false are returned as bool, and null and nil are returned as nil.
+func ValueInterpreter(variables map[string]any) parse.Interpreter[any] {
+	return func(ast parse.AST) (any, error) {
+		switch ast := ast.(type) {
+		case parse.Unparsed:
+			if len(ast.Contents) != 1 {
+				return nil, fmt.Errorf("%w: cannot evaluate multi-word values; found '%v'", parse.ErrEval, ast)
+			}
+			token := ast.Contents[0]
+			if val, ok, err := literal(token); ok || err != nil {
+				return val, err
+			}
+			val, ok := variables[token.Text]
+			if !ok {
+				return nil, fmt.Errorf("%w: '%s'", parse.ErrNotFound, token.Text)
+			}
+			return val, nil
+		default:
+			return nil, fmt.Errorf("%w: unknown AST node: %v", parse.ErrUnknownAST, ast)
+		}
+	}
+}
+
+// literal attempts to interpret the provided token as a literal value.
+func literal(token parse.Token) (any, bool, error) {
+	switch token.Text {
+	case "true":
+		return true, true, nil
+	case "false":
+		return false, true, nil
+	case "null", "nil":
+		return nil, true, nil
+	}
+	if token.Quote != 0 {
+		str, err := parse.Unquote(token.Text)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %v", parse.ErrEval, err)
+		}
+		return str, true, nil
+	}
+	if i, err := strconv.ParseInt(token.Text, 10, 64); err == nil {
+		return i, true, nil
+	}
+	if f, err := strconv.ParseFloat(token.Text, 64); err == nil {
+		return f, true, nil
+	}
+	return nil, false, nil
+}
+
+// Order evaluates the ordinal comparison lhs op rhs, ordering its operands using parse.Compare.
+func Order(op Op, lhs, rhs parse.Value) (bool, error) {
+	cmp, err := parse.Compare(lhs, rhs)
+	if err != nil {
+		return false, err
+	}
+	switch op {
+	case OpGreater:
+		return cmp > 0, nil
+	case OpGreaterOrEqual:
+		return cmp >= 0, nil
+	case OpLess:
+		return cmp < 0, nil
+	case OpLessOrEqual:
+		return cmp <= 0, nil
+	}
+	return false, fmt.Errorf("%w: unexpected ordinal operator: %v", parse.ErrEval, op)
+}
+
+// evalMatch evaluates the provided string predicate.
+func evalMatch(expr *MatchExpr, operand func(parse.AST) (parse.Value, error)) (bool, error) {
+	lhs, err := operand(expr.LHS)
+	if err != nil {
+		return false, err
+	}
+	str, ok := lhs.(parse.String)
+	if !ok {
+		return false, fmt.Errorf("%w: cannot apply %v to %v value '%v'", parse.ErrEval, expr.Op, lhs.Kind(), lhs)
+	}
+	re := expr.Regexp
+	if re != nil && (expr.Op == OpLike || expr.Op == OpMatches) {
+		return re.MatchString(string(str)), nil
+	}
+	rhs, err := operand(expr.RHS)
+	if err != nil {
+		return false, err
+	}
+	pattern, ok := rhs.(parse.String)
+	if !ok {
+		return false, fmt.Errorf("%w: cannot apply %v with %v value '%v'", parse.ErrEval, expr.Op, rhs.Kind(), rhs)
+	}
+	switch expr.Op {
+	case OpLike, OpMatches:
+		if re, err = CompilePattern(expr.Op, string(pattern)); err != nil {
+			return false, fmt.Errorf("%w: invalid pattern '%s': %v", parse.ErrEval, pattern, err)
+		}
+		return re.MatchString(string(str)), nil
+	case OpContains:
+		return strings.Contains(string(str), string(pattern)), nil
+	case OpStartsWith:
+		return strings.HasPrefix(string(str), string(pattern)), nil
+	case OpEndsWith:
+		return strings.HasSuffix(string(str), string(pattern)), nil
+	}
+	return false, fmt.Errorf("%w: unexpected string operator: %v", parse.ErrEval, expr.Op)
+}
+
+// contains reports whether the value of the provided list contains an element equal to val.
+func contains(list parse.AST, val parse.Value, operand func(parse.AST) (parse.Value, error)) (bool, error) {
+	if list, ok := list.(*List); ok {
+		for _, elem := range list.Elems {
+			v, err := operand(elem)
+			if err != nil {
+				return false, err
+			}
+			if parse.Equal(val, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	v, err := operand(list)
+	if err != nil {
+		return false, err
+	}
+	elems, ok := v.(parse.List)
+	if !ok {
+		return false, fmt.Errorf("%w: cannot test membership in %v value '%v'", parse.ErrEval, v.Kind(), v)
+	}
+	for _, elem := range elems {
+		if parse.Equal(val, elem) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func evalOperands(lhs, rhs parse.AST, operand func(parse.AST) (parse.Value, error)) (parse.Value, parse.Value, error) {
+	l, err := operand(lhs)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := operand(rhs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
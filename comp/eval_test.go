@@ -0,0 +1,230 @@
+package comp
+
+import (
+	"github.com/orkes-io/go-parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"i":       7,
+		"u":       uint8(7),
+		"f":       7.0,
+		"big":     big.NewInt(1 << 40),
+		"s":       "abc",
+		"b":       true,
+		"n":       nil,
+		"nan":     math.NaN(),
+		"now":     time.Unix(1000, 0),
+		"later":   time.Unix(2000, 0),
+		"short":   time.Second,
+		"long":    time.Minute,
+		"list":    []any{"abc", 7, nil},
+		"pattern": "%c",
+		"regex":   "^a.c$",
+		"bad":     "(",
+		"ints":    [2]int{1, 7},
+	}
+
+	tests := []struct {
+		input  string
+		output bool
+	}{
+		{"i == 7", true},
+		{"i == u", true},
+		{"i == f", true},
+		{"f >= 6.5", true},
+		{"i < -3", false},
+		{"big > i", true},
+		{"big < 1e30", true},
+		{"s == 'abc'", true},
+		{"s == \"abc\"", true},
+		{"s != `abd`", true},
+		{`(s == 'a\'b') == false`, true},
+		{`'\u00e9t\u00e9' == "été"`, true},
+		{`'a (b)' == "a (b)"`, true},
+		{"s < 'abd'", true},
+		{"s == 7", false},
+		{"s != 7", true},
+		{"b == true", true},
+		{"b > false", true},
+		{"n == null", true},
+		{"n == nil", true},
+		{"n != 0", true},
+		{"nan == nan", false},
+		{"nan != nan", true},
+		{"now < later", true},
+		{"now == now", true},
+		{"short < long", true},
+		{"long >= long", true},
+		{"(i > 3) == b", true},
+		{"(i > 3) != (s == 'xyz')", true},
+		{"s IN ('x', 'abc')", true},
+		{"s NOT IN ('x', 'abc')", false},
+		{"i IN (1, 2, 7.0)", true},
+		{"i IN ()", false},
+		{"n IN (0, null)", true},
+		{"s IN list", true},
+		{"f IN list", true},
+		{"'ab' NOT IN list", true},
+		{"u IN ints", true},
+		{"(s IN list) == b", true},
+		{"s IN (7, s)", true},
+		{"s LIKE 'a%'", true},
+		{"s LIKE 'a_c'", true},
+		{"s LIKE '_b'", false},
+		{"'a.c' LIKE 'a.%'", true},
+		{`'50%' LIKE '%\\%'`, true},
+		{`'5%0' LIKE '%\\%'`, false},
+		{"'x\ny' LIKE 'x%'", true},
+		{"s =~ 'b'", true},
+		{"s =~ '^b'", false},
+		{"s CONTAINS 'bc'", true},
+		{"s STARTS WITH 'ab'", true},
+		{"s ENDS WITH 'ab'", false},
+		{"s LIKE pattern", true},
+		{"s =~ regex", true},
+		{"i BETWEEN 1 AND 7", true},
+		{"f BETWEEN 7.5 AND 10", false},
+		{"i NOT BETWEEN u AND big", false},
+		{"s BETWEEN 'a' AND 'b'", true},
+		{"now NOT BETWEEN later AND later", true},
+		{"n IS NULL", true},
+		{"missing IS NULL", true},
+		{"s IS NULL", false},
+		{"n IS NOT NULL", false},
+		{"missing IS NOT NULL", false},
+		{"list IS NOT NULL", true},
+		{"(n IS NULL) == b", true},
+	}
+
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			result, err := Eval(ast, ValueInterpreter(vars))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.output, result)
+		})
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	vars := map[string]any{
+		"s":   "abc",
+		"i":   7,
+		"n":   nil,
+		"nan": math.NaN(),
+		"bad": "(",
+		"big": new(big.Int).Lsh(big.NewInt(1), 100),
+		"obj": struct{}{},
+	}
+	tests := []string{
+		"s > 7",
+		"n < 4",
+		"nan < 4",
+		"x == 4",
+		"s == a b",
+		`s == "bad \q escape"`,
+		"s IN s",
+		"s IN (x, 'abc')",
+		"s IN missing",
+		"i LIKE 'a'",
+		"s CONTAINS 7",
+		"s =~ bad",
+		"s LIKE x",
+		"s BETWEEN 1 AND 2",
+		"i BETWEEN n AND 10",
+		"i BETWEEN 1 AND x",
+		"a b IS NULL",
+		`"bad \q escape" IS NOT NULL`,
+		"big > i",
+		"i NOT BETWEEN 1 AND big",
+		"obj == obj",
+	}
+
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt, func(t *testing.T) {
+			ast, err := p.ParseStr(tt)
+			require.NoError(t, err)
+			_, err = Eval(ast, ValueInterpreter(vars))
+			assert.ErrorIs(t, err, parse.ErrEval)
+		})
+	}
+
+	_, err = Eval(un("x"), ValueInterpreter(vars))
+	assert.ErrorIs(t, err, parse.ErrUnknownAST)
+
+	_, err = Eval(eq(un("x"), un("y")), nil)
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
+
+func TestEvalChain(t *testing.T) {
+	tests := []struct {
+		input  string
+		output bool
+		evals  int
+	}{
+		{"1 < 2 <= 2", true, 3},
+		{"1 < 2 < 3 < 4 > 0", true, 5},
+		{"3 < 2 < x", false, 2},
+		{"1 < 3 < 2 < x", false, 3},
+	}
+
+	p, err := NewParser(WithChainedComparisons(true))
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			evals := 0
+			values := ValueInterpreter(nil)
+			result, err := Eval(ast, func(ast parse.AST) (any, error) {
+				evals++
+				return values(ast)
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, result)
+			assert.Equal(t, tt.evals, evals)
+		})
+	}
+
+	ast, err := p.ParseStr("1 < 'a' < 3")
+	require.NoError(t, err)
+	_, err = Eval(ast, ValueInterpreter(nil))
+	assert.ErrorIs(t, err, parse.ErrEval)
+	_, err = Eval(&ChainExpr{Operands: []parse.AST{un("1")}, Ops: []Op{OpLess}}, ValueInterpreter(nil))
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
+
+func TestEvalBetween(t *testing.T) {
+	evals := 0
+	values := ValueInterpreter(map[string]any{"x": 5})
+	result, err := Eval(between(un("x"), un("10"), un("20")), func(ast parse.AST) (any, error) {
+		evals++
+		return values(ast)
+	})
+	require.NoError(t, err)
+	assert.False(t, result)
+	assert.Equal(t, 3, evals)
+
+	_, err = Eval(&BetweenExpr{Expr: un("1"), Lower: un("1"), Upper: un("1"), Op: OpLess}, values)
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
+
+func TestEvalNull(t *testing.T) {
+	_, err := Eval(&NullExpr{Expr: un("1"), Op: OpEqual}, ValueInterpreter(nil))
+	assert.ErrorIs(t, err, parse.ErrEval)
+
+	_, err = ValueInterpreter(nil)(un("missing"))
+	assert.ErrorIs(t, err, parse.ErrNotFound)
+}
@@ -0,0 +1,819 @@
+// Package compile compiles expressions into trees of closures, which evaluate faster than walking the AST when the
+// same expression is evaluated many times, such as a condition which is checked for every execution of a workflow:
+//
+//	prog, err := compile.Compile(ast)
+//	...
+//	env := prog.NewEnv(vars.Interpreter(doc))
+//	ok, err := prog.Eval(env)
+//
+// Compile does the work which would otherwise be repeated by each evaluation once, ahead of time: it inspects the type
+// and operator of every node, converts the value of each literal.Lit, compiles constant LIKE and =~ patterns, and
+// assigns each variable of the expression a slot in an Env. Expressions are evaluated according to the same rules as
+// eval.Interpreter, and produce the same results and errors, except that the errors described by Compile are reported
+// ahead of time. Function calls are compiled using the Registry passed to WithFuncs, as eval.WithFuncs does for
+// eval.Interpreter.
+package compile
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/arith"
+	"github.com/orkes-io/go-parse/bools"
+	"github.com/orkes-io/go-parse/coalesce"
+	"github.com/orkes-io/go-parse/comp"
+	"github.com/orkes-io/go-parse/cond"
+	"github.com/orkes-io/go-parse/eval"
+	"github.com/orkes-io/go-parse/funcs"
+	"github.com/orkes-io/go-parse/literal"
+	"github.com/orkes-io/go-parse/vars"
+	"regexp"
+	"strings"
+)
+
+// value is a compiled expression producing a parse.Value.
+type value func(*Env) (parse.Value, error)
+
+// predicate is a compiled expression producing a boolean, which avoids converting the results of boolean operators
+// and comparisons to and from parse.Value.
+type predicate func(*Env) (bool, error)
+
+// Program is a compiled expression. Programs are safe for concurrent use, provided that each goroutine evaluates them
+// using its own Env.
+type Program struct {
+	vars  []parse.AST
+	value value
+	pred  predicate
+}
+
+// Compile compiles the provided expression, which may combine the nodes of the bools, comp, arith, cond, coalesce and
+// literal packages, and funcs.CallExpr nodes if WithFuncs is used. Any other node, such as a vars.Ref, is a variable of
+// the returned Program, whose value is found using the Interpreter of the Env it is evaluated with. Identical vars.Ref
+// and parse.Unparsed nodes, such as repeated references to ${workflow.input.priority}, share a slot, and so are only
+// looked up once per Env; any other node has a slot of its own.
+//
+// An error matching parse.ErrEval is returned if the expression is nil, or contains a node with an unexpected operator
+// or an invalid number of operands, or a call to a function which is not registered or does not accept the number of
+// arguments provided. Any other error is reported when the expression is evaluated, as it is by eval.Interpreter.
+func Compile(ast parse.AST, opts ...CompileOpt) (*Program, error) {
+	c := &compiler{slots: make(map[string]int)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	p := &Program{}
+	var err error
+	if isPredicate(ast) {
+		if p.pred, err = c.predicate(ast); err != nil {
+			return nil, err
+		}
+		p.value = box(p.pred)
+	} else {
+		if p.value, err = c.compile(ast); err != nil {
+			return nil, err
+		}
+		p.pred = assertBool(p.value, nil)
+	}
+	p.vars = c.vars
+	return p, nil
+}
+
+// CompileOpt configures the behavior of Compile.
+type CompileOpt func(*compiler)
+
+// WithFuncs sets the functions which may be called by the compiled expression. Each funcs.CallExpr is compiled into a
+// call to the function of the same name, whose arguments are checked and passed as described by funcs.Interpreter. By
+// default, calls are variables of the Program, like any other node.
+func WithFuncs(registry *funcs.Registry) CompileOpt {
+	return func(c *compiler) {
+		c.funcs = registry
+	}
+}
+
+// Vars returns the variables of this Program, in the order of their slots. The returned slice must not be modified.
+func (p *Program) Vars() []parse.AST {
+	return p.vars
+}
+
+// Eval evaluates this Program, whose result must be a parse.Bool.
+func (p *Program) Eval(env *Env) (bool, error) {
+	if err := p.check(env); err != nil {
+		return false, err
+	}
+	return p.pred(env)
+}
+
+// Value evaluates this Program, whose result may be of any kind.
+func (p *Program) Value(env *Env) (parse.Value, error) {
+	if err := p.check(env); err != nil {
+		return nil, err
+	}
+	return p.value(env)
+}
+
+func (p *Program) check(env *Env) error {
+	if env == nil || env.prog != p {
+		return fmt.Errorf("%w: Env was not created by this Program", parse.ErrEval)
+	}
+	return nil
+}
+
+// Env holds the state of a single evaluation of a Program: the Interpreter used to find the value of each of its
+// variables, and the values found so far. The value of each variable is found the first time it is needed, and
+// converted using parse.ValueOf; errors are likewise kept, so that each variable is looked up at most once. An Env may
+// be reused for further evaluations using Reset, but must not be used concurrently.
+type Env struct {
+	prog   *Program
+	leaves parse.Interpreter[any]
+	slots  []slot
+}
+
+type slot struct {
+	val  parse.Value
+	err  error
+	done bool
+}
+
+// NewEnv returns an Env for evaluating this Program, which finds the value of each variable using the provided
+// Interpreter. If leaves is nil, evaluating a variable results in parse.ErrUnknownAST.
+func (p *Program) NewEnv(leaves parse.Interpreter[any]) *Env {
+	return &Env{prog: p, leaves: leaves, slots: make([]slot, len(p.vars))}
+}
+
+// Reset discards the values found so far, and sets the Interpreter used to find the value of each variable, so that
+// this Env can be used for another evaluation without allocating.
+func (e *Env) Reset(leaves parse.Interpreter[any]) {
+	e.leaves = leaves
+	for i := range e.slots {
+		e.slots[i] = slot{}
+	}
+}
+
+// lookup returns the value of the variable in slot i.
+func (e *Env) lookup(i int) (parse.Value, error) {
+	s := &e.slots[i]
+	if !s.done {
+		s.done = true
+		ast := e.prog.vars[i]
+		if e.leaves == nil {
+			s.err = fmt.Errorf("%w: unknown AST node: %v", parse.ErrUnknownAST, ast)
+		} else if val, err := e.leaves(ast); err != nil {
+			s.err = err
+		} else {
+			s.val, s.err = parse.ValueOf(val)
+		}
+	}
+	return s.val, s.err
+}
+
+type compiler struct {
+	slots map[string]int
+	vars  []parse.AST
+	funcs *funcs.Registry
+}
+
+// isPredicate reports whether the provided node always produces a boolean.
+func isPredicate(ast parse.AST) bool {
+	switch ast.(type) {
+	case *bools.BinExpr, *bools.ListExpr, *bools.UnaryExpr, *comp.EqualExpr, *comp.OrdinalExpr, *comp.ChainExpr,
+		*comp.BetweenExpr, *comp.InExpr, *comp.MatchExpr, *comp.NullExpr:
+		return true
+	}
+	return false
+}
+
+func (c *compiler) compile(ast parse.AST) (value, error) {
+	if isPredicate(ast) {
+		pred, err := c.predicate(ast)
+		if err != nil {
+			return nil, err
+		}
+		return box(pred), nil
+	}
+	switch ast := ast.(type) {
+	case *arith.BinExpr:
+		return c.compileArith(ast)
+	case *arith.UnaryExpr:
+		if ast.Op != arith.OpNegate {
+			return nil, fmt.Errorf("%w: unexpected unary operator: %v", parse.ErrEval, ast.Op)
+		}
+		expr, err := c.compile(ast.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(env *Env) (parse.Value, error) {
+			val, err := expr(env)
+			if err != nil {
+				return nil, err
+			}
+			return eval.Negate(val)
+		}, nil
+	case *cond.CondExpr:
+		return c.compileCond(ast)
+	case *coalesce.CoalesceExpr:
+		return c.compileCoalesce(ast)
+	case *funcs.CallExpr:
+		if c.funcs != nil {
+			return c.compileCall(ast)
+		}
+		return c.variable(ast), nil
+	case *literal.Lit:
+		val, err := parse.ValueOf(ast.Value)
+		return func(*Env) (parse.Value, error) {
+			return val, err
+		}, nil
+	case nil:
+		return nil, fmt.Errorf("%w: nil expression", parse.ErrEval)
+	default:
+		return c.variable(ast), nil
+	}
+}
+
+// variable returns a value which looks up the provided variable in its slot.
+func (c *compiler) variable(ast parse.AST) value {
+	key, shared := slotKey(ast)
+	i, ok := c.slots[key]
+	if !shared || !ok {
+		i = len(c.vars)
+		if shared {
+			c.slots[key] = i
+		}
+		c.vars = append(c.vars, ast)
+	}
+	return func(env *Env) (parse.Value, error) {
+		return env.lookup(i)
+	}
+}
+
+// slotKey returns a key identifying the provided variable, so that identical variables share a slot, or false if the
+// variable is of a type whose nodes cannot share a slot.
+func slotKey(ast parse.AST) (string, bool) {
+	var sb strings.Builder
+	switch ast := ast.(type) {
+	case parse.Unparsed:
+		sb.WriteString("unparsed")
+		for _, token := range ast.Contents {
+			fmt.Fprintf(&sb, " %q%q", token.Quote, token.Text)
+		}
+	case *vars.Ref:
+		sb.WriteString("ref")
+		for _, seg := range ast.Path {
+			fmt.Fprintf(&sb, " %d%q%d%t", seg.Kind, seg.Key, seg.Index, seg.NullSafe)
+		}
+	default:
+		return "", false
+	}
+	return sb.String(), true
+}
+
+// box converts the result of the provided predicate to a parse.Value.
+func box(pred predicate) value {
+	return func(env *Env) (parse.Value, error) {
+		ok, err := pred(env)
+		if err != nil {
+			return nil, err
+		}
+		return parse.Bool(ok), nil
+	}
+}
+
+// assertBool returns a predicate which requires the result of the provided value to be a parse.Bool. The operator
+// consuming the result is used in error messages; a nil op is used for conditions.
+func assertBool(val value, op fmt.Stringer) predicate {
+	return func(env *Env) (bool, error) {
+		v, err := val(env)
+		if err != nil {
+			return false, err
+		}
+		b, ok := v.(parse.Bool)
+		if !ok {
+			if op == nil {
+				return false, fmt.Errorf("%w: condition must be a bool; found %v value '%v'", parse.ErrEval, v.Kind(), v)
+			}
+			return false, fmt.Errorf("%w: cannot apply %v to %v value '%v'", parse.ErrEval, op, v.Kind(), v)
+		}
+		return bool(b), nil
+	}
+}
+
+// operand compiles the provided operand of op, which must produce a Bool. A nil op is used for conditions.
+func (c *compiler) operand(ast parse.AST, op fmt.Stringer) (predicate, error) {
+	if isPredicate(ast) {
+		return c.predicate(ast)
+	}
+	val, err := c.compile(ast)
+	if err != nil {
+		return nil, err
+	}
+	return assertBool(val, op), nil
+}
+
+func (c *compiler) predicate(ast parse.AST) (predicate, error) {
+	switch ast := ast.(type) {
+	case *bools.BinExpr:
+		var first predicate
+		var ops []bools.Op
+		var terms []predicate
+		err := bools.WalkChain(ast, func(b *bools.BinExpr, deepest bool) error {
+			var err error
+			if deepest {
+				if first, err = c.operand(b.LHS, b.Op); err != nil {
+					return err
+				}
+			}
+			if b.Op != bools.OpAnd && b.Op != bools.OpOr {
+				return fmt.Errorf("%w: unexpected binary boolean operator: %v", parse.ErrEval, b.Op)
+			}
+			term, err := c.operand(b.RHS, b.Op)
+			if err != nil {
+				return err
+			}
+			ops, terms = append(ops, b.Op), append(terms, term)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return logic(first, ops, terms), nil
+	case *bools.ListExpr:
+		if ast.Op != bools.OpAnd && ast.Op != bools.OpOr {
+			return nil, fmt.Errorf("%w: unexpected boolean list operator: %v", parse.ErrEval, ast.Op)
+		}
+		identity := ast.Op == bools.OpAnd // the identity of the operator, so that empty lists are handled
+		ops := make([]bools.Op, len(ast.Terms))
+		terms := make([]predicate, len(ast.Terms))
+		for i, term := range ast.Terms {
+			var err error
+			if terms[i], err = c.operand(term, ast.Op); err != nil {
+				return nil, err
+			}
+			ops[i] = ast.Op
+		}
+		return logic(func(*Env) (bool, error) { return identity, nil }, ops, terms), nil
+	case *bools.UnaryExpr:
+		if ast.Op != bools.OpNot {
+			return nil, fmt.Errorf("%w: unexpected boolean unary operator: %v", parse.ErrEval, ast.Op)
+		}
+		expr, err := c.operand(ast.Expr, ast.Op)
+		if err != nil {
+			return nil, err
+		}
+		return func(env *Env) (bool, error) {
+			ok, err := expr(env)
+			return !ok && err == nil, err
+		}, nil
+	case *comp.EqualExpr:
+		if ast.Op != comp.OpEqual && ast.Op != comp.OpNotEqual {
+			return nil, fmt.Errorf("%w: unexpected equality operator: %v", parse.ErrEval, ast.Op)
+		}
+		lhs, rhs, err := c.compilePair(ast.LHS, ast.RHS)
+		if err != nil {
+			return nil, err
+		}
+		equal := ast.Op == comp.OpEqual
+		return func(env *Env) (bool, error) {
+			l, r, err := evalPair(env, lhs, rhs)
+			if err != nil {
+				return false, err
+			}
+			return parse.Equal(l, r) == equal, nil
+		}, nil
+	case *comp.OrdinalExpr:
+		holds, err := ordering(ast.Op)
+		if err != nil {
+			return nil, err
+		}
+		lhs, rhs, err := c.compilePair(ast.LHS, ast.RHS)
+		if err != nil {
+			return nil, err
+		}
+		return func(env *Env) (bool, error) {
+			l, r, err := evalPair(env, lhs, rhs)
+			if err != nil {
+				return false, err
+			}
+			cmp, err := parse.Compare(l, r)
+			return err == nil && holds(cmp), err
+		}, nil
+	case *comp.ChainExpr:
+		return c.compileChain(ast)
+	case *comp.BetweenExpr:
+		return c.compileBetween(ast)
+	case *comp.InExpr:
+		return c.compileIn(ast)
+	case *comp.MatchExpr:
+		return c.compileMatch(ast)
+	case *comp.NullExpr:
+		if ast.Op != comp.OpIsNull && ast.Op != comp.OpIsNotNull {
+			return nil, fmt.Errorf("%w: unexpected null operator: %v", parse.ErrEval, ast.Op)
+		}
+		expr, err := c.compile(ast.Expr)
+		if err != nil {
+			return nil, err
+		}
+		isNull := ast.Op == comp.OpIsNull
+		return func(env *Env) (bool, error) {
+			val, err := expr(env)
+			if err != nil && !errors.Is(err, parse.ErrNotFound) {
+				return false, err
+			}
+			return (err != nil || val.Kind() == parse.KindNull) == isNull, nil
+		}, nil
+	}
+	return c.operand(ast, nil)
+}
+
+// logic returns a predicate which evaluates first, followed by each term, combined using the corresponding operator.
+// Terms which cannot affect the result are skipped.
+func logic(first predicate, ops []bools.Op, terms []predicate) predicate {
+	return func(env *Env) (bool, error) {
+		val, err := first(env)
+		if err != nil {
+			return false, err
+		}
+		for i, op := range ops {
+			// the right-hand side of an AND is needed if the left-hand side is true, and that of an OR if it is false
+			if (op == bools.OpAnd) == val {
+				if val, err = terms[i](env); err != nil {
+					return false, err
+				}
+			}
+		}
+		return val, nil
+	}
+}
+
+func (c *compiler) compilePair(lhs, rhs parse.AST) (value, value, error) {
+	l, err := c.compile(lhs)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := c.compile(rhs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+func evalPair(env *Env, lhs, rhs value) (parse.Value, parse.Value, error) {
+	l, err := lhs(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := rhs(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, r, nil
+}
+
+func (c *compiler) compileChain(ast *comp.ChainExpr) (predicate, error) {
+	if len(ast.Operands) != len(ast.Ops)+1 {
+		return nil, fmt.Errorf("%w: chain of %d operands and %d operators", parse.ErrEval, len(ast.Operands), len(ast.Ops))
+	}
+	holds := make([]func(int) bool, len(ast.Ops))
+	for i, op := range ast.Ops {
+		var err error
+		if holds[i], err = ordering(op); err != nil {
+			return nil, err
+		}
+	}
+	operands := make([]value, len(ast.Operands))
+	for i, operand := range ast.Operands {
+		var err error
+		if operands[i], err = c.compile(operand); err != nil {
+			return nil, err
+		}
+	}
+	return func(env *Env) (bool, error) {
+		lhs, err := operands[0](env)
+		if err != nil {
+			return false, err
+		}
+		for i, holds := range holds {
+			rhs, err := operands[i+1](env)
+			if err != nil {
+				return false, err
+			}
+			cmp, err := parse.Compare(lhs, rhs)
+			if err != nil || !holds(cmp) {
+				return false, err
+			}
+			lhs = rhs
+		}
+		return true, nil
+	}, nil
+}
+
+func (c *compiler) compileBetween(ast *comp.BetweenExpr) (predicate, error) {
+	if ast.Op != comp.OpBetween && ast.Op != comp.OpNotBetween {
+		return nil, fmt.Errorf("%w: unexpected range operator: %v", parse.ErrEval, ast.Op)
+	}
+	expr, lower, err := c.compilePair(ast.Expr, ast.Lower)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := c.compile(ast.Upper)
+	if err != nil {
+		return nil, err
+	}
+	between := ast.Op == comp.OpBetween
+	return func(env *Env) (bool, error) {
+		val, l, err := evalPair(env, expr, lower)
+		if err != nil {
+			return false, err
+		}
+		u, err := upper(env)
+		if err != nil {
+			return false, err
+		}
+		above, err := parse.Compare(val, l)
+		if err != nil {
+			return false, err
+		}
+		below, err := parse.Compare(val, u)
+		if err != nil {
+			return false, err
+		}
+		return (above >= 0 && below <= 0) == between, nil
+	}, nil
+}
+
+func (c *compiler) compileIn(ast *comp.InExpr) (predicate, error) {
+	if ast.Op != comp.OpIn && ast.Op != comp.OpNotIn {
+		return nil, fmt.Errorf("%w: unexpected membership operator: %v", parse.ErrEval, ast.Op)
+	}
+	lhs, err := c.compile(ast.LHS)
+	if err != nil {
+		return nil, err
+	}
+	in := ast.Op == comp.OpIn
+	if list, ok := ast.RHS.(*comp.List); ok {
+		elems := make([]value, len(list.Elems))
+		for i, elem := range list.Elems {
+			if elems[i], err = c.compile(elem); err != nil {
+				return nil, err
+			}
+		}
+		return func(env *Env) (bool, error) {
+			val, err := lhs(env)
+			if err != nil {
+				return false, err
+			}
+			for _, elem := range elems {
+				v, err := elem(env)
+				if err != nil {
+					return false, err
+				}
+				if parse.Equal(val, v) {
+					return in, nil
+				}
+			}
+			return !in, nil
+		}, nil
+	}
+	rhs, err := c.compile(ast.RHS)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (bool, error) {
+		val, v, err := evalPair(env, lhs, rhs)
+		if err != nil {
+			return false, err
+		}
+		list, ok := v.(parse.List)
+		if !ok {
+			return false, fmt.Errorf("%w: cannot test membership in %v value '%v'", parse.ErrEval, v.Kind(), v)
+		}
+		for _, elem := range list {
+			if parse.Equal(val, elem) {
+				return in, nil
+			}
+		}
+		return !in, nil
+	}, nil
+}
+
+func (c *compiler) compileMatch(ast *comp.MatchExpr) (predicate, error) {
+	op := ast.Op
+	var match func(str, pattern string) (bool, error)
+	switch op {
+	case comp.OpLike, comp.OpMatches:
+		match = func(str, pattern string) (bool, error) {
+			re, err := comp.CompilePattern(op, pattern)
+			if err != nil {
+				return false, fmt.Errorf("%w: invalid pattern '%s': %v", parse.ErrEval, pattern, err)
+			}
+			return re.MatchString(str), nil
+		}
+	case comp.OpContains:
+		match = func(str, pattern string) (bool, error) { return strings.Contains(str, pattern), nil }
+	case comp.OpStartsWith:
+		match = func(str, pattern string) (bool, error) { return strings.HasPrefix(str, pattern), nil }
+	case comp.OpEndsWith:
+		match = func(str, pattern string) (bool, error) { return strings.HasSuffix(str, pattern), nil }
+	default:
+		return nil, fmt.Errorf("%w: unexpected string operator: %v", parse.ErrEval, op)
+	}
+	lhs, err := c.compile(ast.LHS)
+	if err != nil {
+		return nil, err
+	}
+	str := func(env *Env) (string, error) {
+		val, err := lhs(env)
+		if err != nil {
+			return "", err
+		}
+		s, ok := val.(parse.String)
+		if !ok {
+			return "", fmt.Errorf("%w: cannot apply %v to %v value '%v'", parse.ErrEval, op, val.Kind(), val)
+		}
+		return string(s), nil
+	}
+
+	// patterns which are string literals are compiled once, rather than for each evaluation
+	re := ast.Regexp
+	if lit, ok := ast.RHS.(*literal.Lit); ok && re == nil && (op == comp.OpLike || op == comp.OpMatches) {
+		if pattern, ok := lit.Value.(string); ok {
+			re, _ = comp.CompilePattern(op, pattern) // an invalid pattern is reported by match
+		}
+	}
+	if re != nil && (op == comp.OpLike || op == comp.OpMatches) {
+		return matchRegexp(str, re), nil
+	}
+
+	rhs, err := c.compile(ast.RHS)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (bool, error) {
+		s, err := str(env)
+		if err != nil {
+			return false, err
+		}
+		val, err := rhs(env)
+		if err != nil {
+			return false, err
+		}
+		pattern, ok := val.(parse.String)
+		if !ok {
+			return false, fmt.Errorf("%w: cannot apply %v with %v value '%v'", parse.ErrEval, op, val.Kind(), val)
+		}
+		return match(s, string(pattern))
+	}, nil
+}
+
+func matchRegexp(str func(*Env) (string, error), re *regexp.Regexp) predicate {
+	return func(env *Env) (bool, error) {
+		s, err := str(env)
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(s), nil
+	}
+}
+
+func (c *compiler) compileArith(ast *arith.BinExpr) (value, error) {
+	var first value
+	var ops []arith.Op
+	var operands []value
+	err := arith.WalkChain(ast, func(b *arith.BinExpr, deepest bool) error {
+		var err error
+		if deepest {
+			if first, err = c.compile(b.LHS); err != nil {
+				return err
+			}
+		}
+		operand, err := c.compile(b.RHS)
+		if err != nil {
+			return err
+		}
+		ops, operands = append(ops, b.Op), append(operands, operand)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (parse.Value, error) {
+		val, err := first(env)
+		if err != nil {
+			return nil, err
+		}
+		for i, op := range ops {
+			rhs, err := operands[i](env)
+			if err != nil {
+				return nil, err
+			}
+			if val, err = eval.Arith(op, val, rhs); err != nil {
+				return nil, err
+			}
+		}
+		return val, nil
+	}, nil
+}
+
+func (c *compiler) compileCond(ast *cond.CondExpr) (value, error) {
+	// compile chains of conditionals in the else branch iteratively, since they may be very long
+	var conds []predicate
+	var thens []value
+	for {
+		pred, err := c.operand(ast.Cond, nil)
+		if err != nil {
+			return nil, err
+		}
+		then, err := c.compile(ast.Then)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, pred)
+		thens = append(thens, then)
+		next, ok := ast.Else.(*cond.CondExpr)
+		if !ok {
+			break
+		}
+		ast = next
+	}
+	els, err := c.compile(ast.Else)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *Env) (parse.Value, error) {
+		for i, pred := range conds {
+			ok, err := pred(env)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return thens[i](env)
+			}
+		}
+		return els(env)
+	}, nil
+}
+
+func (c *compiler) compileCoalesce(ast *coalesce.CoalesceExpr) (value, error) {
+	if len(ast.Operands) == 0 {
+		return nil, fmt.Errorf("%w: CoalesceExpr has no operands", parse.ErrEval)
+	}
+	operands := make([]value, len(ast.Operands))
+	for i, operand := range ast.Operands {
+		var err error
+		if operands[i], err = c.compile(operand); err != nil {
+			return nil, err
+		}
+	}
+	last := len(operands) - 1
+	return func(env *Env) (parse.Value, error) {
+		for _, operand := range operands[:last] {
+			val, err := operand(env)
+			if err != nil && !errors.Is(err, parse.ErrNotFound) {
+				return nil, err
+			}
+			if err == nil && val.Kind() != parse.KindNull {
+				return val, nil
+			}
+		}
+		return operands[last](env)
+	}, nil
+}
+
+func (c *compiler) compileCall(ast *funcs.CallExpr) (value, error) {
+	fn, err := c.funcs.Resolve(ast)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]value, len(ast.Args))
+	for i, arg := range ast.Args {
+		if args[i], err = c.compile(arg); err != nil {
+			return nil, err
+		}
+	}
+	return func(env *Env) (parse.Value, error) {
+		vals := make([]any, len(args))
+		for i, arg := range args {
+			val, err := arg(env)
+			if vals[i], err = fn.Arg(ast.Name, i, val, err); err != nil {
+				return nil, err
+			}
+		}
+		result, err := fn.Invoke(ast.Name, vals)
+		if err != nil {
+			return nil, err
+		}
+		return parse.ValueOf(result)
+	}, nil
+}
+
+// ordering returns a function reporting whether the result of parse.Compare satisfies the provided ordinal operator.
+func ordering(op comp.Op) (func(int) bool, error) {
+	switch op {
+	case comp.OpGreater:
+		return func(cmp int) bool { return cmp > 0 }, nil
+	case comp.OpGreaterOrEqual:
+		return func(cmp int) bool { return cmp >= 0 }, nil
+	case comp.OpLess:
+		return func(cmp int) bool { return cmp < 0 }, nil
+	case comp.OpLessOrEqual:
+		return func(cmp int) bool { return cmp <= 0 }, nil
+	}
+	return nil, fmt.Errorf("%w: unexpected ordinal operator: %v", parse.ErrEval, op)
+}
@@ -0,0 +1,440 @@
+package compile
+
+import (
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/arith"
+	"github.com/orkes-io/go-parse/bools"
+	"github.com/orkes-io/go-parse/coalesce"
+	"github.com/orkes-io/go-parse/comp"
+	"github.com/orkes-io/go-parse/cond"
+	"github.com/orkes-io/go-parse/eval"
+	"github.com/orkes-io/go-parse/funcs"
+	"github.com/orkes-io/go-parse/internal/exprtest"
+	"github.com/orkes-io/go-parse/literal"
+	"github.com/orkes-io/go-parse/vars"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var doc = map[string]any{
+	"priority": 7,
+	"ratio":    0.5,
+	"name":     "task_12",
+	"retry":    false,
+	"tags":     []string{"a", "b"},
+	"started":  time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC),
+	"deadline": time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+	"timeout":  5 * time.Minute,
+	"output":   map[string]any{"result": nil, "count": int64(3)},
+}
+
+func TestProgram_Value(t *testing.T) {
+	tests := []string{
+		"${priority} > 5 AND NOT ${retry}",
+		"${priority} * 2 + 1",
+		"${priority} / 2 + ${ratio}",
+		"-${priority} % 4 == -3",
+		"2 ** 10 == 1024.0",
+		"${priority} BETWEEN 1 AND 10 AND ${ratio} NOT BETWEEN 1 AND 2",
+		"0 < ${ratio} <= ${priority} AND NOT 1 < 2 < 2",
+		"'b' IN ${tags} AND ${priority} NOT IN (1, 2, 3) AND 3 IN (1, 2, ${output.count})",
+		"${name} LIKE 'task\\\\_%' AND ${name} =~ '[0-9]+$' AND ${name} STARTS WITH 'task'",
+		"${name} CONTAINS ${tags[0]} AND ${name} ENDS WITH '12'",
+		"${name} LIKE ${tags[1]} OR ${name} =~ ${tags[0]}",
+		"${output.result} IS NULL AND ${output?.missing} IS NULL AND ${output.count} IS NOT NULL",
+		"${missing} IS NOT NULL",
+		"${output.result} ?? ${output?.missing} ?? ${output.count} + 1",
+		"${missing} ?? ${name}",
+		"${priority} > 5 ? 'high' : 'low'",
+		"${retry} ? 1 : ${ratio} > 1 ? 2 : 3",
+		"${started} + ${timeout} > ${started} AND ${started} > 2024-01-02",
+		"${deadline} - ${started}",
+		"${timeout} * 3 - 1m",
+		"${tags}",
+		"${output}",
+		"null",
+		"(1 == 1.0) == true AND '1' != 1",
+		"${retry} OR ${priority} == 7 AND ${name} != 'x'",
+
+		// errors
+		"${priority} AND true",
+		"NOT ${name}",
+		"false OR 1",
+		"${name} > 1",
+		"1 < 2 < 'a'",
+		"${priority} BETWEEN 'a' AND 10",
+		"${priority} BETWEEN 1 AND 'a'",
+		"1 IN ${name}",
+		"${priority} LIKE 'a'",
+		"${name} CONTAINS 1",
+		"${name} =~ ${name} + '('",
+		"${name} + 1",
+		"-${name}",
+		"${ratio} / 0",
+		"${priority} ? 1 : 2",
+		"${missing} > 1",
+		"${missing} ?? ${other}",
+		"${missing} IS NULL AND ${priority} / 0 IS NULL",
+	}
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			ast := exprtest.Parse(t, input)
+			expected, expectedErr := eval.Eval(ast, vars.Interpreter(doc))
+
+			prog, err := Compile(ast)
+			require.NoError(t, err)
+			result, err := prog.Value(prog.NewEnv(vars.Interpreter(doc)))
+			if expectedErr != nil {
+				assert.EqualError(t, err, expectedErr.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, expected, result)
+			}
+		})
+	}
+}
+
+func TestWithFuncs(t *testing.T) {
+	tests := []string{
+		"len(${tags}) + 1",
+		"len(${name}) == 7 AND upper(${name}) STARTS WITH 'TASK'",
+		"upper(trim(' x ')) == 'X' AND lower(${name}) == ${name}",
+		"abs(${priority} - 10) > 2 AND max(${ratio}, 1) == 1",
+		"max(${priority}, 2) * 2",
+		"coalesce(${missing}, ${output.result}, ${name})",
+		"now() > ${started}",
+
+		// errors
+		"len(1)",
+		"abs(${name})",
+		"lower(${priority})",
+		"lower(${missing})",
+		"${retry} AND len(1) > 0",
+	}
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			ast := exprtest.Parse(t, input)
+			expected, expectedErr := eval.Eval(ast, vars.Interpreter(doc), eval.WithFuncs(funcs.Std()))
+
+			prog, err := Compile(ast, WithFuncs(funcs.Std()))
+			require.NoError(t, err)
+			result, err := prog.Value(prog.NewEnv(vars.Interpreter(doc)))
+			if expectedErr != nil {
+				assert.EqualError(t, err, expectedErr.Error())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, expected, result)
+			}
+		})
+	}
+
+	// unknown functions and wrong arities are reported by Compile
+	for _, input := range []string{"unknown(1)", "len(${tags}, 1)", "${retry} OR lower() == ''"} {
+		_, err := Compile(exprtest.Parse(t, input), WithFuncs(funcs.Std()))
+		assert.ErrorIs(t, err, parse.ErrEval, input)
+	}
+
+	// without WithFuncs, calls are found by the leaves Interpreter
+	leaves := func(ast parse.AST) (any, error) {
+		if call, ok := ast.(*funcs.CallExpr); ok && call.Name == "answer" {
+			return 42, nil
+		}
+		return vars.Interpreter(doc)(ast)
+	}
+	prog, err := Compile(exprtest.Parse(t, "answer() - ${priority}"))
+	require.NoError(t, err)
+	result, err := prog.Value(prog.NewEnv(leaves))
+	require.NoError(t, err)
+	assert.Equal(t, parse.Int(35), result)
+}
+
+func TestProgram_Eval(t *testing.T) {
+	tests := []struct {
+		input  string
+		output bool
+		err    string
+	}{
+		{"${priority} > 5 AND NOT ${retry}", true, ""},
+		{"${retry}", false, ""},
+		{"${priority} > 5 ? ${retry} : true", false, ""},
+		{"${tags[0]} ?? false", false, "condition must be a bool; found string value 'a'"},
+		{"${priority} + 1", false, "condition must be a bool; found int value '8'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			prog, err := Compile(exprtest.Parse(t, tt.input))
+			require.NoError(t, err)
+			result, err := prog.Eval(prog.NewEnv(vars.Interpreter(doc)))
+			assert.Equal(t, tt.output, result)
+			if tt.err != "" {
+				assert.ErrorIs(t, err, parse.ErrEval)
+				assert.ErrorContains(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCompileError(t *testing.T) {
+	one := &literal.Lit{Kind: literal.KindInt, Value: int64(1)}
+	tests := []parse.AST{
+		nil,
+		&bools.BinExpr{Op: bools.OpNot, LHS: one, RHS: one},
+		&bools.ListExpr{Op: bools.OpNot, Terms: []parse.AST{one}},
+		&bools.UnaryExpr{Op: bools.OpAnd, Expr: one},
+		&bools.UnaryExpr{Op: bools.OpNot, Expr: nil},
+		&comp.EqualExpr{Op: comp.OpLess, LHS: one, RHS: one},
+		&comp.OrdinalExpr{Op: comp.OpEqual, LHS: one, RHS: one},
+		&comp.ChainExpr{Operands: []parse.AST{one}, Ops: []comp.Op{comp.OpLess}},
+		&comp.ChainExpr{Operands: []parse.AST{one, one}, Ops: []comp.Op{comp.OpIn}},
+		&comp.BetweenExpr{Op: comp.OpIn, Expr: one, Lower: one, Upper: one},
+		&comp.InExpr{Op: comp.OpLike, LHS: one, RHS: one},
+		&comp.MatchExpr{Op: comp.OpEqual, LHS: one, RHS: one},
+		&comp.NullExpr{Op: comp.OpEqual, Expr: one},
+		&arith.UnaryExpr{Op: arith.OpAdd, Expr: one},
+		&cond.CondExpr{Cond: one, Then: one, Else: &cond.CondExpr{Cond: one, Then: one}},
+		&coalesce.CoalesceExpr{},
+	}
+	for i, tt := range tests {
+		_, err := Compile(tt)
+		assert.ErrorIs(t, err, parse.ErrEval, "case %d", i)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	var evaluated []string
+	leaves := func(ast parse.AST) (any, error) {
+		ref := ast.(*vars.Ref)
+		evaluated = append(evaluated, ref.String())
+		return vars.Resolve(ref, doc)
+	}
+	tests := []struct {
+		input     string
+		vars      []string
+		evaluated []string
+	}{
+		{"${retry} AND ${missing}", []string{"${retry}", "${missing}"}, []string{"${retry}"}},
+		{"NOT ${retry} OR ${missing}", []string{"${retry}", "${missing}"}, []string{"${retry}"}},
+		{"${priority} IN (1, ${priority}, ${missing})", []string{"${priority}", "${missing}"}, []string{"${priority}"}},
+		{"${retry} ? ${missing} : ${name}", []string{"${retry}", "${missing}", "${name}"}, []string{"${retry}", "${name}"}},
+		{"${output.count} ?? ${missing}", []string{"${output.count}", "${missing}"}, []string{"${output.count}"}},
+		{"${missing} IS NULL AND ${missing} ?? true", []string{"${missing}"}, []string{"${missing}"}},
+		{"1 + 2 == 3", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			prog, err := Compile(exprtest.Parse(t, tt.input))
+			require.NoError(t, err)
+			var names []string
+			for _, v := range prog.Vars() {
+				names = append(names, fmt.Sprint(v))
+			}
+			assert.Equal(t, tt.vars, names)
+
+			evaluated = nil
+			env := prog.NewEnv(leaves)
+			result, err := prog.Value(env)
+			require.NoError(t, err)
+			assert.Equal(t, tt.evaluated, evaluated)
+
+			// values are kept until the Env is reset
+			again, err := prog.Value(env)
+			require.NoError(t, err)
+			assert.Equal(t, result, again)
+			assert.Equal(t, tt.evaluated, evaluated)
+
+			evaluated = nil
+			env.Reset(leaves)
+			_, err = prog.Value(env)
+			require.NoError(t, err)
+			assert.Equal(t, tt.evaluated, evaluated)
+		})
+	}
+
+	prog, err := Compile(exprtest.Parse(t, "${priority} > 5"))
+	require.NoError(t, err)
+	env := prog.NewEnv(vars.Interpreter(doc))
+	ok, err := prog.Eval(env)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	env.Reset(vars.Interpreter(map[string]any{"priority": 1}))
+	ok, err = prog.Eval(env)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = prog.Eval(prog.NewEnv(nil))
+	assert.ErrorIs(t, err, parse.ErrUnknownAST)
+	_, err = prog.Eval(nil)
+	assert.ErrorIs(t, err, parse.ErrEval)
+	other, err := Compile(exprtest.Parse(t, "${priority} > 5"))
+	require.NoError(t, err)
+	_, err = prog.Value(other.NewEnv(vars.Interpreter(doc)))
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
+
+func TestCompile_Slots(t *testing.T) {
+	quoted := parse.Unparsed{Contents: []parse.Token{{Text: "'a b'", Quote: '\''}}}
+	leaves := map[string]bool{"a b": true, "a, b": false, "'a b'": true}
+	ast := &bools.BinExpr{
+		Op:  bools.OpAnd,
+		LHS: &bools.BinExpr{Op: bools.OpAnd, LHS: parse.Unparsed{Contents: parse.NewTokens("a b")}, RHS: quoted},
+		RHS: &bools.UnaryExpr{Op: bools.OpNot, Expr: parse.Unparsed{Contents: parse.NewTokens("a", "b")}},
+	}
+	prog, err := Compile(ast)
+	require.NoError(t, err)
+	assert.Len(t, prog.Vars(), 3)
+	ok, err := prog.Eval(prog.NewEnv(func(ast parse.AST) (any, error) {
+		u := ast.(parse.Unparsed)
+		if len(u.Contents) > 1 {
+			return leaves["a, b"], nil
+		}
+		return leaves[u.Contents[0].Text], nil
+	}))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	prog, err = Compile(exprtest.Parse(t, "${a.b} > 1 OR ${a?.b} > 1 OR ${a['b']} > 1 OR ${a[0]} > 1 OR ${a} > 1"))
+	require.NoError(t, err)
+	var names []string
+	for _, v := range prog.Vars() {
+		names = append(names, fmt.Sprint(v))
+	}
+	assert.Equal(t, []string{"${a.b}", "${a?.b}", "${a[0]}", "${a}"}, names)
+}
+
+func TestCompile_Deep(t *testing.T) {
+	const n = 10000
+	tests := []struct {
+		input  string
+		output parse.Value
+		vars   int
+	}{
+		{strings.Repeat("${priority} > 1 AND ", n) + "true", parse.Bool(true), 1},
+		{strings.Repeat("1 + ", n) + "1", parse.Int(n + 1), 0},
+		{strings.Repeat("false ? 1 : ", n) + "2", parse.Int(2), 0},
+	}
+	for _, tt := range tests {
+		ast := exprtest.Parse(t, tt.input)
+		for _, ast := range []parse.AST{ast, bools.Flatten(ast)} {
+			prog, err := Compile(ast)
+			require.NoError(t, err)
+			assert.Len(t, prog.Vars(), tt.vars)
+			result, err := prog.Value(prog.NewEnv(vars.Interpreter(doc)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, result)
+		}
+	}
+}
+
+func TestProgram_Concurrent(t *testing.T) {
+	prog, err := Compile(exprtest.Parse(t, "${priority} > 5 AND ${name} LIKE 'task%' ? ${priority} * 2 : 0"))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := prog.NewEnv(nil)
+			for j := 0; j < 100; j++ {
+				env.Reset(vars.Interpreter(map[string]any{"priority": i + j, "name": "task"}))
+				result, err := prog.Value(env)
+				if assert.NoError(t, err) && i+j > 5 {
+					assert.Equal(t, parse.Int(2*(i+j)), result)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkProgram_Eval(b *testing.B) {
+	p, err := bools.NewParser()
+	require.NoError(b, err)
+	for _, n := range []int{100, 1000, 10000} {
+		ast, err := p.ParseStr(chain(n))
+		require.NoError(b, err)
+		variables := make(map[string]bool, n)
+		for i := 0; i < n; i++ {
+			variables[fmt.Sprintf("c%d", i)] = i%2 == 0
+		}
+		interpreter := bools.VarInterpreter(variables)
+		leaves := func(ast parse.AST) (any, error) {
+			return interpreter(ast)
+		}
+		prog, err := Compile(ast)
+		require.NoError(b, err)
+
+		b.Run(fmt.Sprintf("bools.Eval/clauses=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := bools.Eval(ast, interpreter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("Program.Eval/clauses=%d", n), func(b *testing.B) {
+			env := prog.NewEnv(leaves)
+			for i := 0; i < b.N; i++ {
+				env.Reset(leaves)
+				if _, err := prog.Eval(env); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkProgram_Eval_Combined(b *testing.B) {
+	ast := exprtest.Parse(b, "${priority} > 5 AND ${name} LIKE 'task\\\\_%' AND ${output.count} * 2 + 1 <= ${priority} "+
+		"OR ${ratio} BETWEEN 0 AND 1 AND ${tags[0]} IN ('a', 'b', 'c')")
+	leaves := vars.Interpreter(doc)
+	values := comp.Interpreter(arith.Interpreter(literal.Interpreter().WithFallback(leaves)))
+	prog, err := Compile(ast)
+	require.NoError(b, err)
+
+	b.Run("bools.Eval", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := bools.Eval(ast, values); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("eval.Eval", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := eval.Eval(ast, leaves); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Program.Eval", func(b *testing.B) {
+		env := prog.NewEnv(leaves)
+		for i := 0; i < b.N; i++ {
+			env.Reset(leaves)
+			if _, err := prog.Eval(env); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// chain returns an expression containing n clauses, joined by alternating runs of AND and OR.
+func chain(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			if (i/10)%2 == 0 {
+				sb.WriteString(" AND ")
+			} else {
+				sb.WriteString(" OR ")
+			}
+		}
+		fmt.Fprintf(&sb, "c%d", i)
+	}
+	return sb.String()
+}
@@ -0,0 +1,503 @@
+// Package cond implements a recursive-descent parser for conditional expressions according to the following grammar.
+//
+//	expr     -> term '?' expr ':' expr | 'IF' expr 'THEN' expr 'ELSE' expr | term
+//	term     -> '(' expr ')' | unparsed
+//	unparsed -> .*
+//
+// Conditional expressions are parsed into CondExpr nodes. They are right-associative, so that a ? b : c ? d : e is
+// parsed as a ? b : (c ? d : e). Both the symbolic syntax and the keyword syntax can be configured at runtime, see
+// NewParser for details. By default, only the symbolic syntax is recognized.
+//
+// It leaves the condition and both branches in parse.Unparsed nodes, for later consumption, so that the condition of
+// ${priority} > 5 ? 'fast' : 'slow' is consumed by the comp package. Conditional expressions bind less tightly than
+// any other expression provided by this module, so this parser should run first in a pipeline.
+//
+// The null-safe operators ?. and ??, of the vars and coalesce packages, are never split by this parser, so that
+// ${a?.b} ?? c ? d : e contains a single conditional. Since operators are otherwise recognized anywhere, unquoted text
+// containing a ':', such as the time literal
+// 2024-01-02T15:04:05Z, is split by this parser. Such text must be quoted where it appears within a conditional.
+package cond
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"strings"
+	"unicode"
+)
+
+// CondExpr represents a conditional expression, whose value is that of Then if Cond holds, and that of Else otherwise.
+type CondExpr struct {
+	Cond parse.AST  // Cond is the condition.
+	Then parse.AST  // Then is the branch chosen when the condition holds.
+	Else parse.AST  // Else is the branch chosen when the condition does not hold.
+	Src  parse.Span // Src is the location of this expression in the source text.
+}
+
+// String returns this expression in the default syntax of this package.
+func (c *CondExpr) String() string {
+	return format(c)
+}
+
+// Span returns the location of this expression in the source text.
+func (c *CondExpr) Span() parse.Span {
+	return c.Src
+}
+
+// Parse runs the provided parse.Parser on all unparsed nodes in this AST.
+func (c *CondExpr) Parse(p parse.Parser) error {
+	for _, child := range []*parse.AST{&c.Cond, &c.Then, &c.Else} {
+		if err := parseChild(child, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Children returns the condition and both branches of this expression.
+func (c *CondExpr) Children() []parse.AST {
+	return []parse.AST{c.Cond, c.Then, c.Else}
+}
+
+// WithChildren returns a copy of this expression with the provided condition and branches.
+func (c *CondExpr) WithChildren(children []parse.AST) parse.AST {
+	checkChildren("CondExpr", children, 3)
+	return &CondExpr{Cond: children[0], Then: children[1], Else: children[2], Src: c.Src}
+}
+
+// checkChildren panics if the number of children passed to WithChildren is not as expected.
+func checkChildren(node string, children []parse.AST, expected int) {
+	if len(children) != expected {
+		panic(fmt.Sprintf("cond: %s.WithChildren called with %d children; expected %d", node, len(children), expected))
+	}
+}
+
+// parseChild replaces the provided child with the result of parsing it if it is parse.Unparsed, and otherwise parses
+// it recursively.
+func parseChild(child *parse.AST, p parse.Parser) error {
+	if unparsed, ok := (*child).(parse.Unparsed); ok {
+		parsed, err := p.Parse(unparsed.Contents)
+		if err != nil {
+			return err
+		}
+		*child = parsed
+		return nil
+	}
+	return (*child).Parse(p)
+}
+
+// Token is a token required by this grammar.
+type Token uint8
+
+const (
+	Question   Token = iota + 1 // Question separates the condition from the first branch in the symbolic syntax.
+	Colon                       // Colon separates the branches in the symbolic syntax.
+	If                          // If begins a conditional expression in the keyword syntax.
+	Then                        // Then separates the condition from the first branch in the keyword syntax.
+	Else                        // Else separates the branches in the keyword syntax.
+	OpenParen                   // OpenParen represents the start of a sub-expression.
+	CloseParen                  // CloseParen represents the end of a sub-expression.
+)
+
+type ParserOpt func(*Parser)
+
+// Parser parses this grammar. A Parser is safe for concurrent use by multiple goroutines.
+type Parser struct {
+	config          map[Token]string
+	caseInsensitive bool
+	wordBoundaries  bool
+	quotes          []rune
+
+	symbolic  bool // symbolic is set if the symbolic syntax is configured.
+	matcher   *parse.KeywordTrie
+	tokenizer parse.Tokenizer
+}
+
+// parser holds the state of a single call to Parser.Parse, so that a Parser can be used concurrently.
+type parser struct {
+	*Parser
+	tokens     []parse.Token
+	curr       int
+	expected   []Token // expected lists the tokens which failed to match at index expectedAt.
+	expectedAt int
+}
+
+// WithTokens configures the syntax used by this parser using the provided token mapping. The provided map must contain
+// distinct entries for OpenParen and CloseParen, along with the tokens of at least one syntax: Question and Colon for
+// the symbolic syntax, or If, Then and Else for the keyword syntax. Each syntax is only recognized if all of its tokens
+// are present, and both may be configured at once, in which case the symbolic syntax is used when printing.
+func WithTokens(config map[Token]string) ParserOpt {
+	return func(parser *Parser) {
+		parser.config = config
+	}
+}
+
+// WithWordBoundaries sets whether keywords which begin or end with a letter, digit or underscore are only recognized
+// at identifier boundaries when tokenizing. Symbolic keywords such as '?' are recognized anywhere. Word boundaries
+// are required by default, so that, for instance, the identifier LIFE is not tokenized as the keyword IF followed by
+// E unless this option is disabled.
+func WithWordBoundaries(wordBoundaries bool) ParserOpt {
+	return func(parser *Parser) {
+		parser.wordBoundaries = wordBoundaries
+	}
+}
+
+// WithQuotes sets the runes which delimit string literals. By default, the runes in parse.DefaultQuotes are used.
+// String literals are always treated as a single token, and are never interpreted as keywords, so that the expression
+// x ? "a:b" : c contains a single conditional. Calling WithQuotes with no arguments disables string literals.
+func WithQuotes(quotes ...rune) ParserOpt {
+	return func(parser *Parser) {
+		parser.quotes = quotes
+	}
+}
+
+// WithCaseSensitive sets whether the configured parser is case-sensitive.
+func WithCaseSensitive(caseSensitive bool) ParserOpt {
+	return func(parser *Parser) {
+		parser.caseInsensitive = !caseSensitive
+	}
+}
+
+// NewParser returns a parser configured according to the provided options. If no options are configured, the default
+// parser is returned, which recognizes the symbolic syntax a ? b : c.
+func NewParser(opts ...ParserOpt) (*Parser, error) {
+	p := &Parser{
+		config: map[Token]string{
+			Question:   "?",
+			Colon:      ":",
+			OpenParen:  "(",
+			CloseParen: ")",
+		},
+		matcher:        &parse.KeywordTrie{},
+		quotes:         parse.DefaultQuotes,
+		wordBoundaries: true,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Parser) init() error {
+	if len(p.config[OpenParen]) != 1 || len(p.config[CloseParen]) != 1 {
+		return fmt.Errorf("%w: OpenParen and CloseParen must each have length 1", parse.ErrConfig)
+	}
+	if p.config[OpenParen] == p.config[CloseParen] {
+		return fmt.Errorf("%w: OpenParen and CloseParen must each be distinct", parse.ErrConfig)
+	}
+	for token, str := range p.config {
+		if str == "" || strings.IndexFunc(str, unicode.IsSpace) >= 0 {
+			return fmt.Errorf("%w: token %d must be non-empty and must not contain whitespace", parse.ErrConfig, token)
+		}
+	}
+	p.symbolic = p.has(Question) && p.has(Colon)
+	if !p.symbolic && (p.has(Question) || p.has(Colon)) {
+		return fmt.Errorf("%w: Question and Colon must be configured together", parse.ErrConfig)
+	}
+	keywords := p.has(If) && p.has(Then) && p.has(Else)
+	if !keywords && (p.has(If) || p.has(Then) || p.has(Else)) {
+		return fmt.Errorf("%w: If, Then and Else must be configured together", parse.ErrConfig)
+	}
+	if !p.symbolic && !keywords {
+		return fmt.Errorf("%w: either Question and Colon or If, Then and Else must be configured", parse.ErrConfig)
+	}
+	p.tokenizer = parse.Tokenizer{
+		Open:           []rune(p.config[OpenParen])[0],
+		Close:          []rune(p.config[CloseParen])[0],
+		Keywords:       p.matcher,
+		Quotes:         p.quotes,
+		WordBoundaries: p.wordBoundaries,
+	}
+	for _, q := range p.quotes {
+		if unicode.IsSpace(q) || q == p.tokenizer.Open || q == p.tokenizer.Close {
+			return fmt.Errorf("%w: quote '%c' must not be whitespace, OpenParen or CloseParen", parse.ErrConfig, q)
+		}
+		for _, str := range p.config {
+			if strings.ContainsRune(str, q) {
+				return fmt.Errorf("%w: quote '%c' must not appear in any configured token", parse.ErrConfig, q)
+			}
+		}
+	}
+	if p.caseInsensitive {
+		newTokens := make(map[Token]string, len(p.config))
+		for token, str := range p.config {
+			newTokens[token] = strings.ToLower(str)
+		}
+		p.config = newTokens
+	}
+	for _, str := range p.config {
+		p.matcher.Add(str)
+	}
+	if p.matcher.Count() != len(p.config) {
+		return fmt.Errorf("%w: token collision detected; at least two of the provided tokens are identical", parse.ErrConfig)
+	}
+	p.tokenizer.Atoms = &parse.KeywordTrie{}
+	for _, atom := range atoms {
+		if !p.matcher.Contains(atom) {
+			p.tokenizer.Atoms.Add(atom)
+		}
+	}
+	return nil
+}
+
+// atoms lists the operators of other packages which begin with the default Question token, and so must not be split.
+var atoms = []string{"?.", "??"}
+
+// has reports whether the provided Token is configured.
+func (p *Parser) has(token Token) bool {
+	_, ok := p.config[token]
+	return ok
+}
+
+// ParseStr tokenizes and parses the provided string. See Parser.Parse for details. Any *parse.SyntaxError returned
+// includes the provided string as its Source.
+func (p *Parser) ParseStr(str string) (parse.AST, error) {
+	tokens, err := p.tokenizer.Tokenize(str)
+	var ast parse.AST
+	if err == nil {
+		ast, err = p.Parse(tokens)
+	}
+	var syntaxErr *parse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Source = str
+	}
+	return ast, err
+}
+
+// Parse parses the provided list of tokens, producing a parse.AST. The provided tokens are first split further at
+// every operator, since tokens produced by other parsers may not separate them from their operands, as in x?a:b. A
+// *parse.SyntaxError is returned if the provided tokens do not conform to the grammar specified in this package.
+func (p *Parser) Parse(tokens []parse.Token) (parse.AST, error) {
+	tokens, err := p.tokenizer.Retokenize(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return (&parser{Parser: p, tokens: tokens, expectedAt: -1}).parse()
+}
+
+func (p *parser) parse() (parse.AST, error) {
+	ast, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.curr != len(p.tokens) {
+		return nil, p.errorf("expected end of expression; found '%s'", p.peek().Text)
+	}
+	return ast, nil
+}
+
+func (p *Parser) isKeyword(token parse.Token) bool {
+	if token.Quote != 0 {
+		return false
+	}
+	str := token.Text
+	if p.caseInsensitive {
+		str = strings.ToLower(str)
+	}
+	return p.matcher.Contains(str)
+}
+
+func (p *parser) match(token Token) bool {
+	if p.is(p.curr, token) {
+		p.curr++
+		return true
+	}
+	p.expect(token)
+	return false
+}
+
+// is reports whether the token at index i is the provided Token. Tokens which are not configured never match.
+func (p *parser) is(i int, token Token) bool {
+	if i >= len(p.tokens) || p.tokens[i].Quote != 0 || !p.has(token) {
+		return false
+	}
+	curr := p.tokens[i].Text
+	if p.caseInsensitive {
+		curr = strings.ToLower(curr)
+	}
+	return curr == p.config[token]
+}
+
+// groupEnd returns the index of the CloseParen matching the OpenParen at index i, or the number of tokens if it is
+// not closed. If the token at index i is not an OpenParen, -1 is returned.
+func (p *parser) groupEnd(i int) int {
+	if !p.is(i, OpenParen) {
+		return -1
+	}
+	depth := 0
+	for ; i < len(p.tokens); i++ {
+		if p.is(i, OpenParen) {
+			depth++
+		} else if p.is(i, CloseParen) {
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(p.tokens)
+}
+
+// expect records that the provided token was expected at the current position, for use in error messages.
+func (p *parser) expect(token Token) {
+	if p.expectedAt != p.curr {
+		p.expected, p.expectedAt = p.expected[:0], p.curr
+	}
+	for _, t := range p.expected {
+		if t == token {
+			return
+		}
+	}
+	p.expected = append(p.expected, token)
+}
+
+// errorf returns a *parse.SyntaxError located at the current token.
+func (p *parser) errorf(format string, args ...any) error {
+	var expected []string
+	if p.expectedAt == p.curr {
+		for _, token := range p.expected {
+			expected = append(expected, p.config[token])
+		}
+	}
+	return parse.NewSyntaxError(p.tokens, p.curr, expected, format, args...)
+}
+
+func (p *parser) peek() parse.Token {
+	return p.tokens[p.curr]
+}
+
+// span returns the location of the tokens from start up to the current token.
+func (p *parser) span(start int) parse.Span {
+	return parse.Span{Start: p.tokens[start].Src.Start, End: p.tokens[p.curr-1].Src.End}
+}
+
+func (p *parser) parseExpr() (parse.AST, error) {
+	start := p.curr
+	if p.match(If) {
+		cond, err := p.parseBranch(Then)
+		if err != nil {
+			return nil, err
+		}
+		then, err := p.parseBranch(Else)
+		if err != nil {
+			return nil, err
+		}
+		els, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &CondExpr{Cond: cond, Then: then, Else: els, Src: p.span(start)}, nil
+	}
+	cond, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	if !p.match(Question) {
+		return cond, nil
+	}
+	then, err := p.parseBranch(Colon)
+	if err != nil {
+		return nil, err
+	}
+	els, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &CondExpr{Cond: cond, Then: then, Else: els, Src: p.span(start)}, nil
+}
+
+// parseBranch parses an expression which must be followed by the provided separator, and consumes the separator.
+func (p *parser) parseBranch(sep Token) (parse.AST, error) {
+	ast, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.match(sep) {
+		return nil, p.errorf("expected '%s'", p.config[sep])
+	}
+	return ast, nil
+}
+
+func (p *parser) parseTerm() (parse.AST, error) {
+	// a group followed by more of the same term, as in (a) AND (b), is left for later consumption
+	if end := p.groupEnd(p.curr); end < 0 || end+1 >= len(p.tokens) || p.isKeyword(p.tokens[end+1]) {
+		if p.match(OpenParen) {
+			ast, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.match(CloseParen) {
+				return nil, p.errorf("expected '%s'", p.config[CloseParen])
+			}
+			return ast, nil
+		}
+	}
+	return p.parseRest()
+}
+
+func (p *parser) parseRest() (parse.AST, error) {
+	var result []parse.Token
+	for p.curr < len(p.tokens) {
+		if end := p.groupEnd(p.curr); end >= 0 && end < len(p.tokens) {
+			// keep balanced groups which form part of a term, such as f(x)
+			result = append(result, p.tokens[p.curr:end+1]...)
+			p.curr = end + 1
+			continue
+		}
+		if p.isKeyword(p.peek()) {
+			break
+		}
+		result = append(result, p.peek())
+		p.curr++
+	}
+	if result == nil {
+		if p.curr < len(p.tokens) {
+			return nil, p.errorf("unexpected '%s'", p.peek().Text)
+		}
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return parse.Unparsed{Contents: result}, nil
+}
+
+// Print implements parse.Printer, rendering CondExpr nodes using the syntax configured for this Parser. If both the
+// symbolic and the keyword syntax are configured, the symbolic syntax is used. Parentheses are only added where they
+// are required, so that parsing the resulting text using this Parser produces the original AST.
+func (p *Parser) Print(ast parse.AST, f *parse.Formatter) (string, int, error) {
+	c, ok := ast.(*CondExpr)
+	if !ok {
+		return "", 0, fmt.Errorf("%w: %T", parse.ErrUnknownAST, ast)
+	}
+	open, close := p.config[OpenParen], p.config[CloseParen]
+	// a conditional in the condition must be parenthesized, since conditionals are right-associative
+	cond, err := f.Operand(c.Cond, parse.PrecCond+1, open, close)
+	if err != nil {
+		return "", 0, err
+	}
+	then, err := f.Operand(c.Then, parse.PrecCond, open, close)
+	if err != nil {
+		return "", 0, err
+	}
+	els, err := f.Operand(c.Else, parse.PrecCond, open, close)
+	if err != nil {
+		return "", 0, err
+	}
+	if p.symbolic {
+		return cond + " " + p.config[Question] + " " + then + " " + p.config[Colon] + " " + els, parse.PrecCond, nil
+	}
+	text := p.config[If] + " " + cond + " " + p.config[Then] + " " + then + " " + p.config[Else] + " " + els
+	return text, parse.PrecCond, nil
+}
+
+// defaultParser is used to implement the String method of each node.
+var defaultParser, _ = NewParser()
+
+// format returns the text of the provided AST in the default syntax, or a description of the error encountered.
+func format(ast parse.AST) string {
+	text, err := parse.Format(ast, defaultParser)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return text
+}
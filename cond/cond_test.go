@@ -0,0 +1,216 @@
+package cond
+
+import (
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/asttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestParser_Parse(t *testing.T) {
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"a ? b : c", cond(un("a"), un("b"), un("c"))},
+		{"a?b:c", cond(un("a"), un("b"), un("c"))},
+		{"${priority} > 5 ? 'fast' : 'slow'", cond(un("${priority}", ">", "5"), un("'fast'"), un("'slow'"))},
+		{"a ? b : c ? d : e", cond(un("a"), un("b"), cond(un("c"), un("d"), un("e")))},
+		{"a ? b ? c : d : e", cond(un("a"), cond(un("b"), un("c"), un("d")), un("e"))},
+		{"(a ? b : c) ? d : e", cond(cond(un("a"), un("b"), un("c")), un("d"), un("e"))},
+		{"(a > 1) ? b : c", cond(un("a", ">", "1"), un("b"), un("c"))},
+		{"(a) AND (b) ? c : d", cond(un("(", "a", ")", "AND", "(", "b", ")"), un("c"), un("d"))},
+		{"a ? f(b, c) : (d)", cond(un("a"), un("f", "(", "b,", "c", ")"), un("d"))},
+		{"a ? 'x:y' : \"?\"", cond(un("a"), un("'x:y'"), un(`"?"`))},
+		{"(a ? b : c)", cond(un("a"), un("b"), un("c"))},
+		{"a > b", un("a", ">", "b")},
+		{"${a?.b} ?? c ? d : e", cond(un("${a?.b}", "??", "c"), un("d"), un("e"))},
+		{"a??b?c:d", cond(un("a??b"), un("c"), un("d"))},
+		{"IF a THEN b ELSE c", un("IF", "a", "THEN", "b", "ELSE", "c")},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+}
+
+func TestParser_Parse_Retokenize(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.Parse(parse.NewTokens("a?b:c"))
+	require.NoError(t, err)
+	assert.EqualValues(t, cond(un("a"), un("b"), un("c")), asttest.StripSpans(ast))
+}
+
+func TestParser_Span(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.ParseStr(" a ? b : c ? d : e ")
+	require.NoError(t, err)
+	c := ast.(*CondExpr)
+	assert.Equal(t, 1, c.Span().Start.Offset)
+	assert.Equal(t, 18, c.Span().End.Offset)
+	assert.Equal(t, 9, c.Else.(*CondExpr).Span().Start.Offset)
+}
+
+func TestParser_ParseError(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"a ? b", "expected ':'"},
+		{"a ? b :", "unexpected end of expression"},
+		{"a ? : c", "unexpected ':'"},
+		{"? b : c", "unexpected '?'"},
+		{"a : b", "expected end of expression; found ':'"},
+		{"a ? b : c : d", "expected end of expression; found ':'"},
+		{"(a ? b : c", "expected ')'"},
+		{"", "unexpected end of expression"},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := p.ParseStr(tt.input)
+			var syntaxErr *parse.SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.ErrorIs(t, err, parse.ErrParse)
+			assert.Contains(t, err.Error(), tt.msg)
+			assert.Equal(t, tt.input, syntaxErr.Source)
+		})
+	}
+}
+
+func TestParser_Concurrent(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"a ? b : c", cond(un("a"), un("b"), un("c"))},
+		{"a ? b : c ? d : e", cond(un("a"), un("b"), cond(un("c"), un("d"), un("e")))},
+		{"x", un("x")},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tt := tests[(g+i)%len(tests)]
+				ast, err := p.ParseStr(tt.input)
+				if assert.NoError(t, err, tt.input) {
+					assert.EqualValues(t, tt.output, asttest.StripSpans(ast), tt.input)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestParser_Print(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	custom, err := NewParser(WithTokens(map[Token]string{
+		If:         "IF",
+		Then:       "THEN",
+		Else:       "ELSE",
+		OpenParen:  "[",
+		CloseParen: "]",
+	}))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  parse.AST
+		output string
+		custom string
+	}{
+		{cond(un("a"), un("b"), un("c")), "a ? b : c", "IF a THEN b ELSE c"},
+		{cond(un("a"), un("b"), cond(un("c"), un("d"), un("e"))), "a ? b : c ? d : e", "IF a THEN b ELSE IF c THEN d ELSE e"},
+		{cond(un("a"), cond(un("b"), un("c"), un("d")), un("e")), "a ? b ? c : d : e", "IF a THEN IF b THEN c ELSE d ELSE e"},
+		{cond(cond(un("a"), un("b"), un("c")), un("d"), un("e")), "(a ? b : c) ? d : e", "IF [IF a THEN b ELSE c] THEN d ELSE e"},
+		{cond(un("a", ">", "1"), un("'x'"), un("y")), "a > 1 ? 'x' : y", "IF a > 1 THEN 'x' ELSE y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			for _, c := range []struct {
+				p        *Parser
+				expected string
+			}{{p, tt.output}, {custom, tt.custom}} {
+				text, err := parse.Format(tt.input, c.p)
+				require.NoError(t, err)
+				assert.Equal(t, c.expected, text)
+
+				ast, err := c.p.ParseStr(text)
+				require.NoError(t, err)
+				assert.EqualValues(t, tt.input, asttest.StripSpans(ast))
+			}
+			assert.Equal(t, tt.output, tt.input.(fmt.Stringer).String())
+		})
+	}
+}
+
+func TestWithTokens(t *testing.T) {
+	p, err := NewParser(WithCaseSensitive(false), WithTokens(map[Token]string{
+		Question:   "?",
+		Colon:      ":",
+		If:         "IF",
+		Then:       "THEN",
+		Else:       "ELSE",
+		OpenParen:  "(",
+		CloseParen: ")",
+	}))
+	require.NoError(t, err)
+	ast, err := p.ParseStr("if a then b ? c : d else ELSEWHERE")
+	require.NoError(t, err)
+	assert.EqualValues(t, cond(un("a"), cond(un("b"), un("c"), un("d")), un("ELSEWHERE")), asttest.StripSpans(ast))
+
+	_, err = p.ParseStr("IF a THEN b")
+	assert.ErrorContains(t, err, "expected 'else'")
+
+	testWithErrors := []map[Token]string{
+		{Question: "?", Colon: ":"},
+		{Question: "?", OpenParen: "(", CloseParen: ")"},
+		{If: "IF", Then: "THEN", OpenParen: "(", CloseParen: ")"},
+		{OpenParen: "(", CloseParen: ")"},
+		{Question: "?", Colon: "?", OpenParen: "(", CloseParen: ")"},
+		{Question: "?", Colon: "", OpenParen: "(", CloseParen: ")"},
+		{Question: "?", Colon: "ELSE IF", OpenParen: "(", CloseParen: ")"},
+		{Question: "?", Colon: ":", OpenParen: "((", CloseParen: ")"},
+		{Question: "?", Colon: ":", OpenParen: "(", CloseParen: "("},
+		{Question: "'", Colon: ":", OpenParen: "(", CloseParen: ")"},
+	}
+	for idx, config := range testWithErrors {
+		t.Run(fmt.Sprintf("error case %d", idx), func(t *testing.T) {
+			_, err := NewParser(WithTokens(config))
+			assert.ErrorIs(t, err, parse.ErrConfig)
+		})
+	}
+}
+
+func TestWithChildren(t *testing.T) {
+	var branch parse.Branch = cond(un("a"), un("b"), un("c"))
+	children := []parse.AST{un("x"), un("y"), un("z")}
+	assert.Equal(t, children, parse.ChildrenOf(branch.WithChildren(children)))
+	assert.Panics(t, func() { branch.WithChildren(nil) })
+}
+
+func cond(c, then, els parse.AST) *CondExpr {
+	return &CondExpr{Cond: c, Then: then, Else: els}
+}
+
+// un stands for unparsed and returns a parse.Unparsed
+func un(tokens ...string) parse.AST {
+	return parse.Unparsed{Contents: parse.NewTokens(tokens...)}
+}
@@ -0,0 +1,70 @@
+package cond
+
+import (
+	"fmt"
+	"github.com/orkes-io/go-parse"
+)
+
+// Eval evaluates the provided expression, using the provided Interpreters to find the value of each condition and of
+// each branch which is not itself a conditional expression. See Interpreter for details.
+func Eval(expr parse.AST, conds parse.Interpreter[bool], values parse.Interpreter[any]) (any, error) {
+	return Interpreter(conds, values)(expr)
+}
+
+// Interpreter returns a parse.Interpreter which evaluates CondExpr nodes. The condition of each CondExpr is evaluated
+// using the provided conds Interpreter, and then only the chosen branch is evaluated, so that errors in the other
+// branch, such as a reference to a missing variable, do not affect the result. Branches which are not themselves
+// CondExpr nodes are evaluated using the provided values Interpreter, as is any other node passed to the returned
+// Interpreter. This allows the returned Interpreter to evaluate expressions which may or may not be conditional.
+//
+// The conds Interpreter is usually built from those of the other packages in this module, as in
+//
+//	conds := func(ast parse.AST) (bool, error) { return bools.Eval(ast, comp.Interpreter(values)) }
+//
+// A condition which is itself a CondExpr, as in (a ? b : c) ? d : e, is evaluated by the returned Interpreter, and
+// must produce a bool. Otherwise, an error matching parse.ErrEval is returned.
+func Interpreter(conds parse.Interpreter[bool], values parse.Interpreter[any]) parse.Interpreter[any] {
+	var interpret parse.Interpreter[any]
+	interpret = func(ast parse.AST) (any, error) {
+		if conds == nil || values == nil {
+			return nil, fmt.Errorf("%w: nil Interpreter", parse.ErrEval)
+		}
+		// follow the chain of else branches iteratively, since it may be long
+		for {
+			c, ok := ast.(*CondExpr)
+			if !ok {
+				break
+			}
+			holds, err := condition(interpret, conds, c.Cond)
+			if err != nil {
+				return nil, err
+			}
+			if holds {
+				ast = c.Then
+			} else {
+				ast = c.Else
+			}
+		}
+		if ast == nil {
+			return nil, fmt.Errorf("%w: nil expression", parse.ErrEval)
+		}
+		return values(ast)
+	}
+	return interpret
+}
+
+// condition evaluates the provided condition, using interpret if it is a CondExpr and conds otherwise.
+func condition(interpret parse.Interpreter[any], conds parse.Interpreter[bool], ast parse.AST) (bool, error) {
+	if _, ok := ast.(*CondExpr); !ok {
+		return conds(ast)
+	}
+	val, err := interpret(ast)
+	if err != nil {
+		return false, err
+	}
+	b, ok := val.(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: condition must be a bool; found %T value '%v'", parse.ErrEval, val, val)
+	}
+	return b, nil
+}
@@ -0,0 +1,125 @@
+package cond
+
+import (
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// boolInterpreter interprets single-token Unparsed nodes as the named variables, which must be bools.
+func boolInterpreter(vars map[string]any) parse.Interpreter[bool] {
+	values := valueInterpreter(vars)
+	return func(ast parse.AST) (bool, error) {
+		val, err := values(ast)
+		if err != nil {
+			return false, err
+		}
+		b, ok := val.(bool)
+		if !ok {
+			return false, fmt.Errorf("%w: %v is not a bool", parse.ErrEval, ast)
+		}
+		return b, nil
+	}
+}
+
+// valueInterpreter interprets single-token Unparsed nodes as the named variables.
+func valueInterpreter(vars map[string]any) parse.Interpreter[any] {
+	return func(ast parse.AST) (any, error) {
+		unparsed, ok := ast.(parse.Unparsed)
+		if !ok || len(unparsed.Contents) != 1 {
+			return nil, fmt.Errorf("%w: %v", parse.ErrUnknownAST, ast)
+		}
+		name := unparsed.Contents[0].Text
+		val, ok := vars[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown variable %s", parse.ErrEval, name)
+		}
+		return val, nil
+	}
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]any{
+		"yes": true,
+		"no":  false,
+		"a":   "a",
+		"b":   int64(2),
+	}
+	tests := []struct {
+		input  string
+		output any
+	}{
+		{"yes ? a : b", "a"},
+		{"no ? a : b", int64(2)},
+		{"no ? a : yes ? b : missing", int64(2)},
+		{"yes ? no ? missing : b : missing", int64(2)},
+		{"(no ? no : yes) ? a : missing", "a"},
+		{"yes ? a : missing", "a"},
+		{"no ? missing : a", "a"},
+		{"b", int64(2)},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			result, err := Eval(ast, boolInterpreter(vars), valueInterpreter(vars))
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, result)
+		})
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	vars := map[string]any{
+		"yes": true,
+		"a":   "a",
+	}
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"missing ? a : a", "unknown variable missing"},
+		{"a ? a : a", "a is not a bool"},
+		{"yes ? missing : a", "unknown variable missing"},
+		{"(yes ? a : yes) ? a : a", "condition must be a bool; found string value 'a'"},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			_, err = Eval(ast, boolInterpreter(vars), valueInterpreter(vars))
+			assert.ErrorIs(t, err, parse.ErrEval)
+			assert.ErrorContains(t, err, tt.msg)
+		})
+	}
+
+	_, err = Eval(cond(un("yes"), nil, un("a")), boolInterpreter(vars), valueInterpreter(vars))
+	assert.ErrorIs(t, err, parse.ErrEval)
+	_, err = Eval(un("a"), nil, valueInterpreter(vars))
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
+
+func TestEvalLazy(t *testing.T) {
+	var evaluated []string
+	values := func(ast parse.AST) (any, error) {
+		evaluated = append(evaluated, ast.(parse.Unparsed).String())
+		return nil, nil
+	}
+	conds := func(ast parse.AST) (bool, error) {
+		evaluated = append(evaluated, ast.(parse.Unparsed).String())
+		return ast.(parse.Unparsed).String() == "b", nil
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.ParseStr("a ? x : b ? y : z")
+	require.NoError(t, err)
+	_, err = Eval(ast, conds, values)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "y"}, evaluated)
+}
@@ -0,0 +1,92 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError describes an error found while parsing a list of tokens. Every SyntaxError matches ErrParse when
+// checked using errors.Is.
+type SyntaxError struct {
+	Msg      string   // Msg describes the error.
+	Index    int      // Index is the index of the offending token, or the number of tokens if the expression ended early.
+	Found    string   // Found is the text of the offending token, or empty if the expression ended early.
+	Expected []string // Expected lists the tokens which would have been accepted in place of the offending token.
+	Src      Span     // Src is the location of the offending token in the source text.
+	Source   string   // Source is the source text of the expression, if known. It is set by each ParseStr method.
+}
+
+// NewSyntaxError returns a SyntaxError describing a problem with the token at the provided index in tokens. If index
+// is past the end of tokens, the error is located at the end of the last token.
+func NewSyntaxError(tokens []Token, index int, expected []string, format string, args ...any) *SyntaxError {
+	err := &SyntaxError{
+		Msg:      fmt.Sprintf(format, args...),
+		Index:    index,
+		Expected: expected,
+	}
+	if index < len(tokens) {
+		err.Found = tokens[index].Text
+		err.Src = tokens[index].Src
+	} else if len(tokens) > 0 {
+		end := tokens[len(tokens)-1].Src.End
+		err.Src = Span{Start: end, End: end}
+	}
+	return err
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Src.Start.Line == 0 {
+		return fmt.Sprintf("%v: %s", ErrParse, e.Msg)
+	}
+	return fmt.Sprintf("%v: %s at %v", ErrParse, e.Msg, e.Src.Start)
+}
+
+// Is returns true iff target is ErrParse.
+func (e *SyntaxError) Is(target error) bool {
+	return target == ErrParse
+}
+
+// Span returns the location of the offending token in the source text.
+func (e *SyntaxError) Span() Span {
+	return e.Src
+}
+
+// Caret renders the line of source text on which the error was found, followed by a line with carets underneath the
+// offending token. For example:
+//
+//	x > 3 AND (y < 5
+//	                ^
+//
+// An empty string is returned if the source text or the location of the error is unknown.
+func (e *SyntaxError) Caret() string {
+	start := e.Src.Start
+	if e.Source == "" || start.Line == 0 || start.Offset > len(e.Source) {
+		return ""
+	}
+	lineStart := strings.LastIndexByte(e.Source[:start.Offset], '\n') + 1
+	lineEnd := strings.IndexByte(e.Source[start.Offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(e.Source)
+	} else {
+		lineEnd += start.Offset
+	}
+	line := e.Source[lineStart:lineEnd]
+
+	var sb strings.Builder
+	sb.WriteString(line)
+	sb.WriteByte('\n')
+	for _, r := range e.Source[lineStart:start.Offset] {
+		if r == '\t' { // preserve tabs so that the caret lines up
+			sb.WriteRune('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	width := 1
+	if end := e.Src.End.Offset; end > start.Offset && end <= lineEnd {
+		width = utf8.RuneCountInString(e.Source[start.Offset:end])
+	}
+	sb.WriteString(strings.Repeat("^", width))
+	return sb.String()
+}
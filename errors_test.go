@@ -0,0 +1,30 @@
+package parse
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSyntaxError(t *testing.T) {
+	source := "x > 3 AND\n\t(y < 5 OR zed"
+	tokens := Tokenize(source, '(', ')', &KeywordTrie{})
+
+	err := NewSyntaxError(tokens, 9, []string{")"}, "expected '%s'", ")")
+	assert.True(t, errors.Is(err, ErrParse))
+	assert.Equal(t, "zed", err.Found)
+	assert.Equal(t, "error parsing: expected ')' at 2:12", err.Error())
+	assert.Equal(t, "", err.Caret())
+
+	err.Source = source
+	assert.Equal(t, "\t(y < 5 OR zed\n\t          ^^^", err.Caret())
+
+	err = NewSyntaxError(tokens, len(tokens), nil, "unexpected end of expression")
+	err.Source = source
+	assert.Equal(t, "", err.Found)
+	assert.Equal(t, "\t(y < 5 OR zed\n\t             ^", err.Caret())
+
+	err = NewSyntaxError(nil, 0, nil, "unexpected end of expression")
+	assert.Equal(t, "error parsing: unexpected end of expression", err.Error())
+	assert.Equal(t, "", err.Caret())
+}
@@ -0,0 +1,224 @@
+package eval
+
+import (
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/arith"
+	"math"
+	"time"
+)
+
+// Arith returns the result of the binary arithmetic expression lhs op rhs, according to the following rules.
+//   - If both operands are Ints, the result is an Int. Results which do not fit in an int64 result in parse.ErrEval.
+//     Division truncates toward zero, and the result of % has the sign of the dividend, as in Go. Raising an Int to a
+//     negative power produces a Float.
+//   - If either operand is a Float and the other is a number, both are converted to Float.
+//   - A Duration may be added to or subtracted from a Time, producing a Time, and subtracting two Times produces the
+//     Duration between them. Durations may be added to and subtracted from each other, and multiplied or divided by
+//     an Int.
+//   - Division by zero, and operands of any other kinds, result in parse.ErrEval.
+func Arith(op arith.Op, lhs, rhs parse.Value) (parse.Value, error) {
+	switch l := lhs.(type) {
+	case parse.Int:
+		switch r := rhs.(type) {
+		case parse.Int:
+			return applyInt(op, int64(l), int64(r))
+		case parse.Float:
+			return applyFloat(op, float64(l), float64(r))
+		case parse.Duration:
+			if op == arith.OpMultiply {
+				return durationResult(mulInt(int64(l), int64(r)))
+			}
+		}
+	case parse.Float:
+		switch r := rhs.(type) {
+		case parse.Int:
+			return applyFloat(op, float64(l), float64(r))
+		case parse.Float:
+			return applyFloat(op, float64(l), float64(r))
+		}
+	case parse.Time:
+		switch r := rhs.(type) {
+		case parse.Duration:
+			switch op {
+			case arith.OpAdd:
+				return parse.Time(time.Time(l).Add(time.Duration(r))), nil
+			case arith.OpSubtract:
+				if r == math.MinInt64 {
+					return nil, fmt.Errorf("%w: duration overflow", parse.ErrEval)
+				}
+				return parse.Time(time.Time(l).Add(-time.Duration(r))), nil
+			}
+		case parse.Time:
+			if op == arith.OpSubtract {
+				return parse.Duration(time.Time(l).Sub(time.Time(r))), nil
+			}
+		}
+	case parse.Duration:
+		switch r := rhs.(type) {
+		case parse.Duration:
+			switch op {
+			case arith.OpAdd:
+				return durationResult(addInt(int64(l), int64(r)))
+			case arith.OpSubtract:
+				return durationResult(subInt(int64(l), int64(r)))
+			}
+		case parse.Time:
+			if op == arith.OpAdd {
+				return parse.Time(time.Time(r).Add(time.Duration(l))), nil
+			}
+		case parse.Int:
+			switch op {
+			case arith.OpMultiply:
+				return durationResult(mulInt(int64(l), int64(r)))
+			case arith.OpDivide:
+				if r == 0 {
+					return nil, fmt.Errorf("%w: division by zero", parse.ErrEval)
+				}
+				return durationResult(divInt(int64(l), int64(r)))
+			}
+		}
+	}
+	if lhs == nil || rhs == nil {
+		return nil, fmt.Errorf("%w: nil operand", parse.ErrEval)
+	}
+	return nil, fmt.Errorf("%w: cannot apply %v to %v value '%v' and %v value '%v'", parse.ErrEval, op, lhs.Kind(), lhs,
+		rhs.Kind(), rhs)
+}
+
+// Negate returns the result of the unary arithmetic expression -val, whose operand must be an Int, Float or Duration.
+func Negate(val parse.Value) (parse.Value, error) {
+	switch val := val.(type) {
+	case parse.Int:
+		if val == math.MinInt64 {
+			return nil, fmt.Errorf("%w: integer overflow", parse.ErrEval)
+		}
+		return -val, nil
+	case parse.Float:
+		return -val, nil
+	case parse.Duration:
+		if val == math.MinInt64 {
+			return nil, fmt.Errorf("%w: duration overflow", parse.ErrEval)
+		}
+		return -val, nil
+	case nil:
+		return nil, fmt.Errorf("%w: nil operand", parse.ErrEval)
+	}
+	return nil, fmt.Errorf("%w: cannot negate %v value '%v'", parse.ErrEval, val.Kind(), val)
+}
+
+func applyInt(op arith.Op, lhs, rhs int64) (parse.Value, error) {
+	var result int64
+	ok := true
+	switch op {
+	case arith.OpAdd:
+		result, ok = addInt(lhs, rhs)
+	case arith.OpSubtract:
+		result, ok = subInt(lhs, rhs)
+	case arith.OpMultiply:
+		result, ok = mulInt(lhs, rhs)
+	case arith.OpDivide:
+		if rhs == 0 {
+			return nil, fmt.Errorf("%w: division by zero", parse.ErrEval)
+		}
+		result, ok = divInt(lhs, rhs)
+	case arith.OpModulo:
+		if rhs == 0 {
+			return nil, fmt.Errorf("%w: division by zero", parse.ErrEval)
+		}
+		if rhs != -1 {
+			result = lhs % rhs
+		}
+	case arith.OpPower:
+		if rhs < 0 {
+			return parse.Float(math.Pow(float64(lhs), float64(rhs))), nil
+		}
+		result, ok = powInt(lhs, rhs)
+	default:
+		return nil, fmt.Errorf("%w: unexpected binary operator: %v", parse.ErrEval, op)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: integer overflow in %d %v %d", parse.ErrEval, lhs, op, rhs)
+	}
+	return parse.Int(result), nil
+}
+
+func applyFloat(op arith.Op, lhs, rhs float64) (parse.Value, error) {
+	switch op {
+	case arith.OpAdd:
+		return parse.Float(lhs + rhs), nil
+	case arith.OpSubtract:
+		return parse.Float(lhs - rhs), nil
+	case arith.OpMultiply:
+		return parse.Float(lhs * rhs), nil
+	case arith.OpDivide:
+		if rhs == 0 {
+			return nil, fmt.Errorf("%w: division by zero", parse.ErrEval)
+		}
+		return parse.Float(lhs / rhs), nil
+	case arith.OpModulo:
+		if rhs == 0 {
+			return nil, fmt.Errorf("%w: division by zero", parse.ErrEval)
+		}
+		return parse.Float(math.Mod(lhs, rhs)), nil
+	case arith.OpPower:
+		return parse.Float(math.Pow(lhs, rhs)), nil
+	}
+	return nil, fmt.Errorf("%w: unexpected binary operator: %v", parse.ErrEval, op)
+}
+
+func durationResult(d int64, ok bool) (parse.Value, error) {
+	if !ok {
+		return nil, fmt.Errorf("%w: duration overflow", parse.ErrEval)
+	}
+	return parse.Duration(d), nil
+}
+
+// addInt returns lhs + rhs. The boolean result is false if the result does not fit in an int64, and likewise for the
+// following functions.
+func addInt(lhs, rhs int64) (int64, bool) {
+	result := lhs + rhs
+	return result, (lhs^result)&(rhs^result) >= 0
+}
+
+func subInt(lhs, rhs int64) (int64, bool) {
+	result := lhs - rhs
+	return result, (lhs^rhs)&(lhs^result) >= 0
+}
+
+func mulInt(lhs, rhs int64) (int64, bool) {
+	if lhs == 0 || rhs == 0 {
+		return 0, true
+	}
+	result := lhs * rhs
+	if result/rhs != lhs || (lhs == -1 && rhs == math.MinInt64) || (rhs == -1 && lhs == math.MinInt64) {
+		return 0, false
+	}
+	return result, true
+}
+
+// divInt returns lhs / rhs, where rhs is not zero.
+func divInt(lhs, rhs int64) (int64, bool) {
+	if lhs == math.MinInt64 && rhs == -1 {
+		return 0, false
+	}
+	return lhs / rhs, true
+}
+
+// powInt returns lhs ** rhs, where rhs is not negative, using exponentiation by squaring.
+func powInt(lhs, rhs int64) (int64, bool) {
+	result := int64(1)
+	for ok := true; ; {
+		if rhs&1 == 1 {
+			if result, ok = mulInt(result, lhs); !ok {
+				return 0, false
+			}
+		}
+		if rhs >>= 1; rhs == 0 {
+			return result, true
+		}
+		if lhs, ok = mulInt(lhs, lhs); !ok {
+			return 0, false
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package eval implements an Interpreter which evaluates expressions combining the nodes produced by the parsers in
+// this module, producing a parse.Value. Where the Interpreters provided by each package only evaluate their own nodes,
+// leaving the rest to another Interpreter, the Interpreter provided by this package evaluates an entire expression
+// built using the bools, comp, arith, cond, coalesce, funcs and literal packages, so that every node is evaluated
+// using the same rules:
+//
+//	ast, err := parse.ParseAll(ast, condParser, boolsParser, compParser, coalesceParser, funcsParser, varsParser,
+//	    literalParser, arithParser, funcsParser, arithParser, varsParser, literalParser)
+//	...
+//	result, err := eval.Eval(ast, vars.Interpreter(doc), eval.WithFuncs(funcs.Std()))
+//
+// Values are converted, compared and ordered as described by parse.ValueOf, parse.Equal and parse.Compare.
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/arith"
+	"github.com/orkes-io/go-parse/bools"
+	"github.com/orkes-io/go-parse/coalesce"
+	"github.com/orkes-io/go-parse/comp"
+	"github.com/orkes-io/go-parse/cond"
+	"github.com/orkes-io/go-parse/funcs"
+	"github.com/orkes-io/go-parse/literal"
+)
+
+// Eval evaluates the provided expression, using the provided Interpreter to find the value of each node not handled by
+// this package. See Interpreter for details.
+func Eval(expr parse.AST, leaves parse.Interpreter[any], opts ...EvalOpt) (parse.Value, error) {
+	return Interpreter(leaves, opts...)(expr)
+}
+
+// EvalOpt configures the behavior of Eval and Interpreter.
+type EvalOpt func(*evaluator)
+
+// WithFuncs sets the functions which may be called by the evaluated expression. The arguments of each funcs.CallExpr
+// are evaluated using the rules of this package, and passed to the function as the Go values returned by their
+// Interface method. By default, calls are evaluated using the leaves Interpreter, like any other node.
+func WithFuncs(registry *funcs.Registry) EvalOpt {
+	return func(e *evaluator) {
+		e.funcs = registry
+	}
+}
+
+// Interpreter returns a parse.Interpreter which evaluates the nodes of the bools, comp, arith, cond and coalesce
+// packages, as well as literal.Lit nodes, and funcs.CallExpr nodes if WithFuncs is used. The value of any other node,
+// such as a vars.Ref, is found using the provided leaves Interpreter, and converted using parse.ValueOf. If leaves is
+// nil, such nodes result in parse.ErrUnknownAST.
+//
+// Expressions are evaluated according to the following rules.
+//   - AND, OR and NOT require Bool operands. The right-hand side of an AND is skipped if the left-hand side is false,
+//     and the right-hand side of an OR is skipped if the left-hand side is true.
+//   - Comparisons produce a Bool, following parse.Equal and parse.Compare. The right-hand side of IN must be a
+//     comp.List, whose elements are evaluated in order until one is found to be equal, or evaluate to a List.
+//   - IS NULL holds if its operand is Null, or if its evaluation results in an error matching parse.ErrNotFound.
+//   - String predicates require String operands.
+//   - Arithmetic follows the rules described on Arith and Negate.
+//   - The condition of a cond.CondExpr must be a Bool, and only the chosen branch is evaluated.
+//   - The operands of a coalesce.CoalesceExpr are evaluated in order, until one is not Null. Operands whose evaluation
+//     results in an error matching parse.ErrNotFound are treated as Null, except for the last.
+//   - Calls follow the rules described on funcs.Interpreter, and their results are converted using parse.ValueOf.
+//
+// Operands of the wrong kind result in parse.ErrEval.
+func Interpreter(leaves parse.Interpreter[any], opts ...EvalOpt) parse.Interpreter[parse.Value] {
+	e := &evaluator{leaves: leaves}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.funcs != nil {
+		e.calls = funcs.Interpreter(e.funcs, func(ast parse.AST) (any, error) {
+			val, err := e.eval(ast)
+			if err != nil {
+				return nil, err
+			}
+			return val.Interface(), nil
+		})
+	}
+	return e.eval
+}
+
+type evaluator struct {
+	leaves parse.Interpreter[any]
+	funcs  *funcs.Registry
+	calls  parse.Interpreter[any] // calls evaluates funcs.CallExpr nodes, if funcs is set.
+}
+
+func (e *evaluator) eval(ast parse.AST) (parse.Value, error) {
+	switch ast := ast.(type) {
+	case *bools.BinExpr:
+		var val parse.Bool
+		err := bools.WalkChain(ast, func(b *bools.BinExpr, deepest bool) error {
+			var err error
+			if deepest {
+				if val, err = e.evalBool(b.LHS, b.Op); err != nil {
+					return err
+				}
+			}
+			val, err = e.logic(b.Op, val, b.RHS)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
+	case *bools.ListExpr:
+		if ast.Op != bools.OpAnd && ast.Op != bools.OpOr {
+			return nil, fmt.Errorf("%w: unexpected boolean list operator: %v", parse.ErrEval, ast.Op)
+		}
+		val := parse.Bool(ast.Op == bools.OpAnd) // the identity of the operator, so that empty lists are handled
+		for _, term := range ast.Terms {
+			var err error
+			if val, err = e.logic(ast.Op, val, term); err != nil {
+				return nil, err
+			}
+		}
+		return val, nil
+	case *bools.UnaryExpr:
+		if ast.Op != bools.OpNot {
+			return nil, fmt.Errorf("%w: unexpected boolean unary operator: %v", parse.ErrEval, ast.Op)
+		}
+		val, err := e.evalBool(ast.Expr, ast.Op)
+		if err != nil {
+			return nil, err
+		}
+		return !val, nil
+	case *comp.EqualExpr, *comp.OrdinalExpr, *comp.ChainExpr, *comp.BetweenExpr, *comp.InExpr, *comp.MatchExpr,
+		*comp.NullExpr:
+		ok, err := comp.Evaluate(ast, e.eval)
+		if err != nil {
+			return nil, err
+		}
+		return parse.Bool(ok), nil
+	case *arith.BinExpr:
+		var val parse.Value
+		err := arith.WalkChain(ast, func(b *arith.BinExpr, deepest bool) error {
+			var err error
+			if deepest {
+				if val, err = e.eval(b.LHS); err != nil {
+					return err
+				}
+			}
+			rhs, err := e.eval(b.RHS)
+			if err != nil {
+				return err
+			}
+			val, err = Arith(b.Op, val, rhs)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return val, nil
+	case *arith.UnaryExpr:
+		if ast.Op != arith.OpNegate {
+			return nil, fmt.Errorf("%w: unexpected unary operator: %v", parse.ErrEval, ast.Op)
+		}
+		val, err := e.eval(ast.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return Negate(val)
+	case *cond.CondExpr:
+		// evaluate chains of conditionals in the else branch iteratively, since they may be very long
+		for {
+			ok, err := e.evalBool(ast.Cond, nil)
+			if err != nil {
+				return nil, err
+			}
+			branch := ast.Else
+			if ok {
+				branch = ast.Then
+			}
+			next, isCond := branch.(*cond.CondExpr)
+			if !isCond {
+				return e.eval(branch)
+			}
+			ast = next
+		}
+	case *coalesce.CoalesceExpr:
+		if len(ast.Operands) == 0 {
+			return nil, fmt.Errorf("%w: CoalesceExpr has no operands", parse.ErrEval)
+		}
+		last := len(ast.Operands) - 1
+		for _, operand := range ast.Operands[:last] {
+			val, err := e.eval(operand)
+			if err != nil && !errors.Is(err, parse.ErrNotFound) {
+				return nil, err
+			}
+			if err == nil && val.Kind() != parse.KindNull {
+				return val, nil
+			}
+		}
+		return e.eval(ast.Operands[last])
+	case *funcs.CallExpr:
+		if e.calls == nil {
+			return e.leaf(ast)
+		}
+		val, err := e.calls(ast)
+		if err != nil {
+			return nil, err
+		}
+		return parse.ValueOf(val)
+	case *literal.Lit:
+		return parse.ValueOf(ast.Value)
+	case nil:
+		return nil, fmt.Errorf("%w: nil expression", parse.ErrEval)
+	default:
+		return e.leaf(ast)
+	}
+}
+
+// leaf finds the value of a node not handled by this package using the leaves Interpreter.
+func (e *evaluator) leaf(ast parse.AST) (parse.Value, error) {
+	if e.leaves == nil {
+		return nil, fmt.Errorf("%w: unknown AST node: %v", parse.ErrUnknownAST, ast)
+	}
+	val, err := e.leaves(ast)
+	if err != nil {
+		return nil, err
+	}
+	return parse.ValueOf(val)
+}
+
+// evalBool evaluates the provided operand of op, which must produce a Bool. A nil op is used for conditions.
+func (e *evaluator) evalBool(ast parse.AST, op fmt.Stringer) (parse.Bool, error) {
+	val, err := e.eval(ast)
+	if err != nil {
+		return false, err
+	}
+	b, ok := val.(parse.Bool)
+	if !ok {
+		if op == nil {
+			return false, fmt.Errorf("%w: condition must be a bool; found %v value '%v'", parse.ErrEval, val.Kind(), val)
+		}
+		return false, fmt.Errorf("%w: cannot apply %v to %v value '%v'", parse.ErrEval, op, val.Kind(), val)
+	}
+	return b, nil
+}
+
+// logic returns the result of lhs op rhs, evaluating rhs only if it is needed.
+func (e *evaluator) logic(op bools.Op, lhs parse.Bool, rhs parse.AST) (parse.Bool, error) {
+	switch op {
+	case bools.OpAnd:
+		if !lhs {
+			return false, nil
+		}
+	case bools.OpOr:
+		if lhs {
+			return true, nil
+		}
+	default:
+		return false, fmt.Errorf("%w: unexpected binary boolean operator: %v", parse.ErrEval, op)
+	}
+	return e.evalBool(rhs, op)
+}
@@ -0,0 +1,300 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/arith"
+	"github.com/orkes-io/go-parse/bools"
+	"github.com/orkes-io/go-parse/coalesce"
+	"github.com/orkes-io/go-parse/comp"
+	"github.com/orkes-io/go-parse/funcs"
+	"github.com/orkes-io/go-parse/internal/exprtest"
+	"github.com/orkes-io/go-parse/literal"
+	"github.com/orkes-io/go-parse/vars"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+var doc = map[string]any{
+	"priority": 7,
+	"ratio":    0.5,
+	"name":     "task_12",
+	"retry":    false,
+	"tags":     []string{"a", "b"},
+	"started":  time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC),
+	"deadline": time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+	"timeout":  5 * time.Minute,
+	"output":   map[string]any{"result": nil, "count": int64(3)},
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		input  string
+		output parse.Value
+	}{
+		{"${priority} > 5 AND NOT ${retry}", parse.Bool(true)},
+		{"${priority} * 2 + 1", parse.Int(15)},
+		{"${priority} / 2 + ${ratio}", parse.Float(3.5)},
+		{"-${priority} % 4 == -3", parse.Bool(true)},
+		{"2 ** 10 == 1024.0", parse.Bool(true)},
+		{"${priority} BETWEEN 1 AND 10 AND ${ratio} < 1", parse.Bool(true)},
+		{"0 < ${ratio} <= ${priority}", parse.Bool(true)},
+		{"'b' IN ${tags} AND ${priority} NOT IN (1, 2, 3)", parse.Bool(true)},
+		{"${name} LIKE 'task\\\\_%' AND ${name} =~ '[0-9]+$' AND ${name} STARTS WITH 'task'", parse.Bool(true)},
+		{"${name} CONTAINS ${tags[0]}", parse.Bool(true)},
+		{"${name} LIKE ${tags[1]}", parse.Bool(false)},
+		{"${output.result} IS NULL AND ${output?.missing} IS NULL AND ${output.count} IS NOT NULL", parse.Bool(true)},
+		{"${output.result} ?? ${output?.missing} ?? ${output.count} + 1", parse.Int(4)},
+		{"${priority} > 5 ? 'high' : 'low'", parse.String("high")},
+		{"${retry} ? 1 : ${ratio} > 1 ? 2 : 3", parse.Int(3)},
+		{"${started} + ${timeout} > ${started} AND ${started} > 2024-01-02", parse.Bool(true)},
+		{"${deadline} - ${started}", parse.Duration(9 * time.Hour)},
+		{"${deadline} - ${timeout} * 2", parse.Time(time.Date(2024, 1, 2, 23, 50, 0, 0, time.UTC))},
+		{"${timeout} * 3 - 1m", parse.Duration(14 * time.Minute)},
+		{"${tags}", parse.List{parse.String("a"), parse.String("b")}},
+		{"${output}", parse.Map{"result": parse.Null{}, "count": parse.Int(3)}},
+		{"null", parse.Null{}},
+		{"(1 == 1.0) == true", parse.Bool(true)},
+		{"'1' != 1", parse.Bool(true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := Eval(exprtest.Parse(t, tt.input), vars.Interpreter(doc))
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, result)
+		})
+	}
+}
+
+func TestEvalError(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"${priority} AND true", "cannot apply AND to int value '7'"},
+		{"NOT ${name}", "cannot apply NOT to string value 'task_12'"},
+		{"false OR 1", "cannot apply OR to int value '1'"},
+		{"${name} > 1", "cannot order string value 'task_12' and int value '1'"},
+		{"1 < 2 < 'a'", "cannot order int value '2' and string value 'a'"},
+		{"${priority} BETWEEN 'a' AND 10", "cannot order int value '7' and string value 'a'"},
+		{"1 IN ${name}", "cannot test membership in string value 'task_12'"},
+		{"${priority} LIKE 'a'", "cannot apply LIKE to int value '7'"},
+		{"${name} CONTAINS 1", "cannot apply CONTAINS with int value '1'"},
+		{"${name} =~ ${name} + '('", "cannot apply + to string value"},
+		{"${name} + 1", "cannot apply + to string value 'task_12' and int value '1'"},
+		{"-${name}", "cannot negate string value 'task_12'"},
+		{"${ratio} / 0", "division by zero"},
+		{"${priority} ? 1 : 2", "condition must be a bool; found int value '7'"},
+		{"${missing} > 1", "variable not found"},
+		{"${missing} ?? ${other}", "variable not found"},
+		{"${missing} IS NULL AND ${priority} / 0 IS NULL", "division by zero"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Eval(exprtest.Parse(t, tt.input), vars.Interpreter(doc))
+			assert.ErrorIs(t, err, parse.ErrEval)
+			assert.ErrorContains(t, err, tt.msg)
+		})
+	}
+
+	_, err := Eval(parse.Unparsed{Contents: parse.NewTokens("x")}, nil)
+	assert.ErrorIs(t, err, parse.ErrUnknownAST)
+	_, err = Eval(nil, vars.Interpreter(doc))
+	assert.ErrorIs(t, err, parse.ErrEval)
+	_, err = Eval(&coalesce.CoalesceExpr{}, vars.Interpreter(doc))
+	assert.ErrorIs(t, err, parse.ErrEval)
+	_, err = Eval(&comp.ChainExpr{Operands: []parse.AST{&literal.Lit{Kind: literal.KindInt, Value: int64(1)}}, Ops: []comp.Op{comp.OpLess}}, nil)
+	assert.ErrorIs(t, err, parse.ErrEval)
+	_, err = Eval(&arith.UnaryExpr{Op: arith.OpAdd, Expr: &literal.Lit{Kind: literal.KindInt, Value: int64(1)}}, nil)
+	assert.ErrorIs(t, err, parse.ErrEval)
+	_, err = Eval(&literal.Lit{Kind: literal.KindInt, Value: new(big.Int).Lsh(big.NewInt(1), 70)}, nil)
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
+
+func TestEvalLazy(t *testing.T) {
+	var evaluated []string
+	leaves := func(ast parse.AST) (any, error) {
+		ref := ast.(*vars.Ref)
+		evaluated = append(evaluated, ref.String())
+		return vars.Resolve(ref, doc)
+	}
+	tests := []struct {
+		input     string
+		evaluated []string
+	}{
+		{"${retry} AND ${missing}", []string{"${retry}"}},
+		{"NOT ${retry} OR ${missing}", []string{"${retry}"}},
+		{"${priority} IN (1, ${priority}, ${missing})", []string{"${priority}", "${priority}"}},
+		{"${retry} ? ${missing} : ${name}", []string{"${retry}", "${name}"}},
+		{"${output.count} ?? ${missing}", []string{"${output.count}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated = nil
+			_, err := Eval(exprtest.Parse(t, tt.input), leaves)
+			require.NoError(t, err)
+			assert.Equal(t, tt.evaluated, evaluated)
+		})
+	}
+}
+
+func TestEval_Deep(t *testing.T) {
+	const n = 10000
+	ast := exprtest.Parse(t, strings.Repeat("${priority} > 1 AND ", n)+"true")
+	result, err := Eval(ast, vars.Interpreter(doc))
+	require.NoError(t, err)
+	assert.Equal(t, parse.Bool(true), result)
+	result, err = Eval(bools.Flatten(ast), vars.Interpreter(doc))
+	require.NoError(t, err)
+	assert.Equal(t, parse.Bool(true), result)
+
+	ast = exprtest.Parse(t, strings.Repeat("1 + ", n)+"1")
+	result, err = Eval(ast, nil)
+	require.NoError(t, err)
+	assert.Equal(t, parse.Int(n+1), result)
+
+	ast = exprtest.Parse(t, strings.Repeat("false ? 1 : ", n)+"2")
+	result, err = Eval(ast, nil)
+	require.NoError(t, err)
+	assert.Equal(t, parse.Int(2), result)
+}
+
+func TestArith(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		lhs    parse.Value
+		op     arith.Op
+		rhs    parse.Value
+		output parse.Value
+	}{
+		{parse.Int(7), arith.OpDivide, parse.Int(-2), parse.Int(-3)},
+		{parse.Int(-7), arith.OpModulo, parse.Int(2), parse.Int(-1)},
+		{parse.Int(math.MinInt64), arith.OpModulo, parse.Int(-1), parse.Int(0)},
+		{parse.Int(2), arith.OpPower, parse.Int(62), parse.Int(1 << 62)},
+		{parse.Int(-2), arith.OpPower, parse.Int(63), parse.Int(math.MinInt64)},
+		{parse.Int(1), arith.OpPower, parse.Int(math.MaxInt64), parse.Int(1)},
+		{parse.Int(2), arith.OpPower, parse.Int(-1), parse.Float(0.5)},
+		{parse.Int(3), arith.OpMultiply, parse.Float(0.5), parse.Float(1.5)},
+		{parse.Float(7), arith.OpModulo, parse.Int(4), parse.Float(3)},
+		{parse.Float(2), arith.OpPower, parse.Float(0.5), parse.Float(math.Sqrt2)},
+		{parse.Time(ts), arith.OpAdd, parse.Duration(time.Hour), parse.Time(ts.Add(time.Hour))},
+		{parse.Duration(time.Hour), arith.OpAdd, parse.Time(ts), parse.Time(ts.Add(time.Hour))},
+		{parse.Time(ts), arith.OpSubtract, parse.Duration(time.Hour), parse.Time(ts.Add(-time.Hour))},
+		{parse.Time(ts.Add(time.Hour)), arith.OpSubtract, parse.Time(ts), parse.Duration(time.Hour)},
+		{parse.Duration(time.Hour), arith.OpSubtract, parse.Duration(time.Minute), parse.Duration(59 * time.Minute)},
+		{parse.Int(2), arith.OpMultiply, parse.Duration(time.Second), parse.Duration(2 * time.Second)},
+		{parse.Duration(time.Minute), arith.OpDivide, parse.Int(60), parse.Duration(time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %v %v", tt.lhs, tt.op, tt.rhs), func(t *testing.T) {
+			result, err := Arith(tt.op, tt.lhs, tt.rhs)
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, result)
+		})
+	}
+
+	errorCases := []struct {
+		lhs parse.Value
+		op  arith.Op
+		rhs parse.Value
+	}{
+		{parse.Int(math.MaxInt64), arith.OpAdd, parse.Int(1)},
+		{parse.Int(math.MinInt64), arith.OpSubtract, parse.Int(1)},
+		{parse.Int(math.MaxInt64), arith.OpMultiply, parse.Int(2)},
+		{parse.Int(-1), arith.OpMultiply, parse.Int(math.MinInt64)},
+		{parse.Int(math.MinInt64), arith.OpDivide, parse.Int(-1)},
+		{parse.Int(2), arith.OpPower, parse.Int(63)},
+		{parse.Int(1), arith.OpDivide, parse.Int(0)},
+		{parse.Int(1), arith.OpModulo, parse.Int(0)},
+		{parse.Float(1), arith.OpModulo, parse.Int(0)},
+		{parse.Int(1), arith.OpNegate, parse.Int(1)},
+		{parse.Duration(math.MaxInt64), arith.OpAdd, parse.Duration(1)},
+		{parse.Duration(math.MaxInt64), arith.OpMultiply, parse.Int(2)},
+		{parse.Duration(time.Second), arith.OpDivide, parse.Int(0)},
+		{parse.Time(ts), arith.OpSubtract, parse.Duration(math.MinInt64)},
+		{parse.Time(ts), arith.OpAdd, parse.Time(ts)},
+		{parse.Duration(1), arith.OpDivide, parse.Duration(1)},
+		{parse.String("a"), arith.OpAdd, parse.String("b")},
+		{parse.Null{}, arith.OpAdd, parse.Int(1)},
+		{nil, arith.OpAdd, parse.Int(1)},
+	}
+	for _, tt := range errorCases {
+		_, err := Arith(tt.op, tt.lhs, tt.rhs)
+		assert.ErrorIs(t, err, parse.ErrEval, "%v %v %v", tt.lhs, tt.op, tt.rhs)
+	}
+}
+
+func TestNegate(t *testing.T) {
+	for _, tt := range []struct{ input, output parse.Value }{
+		{parse.Int(1), parse.Int(-1)},
+		{parse.Float(-0.5), parse.Float(0.5)},
+		{parse.Duration(time.Second), parse.Duration(-time.Second)},
+	} {
+		result, err := Negate(tt.input)
+		require.NoError(t, err)
+		assert.Equal(t, tt.output, result)
+	}
+	for _, input := range []parse.Value{parse.Int(math.MinInt64), parse.Duration(math.MinInt64), parse.Bool(true), nil} {
+		_, err := Negate(input)
+		assert.ErrorIs(t, err, parse.ErrEval, input)
+	}
+}
+
+func TestInterpreter_Fallback(t *testing.T) {
+	// leaves which are not found by one Interpreter may be found by another
+	leaves := vars.Interpreter(doc).WithFallback(func(ast parse.AST) (any, error) {
+		if u, ok := ast.(parse.Unparsed); ok && u.String() == "answer" {
+			return 42, nil
+		}
+		return nil, errors.New("unreachable")
+	})
+	result, err := Eval(exprtest.Parse(t, "answer - ${priority}"), leaves)
+	require.NoError(t, err)
+	assert.Equal(t, parse.Int(35), result)
+}
+
+func TestWithFuncs(t *testing.T) {
+	tests := []struct {
+		input  string
+		output parse.Value
+		err    string
+	}{
+		{"len(${tags}) + 1", parse.Int(3), ""},
+		{"upper(trim(' x ')) == 'X' AND lower(${name}) == ${name}", parse.Bool(true), ""},
+		{"abs(${priority} - 10) > 2 AND max(${ratio}, 1) == 1", parse.Bool(true), ""},
+		{"coalesce(${missing}, ${output.result}, ${name})", parse.String("task_12"), ""},
+		{"now() > ${started}", parse.Bool(true), ""},
+		{"lower(${priority})", nil, "argument 1 of lower must be of type string"},
+		{"lower(${missing})", nil, "variable not found"},
+		{"unknown(1)", nil, "unknown function unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := Eval(exprtest.Parse(t, tt.input), vars.Interpreter(doc), WithFuncs(funcs.Std()))
+			if tt.err != "" {
+				assert.ErrorIs(t, err, parse.ErrEval)
+				assert.ErrorContains(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.output, result)
+			}
+		})
+	}
+
+	// without WithFuncs, calls are found by the leaves Interpreter
+	leaves := func(ast parse.AST) (any, error) {
+		if call, ok := ast.(*funcs.CallExpr); ok && call.Name == "answer" {
+			return 42, nil
+		}
+		return vars.Interpreter(doc)(ast)
+	}
+	result, err := Eval(exprtest.Parse(t, "answer() - ${priority}"), leaves)
+	require.NoError(t, err)
+	assert.Equal(t, parse.Int(35), result)
+}
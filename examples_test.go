@@ -5,7 +5,6 @@ import (
 	"github.com/orkes-io/go-parse"
 	"github.com/orkes-io/go-parse/bools"
 	"github.com/orkes-io/go-parse/comp"
-	"strings"
 )
 
 func Example() {
@@ -24,30 +23,38 @@ func Example() {
 		fmt.Printf("error parsing comparison: %v\n", err)
 	}
 
-	bfsPrint(ast)
+	// print the expression using the syntax of each parser
+	text, err := parse.Format(ast, bParser, cParser)
+	if err != nil {
+		fmt.Printf("error printing expression: %v\n", err)
+	}
+	fmt.Println(text)
 	// Output:
-	// x >= 5 AND  NOT(y < 7 OR z != 3)
+	// x >= 5 AND NOT (y < 7 OR z != 3)
 }
 
-func bfsPrint(ast parse.AST) {
-	switch ast := (ast).(type) {
-	case *bools.BinExpr:
-		bfsPrint(ast.LHS)
-		fmt.Printf(" %s ", ast.Op.String())
-		bfsPrint(ast.RHS)
-	case *bools.UnaryExpr:
-		fmt.Print(" NOT(")
-		bfsPrint(ast.Expr)
-		fmt.Print(") ")
-	case *comp.EqualExpr:
-		bfsPrint(ast.LHS)
-		fmt.Printf(" %s ", ast.Op.String())
-		bfsPrint(ast.RHS)
-	case *comp.OrdinalExpr:
-		bfsPrint(ast.LHS)
-		fmt.Printf(" %s ", ast.Op.String())
-		bfsPrint(ast.RHS)
-	case parse.Unparsed:
-		fmt.Print(strings.Join(ast.Contents, " "))
+func ExampleInspect() {
+	bParser, _ := bools.NewParser()
+	cParser, _ := comp.NewParser()
+
+	ast, err := bParser.ParseStr("x >= 5 AND NOT(y < 7 OR z)")
+	if err != nil {
+		fmt.Printf("error parsing boolean expression: %v\n", err)
+	}
+	ast, err = parse.ParseAll(ast, cParser)
+	if err != nil {
+		fmt.Printf("error parsing comparison: %v\n", err)
 	}
+
+	// print every comparison in the expression
+	parse.Inspect(ast, func(ast parse.AST) bool {
+		switch ast := ast.(type) {
+		case *comp.OrdinalExpr:
+			fmt.Println(ast.LHS, ast.Op, ast.RHS)
+		}
+		return true
+	})
+	// Output:
+	// x >= 5
+	// y < 7
 }
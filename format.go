@@ -0,0 +1,86 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Precedence levels returned by the Printers provided by this module. Higher levels bind more tightly. Each parser
+// sees the output of the parsers which run after it as opaque Unparsed nodes, so parsers which run later in a
+// pipeline are assigned higher levels. A node whose precedence is lower than required by its parent is parenthesized.
+const (
+	PrecLowest   = 0    // PrecLowest is the precedence of nodes which must always be parenthesized.
+	PrecCond     = 50   // PrecCond is the level used by the cond package.
+	PrecBools    = 100  // PrecBools is the lowest level used by the bools package.
+	PrecComp     = 200  // PrecComp is the lowest level used by the comp package.
+	PrecCoalesce = 250  // PrecCoalesce is the level used by the coalesce package.
+	PrecArith    = 300  // PrecArith is the lowest level used by the arith package.
+	PrecAtom     = 1000 // PrecAtom is the precedence of nodes which never need parentheses, such as Unparsed.
+)
+
+// A Printer renders AST nodes as text. Print returns the text of the provided node along with its precedence, and
+// uses the provided Formatter to print its children. Printers must return ErrUnknownAST when asked to print a node
+// they do not recognize. Each Parser provided by this module is also a Printer which uses its configured syntax.
+type Printer interface {
+	Print(ast AST, f *Formatter) (text string, prec int, err error)
+}
+
+// A Formatter renders ASTs as text using a list of Printers, such that parsing the text with the corresponding
+// Parsers produces the original AST. Parentheses are only added where they are required by precedence.
+type Formatter struct {
+	printers []Printer
+}
+
+// NewFormatter returns a Formatter which uses the provided Printers in order, until one of them recognizes each node.
+func NewFormatter(printers ...Printer) *Formatter {
+	return &Formatter{printers: printers}
+}
+
+// Format renders the provided AST as text using the provided Printers. See Formatter for details.
+func Format(ast AST, printers ...Printer) (string, error) {
+	return NewFormatter(printers...).Format(ast)
+}
+
+// Format renders the provided AST as text.
+func (f *Formatter) Format(ast AST) (string, error) {
+	text, _, err := f.Print(ast)
+	return text, err
+}
+
+// Print returns the text of the provided AST along with its precedence. Unparsed nodes are printed with their tokens
+// separated by spaces, at PrecAtom. Other nodes are printed by the first Printer which recognizes them. Nodes which are
+// not recognized by any Printer are printed using their String method at PrecLowest, if they have one; otherwise
+// ErrUnknownAST is returned.
+func (f *Formatter) Print(ast AST) (string, int, error) {
+	if unparsed, ok := ast.(Unparsed); ok {
+		return unparsed.String(), PrecAtom, nil
+	}
+	for _, p := range f.printers {
+		text, prec, err := p.Print(ast, f)
+		if !errors.Is(err, ErrUnknownAST) {
+			return text, prec, err
+		}
+	}
+	if s, ok := ast.(fmt.Stringer); ok {
+		return s.String(), PrecLowest, nil
+	}
+	return "", 0, fmt.Errorf("%w: cannot print %T", ErrUnknownAST, ast)
+}
+
+// Operand prints the provided child of a node, surrounding it with the provided open and close parentheses if its
+// precedence is lower than min.
+func (f *Formatter) Operand(ast AST, min int, open, close string) (string, error) {
+	text, prec, err := f.Print(ast)
+	if err != nil {
+		return "", err
+	}
+	return Parenthesize(text, prec, min, open, close), nil
+}
+
+// Parenthesize surrounds the provided text with the provided open and close parentheses if prec is lower than min.
+func Parenthesize(text string, prec, min int, open, close string) string {
+	if prec < min {
+		return open + text + close
+	}
+	return text
+}
@@ -0,0 +1,87 @@
+package parse
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// pairPrinter prints pair nodes as LHS , RHS, where ',' is right-associative.
+type pairPrinter struct{}
+
+func (pairPrinter) Print(ast AST, f *Formatter) (string, int, error) {
+	p, ok := ast.(*pair)
+	if !ok {
+		return "", 0, ErrUnknownAST
+	}
+	lhs, err := f.Operand(p.LHS, 11, "(", ")")
+	if err != nil {
+		return "", 0, err
+	}
+	rhs, err := f.Operand(p.RHS, 10, "(", ")")
+	if err != nil {
+		return "", 0, err
+	}
+	return lhs + " , " + rhs, 10, nil
+}
+
+// named is an AST node with a String method, but no Printer.
+type named string
+
+func (n named) Parse(Parser) error {
+	return nil
+}
+
+func (n named) String() string {
+	return fmt.Sprintf("named %s", string(n))
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input  AST
+		output string
+	}{
+		{
+			&pair{LHS: Unparsed{Contents: NewTokens("a", "b")}, RHS: Unparsed{Contents: NewTokens("'c'")}},
+			"a b , 'c'",
+		},
+		{
+			&pair{LHS: leaf("a"), RHS: &pair{LHS: leaf("b"), RHS: leaf("c")}},
+			"a , b , c",
+		},
+		{
+			&pair{LHS: &pair{LHS: leaf("a"), RHS: leaf("b")}, RHS: leaf("c")},
+			"(a , b) , c",
+		},
+		{
+			&pair{LHS: named("a"), RHS: leaf("b")},
+			"(named a) , b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			text, err := Format(tt.input, pairPrinter{}, leafPrinter{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, text)
+		})
+	}
+
+	_, err := Format(&pair{LHS: leaf("a"), RHS: leaf("b")}, pairPrinter{})
+	assert.ErrorIs(t, err, ErrUnknownAST)
+}
+
+// leafPrinter prints leaf nodes as their contents.
+type leafPrinter struct{}
+
+func (leafPrinter) Print(ast AST, f *Formatter) (string, int, error) {
+	if l, ok := ast.(leaf); ok {
+		return string(l), PrecAtom, nil
+	}
+	return "", 0, ErrUnknownAST
+}
+
+func TestParenthesize(t *testing.T) {
+	assert.Equal(t, "[a]", Parenthesize("a", 1, 2, "[", "]"))
+	assert.Equal(t, "a", Parenthesize("a", 2, 2, "[", "]"))
+}
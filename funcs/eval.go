@@ -0,0 +1,95 @@
+package funcs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+)
+
+// Interpreter returns a parse.Interpreter which evaluates CallExpr nodes by calling the function of the same name in
+// the provided Registry. The value of each argument is found using the provided args Interpreter, and the arguments
+// are evaluated in order before the function is called. Values which are a parse.Value are passed to the function as
+// the Go value returned by their Interface method. Nodes of any other type result in parse.ErrUnknownAST, so that the
+// returned Interpreter can be combined with others using WithFallback.
+//
+// Since arguments may themselves contain calls, the args Interpreter is usually one which includes the returned
+// Interpreter, which can be arranged using a variable:
+//
+//	var values parse.Interpreter[any]
+//	calls := funcs.Interpreter(funcs.Std(), func(ast parse.AST) (any, error) { return values(ast) })
+//	values = arith.Interpreter(calls.WithFallback(literal.Interpreter()).WithFallback(vars.Interpreter(doc)))
+//
+// Arguments whose values are not found are passed as nil to functions which are NullTolerant. An error matching
+// parse.ErrEval is returned if the function is not registered, if it does not accept the number or the types of the
+// arguments provided, or if it returns an error.
+func Interpreter(funcs *Registry, args parse.Interpreter[any]) parse.Interpreter[any] {
+	return func(ast parse.AST) (any, error) {
+		call, ok := ast.(*CallExpr)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown AST node: %v", parse.ErrUnknownAST, ast)
+		}
+		if funcs == nil || args == nil {
+			return nil, fmt.Errorf("%w: nil Registry or Interpreter", parse.ErrEval)
+		}
+		fn, err := funcs.Resolve(call)
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]any, len(call.Args))
+		for i, arg := range call.Args {
+			val, err := args(arg)
+			if vals[i], err = fn.Arg(call.Name, i, val, err); err != nil {
+				return nil, err
+			}
+		}
+		return fn.Invoke(call.Name, vals)
+	}
+}
+
+// Resolve returns the function called by the provided CallExpr. An error matching parse.ErrEval is returned if the
+// function is not registered, or if it does not accept the number of arguments provided. Resolve, Func.Arg and
+// Func.Invoke perform the checks made by Interpreter, for use by other evaluators of CallExpr nodes.
+func (r *Registry) Resolve(call *CallExpr) (Func, error) {
+	fn, ok := r.Lookup(call.Name)
+	if !ok {
+		return Func{}, fmt.Errorf("%w: unknown function %s", parse.ErrEval, call.Name)
+	}
+	if !fn.accepts(len(call.Args)) {
+		return Func{}, fmt.Errorf("%w: %s expects %s; found %d", parse.ErrEval, call.Name, fn.arity(), len(call.Args))
+	}
+	return fn, nil
+}
+
+// Arg returns the value to pass as argument i of a call to this function, named name, given the result of evaluating
+// the argument. Errors are returned as-is, unless they match parse.ErrNotFound and this function is NullTolerant, in
+// which case nil is passed. A parse.Value is passed as the Go value returned by its Interface method, and an error
+// matching parse.ErrEval is returned if the value is not of the type accepted by the parameter.
+func (f Func) Arg(name string, i int, val any, err error) (any, error) {
+	if f.NullTolerant && errors.Is(err, parse.ErrNotFound) {
+		val, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := val.(parse.Value); ok {
+		val = v.Interface()
+	}
+	if param := f.param(i); param != TypeAny && TypeOf(val)&param == 0 {
+		return nil, fmt.Errorf("%w: argument %d of %s must be of type %v; found %T value '%v'",
+			parse.ErrEval, i+1, name, param, val, val)
+	}
+	return val, nil
+}
+
+// Invoke calls this function, named name, with the provided arguments, which must have been checked using Arg. Errors
+// returned by the function are wrapped so that they match parse.ErrEval.
+func (f Func) Invoke(name string, args []any) (any, error) {
+	result, err := f.Call(args)
+	if err != nil {
+		if errors.Is(err, parse.ErrEval) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s: %v", parse.ErrEval, name, err)
+	}
+	return result, nil
+}
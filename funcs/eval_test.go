@@ -0,0 +1,115 @@
+package funcs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+// values interprets single tokens as integers, string literals or variables.
+func values(vars map[string]any) parse.Interpreter[any] {
+	return func(ast parse.AST) (any, error) {
+		unparsed, ok := ast.(parse.Unparsed)
+		if !ok || len(unparsed.Contents) != 1 {
+			return nil, fmt.Errorf("%w: %v", parse.ErrUnknownAST, ast)
+		}
+		token := unparsed.Contents[0]
+		if token.Quote != 0 {
+			return parse.Unquote(token.Text)
+		}
+		if i, err := strconv.ParseInt(token.Text, 10, 64); err == nil {
+			return i, nil
+		}
+		if val, ok := vars[token.Text]; ok {
+			if err, ok := val.(error); ok {
+				return nil, err
+			}
+			return val, nil
+		}
+		return nil, fmt.Errorf("%w: unknown variable %s", parse.ErrEval, token.Text)
+	}
+}
+
+func TestInterpreter(t *testing.T) {
+	vars := map[string]any{
+		"name":  "  Alice ",
+		"items": []any{1, 2, 3},
+		"n":     nil,
+		"x":     fmt.Errorf("%w: x", parse.ErrNotFound),
+		"title": parse.String(" Bob "),
+		"k":     parse.Int(-3),
+	}
+	tests := []struct {
+		input  string
+		result any
+	}{
+		{"len(items)", int64(3)},
+		{"lower(trim(name))", "alice"},
+		{"upper('x')", "X"},
+		{"max(len(items), 7, -2)", int64(7)},
+		{"min(abs(-4), 5)", int64(4)},
+		{"coalesce(n, 'default')", "default"},
+		{"coalesce(x, n, len(items))", int64(3)},
+		{"coalesce(x)", nil},
+		{"trim(title)", "Bob"},
+		{"max(abs(k), len(title))", int64(5)},
+	}
+
+	p, err := NewParser(WithFuncs(Std()))
+	require.NoError(t, err)
+	var interpret parse.Interpreter[any]
+	interpret = Interpreter(Std(), func(ast parse.AST) (any, error) {
+		return interpret(ast)
+	}).WithFallback(values(vars))
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			result, err := interpret(ast)
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+		})
+	}
+}
+
+func TestInterpreterError(t *testing.T) {
+	std := Std()
+	require.NoError(t, std.Register("fail", Func{Call: func([]any) (any, error) {
+		return nil, errors.New("failed")
+	}}))
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"unknown(1)", "unknown function unknown"},
+		{"len(1, 2)", "len expects 1 argument; found 2"},
+		{"len(1)", "argument 1 of len must be of type string|list|map; found int64 value '1'"},
+		{"max(1, 'a')", "argument 2 of max must be of type number"},
+		{"lower(x)", "unknown variable x"},
+		{"coalesce('a', x)", "unknown variable x"},
+		{"lower(missing)", "variable not found"},
+		{"fail()", "fail: failed"},
+	}
+
+	p, err := NewParser()
+	require.NoError(t, err)
+	interpret := Interpreter(std, values(map[string]any{"missing": parse.ErrNotFound}))
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			_, err = interpret(ast)
+			assert.ErrorIs(t, err, parse.ErrEval)
+			assert.ErrorContains(t, err, tt.msg)
+		})
+	}
+
+	_, err = interpret(un("x"))
+	assert.ErrorIs(t, err, parse.ErrUnknownAST)
+	_, err = Interpreter(nil, values(nil))(call("now"))
+	assert.ErrorIs(t, err, parse.ErrEval)
+}
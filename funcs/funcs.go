@@ -0,0 +1,331 @@
+// Package funcs implements a parser for function calls such as len(x), lower(name) or now(), according to the
+// following grammar.
+//
+//	call -> name '(' ( arg ( ',' arg )* )? ')'
+//	name -> a letter or underscore, followed by any number of letters, digits and underscores
+//	arg  -> .*
+//
+// Each list of tokens which consists of a single call is parsed into a CallExpr node; any other list of tokens is left
+// as-is in a parse.Unparsed node. The arguments of a call are parsed by this parser in turn, so that nested calls such
+// as lower(trim(name)) produce nested CallExpr nodes, and are otherwise left in parse.Unparsed nodes for later
+// consumption.
+//
+// The other parsers in this module leave calls intact, so that len(${items}) > 3 is parsed by the comp package into a
+// comparison whose left-hand side contains the tokens of the call. Calls which are operands of arithmetic expressions
+// are only found once the arith parser has run, while the arguments of a call may themselves contain arithmetic, so
+// this parser is usually run both before and after arith, and arith is run again afterward:
+//
+//	ast, err := parse.ParseAll(ast, compParser, funcsParser, varsParser, literalParser,
+//		arithParser, funcsParser, arithParser, varsParser, literalParser)
+//
+// Each further level of calls nested within arithmetic within calls requires another pass of funcs and arith.
+//
+// A Registry of functions, such as the standard library returned by Std, may be provided using WithFuncs, in which
+// case calls to unknown functions, and calls with the wrong number of arguments, are reported while parsing. See
+// Interpreter for evaluation.
+package funcs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"strings"
+	"unicode"
+)
+
+// CallExpr represents a function call, such as max(a, b).
+type CallExpr struct {
+	Name string      // Name is the name of the function called.
+	Args []parse.AST // Args lists the arguments of the call, in order.
+	Src  parse.Span  // Src is the location of this call in the source text, including its parentheses.
+}
+
+// String returns this call in the default syntax of this package.
+func (c *CallExpr) String() string {
+	text, err := parse.Format(c, defaultParser)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return text
+}
+
+// Span returns the location of this call in the source text.
+func (c *CallExpr) Span() parse.Span {
+	return c.Src
+}
+
+// Parse runs the provided parse.Parser on all unparsed nodes in this AST.
+func (c *CallExpr) Parse(p parse.Parser) error {
+	for i, arg := range c.Args {
+		if unparsed, ok := arg.(parse.Unparsed); ok {
+			newArg, err := p.Parse(unparsed.Contents)
+			if err != nil {
+				return err
+			}
+			c.Args[i] = newArg
+		} else if err := arg.Parse(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Children returns the arguments of this call.
+func (c *CallExpr) Children() []parse.AST {
+	return c.Args
+}
+
+// WithChildren returns a copy of this call with the provided arguments.
+func (c *CallExpr) WithChildren(children []parse.AST) parse.AST {
+	if len(children) != len(c.Args) {
+		panic(fmt.Sprintf("funcs: CallExpr.WithChildren called with %d children; expected %d", len(children), len(c.Args)))
+	}
+	return &CallExpr{Name: c.Name, Args: append([]parse.AST(nil), children...), Src: c.Src}
+}
+
+// Token is a token required by this grammar.
+type Token uint8
+
+const (
+	OpenParen  Token = iota + 1 // OpenParen represents the start of a list of arguments.
+	CloseParen                  // CloseParen represents the end of a list of arguments.
+	Comma                       // Comma separates arguments.
+)
+
+type ParserOpt func(*Parser)
+
+// Parser parses function calls. A Parser is safe for concurrent use by multiple goroutines.
+type Parser struct {
+	config map[Token]string
+	quotes []rune
+	funcs  *Registry
+
+	tokenizer parse.Tokenizer
+}
+
+// WithTokens configures the syntax used by this parser using the provided token mapping. The provided map must contain
+// distinct entries for each Token provided in this package: OpenParen, CloseParen and Comma. OpenParen and CloseParen
+// must each be a single rune.
+func WithTokens(config map[Token]string) ParserOpt {
+	return func(parser *Parser) {
+		parser.config = config
+	}
+}
+
+// WithQuotes sets the runes which delimit string literals. By default, the runes in parse.DefaultQuotes are used.
+// String literals are never split, so that the call f('a, b') has a single argument.
+func WithQuotes(quotes ...rune) ParserOpt {
+	return func(parser *Parser) {
+		parser.quotes = quotes
+	}
+}
+
+// WithFuncs sets the functions which may be called. By default, calls to any function are parsed. If a Registry is
+// provided, a *parse.SyntaxError is returned for calls to functions which are not registered, and for calls with a
+// number of arguments not accepted by the function called. The Registry must not be modified while the Parser is in
+// use.
+func WithFuncs(funcs *Registry) ParserOpt {
+	return func(parser *Parser) {
+		parser.funcs = funcs
+	}
+}
+
+// NewParser returns a parser configured according to the provided options. If no options are configured, the default
+// parser is returned.
+func NewParser(opts ...ParserOpt) (*Parser, error) {
+	p := &Parser{
+		config: map[Token]string{
+			OpenParen:  "(",
+			CloseParen: ")",
+			Comma:      ",",
+		},
+		quotes: parse.DefaultQuotes,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Parser) init() error {
+	if len([]rune(p.config[OpenParen])) != 1 || len([]rune(p.config[CloseParen])) != 1 {
+		return fmt.Errorf("%w: OpenParen and CloseParen must each be a single rune", parse.ErrConfig)
+	}
+	if p.config[Comma] == "" {
+		return fmt.Errorf("%w: missing token %d", parse.ErrConfig, Comma)
+	}
+	if p.config[OpenParen] == p.config[CloseParen] || p.config[Comma] == p.config[OpenParen] ||
+		p.config[Comma] == p.config[CloseParen] {
+		return fmt.Errorf("%w: token collision detected; at least two of the provided tokens are identical", parse.ErrConfig)
+	}
+	for _, r := range p.config[Comma] {
+		if unicode.IsSpace(r) || isNameRune(r) {
+			return fmt.Errorf("%w: Comma must not contain whitespace, letters, digits or underscores", parse.ErrConfig)
+		}
+	}
+	matcher := &parse.KeywordTrie{}
+	matcher.Add(p.config[Comma])
+	p.tokenizer = parse.Tokenizer{
+		Open:     []rune(p.config[OpenParen])[0],
+		Close:    []rune(p.config[CloseParen])[0],
+		Keywords: matcher,
+		Quotes:   p.quotes,
+	}
+	for _, q := range p.quotes {
+		if unicode.IsSpace(q) || isNameRune(q) {
+			return fmt.Errorf("%w: quote '%c' must not be whitespace, a letter, a digit or an underscore", parse.ErrConfig, q)
+		}
+		for _, str := range p.config {
+			if strings.ContainsRune(str, q) {
+				return fmt.Errorf("%w: quote '%c' must not appear in any configured token", parse.ErrConfig, q)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseStr tokenizes and parses the provided string. See Parser.Parse for details. Any *parse.SyntaxError returned
+// includes the provided string as its Source.
+func (p *Parser) ParseStr(str string) (parse.AST, error) {
+	tokens, err := p.tokenizer.Tokenize(str)
+	var ast parse.AST
+	if err == nil {
+		ast, err = p.Parse(tokens)
+	}
+	var syntaxErr *parse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Source = str
+	}
+	return ast, err
+}
+
+// Parse parses the provided list of tokens, producing a *CallExpr if they consist of a single call, and a
+// parse.Unparsed node containing the provided tokens otherwise. The tokens are first split further at each
+// parenthesis and comma, since they may have been produced by another parser's tokenizer. A *parse.SyntaxError is
+// returned if the arguments of a call are malformed, or if the call is not permitted by the Registry provided using
+// WithFuncs.
+func (p *Parser) Parse(tokens []parse.Token) (parse.AST, error) {
+	split, err := p.tokenizer.Retokenize(tokens)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isCall(split) {
+		return parse.Unparsed{Contents: tokens}, nil
+	}
+	args, err := p.parseArgs(split)
+	if err != nil {
+		return nil, err
+	}
+	name := split[0].Text
+	if p.funcs != nil {
+		fn, ok := p.funcs.Lookup(name)
+		if !ok {
+			return nil, parse.NewSyntaxError(split, 0, nil, "unknown function %s", name)
+		}
+		if !fn.accepts(len(args)) {
+			return nil, parse.NewSyntaxError(split, 0, nil, "%s expects %s; found %d", name, fn.arity(), len(args))
+		}
+	}
+	return &CallExpr{
+		Name: name,
+		Args: args,
+		Src:  parse.Span{Start: split[0].Src.Start, End: split[len(split)-1].Src.End},
+	}, nil
+}
+
+// isCall reports whether the provided tokens consist of a name followed by a single parenthesized group.
+func (p *Parser) isCall(tokens []parse.Token) bool {
+	if len(tokens) < 3 || tokens[0].Quote != 0 || !isName(tokens[0].Text) || !p.is(tokens[1], OpenParen) {
+		return false
+	}
+	depth := 0
+	for i, token := range tokens[1:] {
+		if p.is(token, OpenParen) {
+			depth++
+		} else if p.is(token, CloseParen) {
+			if depth--; depth == 0 {
+				return i+2 == len(tokens)
+			}
+		}
+	}
+	return false
+}
+
+// parseArgs splits the arguments of the provided call at each comma outside nested parentheses, and parses each one.
+func (p *Parser) parseArgs(tokens []parse.Token) ([]parse.AST, error) {
+	end := len(tokens) - 1
+	if end == 2 {
+		return nil, nil
+	}
+	var args []parse.AST
+	depth, start := 0, 2
+	for i := 2; i <= end; i++ {
+		switch {
+		case p.is(tokens[i], OpenParen):
+			depth++
+		case p.is(tokens[i], CloseParen) && depth > 0:
+			depth--
+		case i == end || depth == 0 && p.is(tokens[i], Comma):
+			if i == start {
+				return nil, parse.NewSyntaxError(tokens, i, nil, "expected argument")
+			}
+			arg, err := p.Parse(tokens[start:i])
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			start = i + 1
+		}
+	}
+	return args, nil
+}
+
+// is reports whether the provided token is the provided Token.
+func (p *Parser) is(token parse.Token, t Token) bool {
+	return token.Quote == 0 && token.Text == p.config[t]
+}
+
+// isName returns true iff the provided text may be the name of a function.
+func isName(text string) bool {
+	for i, r := range text {
+		if !isNameRune(r) || i == 0 && unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return text != ""
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Print implements parse.Printer, rendering CallExpr nodes using the syntax configured for this Parser.
+func (p *Parser) Print(ast parse.AST, f *parse.Formatter) (string, int, error) {
+	call, ok := ast.(*CallExpr)
+	if !ok {
+		return "", 0, fmt.Errorf("%w: %T", parse.ErrUnknownAST, ast)
+	}
+	if !isName(call.Name) {
+		return "", 0, fmt.Errorf("cannot print call to function with invalid name %q", call.Name)
+	}
+	var sb strings.Builder
+	sb.WriteString(call.Name + p.config[OpenParen])
+	for i, arg := range call.Args {
+		if i > 0 {
+			sb.WriteString(p.config[Comma] + " ")
+		}
+		text, _, err := f.Print(arg)
+		if err != nil {
+			return "", 0, err
+		}
+		sb.WriteString(text)
+	}
+	sb.WriteString(p.config[CloseParen])
+	return sb.String(), parse.PrecAtom, nil
+}
+
+// defaultParser is used to implement the String method of CallExpr.
+var defaultParser, _ = NewParser()
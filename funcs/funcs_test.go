@@ -0,0 +1,210 @@
+package funcs
+
+import (
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/asttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestParser_Parse(t *testing.T) {
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"now()", call("now")},
+		{"len(x)", call("len", un("x"))},
+		{"len( ${items} )", call("len", un("${items}"))},
+		{"max(a, b + 1, 3)", call("max", un("a"), un("b", "+", "1"), un("3"))},
+		{"max(a,b)", call("max", un("a"), un("b"))},
+		{"lower(trim(name))", call("lower", call("trim", un("name")))},
+		{"f(g(x), (y))", call("f", call("g", un("x")), un("(", "y", ")"))},
+		{"f('a, b')", call("f", un("'a, b'"))},
+		{"f(x (y), z)", call("f", call("x", un("y")), un("z"))},
+		{"f(x y (z))", call("f", un("x", "y", "(", "z", ")"))},
+		{"_f1(x)", call("_f1", un("x"))},
+		{"x", un("x")},
+		{"f", un("f")},
+		{"f(x) + 1", un("f", "(", "x", ")", "+", "1")},
+		{"f(x)(y)", un("f", "(", "x", ")", "(", "y", ")")},
+		{"(x)", un("(", "x", ")")},
+		{"1f(x)", un("1f", "(", "x", ")")},
+		{"a.b(x)", un("a.b", "(", "x", ")")},
+		{"'f'(x)", un("'f'", "(", "x", ")")},
+		{"f(x", un("f", "(", "x")},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			if unparsed, ok := ast.(parse.Unparsed); ok {
+				// unparsed tokens are returned as provided, so compare their text
+				assert.Equal(t, tt.output.(parse.Unparsed).String(), unparsed.String())
+				return
+			}
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+}
+
+func TestParser_ParseSpan(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.ParseStr("  len(x) ")
+	require.NoError(t, err)
+	assert.Equal(t, 2, ast.(*CallExpr).Span().Start.Offset)
+	assert.Equal(t, 8, ast.(*CallExpr).Span().End.Offset)
+}
+
+func TestParser_ParseError(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+		opts  []ParserOpt
+	}{
+		{"f(,)", "expected argument", nil},
+		{"f(a,)", "expected argument", nil},
+		{"f(,a)", "expected argument", nil},
+		{"f(g(a,))", "expected argument", nil},
+		{"f(x)", "unknown function f", []ParserOpt{WithFuncs(Std())}},
+		{"len(a, b)", "len expects 1 argument; found 2", []ParserOpt{WithFuncs(Std())}},
+		{"now(a)", "now expects 0 arguments; found 1", []ParserOpt{WithFuncs(Std())}},
+		{"max()", "max expects at least 1 arguments; found 0", []ParserOpt{WithFuncs(Std())}},
+		{"lower(upper())", "upper expects 1 argument; found 0", []ParserOpt{WithFuncs(Std())}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p, err := NewParser(tt.opts...)
+			require.NoError(t, err)
+			_, err = p.ParseStr(tt.input)
+			var syntaxErr *parse.SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.Contains(t, err.Error(), tt.msg)
+			assert.Equal(t, tt.input, syntaxErr.Source)
+		})
+	}
+}
+
+func TestParser_Concurrent(t *testing.T) {
+	p, err := NewParser(WithFuncs(Std()))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"len(a)", call("len", un("a"))},
+		{"min(a, max(b, c))", call("min", un("a"), call("max", un("b"), un("c")))},
+		{"now()", call("now")},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tt := tests[(g+i)%len(tests)]
+				ast, err := p.ParseStr(tt.input)
+				if assert.NoError(t, err, tt.input) {
+					assert.EqualValues(t, tt.output, asttest.StripSpans(ast), tt.input)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestParser_Print(t *testing.T) {
+	tests := []struct {
+		input  parse.AST
+		output string
+	}{
+		{call("now"), "now()"},
+		{call("len", un("x")), "len(x)"},
+		{call("max", un("a"), un("b", "+", "1")), "max(a, b + 1)"},
+		{call("lower", call("trim", un("name"))), "lower(trim(name))"},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			text, err := parse.Format(tt.input, p)
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, text)
+			assert.Equal(t, tt.output, tt.input.(*CallExpr).String())
+
+			ast, err := p.ParseStr(text)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.input, asttest.StripSpans(ast))
+		})
+	}
+
+	for _, input := range []parse.AST{call(""), call("a b"), call("1")} {
+		_, err := parse.Format(input, p)
+		assert.Error(t, err)
+	}
+}
+
+func TestWithTokens(t *testing.T) {
+	p, err := NewParser(WithTokens(map[Token]string{OpenParen: "[", CloseParen: "]", Comma: ";"}))
+	require.NoError(t, err)
+
+	ast, err := p.ParseStr("max[a; f[b, c]]")
+	require.NoError(t, err)
+	assert.EqualValues(t, call("max", un("a"), call("f", un("b,", "c"))), asttest.StripSpans(ast))
+	text, err := parse.Format(ast, p)
+	require.NoError(t, err)
+	assert.Equal(t, "max[a; f[b, c]]", text)
+
+	configs := []map[Token]string{
+		{OpenParen: "(", CloseParen: ")"},
+		{OpenParen: "((", CloseParen: ")", Comma: ","},
+		{OpenParen: "(", CloseParen: "(", Comma: ","},
+		{OpenParen: "(", CloseParen: ")", Comma: ")"},
+		{OpenParen: "(", CloseParen: ")", Comma: "and"},
+		{OpenParen: "(", CloseParen: ")", Comma: ", "},
+	}
+	for _, config := range configs {
+		_, err := NewParser(WithTokens(config))
+		assert.ErrorIs(t, err, parse.ErrConfig, config)
+	}
+}
+
+func TestWithQuotes(t *testing.T) {
+	p, err := NewParser(WithQuotes('|'))
+	require.NoError(t, err)
+	ast, err := p.ParseStr("f(|a, b|, 'c, d')")
+	require.NoError(t, err)
+	expected := call("f", un("|a, b|"), un("'c"), un("d'"))
+	expected.Args[0].(parse.Unparsed).Contents[0].Quote = '|'
+	assert.EqualValues(t, expected, asttest.StripSpans(ast))
+
+	for _, quotes := range [][]rune{{'('}, {' '}, {'a'}, {','}} {
+		_, err := NewParser(WithQuotes(quotes...))
+		assert.ErrorIs(t, err, parse.ErrConfig, string(quotes))
+	}
+}
+
+func TestWithChildren(t *testing.T) {
+	var branch parse.Branch = call("f", un("a"), un("b"))
+	children := []parse.AST{un("x"), un("y")}
+	result := branch.WithChildren(children)
+	children[0] = un("z")
+	assert.Equal(t, []parse.AST{un("x"), un("y")}, parse.ChildrenOf(result))
+	assert.Equal(t, "f", result.(*CallExpr).Name)
+	assert.Panics(t, func() { branch.WithChildren(nil) })
+}
+
+func call(name string, args ...parse.AST) *CallExpr {
+	return &CallExpr{Name: name, Args: args}
+}
+
+// un stands for unparsed and returns a parse.Unparsed
+func un(tokens ...string) parse.AST {
+	return parse.Unparsed{Contents: parse.NewTokens(tokens...)}
+}
@@ -0,0 +1,317 @@
+package funcs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Type is a set of the types of value which may be passed to or returned from a function.
+type Type uint16
+
+const (
+	TypeNull     Type = 1 << iota // TypeNull is the type of nil.
+	TypeBool                      // TypeBool is the type of bool values.
+	TypeNumber                    // TypeNumber is the type of numbers of any Go numeric type, *big.Int and json.Number.
+	TypeString                    // TypeString is the type of string values.
+	TypeList                      // TypeList is the type of slices and arrays.
+	TypeMap                       // TypeMap is the type of maps.
+	TypeTime                      // TypeTime is the type of time.Time values.
+	TypeDuration                  // TypeDuration is the type of time.Duration values.
+
+	TypeAny = TypeNull | TypeBool | TypeNumber | TypeString | TypeList | TypeMap | TypeTime | TypeDuration
+)
+
+var typeNames = []string{"null", "bool", "number", "string", "list", "map", "time", "duration"}
+
+func (t Type) String() string {
+	if t == TypeAny {
+		return "any"
+	}
+	var names []string
+	for i, name := range typeNames {
+		if t&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 || t&^TypeAny != 0 {
+		return "unknown type"
+	}
+	return strings.Join(names, "|")
+}
+
+// TypeOf returns the Type of the provided value, or 0 if it is not a value of any Type. A parse.Value has the Type of
+// the Go value returned by its Interface method.
+func TypeOf(val any) Type {
+	if v, ok := val.(parse.Value); ok {
+		val = v.Interface()
+	}
+	switch val.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBool
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, *big.Int, json.Number:
+		return TypeNumber
+	case string:
+		return TypeString
+	case time.Time:
+		return TypeTime
+	case time.Duration:
+		return TypeDuration
+	}
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Slice, reflect.Array:
+		return TypeList
+	case reflect.Map:
+		return TypeMap
+	}
+	return 0
+}
+
+// Func describes a function which may be called from an expression.
+type Func struct {
+	// Params lists the types accepted by each parameter of the function.
+	Params []Type
+	// Variadic is set if the last parameter may be repeated any number of times, including zero, so that the function
+	// accepts at least len(Params)-1 arguments.
+	Variadic bool
+	// Result is the type of the values returned by the function.
+	Result Type
+	// NullTolerant is set if arguments whose values are not found, such as missing variables, are passed to the function
+	// as nil, rather than causing the call to fail with an error matching parse.ErrNotFound.
+	NullTolerant bool
+	// Call calls the function with the provided arguments, which are guaranteed to match Params. Errors returned are
+	// wrapped in parse.ErrEval by the Interpreter.
+	Call func(args []any) (any, error)
+}
+
+// accepts reports whether the function accepts the provided number of arguments.
+func (f Func) accepts(n int) bool {
+	if f.Variadic {
+		return n >= len(f.Params)-1
+	}
+	return n == len(f.Params)
+}
+
+// arity describes the number of arguments accepted by the function, for use in error messages.
+func (f Func) arity() string {
+	n := len(f.Params)
+	switch {
+	case f.Variadic:
+		return fmt.Sprintf("at least %d arguments", n-1)
+	case n == 1:
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// param returns the type accepted by the argument at index i.
+func (f Func) param(i int) Type {
+	if i >= len(f.Params) {
+		return f.Params[len(f.Params)-1]
+	}
+	return f.Params[i]
+}
+
+// Registry holds a set of named functions. A Registry may be used concurrently by multiple goroutines, provided that
+// it is not modified at the same time.
+type Registry struct {
+	funcs map[string]Func
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{funcs: make(map[string]Func)}
+}
+
+// Register adds the provided function to this Registry under the provided name. An error matching parse.ErrConfig is
+// returned if the name is not a valid name or is already registered, or if the function is malformed.
+func (r *Registry) Register(name string, fn Func) error {
+	switch {
+	case !isName(name):
+		return fmt.Errorf("%w: invalid function name %q", parse.ErrConfig, name)
+	case r.funcs[name].Call != nil:
+		return fmt.Errorf("%w: function %s is already registered", parse.ErrConfig, name)
+	case fn.Call == nil:
+		return fmt.Errorf("%w: function %s has no implementation", parse.ErrConfig, name)
+	case fn.Variadic && len(fn.Params) == 0:
+		return fmt.Errorf("%w: variadic function %s has no parameters", parse.ErrConfig, name)
+	}
+	for _, param := range fn.Params {
+		if param == 0 || param&^TypeAny != 0 {
+			return fmt.Errorf("%w: function %s has a parameter of unknown type", parse.ErrConfig, name)
+		}
+	}
+	r.funcs[name] = fn
+	return nil
+}
+
+// Lookup returns the function registered under the provided name, if any.
+func (r *Registry) Lookup(name string) (Func, bool) {
+	fn, ok := r.funcs[name]
+	return fn, ok
+}
+
+// Std returns a new Registry holding the following standard functions, to which more may be added.
+//   - len(x) returns the number of runes in a string, or the number of elements in a list or map, as an int64.
+//   - lower(s), upper(s) and trim(s) return s in lower case, in upper case, or without leading and trailing whitespace.
+//   - abs(n) returns the absolute value of a number.
+//   - min(n, ...) and max(n, ...) return the least or greatest of one or more numbers. The result is NaN if any of
+//     them is NaN.
+//   - now() returns the current time.
+//   - coalesce(x, ...) returns the first of its arguments which is not nil, or nil if there is none. Arguments whose
+//     values are not found are treated as nil.
+//
+// Numbers returned by abs, min and max are converted to int64, *big.Int or float64, as in the arith package.
+func Std() *Registry {
+	r := NewRegistry()
+	for name, fn := range map[string]Func{
+		"len":      {Params: []Type{TypeString | TypeList | TypeMap}, Result: TypeNumber, Call: length},
+		"lower":    {Params: []Type{TypeString}, Result: TypeString, Call: stringFunc(strings.ToLower)},
+		"upper":    {Params: []Type{TypeString}, Result: TypeString, Call: stringFunc(strings.ToUpper)},
+		"trim":     {Params: []Type{TypeString}, Result: TypeString, Call: stringFunc(strings.TrimSpace)},
+		"abs":      {Params: []Type{TypeNumber}, Result: TypeNumber, Call: abs},
+		"min":      {Params: []Type{TypeNumber, TypeNumber}, Variadic: true, Result: TypeNumber, Call: extreme(-1)},
+		"max":      {Params: []Type{TypeNumber, TypeNumber}, Variadic: true, Result: TypeNumber, Call: extreme(1)},
+		"now":      {Result: TypeTime, Call: func([]any) (any, error) { return time.Now(), nil }},
+		"coalesce": {Params: []Type{TypeAny, TypeAny}, Variadic: true, Result: TypeAny, NullTolerant: true, Call: coalesce},
+	} {
+		if err := r.Register(name, fn); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+func length(args []any) (any, error) {
+	if s, ok := args[0].(string); ok {
+		return int64(utf8.RuneCountInString(s)), nil
+	}
+	return int64(reflect.ValueOf(args[0]).Len()), nil
+}
+
+func stringFunc(fn func(string) string) func([]any) (any, error) {
+	return func(args []any) (any, error) {
+		return fn(args[0].(string)), nil
+	}
+}
+
+func abs(args []any) (any, error) {
+	n, err := number(args[0])
+	if err != nil {
+		return nil, err
+	}
+	switch n := n.(type) {
+	case int64:
+		if n == math.MinInt64 {
+			return new(big.Int).Neg(big.NewInt(n)), nil
+		}
+		if n < 0 {
+			return -n, nil
+		}
+		return n, nil
+	case *big.Int:
+		return new(big.Int).Abs(n), nil
+	}
+	return math.Abs(n.(float64)), nil
+}
+
+// extreme returns a function which finds the least of its arguments if sign is -1, or the greatest if sign is 1.
+func extreme(sign int) func([]any) (any, error) {
+	return func(args []any) (any, error) {
+		var result any
+		var resultNum *big.Float
+		for _, arg := range args {
+			n, err := number(arg)
+			if err != nil {
+				return nil, err
+			}
+			if f, ok := n.(float64); ok && math.IsNaN(f) {
+				return f, nil
+			}
+			num := toBigFloat(n)
+			if result == nil || num.Cmp(resultNum) == sign {
+				result, resultNum = n, num
+			}
+		}
+		return result, nil
+	}
+}
+
+func coalesce(args []any) (any, error) {
+	for _, arg := range args {
+		if arg != nil {
+			return arg, nil
+		}
+	}
+	return nil, nil
+}
+
+// number converts the provided number to an int64, *big.Int or float64.
+func number(val any) (any, error) {
+	switch v := val.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return fromBig(new(big.Int).SetUint64(uint64(v))), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return fromBig(new(big.Int).SetUint64(v)), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case *big.Int:
+		if v != nil {
+			return fromBig(v), nil
+		}
+	case json.Number:
+		if i, ok := new(big.Int).SetString(string(v), 10); ok {
+			return fromBig(i), nil
+		}
+		if f, err := v.Float64(); err == nil {
+			return f, nil
+		}
+	}
+	return nil, errors.New("not a number")
+}
+
+// fromBig returns the provided integer as an int64 if it fits.
+func fromBig(i *big.Int) any {
+	if i.IsInt64() {
+		return i.Int64()
+	}
+	return i
+}
+
+// toBigFloat converts an int64, *big.Int or non-NaN float64 to a *big.Float, so that they can be compared exactly.
+func toBigFloat(n any) *big.Float {
+	switch n := n.(type) {
+	case int64:
+		return new(big.Float).SetInt64(n)
+	case *big.Int:
+		return new(big.Float).SetInt(n)
+	}
+	return big.NewFloat(n.(float64))
+}
@@ -0,0 +1,136 @@
+package funcs
+
+import (
+	"encoding/json"
+	"github.com/orkes-io/go-parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestTypeOf(t *testing.T) {
+	tests := []struct {
+		input  any
+		output Type
+	}{
+		{nil, TypeNull},
+		{true, TypeBool},
+		{uint8(3), TypeNumber},
+		{3.5, TypeNumber},
+		{big.NewInt(3), TypeNumber},
+		{json.Number("3"), TypeNumber},
+		{"x", TypeString},
+		{[]any{1}, TypeList},
+		{[2]int{}, TypeList},
+		{map[string]any{}, TypeMap},
+		{time.Unix(0, 0), TypeTime},
+		{time.Second, TypeDuration},
+		{struct{}{}, 0},
+		{parse.Null{}, TypeNull},
+		{parse.Int(3), TypeNumber},
+		{parse.String("x"), TypeString},
+		{parse.List{parse.Int(1)}, TypeList},
+		{parse.Duration(time.Second), TypeDuration},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.output, TypeOf(tt.input), "%#v", tt.input)
+	}
+}
+
+func TestType_String(t *testing.T) {
+	assert.Equal(t, "number", TypeNumber.String())
+	assert.Equal(t, "string|list|map", (TypeString | TypeList | TypeMap).String())
+	assert.Equal(t, "any", TypeAny.String())
+	assert.Equal(t, "unknown type", Type(0).String())
+	assert.Equal(t, "unknown type", (TypeAny + 1).String())
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+	double := Func{
+		Params: []Type{TypeNumber},
+		Result: TypeNumber,
+		Call: func(args []any) (any, error) {
+			n, err := number(args[0])
+			return n.(int64) * 2, err
+		},
+	}
+	require.NoError(t, r.Register("double", double))
+	fn, ok := r.Lookup("double")
+	require.True(t, ok)
+	result, err := fn.Call([]any{21})
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), result)
+
+	_, ok = r.Lookup("triple")
+	assert.False(t, ok)
+
+	tests := []struct {
+		name string
+		fn   Func
+	}{
+		{"double", double},
+		{"", double},
+		{"2x", double},
+		{"a-b", double},
+		{"nil", Func{}},
+		{"variadic", Func{Variadic: true, Call: double.Call}},
+		{"untyped", Func{Params: []Type{0}, Call: double.Call}},
+	}
+	for _, tt := range tests {
+		assert.ErrorIs(t, r.Register(tt.name, tt.fn), parse.ErrConfig, tt.name)
+	}
+}
+
+func TestStd(t *testing.T) {
+	bigInt, _ := new(big.Int).SetString("-100000000000000000000", 10)
+	tests := []struct {
+		name   string
+		args   []any
+		result any
+	}{
+		{"len", []any{"héllo"}, int64(5)},
+		{"len", []any{[]any{1, 2}}, int64(2)},
+		{"len", []any{[3]int{}}, int64(3)},
+		{"len", []any{map[string]any{"a": 1}}, int64(1)},
+		{"lower", []any{"ABC"}, "abc"},
+		{"upper", []any{"abc"}, "ABC"},
+		{"trim", []any{"  a b \n"}, "a b"},
+		{"abs", []any{-3}, int64(3)},
+		{"abs", []any{int64(math.MinInt64)}, new(big.Int).Neg(big.NewInt(math.MinInt64))},
+		{"abs", []any{bigInt}, new(big.Int).Neg(bigInt)},
+		{"abs", []any{-2.5}, 2.5},
+		{"abs", []any{json.Number("-7")}, int64(7)},
+		{"abs", []any{uint64(math.MaxUint64)}, new(big.Int).SetUint64(math.MaxUint64)},
+		{"min", []any{3}, int64(3)},
+		{"min", []any{3, 2.5, uint8(4)}, 2.5},
+		{"min", []any{bigInt, 1}, bigInt},
+		{"max", []any{3, 2.5, uint8(4)}, int64(4)},
+		{"max", []any{int64(1 << 60), float64(1 << 60)}, int64(1 << 60)},
+		{"coalesce", []any{nil, nil, "a", "b"}, "a"},
+		{"coalesce", []any{nil}, nil},
+	}
+	std := Std()
+	for _, tt := range tests {
+		fn, ok := std.Lookup(tt.name)
+		require.True(t, ok, tt.name)
+		require.True(t, fn.accepts(len(tt.args)), tt.name)
+		result, err := fn.Call(tt.args)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.result, result, "%s(%v)", tt.name, tt.args)
+	}
+
+	fn, _ := std.Lookup("max")
+	result, err := fn.Call([]any{1, math.NaN(), 2})
+	require.NoError(t, err)
+	assert.True(t, math.IsNaN(result.(float64)))
+
+	fn, _ = std.Lookup("now")
+	before := time.Now()
+	result, err = fn.Call(nil)
+	require.NoError(t, err)
+	assert.WithinRange(t, result.(time.Time), before, time.Now())
+}
@@ -0,0 +1,69 @@
+// Package asttest provides helpers for testing the parsers in this module.
+package asttest
+
+import (
+	"github.com/orkes-io/go-parse"
+	"reflect"
+)
+
+var spanType = reflect.TypeOf(parse.Span{})
+
+// StripSpans returns a deep copy of the provided AST in which every parse.Span has been zeroed. It allows parsed ASTs
+// to be compared against ASTs built by hand, without regard to their location in the source text.
+func StripSpans(ast parse.AST) parse.AST {
+	if ast == nil {
+		return nil
+	}
+	return strip(reflect.ValueOf(&ast).Elem()).Interface().(parse.AST)
+}
+
+func strip(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.New(v.Type()).Elem()
+		result.Set(strip(v.Elem()))
+		return result
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.New(v.Type().Elem())
+		result.Elem().Set(strip(v.Elem()))
+		return result
+	case reflect.Struct:
+		if v.Type() == spanType {
+			return reflect.Zero(spanType)
+		}
+		result := reflect.New(v.Type()).Elem()
+		result.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if result.Field(i).CanSet() {
+				result.Field(i).Set(strip(v.Field(i)))
+			}
+		}
+		return result
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(strip(v.Index(i)))
+		}
+		return result
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		result := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), strip(iter.Value()))
+		}
+		return result
+	}
+	return v
+}
@@ -0,0 +1,45 @@
+// Package exprtest provides a fixture for testing the packages in this module which handle entire expressions, such
+// as eval, typecheck and compile.
+package exprtest
+
+import (
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/arith"
+	"github.com/orkes-io/go-parse/bools"
+	"github.com/orkes-io/go-parse/coalesce"
+	"github.com/orkes-io/go-parse/comp"
+	"github.com/orkes-io/go-parse/cond"
+	"github.com/orkes-io/go-parse/funcs"
+	"github.com/orkes-io/go-parse/literal"
+	"github.com/orkes-io/go-parse/vars"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// Parse parses the provided expression using the parsers of every package in this module, failing the test if it
+// cannot be parsed. AND binds more tightly than OR, and chained comparisons are enabled.
+func Parse(t testing.TB, input string) parse.AST {
+	t.Helper()
+	k, err := cond.NewParser()
+	require.NoError(t, err)
+	b, err := bools.NewParser(bools.WithPrecedence(bools.PrecedenceStandard))
+	require.NoError(t, err)
+	c, err := comp.NewParser(comp.WithChainedComparisons(true))
+	require.NoError(t, err)
+	co, err := coalesce.NewParser()
+	require.NoError(t, err)
+	f, err := funcs.NewParser()
+	require.NoError(t, err)
+	v, err := vars.NewParser()
+	require.NoError(t, err)
+	l, err := literal.NewParser()
+	require.NoError(t, err)
+	a, err := arith.NewParser()
+	require.NoError(t, err)
+
+	ast, err := k.ParseStr(input)
+	require.NoError(t, err)
+	ast, err = parse.ParseAll(ast, b, c, co, f, v, l, a, f, a, v, l)
+	require.NoError(t, err)
+	return ast
+}
@@ -0,0 +1,38 @@
+// Package spine walks the left spines of binary expression trees, such as the left-associative chain built for
+// a AND b AND c, iteratively.
+package spine
+
+import "sync"
+
+// stacks holds the stacks used to reverse spines, so that they are reused between walks rather than allocated by each.
+var stacks = sync.Pool{New: func() any { return new([]any) }}
+
+// Walk calls visit for root and every node found by repeatedly calling left on it, starting from the deepest, so that
+// long chains are processed in source order without recursing. deepest is true for the first call only. Walking stops
+// at the first error returned by visit, which is returned.
+func Walk[T any](root T, left func(T) (T, bool), visit func(node T, deepest bool) error) error {
+	next, ok := left(root)
+	if !ok {
+		return visit(root, true)
+	}
+	stack := stacks.Get().(*[]any)
+	defer func() {
+		// clear the stack so that the pool does not keep the nodes reachable
+		for i := range *stack {
+			(*stack)[i] = nil
+		}
+		*stack = (*stack)[:0]
+		stacks.Put(stack)
+	}()
+	*stack = append(*stack, root)
+	for ok {
+		*stack = append(*stack, next)
+		next, ok = left(next)
+	}
+	for i := len(*stack) - 1; i >= 0; i-- {
+		if err := visit((*stack)[i].(T), i == len(*stack)-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package spine
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type node struct {
+	lhs *node
+	val int
+}
+
+func left(n *node) (*node, bool) {
+	return n.lhs, n.lhs != nil
+}
+
+func chain(n int) *node {
+	var root *node
+	for i := 0; i < n; i++ {
+		root = &node{lhs: root, val: i}
+	}
+	return root
+}
+
+func TestWalk(t *testing.T) {
+	for _, n := range []int{1, 2, 5, 100000} {
+		var vals []int
+		var deepest []bool
+		err := Walk(chain(n), left, func(node *node, first bool) error {
+			vals = append(vals, node.val)
+			if first {
+				deepest = append(deepest, first)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Len(t, vals, n)
+		for i, val := range vals {
+			if val != i {
+				assert.Failf(t, "out of order", "visited %d at %d", val, i)
+				break
+			}
+		}
+		assert.Equal(t, []bool{true}, deepest)
+	}
+}
+
+func TestWalk_Error(t *testing.T) {
+	errStop := errors.New("stop")
+	var vals []int
+	err := Walk(chain(5), left, func(node *node, _ bool) error {
+		vals = append(vals, node.val)
+		if node.val == 2 {
+			return errStop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, []int{0, 1, 2}, vals)
+}
+
+func TestWalk_Allocs(t *testing.T) {
+	root := chain(50)
+	visit := func(*node, bool) error { return nil }
+	_ = Walk(root, left, visit) // populate the pool
+	assert.Zero(t, testing.AllocsPerRun(100, func() { _ = Walk(root, left, visit) }))
+}
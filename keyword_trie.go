@@ -1,5 +1,8 @@
 package parse
 
+import "unicode"
+
+// KeywordTrie matches a set of keywords against a stream of runes.
 type KeywordTrie struct {
 	children []*KeywordTrie
 	runes    []rune
@@ -51,6 +54,50 @@ func (t *KeywordTrie) Match(stream []rune) string {
 	return t.leaf
 }
 
+// MatchWord is like Match, except that keywords which begin or end with a letter, digit or underscore only match at
+// identifier boundaries. prev is the rune immediately preceding stream, or 0 if there is none. For instance, the
+// keyword OR matches "OR x" but neither "ORDER" nor "COLOR", while a keyword such as == matches anywhere. If the
+// maximal keyword found does not fall on a boundary, the longest shorter keyword which does is returned instead.
+func (t *KeywordTrie) MatchWord(prev rune, stream []rune) string {
+	var candidates []string
+	node := t
+	for i := 0; node != nil; i++ {
+		if node.leaf != "" {
+			candidates = append(candidates, node.leaf)
+		}
+		if i == len(stream) {
+			break
+		}
+		node = node.child(stream[i])
+	}
+	for i := len(candidates) - 1; i >= 0; i-- {
+		keyword := []rune(candidates[i])
+		n := len(keyword)
+		if isWordRune(keyword[0]) && isWordRune(prev) {
+			continue
+		}
+		if isWordRune(keyword[n-1]) && n < len(stream) && isWordRune(stream[n]) {
+			continue
+		}
+		return candidates[i]
+	}
+	return ""
+}
+
+func (t *KeywordTrie) child(r rune) *KeywordTrie {
+	for idx, c := range t.runes {
+		if c == r {
+			return t.children[idx]
+		}
+	}
+	return nil
+}
+
+// isWordRune returns true iff r may form part of an identifier.
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
 // Add adds the provided keyword to this Trie.
 func (t *KeywordTrie) Add(keyword string) {
 	t.add(keyword, []rune(keyword))
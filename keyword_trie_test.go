@@ -28,3 +28,39 @@ func TestKeywordTrie(t *testing.T) {
 
 	assert.Equal(t, 7, trie.Count())
 }
+
+func TestKeywordTrie_MatchWord(t *testing.T) {
+	trie := &KeywordTrie{}
+	for _, keyword := range []string{"OR", "ORDER BY", "NOT", "&&", "==", "=", "IS", "IS NOT"} {
+		trie.Add(keyword)
+	}
+
+	tests := []struct {
+		prev   rune
+		input  string
+		output string
+	}{
+		{0, "OR x", "OR"},
+		{0, "OR", "OR"},
+		{' ', "OR(x)", "OR"},
+		{0, "ORDER", ""},
+		{'C', "OR", ""},
+		{'_', "OR", ""},
+		{'.', "OR.x", "OR"},
+		{0, "NOTE", ""},
+		{0, "NOT(x)", "NOT"},
+		{0, "ORDER BY x", "ORDER BY"},
+		{0, "ORDER BYE", ""},
+		{0, "IS NOTE", "IS"},
+		{'x', "&&y", "&&"},
+		{'x', "==3", "=="},
+		{'x', "=3", "="},
+		{0, "ÉOR", ""},
+		{'é', "OR", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.output, trie.MatchWord(tt.prev, []rune(tt.input)))
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package literal
+
+import (
+	"fmt"
+	"github.com/orkes-io/go-parse"
+)
+
+// Interpreter returns a parse.Interpreter which finds the value of Lit nodes. Nodes of any other type result in
+// parse.ErrUnknownAST, so that the returned Interpreter can be combined with others using WithFallback. For instance,
+// to evaluate comparisons between literals and variables:
+//
+//	comp.Interpreter(literal.Interpreter().WithFallback(variables))
+func Interpreter() parse.Interpreter[any] {
+	return func(ast parse.AST) (any, error) {
+		lit, ok := ast.(*Lit)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown AST node: %v", parse.ErrUnknownAST, ast)
+		}
+		return lit.Value, nil
+	}
+}
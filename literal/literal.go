@@ -0,0 +1,335 @@
+// Package literal implements a parser which recognizes literal values, such as numbers, strings and timestamps. Each
+// list of tokens which consists of a single literal is parsed into a Lit node; any other list of tokens is left as-is
+// in a parse.Unparsed node. The following literals are recognized.
+//
+//	int      -> a decimal integer, e.g. 42 or -7
+//	float    -> a decimal number with a fraction or exponent, e.g. 3.5, .5 or 1e9
+//	string   -> a quoted string literal, see parse.Unquote
+//	bool     -> 'true' | 'false'
+//	null     -> 'null' | 'nil'
+//	duration -> a duration accepted by time.ParseDuration, e.g. 5m or 1h30m
+//	time     -> an ISO-8601 timestamp, e.g. 2024-01-02T15:04:05Z or 2024-01-02
+//
+// Since this parser never splits tokens, it is usually run last. When used along with the arith package, which splits
+// tokens such as -3 and 2024-01-02 at each operator, this parser should also run before arith, so that such literals
+// are recognized before they are split:
+//
+//	ast, err := parse.ParseAll(ast, compParser, literalParser, arithParser, literalParser)
+package literal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Kind identifies the type of a literal.
+type Kind uint8
+
+const (
+	KindInt      Kind = iota + 1 // KindInt is the kind of integers, whose values are int64 or *big.Int.
+	KindFloat                    // KindFloat is the kind of floating point numbers, whose values are float64.
+	KindString                   // KindString is the kind of string literals, whose values are string.
+	KindBool                     // KindBool is the kind of true and false, whose values are bool.
+	KindNull                     // KindNull is the kind of null, whose value is nil.
+	KindDuration                 // KindDuration is the kind of durations, whose values are time.Duration.
+	KindTime                     // KindTime is the kind of timestamps, whose values are time.Time.
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindInt:
+		return "int"
+	case KindFloat:
+		return "float"
+	case KindString:
+		return "string"
+	case KindBool:
+		return "bool"
+	case KindNull:
+		return "null"
+	case KindDuration:
+		return "duration"
+	case KindTime:
+		return "time"
+	default:
+		return "unknown kind"
+	}
+}
+
+// allKinds lists every Kind.
+var allKinds = []Kind{KindString, KindBool, KindNull, KindInt, KindFloat, KindDuration, KindTime}
+
+// Lit represents a literal value.
+type Lit struct {
+	Kind  Kind       // Kind is the type of this literal.
+	Value any        // Value is the value of this literal; see Kind for the type used by each kind.
+	Src   parse.Span // Src is the location of this literal in the source text.
+}
+
+// String returns this literal in the default syntax of this package.
+func (l *Lit) String() string {
+	text, err := parse.Format(l, defaultParser)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return text
+}
+
+// Span returns the location of this literal in the source text.
+func (l *Lit) Span() parse.Span {
+	return l.Src
+}
+
+// Parse does nothing, since a Lit has no unparsed nodes.
+func (l *Lit) Parse(parse.Parser) error {
+	return nil
+}
+
+// Timestamp layouts recognized by this parser. Timestamps without a time zone are interpreted as UTC.
+var layouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"}
+
+var (
+	intPattern   = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	floatPattern = regexp.MustCompile(`^[+-]?([0-9]+\.[0-9]*|\.[0-9]+|[0-9]+)([eE][+-]?[0-9]+)?$`)
+)
+
+type ParserOpt func(*Parser)
+
+// Parser parses literals. A Parser is safe for concurrent use by multiple goroutines.
+type Parser struct {
+	kinds  []Kind
+	quotes []rune
+
+	tokenizer parse.Tokenizer
+}
+
+// WithKinds sets the kinds of literal recognized by this parser. By default, every Kind is recognized. For instance,
+// WithKinds(KindInt, KindFloat, KindString) prevents identifiers such as true or 5m from being parsed as literals.
+func WithKinds(kinds ...Kind) ParserOpt {
+	return func(parser *Parser) {
+		parser.kinds = kinds
+	}
+}
+
+// WithQuotes sets the runes which delimit string literals. By default, the runes in parse.DefaultQuotes are used.
+// String literals are printed using the first of the provided runes.
+func WithQuotes(quotes ...rune) ParserOpt {
+	return func(parser *Parser) {
+		parser.quotes = quotes
+	}
+}
+
+// NewParser returns a parser configured according to the provided options. If no options are configured, the default
+// parser is returned.
+func NewParser(opts ...ParserOpt) (*Parser, error) {
+	p := &Parser{
+		kinds:  allKinds,
+		quotes: parse.DefaultQuotes,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Parser) init() error {
+	for _, kind := range p.kinds {
+		if kind < KindInt || kind > KindTime {
+			return fmt.Errorf("%w: unknown kind %d", parse.ErrConfig, kind)
+		}
+	}
+	if p.recognizes(KindString) && len(p.quotes) == 0 {
+		return fmt.Errorf("%w: KindString requires at least one quote", parse.ErrConfig)
+	}
+	p.tokenizer = parse.Tokenizer{Keywords: &parse.KeywordTrie{}, Quotes: p.quotes}
+	return nil
+}
+
+func (p *Parser) recognizes(kind Kind) bool {
+	for _, k := range p.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseStr tokenizes and parses the provided string. See Parser.Parse for details. Any *parse.SyntaxError returned
+// includes the provided string as its Source.
+func (p *Parser) ParseStr(str string) (parse.AST, error) {
+	tokens, err := p.tokenizer.Tokenize(str)
+	var ast parse.AST
+	if err == nil {
+		ast, err = p.Parse(tokens)
+	}
+	var syntaxErr *parse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Source = str
+	}
+	return ast, err
+}
+
+// Parse parses the provided list of tokens, producing a *Lit if it consists of a single literal, and a parse.Unparsed
+// node containing the provided tokens otherwise. A *parse.SyntaxError is returned if the tokens consist of a single
+// malformed string literal.
+func (p *Parser) Parse(tokens []parse.Token) (parse.AST, error) {
+	if len(tokens) == 1 {
+		lit, err := p.parseLit(tokens[0])
+		if err != nil {
+			return nil, parse.NewSyntaxError(tokens, 0, nil, "invalid string literal %s", tokens[0].Text)
+		}
+		if lit != nil {
+			return lit, nil
+		}
+	}
+	return parse.Unparsed{Contents: tokens}, nil
+}
+
+// parseLit returns the literal found in the provided token, or nil if it is not a literal.
+func (p *Parser) parseLit(token parse.Token) (*Lit, error) {
+	text := token.Text
+	for _, kind := range p.kinds {
+		if (token.Quote != 0) != (kind == KindString) { // only string literals are quoted
+			continue
+		}
+		var val any
+		switch kind {
+		case KindString:
+			if !p.isQuote(token.Quote) {
+				continue
+			}
+			str, err := parse.Unquote(text)
+			if err != nil {
+				return nil, err
+			}
+			val = str
+		case KindBool:
+			if text != "true" && text != "false" {
+				continue
+			}
+			val = text == "true"
+		case KindNull:
+			if text != "null" && text != "nil" {
+				continue
+			}
+		case KindInt:
+			if !intPattern.MatchString(text) {
+				continue
+			}
+			if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+				val = i
+			} else {
+				val, _ = new(big.Int).SetString(text, 10)
+			}
+		case KindFloat:
+			if !floatPattern.MatchString(text) || intPattern.MatchString(text) {
+				continue
+			}
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				continue // out of range
+			}
+			val = f
+		case KindDuration:
+			d, err := time.ParseDuration(text)
+			if err != nil || intPattern.MatchString(text) {
+				continue
+			}
+			val = d
+		case KindTime:
+			t, ok := parseTime(text)
+			if !ok {
+				continue
+			}
+			val = t
+		}
+		return &Lit{Kind: kind, Value: val, Src: token.Src}, nil
+	}
+	return nil, nil
+}
+
+func parseTime(text string) (time.Time, bool) {
+	if len(text) < len("2006-01-02") || text[4] != '-' {
+		return time.Time{}, false
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (p *Parser) isQuote(r rune) bool {
+	for _, q := range p.quotes {
+		if r == q {
+			return true
+		}
+	}
+	return false
+}
+
+// Print implements parse.Printer, rendering Lit nodes using the syntax recognized by this Parser, such that parsing
+// the resulting text produces the original literal. An error is returned for literals which cannot be represented,
+// such as NaN, or whose Kind is not recognized by this Parser.
+func (p *Parser) Print(ast parse.AST, f *parse.Formatter) (string, int, error) {
+	lit, ok := ast.(*Lit)
+	if !ok {
+		return "", 0, fmt.Errorf("%w: %T", parse.ErrUnknownAST, ast)
+	}
+	if !p.recognizes(lit.Kind) {
+		return "", 0, fmt.Errorf("cannot print literal of kind %v", lit.Kind)
+	}
+	switch val := lit.Value.(type) {
+	case int64:
+		if lit.Kind == KindInt {
+			return strconv.FormatInt(val, 10), parse.PrecAtom, nil
+		}
+	case *big.Int:
+		if lit.Kind == KindInt && val != nil {
+			return val.String(), parse.PrecAtom, nil
+		}
+	case float64:
+		if lit.Kind != KindFloat || math.IsNaN(val) || math.IsInf(val, 0) {
+			break
+		}
+		text := strconv.FormatFloat(val, 'g', -1, 64)
+		if intPattern.MatchString(text) { // keep the decimal point, so that the literal is not read as an integer
+			text += ".0"
+		}
+		return text, parse.PrecAtom, nil
+	case string:
+		if lit.Kind == KindString {
+			return parse.Quote(val, p.quotes[0]), parse.PrecAtom, nil
+		}
+	case bool:
+		if lit.Kind == KindBool {
+			return strconv.FormatBool(val), parse.PrecAtom, nil
+		}
+	case nil:
+		if lit.Kind == KindNull {
+			return "null", parse.PrecAtom, nil
+		}
+	case time.Duration:
+		if lit.Kind == KindDuration {
+			return val.String(), parse.PrecAtom, nil
+		}
+	case time.Time:
+		if lit.Kind == KindTime {
+			return val.Format(time.RFC3339Nano), parse.PrecAtom, nil
+		}
+	}
+	return "", 0, fmt.Errorf("cannot print %v literal with value %#v", lit.Kind, lit.Value)
+}
+
+// defaultParser is used to implement the String method of Lit.
+var defaultParser, _ = NewParser()
@@ -0,0 +1,229 @@
+package literal
+
+import (
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/asttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParser_Parse(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"42", lit(KindInt, int64(42))},
+		{"-7", lit(KindInt, int64(-7))},
+		{"+7", lit(KindInt, int64(7))},
+		{"123456789012345678901234567890", lit(KindInt, huge)},
+		{"3.5", lit(KindFloat, 3.5)},
+		{".5", lit(KindFloat, 0.5)},
+		{"1e9", lit(KindFloat, 1e9)},
+		{"-2.5E-3", lit(KindFloat, -2.5e-3)},
+		{`"a (b)"`, lit(KindString, "a (b)")},
+		{`'it\'s'`, lit(KindString, "it's")},
+		{"`\\u00e9t\\u00e9`", lit(KindString, "été")},
+		{`"true"`, lit(KindString, "true")},
+		{"true", lit(KindBool, true)},
+		{"false", lit(KindBool, false)},
+		{"null", lit(KindNull, nil)},
+		{"nil", lit(KindNull, nil)},
+		{"5m", lit(KindDuration, 5*time.Minute)},
+		{"-1h30m", lit(KindDuration, -90*time.Minute)},
+		{"1.5s", lit(KindDuration, 1500*time.Millisecond)},
+		{"2024-01-02T15:04:05Z", lit(KindTime, time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC))},
+		{"2024-01-02T15:04:05.5+02:00", lit(KindTime, time.Date(2024, 1, 2, 15, 4, 5, 5e8, time.FixedZone("", 2*60*60)))},
+		{"2024-01-02T15:04:05", lit(KindTime, time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC))},
+		{"2024-01-02", lit(KindTime, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))},
+		{"x", un("x")},
+		{"True", un("True")},
+		{"NaN", un("NaN")},
+		{"inf", un("inf")},
+		{"0x10", un("0x10")},
+		{"1_000", un("1_000")},
+		{"5", lit(KindInt, int64(5))},
+		{"5 m", un("5", "m")},
+		{"2024-13-01", un("2024-13-01")},
+		{"${a}", un("${a}")},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			expected, actual := asttest.StripSpans(tt.output), asttest.StripSpans(ast)
+			if lit, ok := expected.(*Lit); ok && lit.Kind == KindTime {
+				require.IsType(t, &Lit{}, actual)
+				assert.True(t, lit.Value.(time.Time).Equal(actual.(*Lit).Value.(time.Time)))
+				return
+			}
+			assert.EqualValues(t, expected, actual)
+		})
+	}
+}
+
+func TestParser_ParseError(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	_, err = p.ParseStr(`"bad \q escape"`)
+	var syntaxErr *parse.SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, `error parsing: invalid string literal "bad \q escape" at 1:1`, err.Error())
+
+	_, err = p.ParseStr(`"unterminated`)
+	assert.ErrorIs(t, err, parse.ErrParse)
+}
+
+func TestParser_Span(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+	ast, err := p.ParseStr("  'abc'")
+	require.NoError(t, err)
+	assert.Equal(t, 2, parse.SpanOf(ast).Start.Offset)
+	assert.Equal(t, 7, parse.SpanOf(ast).End.Offset)
+}
+
+func TestParser_Concurrent(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"42", lit(KindInt, int64(42))},
+		{"'x'", lit(KindString, "x")},
+		{"5m", lit(KindDuration, 5*time.Minute)},
+		{"a b", un("a", "b")},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tt := tests[(g+i)%len(tests)]
+				ast, err := p.ParseStr(tt.input)
+				if assert.NoError(t, err, tt.input) {
+					assert.EqualValues(t, tt.output, asttest.StripSpans(ast), tt.input)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestParser_Print(t *testing.T) {
+	tests := []struct {
+		input  parse.AST
+		output string
+	}{
+		{lit(KindInt, int64(-42)), "-42"},
+		{lit(KindInt, new(big.Int).Lsh(big.NewInt(1), 70)), "1180591620717411303424"},
+		{lit(KindFloat, 2.0), "2.0"},
+		{lit(KindFloat, 0.25), "0.25"},
+		{lit(KindFloat, 1e21), "1e+21"},
+		{lit(KindString, "it's \"x\"\n"), `"it's \"x\"\n"`},
+		{lit(KindBool, true), "true"},
+		{lit(KindNull, nil), "null"},
+		{lit(KindDuration, 90*time.Minute), "1h30m0s"},
+		{lit(KindTime, time.Date(2024, 1, 2, 15, 4, 5, 5e8, time.UTC)), "2024-01-02T15:04:05.5Z"},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			text, err := parse.Format(tt.input, p)
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, text)
+			assert.Equal(t, tt.output, tt.input.(fmt.Stringer).String())
+
+			ast, err := p.ParseStr(text)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.input, asttest.StripSpans(ast))
+		})
+	}
+
+	for _, input := range []parse.AST{lit(KindFloat, math.NaN()), lit(KindFloat, math.Inf(1)), lit(KindInt, "1"), lit(Kind(0), nil)} {
+		_, err := parse.Format(input, p)
+		assert.Error(t, err)
+	}
+}
+
+func TestWithKinds(t *testing.T) {
+	p, err := NewParser(WithKinds(KindInt, KindString))
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"42", lit(KindInt, int64(42))},
+		{"'x'", lit(KindString, "x")},
+		{"4.5", un("4.5")},
+		{"true", un("true")},
+		{"5m", un("5m")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.output, asttest.StripSpans(ast))
+		})
+	}
+
+	_, err = parse.Format(lit(KindBool, true), p)
+	assert.Error(t, err)
+
+	_, err = NewParser(WithKinds(KindTime + 1))
+	assert.ErrorIs(t, err, parse.ErrConfig)
+	_, err = NewParser(WithQuotes())
+	assert.ErrorIs(t, err, parse.ErrConfig)
+	_, err = NewParser(WithQuotes(), WithKinds(KindInt))
+	assert.NoError(t, err)
+}
+
+func TestWithQuotes(t *testing.T) {
+	p, err := NewParser(WithQuotes('|'))
+	require.NoError(t, err)
+
+	ast, err := p.ParseStr(`|a\|b|`)
+	require.NoError(t, err)
+	assert.EqualValues(t, lit(KindString, "a|b"), asttest.StripSpans(ast))
+
+	text, err := parse.Format(ast, p)
+	require.NoError(t, err)
+	assert.Equal(t, `|a\|b|`, text)
+
+	ast, err = p.ParseStr(`"x"`)
+	require.NoError(t, err)
+	assert.EqualValues(t, parse.Unparsed{Contents: []parse.Token{{Text: `"x"`}}}, asttest.StripSpans(ast))
+}
+
+func TestInterpreter(t *testing.T) {
+	val, err := Interpreter()(lit(KindDuration, time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, val)
+
+	_, err = Interpreter()(un("x"))
+	assert.ErrorIs(t, err, parse.ErrUnknownAST)
+}
+
+func lit(kind Kind, value any) parse.AST {
+	return &Lit{Kind: kind, Value: value}
+}
+
+// un stands for unparsed and returns a parse.Unparsed
+func un(tokens ...string) parse.AST {
+	return parse.Unparsed{Contents: parse.NewTokens(tokens...)}
+}
@@ -0,0 +1,305 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultQuotes lists the runes used to delimit string literals by default.
+var DefaultQuotes = []rune{'"', '\'', '`'}
+
+// Tokenizer splits expressions into tokens. Tokens are separated by whitespace, open and close braces and keywords.
+// Open and close braces must be single runes.
+//
+// Any runes listed in Quotes delimit string literals. Within a string literal, whitespace, braces and keywords are
+// not treated specially, and any rune following a backslash is escaped, so that '\” is a string containing a single
+// quote. A token made up of exactly one string literal has its Quote field set; see Unquote for its value. String
+// literals which are adjacent to other text form part of a larger token, so that ${a["b c"]} is a single token.
+//
+// Atoms are never split, even if they contain a keyword, and form part of the word around them rather than tokens of
+// their own. For instance, when ? is a keyword, the atom ?. keeps ${a?.b} in a single token.
+//
+// If Numbers is set, the sign of the exponent of a decimal number, as in 1.5e-5, forms part of the number's token
+// rather than being split from it as a keyword.
+//
+// If WordBoundaries is set, keywords which begin or end with a letter, digit or underscore are only recognized at
+// identifier boundaries; see KeywordTrie.MatchWord. This prevents identifiers such as ORDER from being split by a
+// keyword such as OR, while still splitting symbolic keywords such as == from the text around them.
+type Tokenizer struct {
+	Open           rune         // Open is the rune which opens a sub-expression.
+	Close          rune         // Close is the rune which closes a sub-expression.
+	Keywords       *KeywordTrie // Keywords contains keywords which always form tokens of their own.
+	Atoms          *KeywordTrie // Atoms contains text which is never split by a keyword; it may be nil.
+	Quotes         []rune       // Quotes lists the runes which delimit string literals.
+	Numbers        bool         // Numbers keeps the signs of exponents, as in 1.5e-5, within numbers.
+	WordBoundaries bool         // WordBoundaries restricts alphanumeric keywords to identifier boundaries.
+}
+
+// Tokenize is a general-purpose expression tokenizer which splits out the keywords held by the provided KeywordTrie.
+// Open and close braces must be single runes and are handled according to the provided runes. Each token returned
+// records its location in the provided string. Quotes are not handled, so that string literals are split like any
+// other text; use a Tokenizer with Quotes set to keep them whole.
+func Tokenize(str string, open, close rune, keywordMatcher *KeywordTrie) []Token {
+	tokenizer := Tokenizer{Open: open, Close: close, Keywords: keywordMatcher}
+	tokens, _ := tokenizer.Tokenize(str) // errors only occur in string literals
+	return tokens
+}
+
+// Tokenize splits the provided string into tokens, each of which records its location in the string. A
+// *SyntaxError is returned if a string literal is not terminated.
+func (t *Tokenizer) Tokenize(str string) ([]Token, error) {
+	return t.tokenize(nil, str, Pos{Line: 1, Col: 1})
+}
+
+// Retokenize splits each of the provided tokens further, for use by parsers which receive tokens produced by another
+// Tokenizer with different keywords. String literals are left as-is. Each token returned records its location in the
+// original source text, if the token it was split from did.
+func (t *Tokenizer) Retokenize(tokens []Token) ([]Token, error) {
+	result := make([]Token, 0, len(tokens))
+	for _, token := range tokens {
+		if token.Quote != 0 {
+			result = append(result, token)
+			continue
+		}
+		n := len(result)
+		var err error
+		if result, err = t.tokenize(result, token.Text, token.Src.Start); err != nil {
+			return nil, err
+		}
+		if token.Src.Start.Line == 0 { // the token has no location, so neither do the tokens split from it
+			for i := n; i < len(result); i++ {
+				result[i].Src = Span{}
+			}
+		}
+	}
+	return result, nil
+}
+
+// tokenize appends the tokens found in the provided string to result. start is the position of the beginning of the
+// string in the source text.
+func (t *Tokenizer) tokenize(result []Token, str string, start Pos) ([]Token, error) {
+	runes, positions := decode(str, start)
+	begin := -1    // begin is the index of the first rune of the current word, or -1 if there is none.
+	literal := -1  // literal is the index of the closing quote if the current word began with a string literal.
+	var quote rune // quote is the rune which began the current word, if it began with a string literal.
+	token := func(from, to int) Token {
+		return Token{Text: string(runes[from:to]), Src: Span{Start: positions[from], End: positions[to]}}
+	}
+	push := func(end int) { // push the current word onto result
+		if begin >= 0 {
+			tok := token(begin, end)
+			if literal == end-1 {
+				tok.Quote = quote
+			}
+			result = append(result, tok)
+			begin, literal = -1, -1
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		if t.isQuote(runes[i]) {
+			end := closingQuote(runes, i)
+			if end < 0 {
+				tokens := append(result, token(i, len(runes)))
+				return nil, NewSyntaxError(tokens, len(tokens)-1, []string{string(runes[i])}, "unterminated string literal")
+			}
+			if begin < 0 {
+				begin, literal, quote = i, end, runes[i]
+			}
+			i = end
+			continue
+		}
+		if runes[i] == t.Open || runes[i] == t.Close {
+			push(i)
+			result = append(result, token(i, i+1))
+			continue
+		}
+		if unicode.IsSpace(runes[i]) {
+			push(i)
+			continue
+		}
+		if t.Atoms != nil {
+			if atom := t.Atoms.Match(runes[i:]); atom != "" {
+				if begin < 0 {
+					begin = i
+				}
+				i += len([]rune(atom)) - 1
+				continue
+			}
+		}
+		if t.Numbers && begin >= 0 && isExponentSign(runes, begin, i) {
+			continue
+		}
+		matched := t.match(runes, i)
+		if len(matched) > 0 {
+			push(i)
+			n := len([]rune(matched))
+			result = append(result, token(i, i+n))
+			i += n - 1
+		} else if begin < 0 {
+			begin = i
+		}
+	}
+	push(len(runes))
+	return result, nil
+}
+
+// isExponentSign reports whether runes[i] is the sign of the exponent of a decimal number, which begins at
+// runes[begin] and is followed by at least one digit, as in 1.5e-5.
+func isExponentSign(runes []rune, begin, i int) bool {
+	if (runes[i] != '-' && runes[i] != '+') || i+1 >= len(runes) || !isDigit(runes[i+1]) {
+		return false
+	}
+	if i-begin < 2 || (runes[i-1] != 'e' && runes[i-1] != 'E') {
+		return false
+	}
+	digits, dot := false, false
+	for _, r := range runes[begin : i-1] {
+		switch {
+		case isDigit(r):
+			digits = true
+		case r == '.' && !dot:
+			dot = true
+		default:
+			return false
+		}
+	}
+	return digits
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// match returns the keyword found at runes[i], if any.
+func (t *Tokenizer) match(runes []rune, i int) string {
+	if !t.WordBoundaries {
+		return t.Keywords.Match(runes[i:])
+	}
+	var prev rune
+	if i > 0 {
+		prev = runes[i-1]
+	}
+	return t.Keywords.MatchWord(prev, runes[i:])
+}
+
+func (t *Tokenizer) isQuote(r rune) bool {
+	for _, q := range t.Quotes {
+		if r == q {
+			return true
+		}
+	}
+	return false
+}
+
+// closingQuote returns the index of the rune closing the string literal which begins at runes[start], or -1 if the
+// literal is not terminated.
+func closingQuote(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case runes[start]:
+			return i
+		}
+	}
+	return -1
+}
+
+// Unquote returns the value of the provided string literal, which must begin and end with the same quote. The
+// following escape sequences are recognized: \\, \', \", \`, \n, \r, \t, \uXXXX, and a backslash followed by the
+// quote delimiting the literal. An error matching ErrParse is returned if the literal is malformed.
+func Unquote(literal string) (string, error) {
+	runes := []rune(literal)
+	if len(runes) < 2 || runes[0] != runes[len(runes)-1] {
+		return "", fmt.Errorf("%w: malformed string literal %s", ErrParse, literal)
+	}
+	var sb strings.Builder
+	for i := 1; i < len(runes)-1; i++ {
+		if runes[i] == runes[0] {
+			return "", fmt.Errorf("%w: unescaped quote in string literal %s", ErrParse, literal)
+		}
+		if runes[i] != '\\' {
+			sb.WriteRune(runes[i])
+			continue
+		}
+		i++
+		if i == len(runes)-1 {
+			return "", fmt.Errorf("%w: unterminated escape sequence in string literal %s", ErrParse, literal)
+		}
+		switch runes[i] {
+		case '\\', '\'', '"', '`', runes[0]:
+			sb.WriteRune(runes[i])
+		case 'n':
+			sb.WriteRune('\n')
+		case 'r':
+			sb.WriteRune('\r')
+		case 't':
+			sb.WriteRune('\t')
+		case 'u':
+			if i+4 >= len(runes)-1 {
+				return "", fmt.Errorf("%w: malformed escape sequence in string literal %s", ErrParse, literal)
+			}
+			code, err := strconv.ParseUint(string(runes[i+1:i+5]), 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("%w: malformed escape sequence in string literal %s", ErrParse, literal)
+			}
+			sb.WriteRune(rune(code))
+			i += 4
+		default:
+			return "", fmt.Errorf("%w: unknown escape sequence '\\%c' in string literal %s", ErrParse, runes[i], literal)
+		}
+	}
+	return sb.String(), nil
+}
+
+// Quote returns a string literal delimited by the provided quote whose value is the provided string, such that
+// Unquote(Quote(str, quote)) == str. Backslashes, the quote itself and control characters are escaped.
+func Quote(str string, quote rune) string {
+	var sb strings.Builder
+	sb.WriteRune(quote)
+	for _, r := range str {
+		switch {
+		case r == '\\' || r == quote:
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&sb, `\u%04x`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteRune(quote)
+	return sb.String()
+}
+
+// decode returns the runes of the provided string, along with the position of each rune, given the position of the
+// start of the string. The returned list of positions has one more entry than the list of runes, holding the position
+// of the end of the string.
+func decode(str string, start Pos) ([]rune, []Pos) {
+	runes := make([]rune, 0, len(str))
+	positions := make([]Pos, 0, len(str)+1)
+	pos := start
+	for offset, r := range str {
+		pos.Offset = start.Offset + offset
+		runes = append(runes, r)
+		positions = append(positions, pos)
+		pos.Rune++
+		if r == '\n' {
+			pos.Line++
+			pos.Col = 1
+		} else {
+			pos.Col++
+		}
+	}
+	pos.Offset = start.Offset + len(str)
+	return runes, append(positions, pos)
+}
@@ -0,0 +1,213 @@
+package parse
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	trie := &KeywordTrie{}
+	trie.Add("AND")
+	trie.Add("≠")
+
+	tokens := Tokenize("(été ≠ 3)\n  AND x", '(', ')', trie)
+
+	assert.Equal(t, []Token{
+		{Text: "(", Src: Span{Start: Pos{Offset: 0, Rune: 0, Line: 1, Col: 1}, End: Pos{Offset: 1, Rune: 1, Line: 1, Col: 2}}},
+		{Text: "été", Src: Span{Start: Pos{Offset: 1, Rune: 1, Line: 1, Col: 2}, End: Pos{Offset: 6, Rune: 4, Line: 1, Col: 5}}},
+		{Text: "≠", Src: Span{Start: Pos{Offset: 7, Rune: 5, Line: 1, Col: 6}, End: Pos{Offset: 10, Rune: 6, Line: 1, Col: 7}}},
+		{Text: "3", Src: Span{Start: Pos{Offset: 11, Rune: 7, Line: 1, Col: 8}, End: Pos{Offset: 12, Rune: 8, Line: 1, Col: 9}}},
+		{Text: ")", Src: Span{Start: Pos{Offset: 12, Rune: 8, Line: 1, Col: 9}, End: Pos{Offset: 13, Rune: 9, Line: 1, Col: 10}}},
+		{Text: "AND", Src: Span{Start: Pos{Offset: 16, Rune: 12, Line: 2, Col: 3}, End: Pos{Offset: 19, Rune: 15, Line: 2, Col: 6}}},
+		{Text: "x", Src: Span{Start: Pos{Offset: 20, Rune: 16, Line: 2, Col: 7}, End: Pos{Offset: 21, Rune: 17, Line: 2, Col: 8}}},
+	}, tokens)
+}
+
+func TestTokenizer_Tokenize(t *testing.T) {
+	trie := &KeywordTrie{}
+	trie.Add("==")
+	trie.Add("AND")
+	tokenizer := Tokenizer{Open: '(', Close: ')', Keywords: trie, Quotes: DefaultQuotes}
+
+	tests := []struct {
+		input  string
+		output []Token
+	}{
+		{
+			`x=="a AND (b)"`,
+			[]Token{{Text: "x"}, {Text: "=="}, {Text: `"a AND (b)"`, Quote: '"'}},
+		},
+		{
+			`'it\'s' AND ` + "`x`",
+			[]Token{{Text: `'it\'s'`, Quote: '\''}, {Text: "AND"}, {Text: "`x`", Quote: '`'}},
+		},
+		{
+			`${a["b c"]} == 'x'y`,
+			[]Token{{Text: `${a["b c"]}`}, {Text: "=="}, {Text: `'x'y`}},
+		},
+		{
+			`"a""b"`,
+			[]Token{{Text: `"a""b"`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens, err := tokenizer.Tokenize(tt.input)
+			require.NoError(t, err)
+			for i := range tokens {
+				tokens[i].Src = Span{}
+			}
+			assert.Equal(t, tt.output, tokens)
+		})
+	}
+
+	_, err := tokenizer.Tokenize(`x == "abc\"`)
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	assert.Equal(t, 2, syntaxErr.Index)
+	assert.Equal(t, []string{`"`}, syntaxErr.Expected)
+	assert.Equal(t, 5, syntaxErr.Src.Start.Offset)
+}
+
+func TestTokenizer_Atoms(t *testing.T) {
+	keywords, atoms := &KeywordTrie{}, &KeywordTrie{}
+	keywords.Add("?")
+	keywords.Add(":")
+	atoms.Add("?.")
+	atoms.Add("??")
+	tokenizer := Tokenizer{Open: '(', Close: ')', Keywords: keywords, Atoms: atoms}
+
+	tests := []struct {
+		input  string
+		output []string
+	}{
+		{"a?b:c", []string{"a", "?", "b", ":", "c"}},
+		{"${a?.b}?x:y", []string{"${a?.b}", "?", "x", ":", "y"}},
+		{"a ?? b ? c : d", []string{"a", "??", "b", "?", "c", ":", "d"}},
+		{"a??b???c", []string{"a??b??", "?", "c"}},
+		{"?.a", []string{"?.a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens, err := tokenizer.Tokenize(tt.input)
+			require.NoError(t, err)
+			var texts []string
+			for _, token := range tokens {
+				texts = append(texts, token.Text)
+			}
+			assert.Equal(t, tt.output, texts)
+		})
+	}
+}
+
+func TestTokenizer_Numbers(t *testing.T) {
+	keywords := &KeywordTrie{}
+	keywords.Add("-")
+	keywords.Add("+")
+	keywords.Add("*")
+	tokenizer := Tokenizer{Open: '(', Close: ')', Keywords: keywords, Numbers: true}
+
+	tests := []struct {
+		input  string
+		output []string
+	}{
+		{"1.5e-5*2", []string{"1.5e-5", "*", "2"}},
+		{"x-1E+10-.5e-3", []string{"x", "-", "1E+10", "-", ".5e-3"}},
+		{"1e-x", []string{"1e", "-", "x"}},
+		{"size-1", []string{"size", "-", "1"}},
+		{"x1e-5", []string{"x1e", "-", "5"}},
+		{"1.2.3e-4", []string{"1.2.3e", "-", "4"}},
+		{"e-5", []string{"e", "-", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			tokens, err := tokenizer.Tokenize(tt.input)
+			require.NoError(t, err)
+			var texts []string
+			for _, token := range tokens {
+				texts = append(texts, token.Text)
+			}
+			assert.Equal(t, tt.output, texts)
+		})
+	}
+}
+
+func TestTokenizer_Retokenize(t *testing.T) {
+	first := Tokenizer{Open: '(', Close: ')', Keywords: &KeywordTrie{}, Quotes: DefaultQuotes}
+	tokens, err := first.Tokenize("x+1 AND\n  '2*3' y*2")
+	require.NoError(t, err)
+
+	trie := &KeywordTrie{}
+	trie.Add("+")
+	trie.Add("*")
+	second := Tokenizer{Open: '(', Close: ')', Keywords: trie, Quotes: DefaultQuotes}
+	tokens, err = second.Retokenize(tokens)
+	require.NoError(t, err)
+
+	var texts []string
+	for _, token := range tokens {
+		texts = append(texts, token.Text)
+	}
+	assert.Equal(t, []string{"x", "+", "1", "AND", "'2*3'", "y", "*", "2"}, texts)
+	assert.Equal(t, Span{Start: Pos{Offset: 1, Rune: 1, Line: 1, Col: 2}, End: Pos{Offset: 2, Rune: 2, Line: 1, Col: 3}}, tokens[1].Src)
+	assert.Equal(t, Span{Start: Pos{Offset: 17, Rune: 17, Line: 2, Col: 10}, End: Pos{Offset: 18, Rune: 18, Line: 2, Col: 11}}, tokens[6].Src)
+	assert.Equal(t, rune('\''), tokens[4].Quote)
+
+	tokens, err = second.Retokenize(NewTokens("a*b"))
+	require.NoError(t, err)
+	assert.Equal(t, NewTokens("a", "*", "b"), tokens)
+}
+
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{`""`, ""},
+		{`"abc"`, "abc"},
+		{`'it\'s'`, "it's"},
+		{"`a\\`b`", "a`b"},
+		{`"tab\tnewline\nslash\\"`, "tab\tnewline\nslash\\"},
+		{`"été"`, "été"},
+		{`'"'`, `"`},
+		{`|a\|b|`, "a|b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			str, err := Unquote(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.output, str)
+		})
+	}
+
+	for _, input := range []string{`"`, `"abc'`, `"a"b"`, `"\q"`, `"\u12"`, `"\"`, `abc`} {
+		t.Run(input, func(t *testing.T) {
+			_, err := Unquote(input)
+			assert.ErrorIs(t, err, ErrParse)
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input  string
+		quote  rune
+		output string
+	}{
+		{"abc", '"', `"abc"`},
+		{`it's "x"`, '\'', `'it\'s "x"'`},
+		{"tab\tnewline\nslash\\", '`', "`tab\\tnewline\\nslash\\\\`"},
+		{"bell\a été", '"', `"bell\u0007 été"`},
+		{"a|b", '|', `|a\|b|`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			literal := Quote(tt.input, tt.quote)
+			assert.Equal(t, tt.output, literal)
+			str, err := Unquote(literal)
+			require.NoError(t, err)
+			assert.Equal(t, tt.input, str)
+		})
+	}
+}
@@ -0,0 +1,499 @@
+// Package typecheck implements a static type checker for expressions built using the parsers in this module. Given a
+// Schema describing the type of each variable, Check finds the type of every node of an expression, and reports each
+// operation whose operands can never have types it accepts, such as comparing a string with a number, or using a
+// number as an operand of AND:
+//
+//	schema := typecheck.Schema{"priority": typecheck.TypeInt, "name": typecheck.TypeString}
+//	t, err := typecheck.Check(ast, schema)
+//
+// Types are checked according to the rules followed by eval.Interpreter, so that an expression which passes the checks
+// only fails to evaluate if variables have values of a type other than those described by the Schema, or if an
+// operation fails for other reasons, such as division by zero. Since a Type is a set of kinds, operands are accepted
+// as long as one of their possible kinds is accepted.
+package typecheck
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/arith"
+	"github.com/orkes-io/go-parse/bools"
+	"github.com/orkes-io/go-parse/coalesce"
+	"github.com/orkes-io/go-parse/comp"
+	"github.com/orkes-io/go-parse/cond"
+	"github.com/orkes-io/go-parse/funcs"
+	"github.com/orkes-io/go-parse/literal"
+	"github.com/orkes-io/go-parse/vars"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// ErrType is matched by every error reported by Check, when checked using errors.Is.
+var ErrType = errors.New("type error")
+
+// Type is a set of the kinds of value which an expression may produce.
+type Type uint16
+
+const (
+	TypeNull     Type = 1 << parse.KindNull     // TypeNull is the type of null.
+	TypeBool     Type = 1 << parse.KindBool     // TypeBool is the type of true and false.
+	TypeInt      Type = 1 << parse.KindInt      // TypeInt is the type of integers.
+	TypeFloat    Type = 1 << parse.KindFloat    // TypeFloat is the type of floating point numbers.
+	TypeString   Type = 1 << parse.KindString   // TypeString is the type of strings.
+	TypeList     Type = 1 << parse.KindList     // TypeList is the type of lists.
+	TypeMap      Type = 1 << parse.KindMap      // TypeMap is the type of maps.
+	TypeTime     Type = 1 << parse.KindTime     // TypeTime is the type of timestamps.
+	TypeDuration Type = 1 << parse.KindDuration // TypeDuration is the type of durations.
+
+	TypeNumber = TypeInt | TypeFloat
+	TypeAny    = TypeNull | TypeBool | TypeNumber | TypeString | TypeList | TypeMap | TypeTime | TypeDuration
+)
+
+// TypeOf returns the Type holding only the provided kind.
+func TypeOf(kind parse.Kind) Type {
+	return 1 << kind
+}
+
+func (t Type) String() string {
+	switch {
+	case t == TypeAny:
+		return "any"
+	case t == 0 || t&^TypeAny != 0:
+		return "unknown type"
+	}
+	var names []string
+	for kind := parse.KindNull; kind <= parse.KindDuration; kind++ {
+		if t&TypeOf(kind) != 0 {
+			names = append(names, kind.String())
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Schema maps the path of each variable to its Type. Paths are written as the name of each field, separated by dots,
+// with each index or wildcard written as [*]. For instance, ${workflow.input.items[0].name} is described by the path
+// workflow.input.items[*].name, and ${tasks["task-ref"].output} by tasks.task-ref.output.
+//
+// The Type of a reference not found in the Schema is the Type of its longest prefix which is found, if that is a list
+// or map, which is assumed to hold values of any Type. References to variables whose path has no such prefix are
+// reported by Check. A reference which may not exist should include TypeNull in its Type.
+type Schema map[string]Type
+
+// Error describes an operation whose operands have types it does not accept. Every Error matches ErrType when checked
+// using errors.Is.
+type Error struct {
+	Msg string     // Msg describes the error.
+	Src parse.Span // Src is the location of the offending expression in the source text.
+}
+
+func (e *Error) Error() string {
+	if e.Src.Start.Line == 0 {
+		return fmt.Sprintf("%v: %s", ErrType, e.Msg)
+	}
+	return fmt.Sprintf("%v: %s at %v", ErrType, e.Msg, e.Src.Start)
+}
+
+// Is returns true iff target is ErrType.
+func (e *Error) Is(target error) bool {
+	return target == ErrType
+}
+
+// Span returns the location of the offending expression in the source text.
+func (e *Error) Span() parse.Span {
+	return e.Src
+}
+
+// Errors lists every Error found in an expression, in order of their location in the source text.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is returns true iff target is ErrType.
+func (e Errors) Is(target error) bool {
+	return target == ErrType
+}
+
+// CheckOpt configures the behavior of Check.
+type CheckOpt func(*checker)
+
+// WithFuncs sets the functions which may be called by the checked expression. The arguments of each funcs.CallExpr are
+// checked against the parameters of the function called, and its Type is the Result of the function. By default,
+// calls are assumed to accept any arguments, and to produce a value of any Type.
+func WithFuncs(registry *funcs.Registry) CheckOpt {
+	return func(c *checker) {
+		c.funcs = registry
+	}
+}
+
+type checker struct {
+	schema Schema
+	funcs  *funcs.Registry
+	errs   Errors
+}
+
+// Check finds the Type of the provided expression, given the types of the variables it refers to. Every node of the
+// expression is checked, so that all the errors found are reported at once, as an Errors value. The nodes of the
+// bools, comp, arith, cond, coalesce, funcs, literal and vars packages are recognized; any other node, such as
+// parse.Unparsed, is reported as an error.
+//
+// The returned Type is the Type of the expression, even if errors were found. Operations whose operands are not
+// accepted are assumed to produce any value of the Type they usually produce, so that each mistake is only reported
+// once.
+func Check(ast parse.AST, schema Schema, opts ...CheckOpt) (Type, error) {
+	c := &checker{schema: schema}
+	for _, opt := range opts {
+		opt(c)
+	}
+	t := c.check(ast)
+	if len(c.errs) == 0 {
+		return t, nil
+	}
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		return c.errs[i].Src.Start.Offset < c.errs[j].Src.Start.Offset
+	})
+	return t, c.errs
+}
+
+// errorf records an Error located at the provided node.
+func (c *checker) errorf(ast parse.AST, format string, args ...any) {
+	c.errs = append(c.errs, &Error{Msg: fmt.Sprintf(format, args...), Src: parse.SpanOf(ast)})
+}
+
+func (c *checker) check(ast parse.AST) Type {
+	switch ast := ast.(type) {
+	case *bools.BinExpr:
+		_ = bools.WalkChain(ast, func(b *bools.BinExpr, deepest bool) error {
+			if deepest {
+				c.expect(b.LHS, TypeBool, "%v requires bool operands", b.Op)
+			}
+			c.expect(b.RHS, TypeBool, "%v requires bool operands", b.Op)
+			return nil
+		})
+		return TypeBool
+	case *bools.ListExpr:
+		for _, term := range ast.Terms {
+			c.expect(term, TypeBool, "%v requires bool operands", ast.Op)
+		}
+		return TypeBool
+	case *bools.UnaryExpr:
+		c.expect(ast.Expr, TypeBool, "%v requires a bool operand", ast.Op)
+		return TypeBool
+	case *comp.EqualExpr:
+		c.checkEqual(ast, ast.Op, c.check(ast.LHS), c.check(ast.RHS))
+		return TypeBool
+	case *comp.OrdinalExpr:
+		c.checkOrder(ast, ast.Op, c.check(ast.LHS), c.check(ast.RHS))
+		return TypeBool
+	case *comp.ChainExpr:
+		var lhs Type
+		for i, operand := range ast.Operands {
+			rhs := c.check(operand)
+			if i > 0 && i <= len(ast.Ops) {
+				c.checkOrder(ast, ast.Ops[i-1], lhs, rhs)
+			}
+			lhs = rhs
+		}
+		return TypeBool
+	case *comp.BetweenExpr:
+		val, lower, upper := c.check(ast.Expr), c.check(ast.Lower), c.check(ast.Upper)
+		c.checkOrder(ast, ast.Op, val, lower)
+		c.checkOrder(ast, ast.Op, val, upper)
+		return TypeBool
+	case *comp.InExpr:
+		val := c.check(ast.LHS)
+		if list, ok := ast.RHS.(*comp.List); ok {
+			for _, elem := range list.Elems {
+				c.checkEqual(elem, ast.Op, val, c.check(elem))
+			}
+		} else {
+			c.expect(ast.RHS, TypeList, "%v requires a list", ast.Op)
+		}
+		return TypeBool
+	case *comp.MatchExpr:
+		c.expect(ast.LHS, TypeString, "%v requires string operands", ast.Op)
+		c.expect(ast.RHS, TypeString, "%v requires string operands", ast.Op)
+		return TypeBool
+	case *comp.NullExpr:
+		c.check(ast.Expr)
+		return TypeBool
+	case *arith.BinExpr:
+		var t Type
+		_ = arith.WalkChain(ast, func(b *arith.BinExpr, deepest bool) error {
+			if deepest {
+				t = c.check(b.LHS)
+			}
+			t = c.checkArith(b, b.Op, t, c.check(b.RHS))
+			return nil
+		})
+		return t
+	case *arith.UnaryExpr:
+		t := c.check(ast.Expr)
+		if t&(TypeNumber|TypeDuration) == 0 {
+			c.errorf(ast, "cannot negate %v", t)
+			return TypeNumber | TypeDuration
+		}
+		return t & (TypeNumber | TypeDuration)
+	case *cond.CondExpr:
+		// check chains of conditionals in the else branch iteratively, since they may be very long
+		var t Type
+		for {
+			c.expect(ast.Cond, TypeBool, "condition must be a bool")
+			t |= c.check(ast.Then)
+			next, ok := ast.Else.(*cond.CondExpr)
+			if !ok {
+				return t | c.check(ast.Else)
+			}
+			ast = next
+		}
+	case *coalesce.CoalesceExpr:
+		var t Type
+		for i, operand := range ast.Operands {
+			operandType := c.check(operand)
+			if i < len(ast.Operands)-1 {
+				operandType &^= TypeNull
+			}
+			t |= operandType
+		}
+		if t == 0 {
+			return TypeNull
+		}
+		return t
+	case *funcs.CallExpr:
+		return c.checkCall(ast)
+	case *literal.Lit:
+		if i, ok := ast.Value.(*big.Int); ok && !i.IsInt64() {
+			// such literals cannot be evaluated, as every Int is an int64
+			c.errorf(ast, "integer out of range: %v", i)
+		}
+		return literalType(ast.Kind)
+	case *vars.Ref:
+		return c.checkRef(ast)
+	case nil:
+		c.errorf(ast, "missing expression")
+		return TypeAny
+	default:
+		c.errorf(ast, "cannot determine the type of '%v'", ast)
+		return TypeAny
+	}
+}
+
+// expect checks the provided operand, recording an error if it cannot have the expected Type.
+func (c *checker) expect(ast parse.AST, expected Type, format string, args ...any) {
+	if t := c.check(ast); t&expected == 0 {
+		c.errorf(ast, format+"; found %v", append(args, t)...)
+	}
+}
+
+// comparable returns the kinds of lhs which may be equal to some value of rhs. Numbers of either kind may be equal to
+// each other.
+func comparable(lhs, rhs Type) Type {
+	if rhs&TypeNumber != 0 {
+		rhs |= TypeNumber
+	}
+	return lhs & rhs
+}
+
+// checkEqual records an error if values of the provided types can never be equal, unless either is null.
+func (c *checker) checkEqual(ast parse.AST, op comp.Op, lhs, rhs Type) {
+	if comparable(lhs, rhs) == 0 && lhs != TypeNull && rhs != TypeNull {
+		c.errorf(ast, "cannot compare %v with %v using %v", lhs, rhs, op)
+	}
+}
+
+// checkOrder records an error if values of the provided types can never be ordered.
+func (c *checker) checkOrder(ast parse.AST, op comp.Op, lhs, rhs Type) {
+	const ordered = TypeBool | TypeNumber | TypeString | TypeTime | TypeDuration
+	if comparable(lhs, rhs)&ordered == 0 {
+		c.errorf(ast, "cannot order %v and %v using %v", lhs, rhs, op)
+	}
+}
+
+// checkArith returns the Type of lhs op rhs, recording an error if no values of the provided types can be combined.
+func (c *checker) checkArith(ast parse.AST, op arith.Op, lhs, rhs Type) Type {
+	var result Type
+	for l := parse.KindNull; l <= parse.KindDuration; l++ {
+		if lhs&TypeOf(l) == 0 {
+			continue
+		}
+		for r := parse.KindNull; r <= parse.KindDuration; r++ {
+			if rhs&TypeOf(r) != 0 {
+				result |= arithType(op, l, r)
+			}
+		}
+	}
+	if result == 0 {
+		c.errorf(ast, "cannot apply %v to %v and %v", op, lhs, rhs)
+		return TypeNumber
+	}
+	return result
+}
+
+// arithType returns the Type of lhs op rhs, where lhs and rhs have the provided kinds, or 0 if the operation is not
+// allowed. See eval.Arith.
+func arithType(op arith.Op, lhs, rhs parse.Kind) Type {
+	l, r := TypeOf(lhs), TypeOf(rhs)
+	switch {
+	case l == TypeInt && r == TypeInt:
+		if op == arith.OpPower {
+			return TypeNumber
+		}
+		return TypeInt
+	case l&TypeNumber != 0 && r&TypeNumber != 0:
+		return TypeFloat
+	}
+	switch op {
+	case arith.OpAdd:
+		switch {
+		case l == TypeTime && r == TypeDuration, l == TypeDuration && r == TypeTime:
+			return TypeTime
+		case l == TypeDuration && r == TypeDuration:
+			return TypeDuration
+		}
+	case arith.OpSubtract:
+		switch {
+		case l == TypeTime && r == TypeDuration:
+			return TypeTime
+		case l == TypeTime && r == TypeTime, l == TypeDuration && r == TypeDuration:
+			return TypeDuration
+		}
+	case arith.OpMultiply:
+		if l == TypeDuration && r == TypeInt || l == TypeInt && r == TypeDuration {
+			return TypeDuration
+		}
+	case arith.OpDivide:
+		if l == TypeDuration && r == TypeInt {
+			return TypeDuration
+		}
+	}
+	return 0
+}
+
+// checkCall checks the arguments of the provided call, returning the Type of its result.
+func (c *checker) checkCall(call *funcs.CallExpr) Type {
+	if c.funcs == nil {
+		for _, arg := range call.Args {
+			c.check(arg)
+		}
+		return TypeAny
+	}
+	fn, ok := c.funcs.Lookup(call.Name)
+	if !ok {
+		c.errorf(call, "unknown function '%s'", call.Name)
+		for _, arg := range call.Args {
+			c.check(arg)
+		}
+		return TypeAny
+	}
+	n := len(fn.Params)
+	if fn.Variadic && len(call.Args) < n-1 {
+		c.errorf(call, "%s called with %d arguments; expected at least %d", call.Name, len(call.Args), n-1)
+	} else if !fn.Variadic && len(call.Args) != n {
+		c.errorf(call, "%s called with %d arguments; expected %d", call.Name, len(call.Args), n)
+	}
+	for i, arg := range call.Args {
+		if i >= n && !fn.Variadic {
+			c.check(arg)
+			continue
+		}
+		param := fn.Params[n-1]
+		if i < n {
+			param = fn.Params[i]
+		}
+		c.expect(arg, fromFuncType(param), "argument %d of %s must be %v", i+1, call.Name, fromFuncType(param))
+	}
+	return fromFuncType(fn.Result)
+}
+
+// checkRef returns the Type of the provided reference according to the Schema.
+func (c *checker) checkRef(ref *vars.Ref) Type {
+	var sb strings.Builder
+	// t is the Type of the longest prefix of the path found in the Schema, and exact is set if that is the whole path
+	t, found, exact := Type(0), "", false
+	nullSafe, wildcard := false, false
+	for i, seg := range ref.Path {
+		switch seg.Kind {
+		case vars.SegmentField:
+			if i > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(seg.Key)
+		default:
+			sb.WriteString("[*]")
+		}
+		nullSafe = nullSafe || seg.NullSafe
+		wildcard = wildcard || seg.Kind == vars.SegmentWildcard
+		if segType, ok := c.schema[sb.String()]; ok {
+			t, found, exact = segType, sb.String(), true
+			continue
+		}
+		exact = false
+		// the contents of lists and maps not described by the Schema may have any type
+		if found != "" && t&(TypeList|TypeMap) == 0 {
+			c.errorf(ref, "cannot access %s; %s is %v", sb.String(), found, t)
+			return TypeAny
+		}
+	}
+	switch {
+	case found == "":
+		c.errorf(ref, "unknown variable %v", ref)
+		return TypeAny
+	case wildcard:
+		return TypeList
+	case !exact:
+		return TypeAny
+	case nullSafe:
+		return t | TypeNull
+	}
+	return t
+}
+
+// literalType returns the Type of literals of the provided kind.
+func literalType(kind literal.Kind) Type {
+	switch kind {
+	case literal.KindInt:
+		return TypeInt
+	case literal.KindFloat:
+		return TypeFloat
+	case literal.KindString:
+		return TypeString
+	case literal.KindBool:
+		return TypeBool
+	case literal.KindNull:
+		return TypeNull
+	case literal.KindDuration:
+		return TypeDuration
+	case literal.KindTime:
+		return TypeTime
+	}
+	return TypeAny
+}
+
+// fromFuncType converts a funcs.Type to the equivalent Type.
+func fromFuncType(t funcs.Type) Type {
+	var result Type
+	for _, pair := range []struct {
+		from funcs.Type
+		to   Type
+	}{
+		{funcs.TypeNull, TypeNull},
+		{funcs.TypeBool, TypeBool},
+		{funcs.TypeNumber, TypeNumber},
+		{funcs.TypeString, TypeString},
+		{funcs.TypeList, TypeList},
+		{funcs.TypeMap, TypeMap},
+		{funcs.TypeTime, TypeTime},
+		{funcs.TypeDuration, TypeDuration},
+	} {
+		if t&pair.from != 0 {
+			result |= pair.to
+		}
+	}
+	return result
+}
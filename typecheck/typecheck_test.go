@@ -0,0 +1,241 @@
+package typecheck
+
+import (
+	"errors"
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/eval"
+	"github.com/orkes-io/go-parse/funcs"
+	"github.com/orkes-io/go-parse/internal/exprtest"
+	"github.com/orkes-io/go-parse/vars"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+var schema = Schema{
+	"priority":              TypeInt,
+	"ratio":                 TypeFloat,
+	"name":                  TypeString,
+	"retry":                 TypeBool,
+	"tags":                  TypeList,
+	"tags[*]":               TypeString,
+	"started":               TypeTime,
+	"timeout":               TypeDuration,
+	"task":                  TypeMap | TypeNull,
+	"task.output.count":     TypeInt,
+	"workflow.input":        TypeMap,
+	"workflow.input.limit":  TypeInt | TypeNull,
+	"tasks.task-ref.status": TypeString,
+}
+
+// doc holds values matching schema.
+var doc = map[string]any{
+	"priority": 7,
+	"ratio":    0.5,
+	"name":     "task_12",
+	"retry":    false,
+	"tags":     []any{"a", "b"},
+	"started":  time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC),
+	"timeout":  5 * time.Minute,
+	"task":     map[string]any{"output": map[string]any{"count": 3, "extra": "x"}},
+	"workflow": map[string]any{"input": map[string]any{"limit": nil, "other": 1.5}},
+	"tasks":    map[string]any{"task-ref": map[string]any{"status": "COMPLETED"}},
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input  string
+		output Type
+	}{
+		{"${priority} > 5 AND NOT ${retry}", TypeBool},
+		{"${priority} * 2 + 1", TypeInt},
+		{"${priority} / 2 + ${ratio}", TypeFloat},
+		{"2 ** ${priority}", TypeNumber},
+		{"-${timeout}", TypeDuration},
+		{"${started} + ${timeout} * 2", TypeTime},
+		{"${started} - ${started}", TypeDuration},
+		{"0 < ${ratio} <= ${priority} AND ${priority} BETWEEN 1 AND 10", TypeBool},
+		{"${priority} == 7.0 AND ${name} != null AND ${retry} == true", TypeBool},
+		{"${name} IN ('a', 'b') AND 'a' IN ${tags} AND ${tags[0]} NOT IN ${tags}", TypeBool},
+		{"${name} LIKE 'task\\\\_%' OR ${tags[1]} =~ ${name}", TypeBool},
+		{"${task.output.count} IS NOT NULL", TypeBool},
+		{"${task?.output?.count}", TypeInt | TypeNull},
+		{"${task.output.extra}", TypeAny},
+		{"${workflow.input.other}", TypeAny},
+		{"${workflow.input.limit} ?? 10", TypeInt},
+		{"${workflow.input.limit} ?? ${ratio}", TypeNumber},
+		{"${tasks[\"task-ref\"].status} == 'COMPLETED'", TypeBool},
+		{"${retry} ? 'a' : ${priority} > 1 ? 1 : 2.5", TypeString | TypeNumber},
+		{"${tags[*]}", TypeList},
+		{"len(${tags}) > 1 AND lower(${name}) == 'x'", TypeBool},
+		{"null", TypeNull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast := exprtest.Parse(t, tt.input)
+			typ, err := Check(ast, schema)
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, typ)
+
+			// expressions which pass the checks evaluate to a value of the Type found
+			if strings.Contains(tt.input, "(") {
+				return // calls are not evaluated by eval.Interpreter
+			}
+			val, err := eval.Eval(ast, vars.Interpreter(doc))
+			require.NoError(t, err)
+			assert.NotZero(t, typ&TypeOf(val.Kind()), "%v is not %v", val, typ)
+		})
+	}
+}
+
+func TestCheckError(t *testing.T) {
+	type typeErr struct {
+		msg string
+		col int
+	}
+	tests := []struct {
+		input  string
+		output Type
+		errs   []typeErr
+	}{
+		{
+			"${priority} AND ${name} == 1",
+			TypeBool,
+			[]typeErr{
+				{"AND requires bool operands; found int", 1},
+				{"cannot compare string with int using ==", 17},
+			},
+		},
+		{
+			"NOT (${name} > 1 OR ${started} < ${timeout})",
+			TypeBool,
+			[]typeErr{
+				{"cannot order string and int using >", 6},
+				{"cannot order time and duration using <", 21},
+			},
+		},
+		{
+			"${name} + 1 > 2 ? -${retry} : ${tags} * 2",
+			TypeNumber | TypeDuration,
+			[]typeErr{
+				{"cannot apply + to string and int", 1},
+				{"cannot negate bool", 19},
+				{"cannot apply * to list and int", 31},
+			},
+		},
+		{
+			"${priority} ? 1 : 2",
+			TypeInt,
+			[]typeErr{{"condition must be a bool; found int", 1}},
+		},
+		{
+			"${priority} IN ('a', 2, ${name}) OR ${name} IN ${name}",
+			TypeBool,
+			[]typeErr{
+				{"cannot compare int with string using IN", 17},
+				{"cannot compare int with string using IN", 25},
+				{"IN requires a list; found string", 48},
+			},
+		},
+		{
+			"${priority} LIKE ${tags} AND ${priority} BETWEEN 'a' AND 'z'",
+			TypeBool,
+			[]typeErr{
+				{"LIKE requires string operands; found int", 1},
+				{"LIKE requires string operands; found list", 18},
+				{"cannot order int and string using BETWEEN", 30},
+				{"cannot order int and string using BETWEEN", 30},
+			},
+		},
+		{
+			"${missing} > 1 OR ${priority.value} OR ${task.status} == ${workflow.input.limit.x}",
+			TypeBool,
+			[]typeErr{
+				{"unknown variable ${missing}", 1},
+				{"cannot access priority.value; priority is int", 19},
+				{"cannot access workflow.input.limit.x; workflow.input.limit is null|int", 58},
+			},
+		},
+		{
+			"0 < ${name} <= 10",
+			TypeBool,
+			[]typeErr{
+				{"cannot order int and string using <", 1},
+				{"cannot order string and int using <=", 1},
+			},
+		},
+		{
+			"${priority} + 99999999999999999999 > 1",
+			TypeBool,
+			[]typeErr{{"integer out of range: 99999999999999999999", 15}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			typ, err := Check(exprtest.Parse(t, tt.input), schema)
+			assert.Equal(t, tt.output, typ)
+			require.ErrorIs(t, err, ErrType)
+			var errs Errors
+			require.True(t, errors.As(err, &errs))
+			var actual []typeErr
+			for _, e := range errs {
+				actual = append(actual, typeErr{e.Msg, e.Span().Start.Col})
+				assert.ErrorIs(t, e, ErrType)
+			}
+			assert.Equal(t, tt.errs, actual)
+		})
+	}
+
+	_, err := Check(parse.Unparsed{Contents: parse.NewTokens("x", "y")}, schema)
+	assert.EqualError(t, err, "type error: cannot determine the type of 'x y'")
+	_, err = Check(nil, schema)
+	assert.ErrorIs(t, err, ErrType)
+}
+
+func TestWithFuncs(t *testing.T) {
+	tests := []struct {
+		input  string
+		output Type
+		errs   []string
+	}{
+		{"len(${tags}) + abs(${ratio})", TypeNumber, nil},
+		{"max(1, ${priority}, ${ratio}) > 2", TypeBool, nil},
+		{"upper(${name}) == upper(${priority})", TypeBool, []string{"argument 1 of upper must be string; found int"}},
+		{"len(${priority}) > 1", TypeBool, []string{"argument 1 of len must be string|list|map; found int"}},
+		{"trim(${name}, 1) == 'x'", TypeBool, []string{"trim called with 2 arguments; expected 1"}},
+		{"max() > 1", TypeBool, []string{"max called with 0 arguments; expected at least 1"}},
+		{"size(${tags}) > 1", TypeBool, []string{"unknown function 'size'"}},
+		{"lower(${name}) + 1", TypeNumber, []string{"cannot apply + to string and int"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			typ, err := Check(exprtest.Parse(t, tt.input), schema, WithFuncs(funcs.Std()))
+			assert.Equal(t, tt.output, typ)
+			var msgs []string
+			if err != nil {
+				for _, e := range err.(Errors) {
+					msgs = append(msgs, e.Msg)
+				}
+			}
+			assert.Equal(t, tt.errs, msgs)
+		})
+	}
+
+	// without a registry, calls may produce any value
+	typ, err := Check(exprtest.Parse(t, "size(${missing})"), schema)
+	assert.Equal(t, TypeAny, typ)
+	assert.EqualError(t, err, "type error: unknown variable ${missing} at 1:6")
+}
+
+func TestType_String(t *testing.T) {
+	assert.Equal(t, "any", TypeAny.String())
+	assert.Equal(t, "int|float", TypeNumber.String())
+	assert.Equal(t, "null|string|duration", (TypeNull | TypeString | TypeDuration).String())
+	assert.Equal(t, "unknown type", Type(0).String())
+	assert.Equal(t, "unknown type", Type(1<<15).String())
+	for kind := parse.KindNull; kind <= parse.KindDuration; kind++ {
+		assert.Equal(t, kind.String(), TypeOf(kind).String())
+	}
+}
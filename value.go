@@ -0,0 +1,539 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind identifies the type of a Value.
+type Kind uint8
+
+const (
+	KindNull     Kind = iota // KindNull is the kind of Null.
+	KindBool                 // KindBool is the kind of Bool.
+	KindInt                  // KindInt is the kind of Int.
+	KindFloat                // KindFloat is the kind of Float.
+	KindString               // KindString is the kind of String.
+	KindList                 // KindList is the kind of List.
+	KindMap                  // KindMap is the kind of Map.
+	KindTime                 // KindTime is the kind of Time.
+	KindDuration             // KindDuration is the kind of Duration.
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNull:
+		return "null"
+	case KindBool:
+		return "bool"
+	case KindInt:
+		return "int"
+	case KindFloat:
+		return "float"
+	case KindString:
+		return "string"
+	case KindList:
+		return "list"
+	case KindMap:
+		return "map"
+	case KindTime:
+		return "time"
+	case KindDuration:
+		return "duration"
+	default:
+		return "unknown kind"
+	}
+}
+
+// A Value is a dynamically typed value produced by evaluating an expression. Every Value is one of Null, Bool, Int,
+// Float, String, List, Map, Time or Duration; see ValueOf for how Go values are converted to Values, and Equal and
+// Compare for how Values are compared.
+type Value interface {
+	// Kind returns the type of this Value.
+	Kind() Kind
+	// Interface returns this Value as a plain Go value, such as int64 or []any.
+	Interface() any
+	// String returns a textual representation of this Value.
+	String() string
+	// value prevents Value from being implemented outside this package.
+	value()
+}
+
+// Null is the Value of nil, null, and missing values.
+type Null struct{}
+
+// Bool is a Value holding true or false.
+type Bool bool
+
+// Int is a Value holding an integer.
+type Int int64
+
+// Float is a Value holding a floating point number.
+type Float float64
+
+// String is a Value holding a string.
+type String string
+
+// List is a Value holding an ordered list of Values.
+type List []Value
+
+// Map is a Value holding Values indexed by string keys.
+type Map map[string]Value
+
+// Time is a Value holding an instant in time.
+type Time time.Time
+
+// Duration is a Value holding an amount of elapsed time.
+type Duration time.Duration
+
+func (Null) Kind() Kind     { return KindNull }
+func (Bool) Kind() Kind     { return KindBool }
+func (Int) Kind() Kind      { return KindInt }
+func (Float) Kind() Kind    { return KindFloat }
+func (String) Kind() Kind   { return KindString }
+func (List) Kind() Kind     { return KindList }
+func (Map) Kind() Kind      { return KindMap }
+func (Time) Kind() Kind     { return KindTime }
+func (Duration) Kind() Kind { return KindDuration }
+
+func (Null) Interface() any       { return nil }
+func (b Bool) Interface() any     { return bool(b) }
+func (i Int) Interface() any      { return int64(i) }
+func (f Float) Interface() any    { return float64(f) }
+func (s String) Interface() any   { return string(s) }
+func (t Time) Interface() any     { return time.Time(t) }
+func (d Duration) Interface() any { return time.Duration(d) }
+
+func (l List) Interface() any {
+	result := make([]any, len(l))
+	for i, elem := range l {
+		result[i] = elem.Interface()
+	}
+	return result
+}
+
+func (m Map) Interface() any {
+	result := make(map[string]any, len(m))
+	for k, v := range m {
+		result[k] = v.Interface()
+	}
+	return result
+}
+
+func (Null) String() string       { return "null" }
+func (b Bool) String() string     { return strconv.FormatBool(bool(b)) }
+func (i Int) String() string      { return strconv.FormatInt(int64(i), 10) }
+func (f Float) String() string    { return strconv.FormatFloat(float64(f), 'g', -1, 64) }
+func (s String) String() string   { return string(s) }
+func (t Time) String() string     { return time.Time(t).Format(time.RFC3339Nano) }
+func (d Duration) String() string { return time.Duration(d).String() }
+
+// String returns the elements of this List in brackets, with strings quoted, as in ["a", 1].
+func (l List) String() string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i, elem := range l {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(quoted(elem))
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// String returns the entries of this Map in braces, ordered by key, with strings quoted, as in {"a": 1}.
+func (m Map) String() string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(strconv.Quote(k) + ": " + quoted(m[k]))
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// quoted returns the text of the provided Value, quoting it if it is a String.
+func quoted(v Value) string {
+	if s, ok := v.(String); ok {
+		return strconv.Quote(string(s))
+	}
+	if v == nil {
+		return "<nil>"
+	}
+	return v.String()
+}
+
+func (Null) value()     {}
+func (Bool) value()     {}
+func (Int) value()      {}
+func (Float) value()    {}
+func (String) value()   {}
+func (List) value()     {}
+func (Map) value()      {}
+func (Time) value()     {}
+func (Duration) value() {}
+
+// ValueOf converts the provided Go value to a Value, according to the following rules.
+//   - nil is converted to Null, and Values are returned as-is.
+//   - Integers of any Go integer type, and *big.Int values, are converted to Int. Integers which do not fit in an int64
+//     result in ErrEval.
+//   - float32 and float64 are converted to Float. json.Number values are converted to Int if they are integers, and to
+//     Float otherwise.
+//   - bool, string, time.Time and time.Duration are converted to Bool, String, Time and Duration respectively.
+//   - Slices and arrays are converted to List, and maps with string keys are converted to Map, by converting each of
+//     their elements.
+//
+// Values of any other type result in ErrEval.
+func ValueOf(v any) (Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return Null{}, nil
+	case Value:
+		return v, nil
+	case bool:
+		return Bool(v), nil
+	case int:
+		return Int(v), nil
+	case int8:
+		return Int(v), nil
+	case int16:
+		return Int(v), nil
+	case int32:
+		return Int(v), nil
+	case int64:
+		return Int(v), nil
+	case uint:
+		return uintValue(uint64(v))
+	case uint8:
+		return Int(v), nil
+	case uint16:
+		return Int(v), nil
+	case uint32:
+		return Int(v), nil
+	case uint64:
+		return uintValue(v)
+	case float32:
+		return Float(v), nil
+	case float64:
+		return Float(v), nil
+	case *big.Int:
+		if v == nil || !v.IsInt64() {
+			return nil, fmt.Errorf("%w: integer out of range: %v", ErrEval, v)
+		}
+		return Int(v.Int64()), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return Int(i), nil
+		}
+		if _, ok := new(big.Int).SetString(string(v), 10); ok {
+			return nil, fmt.Errorf("%w: integer out of range: %v", ErrEval, v)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number: %v", ErrEval, v)
+		}
+		return Float(f), nil
+	case string:
+		return String(v), nil
+	case time.Time:
+		return Time(v), nil
+	case time.Duration:
+		return Duration(v), nil
+	case []any:
+		list := make(List, len(v))
+		for i, elem := range v {
+			val, err := ValueOf(elem)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = val
+		}
+		return list, nil
+	case map[string]any:
+		m := make(Map, len(v))
+		for k, elem := range v {
+			val, err := ValueOf(elem)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = val
+		}
+		return m, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		list := make(List, rv.Len())
+		for i := range list {
+			val, err := ValueOf(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			list[i] = val
+		}
+		return list, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := make(Map, rv.Len())
+		for iter := rv.MapRange(); iter.Next(); {
+			val, err := ValueOf(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			m[iter.Key().String()] = val
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported %T value '%v'", ErrEval, v, v)
+}
+
+func uintValue(u uint64) (Value, error) {
+	if u > math.MaxInt64 {
+		return nil, fmt.Errorf("%w: integer out of range: %d", ErrEval, u)
+	}
+	return Int(u), nil
+}
+
+// Timestamp layouts accepted by Convert. Timestamps without a time zone are interpreted as UTC.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"}
+
+// Convert converts the provided Value to the provided Kind, according to the following rules. Any other conversion
+// results in ErrEval.
+//   - Every Value may be converted to its own Kind.
+//   - Bool, Int, Float, Time and Duration values may be converted to String, producing the text returned by String.
+//   - Strings may be converted to Bool, Int, Float, Time or Duration by parsing them. Bools must be true or false, and
+//     times must be ISO-8601 timestamps, as in 2024-01-02T15:04:05Z or 2024-01-02.
+//   - Int may be converted to Float. Float may be converted to Int if it is a whole number which fits in an int64.
+func Convert(v Value, kind Kind) (Value, error) {
+	if v == nil {
+		return nil, fmt.Errorf("%w: cannot convert nil Value", ErrEval)
+	}
+	if v.Kind() == kind {
+		return v, nil
+	}
+	switch v := v.(type) {
+	case Bool, Time, Duration:
+		if kind == KindString {
+			return String(v.String()), nil
+		}
+	case Int:
+		switch kind {
+		case KindFloat:
+			return Float(v), nil
+		case KindString:
+			return String(v.String()), nil
+		}
+	case Float:
+		switch kind {
+		case KindInt:
+			// float64(math.MaxInt64) rounds up to 2^63, which does not fit
+			if f := float64(v); f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+				return Int(f), nil
+			}
+		case KindString:
+			return String(v.String()), nil
+		}
+	case String:
+		if val, ok := parseString(string(v), kind); ok {
+			return val, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: cannot convert %v value '%v' to %v", ErrEval, v.Kind(), v, kind)
+}
+
+// parseString parses the provided string as a Value of the provided Kind.
+func parseString(str string, kind Kind) (Value, bool) {
+	switch kind {
+	case KindBool:
+		switch str {
+		case "true":
+			return Bool(true), true
+		case "false":
+			return Bool(false), true
+		}
+	case KindInt:
+		if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return Int(i), true
+		}
+	case KindFloat:
+		if f, err := strconv.ParseFloat(str, 64); err == nil {
+			return Float(f), true
+		}
+	case KindTime:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, str); err == nil {
+				return Time(t), true
+			}
+		}
+	case KindDuration:
+		if d, err := time.ParseDuration(str); err == nil {
+			return Duration(d), true
+		}
+	}
+	return nil, false
+}
+
+// Equal reports whether a and b are equal, according to the following rules.
+//   - Numbers are compared by numeric value, so that Int(3) is equal to Float(3.0). NaN is not equal to anything.
+//   - Lists are equal if they have the same length and their elements are pairwise equal. Maps are equal if they have
+//     the same keys, and the values of each key are equal.
+//   - Times are equal if they represent the same instant, even in different locations.
+//   - Values of distinct kinds are never equal, so Null is only equal to Null.
+func Equal(a, b Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if isNumber(a) && isNumber(b) {
+		cmp, ok := compareNumbers(a, b)
+		return ok && cmp == 0
+	}
+	switch a := a.(type) {
+	case Null:
+		return b.Kind() == KindNull
+	case Bool:
+		b, ok := b.(Bool)
+		return ok && a == b
+	case String:
+		b, ok := b.(String)
+		return ok && a == b
+	case Time:
+		b, ok := b.(Time)
+		return ok && time.Time(a).Equal(time.Time(b))
+	case Duration:
+		b, ok := b.(Duration)
+		return ok && a == b
+	case List:
+		b, ok := b.(List)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case Map:
+		b, ok := b.(Map)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !Equal(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Compare orders a and b, returning a negative number if a < b, zero if a == b, and a positive number if a > b. Numbers
+// are ordered by numeric value, strings lexicographically by byte, false before true, and times and durations
+// chronologically. Attempting to order values of any other kinds, values of distinct kinds other than Int and Float,
+// or NaN, results in ErrEval.
+func Compare(a, b Value) (int, error) {
+	if a == nil || b == nil {
+		return 0, fmt.Errorf("%w: cannot order nil Value", ErrEval)
+	}
+	if isNumber(a) && isNumber(b) {
+		cmp, ok := compareNumbers(a, b)
+		if !ok {
+			return 0, fmt.Errorf("%w: cannot order NaN", ErrEval)
+		}
+		return cmp, nil
+	}
+	switch a := a.(type) {
+	case String:
+		if b, ok := b.(String); ok {
+			return strings.Compare(string(a), string(b)), nil
+		}
+	case Bool:
+		if b, ok := b.(Bool); ok {
+			return boolToInt(a) - boolToInt(b), nil
+		}
+	case Time:
+		if b, ok := b.(Time); ok {
+			switch {
+			case time.Time(a).Before(time.Time(b)):
+				return -1, nil
+			case time.Time(a).After(time.Time(b)):
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case Duration:
+		if b, ok := b.(Duration); ok {
+			return cmpInt64(int64(a), int64(b)), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: cannot order %v value '%v' and %v value '%v'", ErrEval, a.Kind(), a, b.Kind(), b)
+}
+
+func isNumber(v Value) bool {
+	kind := v.Kind()
+	return kind == KindInt || kind == KindFloat
+}
+
+// compareNumbers orders two Int or Float values exactly. The boolean result is false if either value is NaN.
+func compareNumbers(a, b Value) (int, bool) {
+	if a, ok := a.(Int); ok {
+		if b, ok := b.(Int); ok {
+			return cmpInt64(int64(a), int64(b)), true
+		}
+	}
+	x, y := toBigFloat(a), toBigFloat(b)
+	if x == nil || y == nil {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+// toBigFloat converts an Int or Float to a *big.Float, returning nil if it is NaN.
+func toBigFloat(v Value) *big.Float {
+	switch v := v.(type) {
+	case Int:
+		return new(big.Float).SetInt64(int64(v))
+	case Float:
+		if math.IsNaN(float64(v)) {
+			return nil
+		}
+		return big.NewFloat(float64(v))
+	}
+	return nil
+}
+
+func cmpInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b Bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,241 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestValueOf(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		input  any
+		output Value
+	}{
+		{nil, Null{}},
+		{true, Bool(true)},
+		{7, Int(7)},
+		{int8(-8), Int(-8)},
+		{uint32(32), Int(32)},
+		{uint64(math.MaxInt64), Int(math.MaxInt64)},
+		{big.NewInt(-5), Int(-5)},
+		{json.Number("12"), Int(12)},
+		{json.Number("1.5e3"), Float(1500)},
+		{float32(0.5), Float(0.5)},
+		{2.25, Float(2.25)},
+		{"x", String("x")},
+		{ts, Time(ts)},
+		{5 * time.Minute, Duration(5 * time.Minute)},
+		{[]any{1, "a", nil}, List{Int(1), String("a"), Null{}}},
+		{[]string{"a", "b"}, List{String("a"), String("b")}},
+		{[2]int{1, 2}, List{Int(1), Int(2)}},
+		{map[string]any{"a": []any{true}}, Map{"a": List{Bool(true)}}},
+		{map[string]int{"a": 1}, Map{"a": Int(1)}},
+		{Float(3), Float(3)},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T %v", tt.input, tt.input), func(t *testing.T) {
+			val, err := ValueOf(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, val)
+		})
+	}
+
+	for _, input := range []any{
+		uint64(math.MaxUint64),
+		new(big.Int).Lsh(big.NewInt(1), 64),
+		json.Number("99999999999999999999"),
+		struct{}{},
+		map[int]any{1: 2},
+		[]any{1, struct{}{}},
+		map[string]any{"a": make(chan int)},
+	} {
+		_, err := ValueOf(input)
+		assert.ErrorIs(t, err, ErrEval, input)
+	}
+}
+
+func TestValue_Interface(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	input := map[string]any{
+		"null":     nil,
+		"bool":     true,
+		"int":      int64(1),
+		"float":    1.5,
+		"string":   "s",
+		"list":     []any{int64(1), "a"},
+		"time":     ts,
+		"duration": time.Second,
+	}
+	val, err := ValueOf(input)
+	require.NoError(t, err)
+	assert.Equal(t, KindMap, val.Kind())
+	assert.Equal(t, input, val.Interface())
+}
+
+func TestValue_String(t *testing.T) {
+	tests := []struct {
+		input  Value
+		output string
+	}{
+		{Null{}, "null"},
+		{Bool(false), "false"},
+		{Int(-3), "-3"},
+		{Float(0.5), "0.5"},
+		{Float(1e21), "1e+21"},
+		{String("a b"), "a b"},
+		{Time(time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)), "2024-01-02T15:04:05Z"},
+		{Duration(90 * time.Minute), "1h30m0s"},
+		{List{String("a"), Int(1), List{}}, `["a", 1, []]`},
+		{Map{"b": String("x"), "a": Null{}}, `{"a": null, "b": "x"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			assert.Equal(t, tt.output, tt.input.String())
+		})
+	}
+	for kind := KindNull; kind <= KindDuration; kind++ {
+		assert.NotEqual(t, "unknown kind", kind.String())
+	}
+	assert.Equal(t, "unknown kind", Kind(100).String())
+}
+
+func TestConvert(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input  Value
+		kind   Kind
+		output Value
+	}{
+		{Int(3), KindInt, Int(3)},
+		{Null{}, KindNull, Null{}},
+		{Int(3), KindFloat, Float(3)},
+		{Float(-4), KindInt, Int(-4)},
+		{Int(3), KindString, String("3")},
+		{Float(2.5), KindString, String("2.5")},
+		{Bool(true), KindString, String("true")},
+		{Time(ts), KindString, String("2024-01-02T00:00:00Z")},
+		{Duration(time.Second), KindString, String("1s")},
+		{String("false"), KindBool, Bool(false)},
+		{String("-12"), KindInt, Int(-12)},
+		{String("1e3"), KindFloat, Float(1000)},
+		{String("2024-01-02"), KindTime, Time(ts)},
+		{String("2024-01-02T00:00:00Z"), KindTime, Time(ts)},
+		{String("1h"), KindDuration, Duration(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v to %v", tt.input, tt.kind), func(t *testing.T) {
+			val, err := Convert(tt.input, tt.kind)
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, val)
+		})
+	}
+
+	errorCases := []struct {
+		input Value
+		kind  Kind
+	}{
+		{Null{}, KindString},
+		{Int(1), KindNull},
+		{Int(1), KindBool},
+		{Float(1.5), KindInt},
+		{Float(math.Inf(1)), KindInt},
+		{Float(math.MaxInt64), KindInt},
+		{Float(math.NaN()), KindInt},
+		{String("yes"), KindBool},
+		{String("1.5"), KindInt},
+		{String("soon"), KindTime},
+		{String("1"), KindDuration},
+		{String("a"), KindList},
+		{List{}, KindString},
+		{Map{}, KindList},
+		{Duration(1), KindInt},
+		{nil, KindNull},
+	}
+	for _, tt := range errorCases {
+		_, err := Convert(tt.input, tt.kind)
+		assert.ErrorIs(t, err, ErrEval, "%v to %v", tt.input, tt.kind)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		a, b  Value
+		equal bool
+	}{
+		{Null{}, Null{}, true},
+		{Null{}, Int(0), false},
+		{Int(3), Float(3), true},
+		{Float(0.1), Float(0.1), true},
+		{Int(math.MaxInt64), Float(math.MaxInt64), false},
+		{Float(math.NaN()), Float(math.NaN()), false},
+		{String("1"), Int(1), false},
+		{Bool(true), Bool(true), true},
+		{Bool(true), String("true"), false},
+		{Time(ts), Time(ts.In(time.FixedZone("X", 3600))), true},
+		{Duration(time.Second), Duration(time.Second), true},
+		{Duration(time.Second), Int(int64(time.Second)), false},
+		{List{Int(1), String("a")}, List{Float(1), String("a")}, true},
+		{List{Int(1)}, List{Int(1), Int(2)}, false},
+		{List{}, Map{}, false},
+		{Map{"a": Int(1)}, Map{"a": Float(1)}, true},
+		{Map{"a": Int(1)}, Map{"b": Int(1)}, false},
+		{Map{"a": Int(1)}, Map{"a": Int(2)}, false},
+		{nil, nil, true},
+		{nil, Null{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v == %v", tt.a, tt.b), func(t *testing.T) {
+			assert.Equal(t, tt.equal, Equal(tt.a, tt.b))
+			assert.Equal(t, tt.equal, Equal(tt.b, tt.a))
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		a, b Value
+		cmp  int
+	}{
+		{Int(1), Int(2), -1},
+		{Int(2), Float(1.5), 1},
+		{Float(math.Inf(-1)), Int(math.MinInt64), -1},
+		{Int(math.MaxInt64), Float(math.MaxInt64), -1},
+		{String("b"), String("ab"), 1},
+		{Bool(false), Bool(true), -1},
+		{Time(ts), Time(ts.Add(time.Nanosecond)), -1},
+		{Duration(time.Hour), Duration(time.Minute), 1},
+		{Duration(time.Hour), Duration(time.Hour), 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v <=> %v", tt.a, tt.b), func(t *testing.T) {
+			cmp, err := Compare(tt.a, tt.b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.cmp, cmp)
+			cmp, err = Compare(tt.b, tt.a)
+			require.NoError(t, err)
+			assert.Equal(t, -tt.cmp, cmp)
+		})
+	}
+
+	errorCases := [][2]Value{
+		{Null{}, Null{}},
+		{Int(1), String("1")},
+		{Float(math.NaN()), Int(1)},
+		{List{}, List{}},
+		{Map{}, Map{}},
+		{Time(ts), Duration(1)},
+		{nil, Int(1)},
+	}
+	for _, tt := range errorCases {
+		_, err := Compare(tt[0], tt[1])
+		assert.ErrorIs(t, err, ErrEval, "%v <=> %v", tt[0], tt[1])
+	}
+}
@@ -0,0 +1,167 @@
+package vars
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"reflect"
+	"sort"
+)
+
+// ErrNotFound is returned when a reference does not match any value. It is the same as parse.ErrNotFound, and so
+// matches parse.ErrEval when checked using errors.Is.
+var ErrNotFound = parse.ErrNotFound
+
+// Interpreter returns a parse.Interpreter which finds the value of Ref nodes in the provided document; see Resolve for
+// details. Nodes of any other type result in parse.ErrUnknownAST, so that the returned Interpreter can be combined with
+// others using WithFallback. For instance, to evaluate comparisons between literals and variables:
+//
+//	comp.Interpreter(literal.Interpreter().WithFallback(vars.Interpreter(doc)))
+func Interpreter(doc any) parse.Interpreter[any] {
+	doc = decode(doc)
+	return func(ast parse.AST) (any, error) {
+		ref, ok := ast.(*Ref)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown AST node: %v", parse.ErrUnknownAST, ast)
+		}
+		return Resolve(ref, doc)
+	}
+}
+
+// Resolve returns the value found by following the path of the provided reference through the provided document.
+// Documents are made up of maps with string keys, slices and arrays, such as those produced by json.Unmarshal;
+// json.RawMessage values are decoded as they are reached, with numbers decoded as json.Number.
+//
+// Fields select values from maps, and indices select elements from slices and arrays, with negative indices counting
+// back from the end. An error matching ErrNotFound is returned if a field is missing, an index is out of range, or a
+// segment is applied to a value of the wrong type, unless the segment is null-safe, in which case the result is nil.
+//
+// References containing wildcards produce a []any holding every matching value, in order of index or key. Values for
+// which the rest of the path is not found are skipped, so such references never result in ErrNotFound.
+func Resolve(ref *Ref, doc any) (any, error) {
+	if ref == nil || len(ref.Path) == 0 {
+		return nil, fmt.Errorf("%w: empty reference", parse.ErrEval)
+	}
+	if !ref.Wildcard() {
+		val := doc
+		for i, seg := range ref.Path {
+			var ok bool
+			if val, ok = step(val, seg); !ok {
+				if seg.NullSafe {
+					return nil, nil
+				}
+				return nil, fmt.Errorf("%w: %v", ErrNotFound, &Ref{Path: ref.Path[:i+1]})
+			}
+		}
+		return val, nil
+	}
+
+	vals := []any{doc}
+	for _, seg := range ref.Path {
+		next := make([]any, 0, len(vals))
+		for _, val := range vals {
+			if seg.Kind == SegmentWildcard {
+				next = append(next, expand(val)...)
+			} else if val, ok := step(val, seg); ok {
+				next = append(next, val)
+			}
+		}
+		vals = next
+	}
+	return vals, nil
+}
+
+// step applies the provided field or index segment to the provided value.
+func step(val any, seg Segment) (any, bool) {
+	val = decode(val)
+	switch seg.Kind {
+	case SegmentField:
+		if m, ok := val.(map[string]any); ok {
+			result, ok := m[seg.Key]
+			return result, ok
+		}
+		v := reflect.ValueOf(val)
+		if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		result := v.MapIndex(reflect.ValueOf(seg.Key).Convert(v.Type().Key()))
+		if !result.IsValid() {
+			return nil, false
+		}
+		return result.Interface(), true
+	case SegmentIndex:
+		if s, ok := val.([]any); ok {
+			i, ok := index(seg.Index, len(s))
+			if !ok {
+				return nil, false
+			}
+			return s[i], true
+		}
+		v := reflect.ValueOf(val)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, false
+		}
+		i, ok := index(seg.Index, v.Len())
+		if !ok {
+			return nil, false
+		}
+		return v.Index(i).Interface(), true
+	}
+	return nil, false
+}
+
+// index converts a possibly negative index into an index of a slice of length n.
+func index(i, n int) (int, bool) {
+	if i < 0 {
+		i += n
+	}
+	return i, i >= 0 && i < n
+}
+
+// expand returns every element of the provided slice or array, or every value of the provided map in order of key.
+// Other values have no elements.
+func expand(val any) []any {
+	val = decode(val)
+	if s, ok := val.([]any); ok {
+		return s
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		result := make([]any, v.Len())
+		for i := range result {
+			result[i] = v.Index(i).Interface()
+		}
+		return result
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		result := make([]any, len(keys))
+		for i, key := range keys {
+			result[i] = v.MapIndex(key).Interface()
+		}
+		return result
+	}
+	return nil
+}
+
+// decode decodes the provided value if it is a json.RawMessage, and returns it as-is otherwise.
+func decode(val any) any {
+	raw, ok := val.(json.RawMessage)
+	if !ok {
+		return val
+	}
+	var result any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&result); err != nil {
+		return val
+	}
+	return result
+}
@@ -0,0 +1,123 @@
+package vars
+
+import (
+	"encoding/json"
+	"github.com/orkes-io/go-parse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const doc = `{
+	"workflow": {
+		"input": {
+			"items": [{"name": "a", "price": 3}, {"name": "b", "price": 4.5}, {"name": "c"}],
+			"task-ref": {"output": null}
+		}
+	},
+	"matrix": [[1, 2], [3, 4]]
+}`
+
+func TestResolve(t *testing.T) {
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal([]byte(doc), &decoded))
+
+	tests := []struct {
+		input  string
+		result any
+		err    error
+	}{
+		{"${workflow.input.items[0].name}", "a", nil},
+		{"${workflow.input.items[-1].name}", "c", nil},
+		{"${workflow.input.items[1].price}", 4.5, nil},
+		{`${workflow.input["task-ref"].output}`, nil, nil},
+		{"${matrix[1][0]}", 3.0, nil},
+		{"${workflow.input.items[*].price}", []any{3.0, 4.5}, nil},
+		{"${workflow.input.items.*.name}", []any{"a", "b", "c"}, nil},
+		{"${matrix[*][*]}", []any{1.0, 2.0, 3.0, 4.0}, nil},
+		{"${workflow.input.*}", []any{decoded["workflow"].(map[string]any)["input"].(map[string]any)["items"], map[string]any{"output": nil}}, nil},
+		{"${missing[*]}", []any{}, nil},
+		{"${missing}", nil, ErrNotFound},
+		{"${workflow.input.items[3]}", nil, ErrNotFound},
+		{"${workflow.input.items[-4]}", nil, ErrNotFound},
+		{"${workflow.input.items.name}", nil, ErrNotFound},
+		{"${workflow[0]}", nil, ErrNotFound},
+		{"${matrix[0][0].x}", nil, ErrNotFound},
+		{"${workflow.input?.missing.name}", nil, nil},
+		{"${workflow?.input.items?.[5]}", nil, nil},
+		{`${workflow.input["task-ref"].output?.result}`, nil, nil},
+		{"${workflow.input.items?.[0].name}", "a", nil},
+		{"${workflow.input?.missing[*]}", []any{}, nil},
+		{"${workflow?.missing.name}", nil, nil},
+		{"${workflow.missing?.name}", nil, ErrNotFound},
+		{"${workflow.input.items[0]?.name.x}", nil, ErrNotFound},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			result, err := Resolve(ast.(*Ref), decoded)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.ErrorIs(t, err, parse.ErrEval)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+		})
+	}
+
+	_, err = Resolve(&Ref{}, decoded)
+	assert.ErrorIs(t, err, parse.ErrEval)
+
+	_, err = Resolve(mustRef(t, "${a.b.c}"), map[string]any{"a": map[string]any{}})
+	assert.EqualError(t, err, "eval error: variable not found: ${a.b}")
+}
+
+func TestResolve_Types(t *testing.T) {
+	type key string
+	tests := []struct {
+		input  string
+		doc    any
+		result any
+	}{
+		{"${a.b}", map[string]map[string]int{"a": {"b": 1}}, 1},
+		{"${a[1]}", map[key][2]string{"a": {"x", "y"}}, "y"},
+		{"${a[*]}", map[string][]int{"a": {1, 2}}, []any{1, 2}},
+		{"${a.*}", map[string]map[string]int{"a": {"y": 2, "x": 1}}, []any{1, 2}},
+		{"${a.b[0]}", json.RawMessage(`{"a": {"b": [12345678901234567890]}}`), json.Number("12345678901234567890")},
+		{"${a.b}", map[string]any{"a": json.RawMessage(`{"b": "c"}`)}, "c"},
+		{"${a[*].b}", map[string]any{"a": json.RawMessage(`[{"b": 1}, {}, 2]`)}, []any{json.Number("1")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := Interpreter(tt.doc)(mustRef(t, tt.input))
+			require.NoError(t, err)
+			assert.Equal(t, tt.result, result)
+		})
+	}
+}
+
+func TestInterpreter(t *testing.T) {
+	interpret := Interpreter(map[string]any{"a": 1}).WithFallback(func(ast parse.AST) (any, error) {
+		return "fallback", nil
+	})
+	val, err := interpret(mustRef(t, "${a}"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+
+	val, err = interpret(un("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "fallback", val)
+
+	_, err = interpret(mustRef(t, "${b}"))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func mustRef(t *testing.T, input string) *Ref {
+	ast, err := defaultParser.ParseStr(input)
+	require.NoError(t, err)
+	return ast.(*Ref)
+}
@@ -0,0 +1,380 @@
+// Package vars implements a parser for variable references such as ${workflow.input.items[0].name}, according to the
+// following grammar.
+//
+//	ref     -> '${' head segment* '}'
+//	head    -> name | '[' quoted ']'
+//	segment -> '.' name | '.' '*' | '[' index ']' | '[' '*' ']' | '[' quoted ']' | '?.' name | '?.' '[' key ']'
+//	key     -> index | quoted
+//	name    -> any runes other than whitespace, quotes, '.', '*', '$', '[', ']', '{', '}' and '\'
+//	index   -> '-'? [0-9]+
+//	quoted  -> a string literal, see parse.Unquote
+//
+// Each list of tokens which consists of a single reference is parsed into a Ref node; any other list of tokens is left
+// as-is in a parse.Unparsed node. Like the literal package, this parser never splits tokens, so it is usually run both
+// before and after the arith package:
+//
+//	ast, err := parse.ParseAll(ast, compParser, varsParser, arithParser, varsParser)
+//
+// Segments which follow ?. are null-safe, as in ${task?.output?.result}: if the value they select does not exist, or
+// the value they select from is null, the reference evaluates to null rather than resulting in ErrNotFound.
+//
+// Since arith splits tokens at each operator, names containing operators must be quoted, as in ${tasks["task-ref"]}.
+// Wildcards are only recognized in references which are not operands of arithmetic expressions.
+package vars
+
+import (
+	"errors"
+	"fmt"
+	"github.com/orkes-io/go-parse"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SegmentKind identifies the type of a Segment.
+type SegmentKind uint8
+
+const (
+	SegmentField    SegmentKind = iota + 1 // SegmentField selects the value of a field of an object.
+	SegmentIndex                           // SegmentIndex selects an element of an array.
+	SegmentWildcard                        // SegmentWildcard selects every field of an object or element of an array.
+)
+
+// Segment is a single step in the path of a Ref.
+type Segment struct {
+	Kind  SegmentKind // Kind is the type of this segment.
+	Key   string      // Key is the name of the field selected by a SegmentField.
+	Index int         // Index is the index selected by a SegmentIndex. Negative indices count back from the end.
+	// NullSafe is set if this segment follows ?., in which case a missing value results in null rather than an error.
+	NullSafe bool
+}
+
+// Field returns a Segment selecting the provided field.
+func Field(key string) Segment {
+	return Segment{Kind: SegmentField, Key: key}
+}
+
+// Index returns a Segment selecting the element at the provided index.
+func Index(index int) Segment {
+	return Segment{Kind: SegmentIndex, Index: index}
+}
+
+// NullSafe returns a copy of the provided field or index Segment which is null-safe, as if it followed ?.
+func NullSafe(seg Segment) Segment {
+	seg.NullSafe = true
+	return seg
+}
+
+// Wildcard returns a Segment selecting every field or element.
+func Wildcard() Segment {
+	return Segment{Kind: SegmentWildcard}
+}
+
+// Ref represents a reference to a variable, such as ${workflow.input.name}.
+type Ref struct {
+	Path []Segment  // Path lists the segments of this reference; the first is always a SegmentField.
+	Src  parse.Span // Src is the location of this reference in the source text.
+}
+
+// String returns this reference in the default syntax of this package.
+func (r *Ref) String() string {
+	text, err := parse.Format(r, defaultParser)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return text
+}
+
+// Span returns the location of this reference in the source text.
+func (r *Ref) Span() parse.Span {
+	return r.Src
+}
+
+// Parse does nothing, since a Ref has no unparsed nodes.
+func (r *Ref) Parse(parse.Parser) error {
+	return nil
+}
+
+// Wildcard returns true iff the path of this reference contains a wildcard, in which case it may refer to many values.
+func (r *Ref) Wildcard() bool {
+	for _, seg := range r.Path {
+		if seg.Kind == SegmentWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+type ParserOpt func(*Parser)
+
+// Parser parses variable references. A Parser is safe for concurrent use by multiple goroutines.
+type Parser struct {
+	quotes []rune
+
+	tokenizer parse.Tokenizer
+}
+
+// WithQuotes sets the runes which delimit quoted keys. By default, the runes in parse.DefaultQuotes are used. Keys
+// which are not valid names are printed using the first of the provided runes.
+func WithQuotes(quotes ...rune) ParserOpt {
+	return func(parser *Parser) {
+		parser.quotes = quotes
+	}
+}
+
+// NewParser returns a parser configured according to the provided options. If no options are configured, the default
+// parser is returned.
+func NewParser(opts ...ParserOpt) (*Parser, error) {
+	p := &Parser{quotes: parse.DefaultQuotes}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Parser) init() error {
+	if len(p.quotes) == 0 {
+		return fmt.Errorf("%w: at least one quote is required", parse.ErrConfig)
+	}
+	for _, q := range p.quotes {
+		if unicode.IsSpace(q) || strings.ContainsRune(reserved, q) {
+			return fmt.Errorf("%w: cannot use %q as a quote", parse.ErrConfig, q)
+		}
+	}
+	p.tokenizer = parse.Tokenizer{Keywords: &parse.KeywordTrie{}, Quotes: p.quotes}
+	return nil
+}
+
+// ParseStr tokenizes and parses the provided string. See Parser.Parse for details. Any *parse.SyntaxError returned
+// includes the provided string as its Source.
+func (p *Parser) ParseStr(str string) (parse.AST, error) {
+	tokens, err := p.tokenizer.Tokenize(str)
+	var ast parse.AST
+	if err == nil {
+		ast, err = p.Parse(tokens)
+	}
+	var syntaxErr *parse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.Source = str
+	}
+	return ast, err
+}
+
+// Parse parses the provided list of tokens, producing a *Ref if it consists of a single token which begins with ${
+// and ends with }, and a parse.Unparsed node containing the provided tokens otherwise. A *parse.SyntaxError is
+// returned if such a token is not a valid reference.
+func (p *Parser) Parse(tokens []parse.Token) (parse.AST, error) {
+	if len(tokens) != 1 || tokens[0].Quote != 0 {
+		return parse.Unparsed{Contents: tokens}, nil
+	}
+	text := tokens[0].Text
+	if !strings.HasPrefix(text, "${") || !strings.HasSuffix(text, "}") || len(text) < 3 {
+		return parse.Unparsed{Contents: tokens}, nil
+	}
+	path, err := p.parsePath([]rune(text[2 : len(text)-1]))
+	if err != nil {
+		return nil, parse.NewSyntaxError(tokens, 0, nil, "invalid variable reference %s: %v", text, err)
+	}
+	return &Ref{Path: path, Src: tokens[0].Src}, nil
+}
+
+// parsePath parses the text between the braces of a reference.
+func (p *Parser) parsePath(runes []rune) ([]Segment, error) {
+	var path []Segment
+	for i := 0; i < len(runes); {
+		var seg Segment
+		var err error
+		switch {
+		case runes[i] == '[':
+			seg, i, err = p.parseBracket(runes, i+1)
+		case len(path) == 0:
+			seg, i, err = p.parseName(runes, i)
+		case nullSafe(runes, i):
+			seg, i, err = p.parseNullSafe(runes, i+2)
+		case runes[i] == '.' && i+1 < len(runes) && runes[i+1] == '*':
+			seg, i = Wildcard(), i+2
+		case runes[i] == '.':
+			seg, i, err = p.parseName(runes, i+1)
+		default:
+			err = fmt.Errorf("unexpected %q", runes[i])
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 && seg.Kind != SegmentField {
+			return nil, errors.New("path must begin with a name")
+		}
+		path = append(path, seg)
+	}
+	if len(path) == 0 {
+		return nil, errors.New("empty path")
+	}
+	return path, nil
+}
+
+// parseNullSafe parses the segment which follows the ?. at runes[i-2], returning the index of the rune which follows
+// it.
+func (p *Parser) parseNullSafe(runes []rune, i int) (Segment, int, error) {
+	var seg Segment
+	var err error
+	if i < len(runes) && runes[i] == '[' {
+		seg, i, err = p.parseBracket(runes, i+1)
+	} else {
+		seg, i, err = p.parseName(runes, i)
+	}
+	if err == nil && seg.Kind == SegmentWildcard {
+		err = errors.New("wildcards cannot be null-safe")
+	}
+	return NullSafe(seg), i, err
+}
+
+// nullSafe returns true iff runes[i] begins the ?. which precedes a null-safe segment.
+func nullSafe(runes []rune, i int) bool {
+	return runes[i] == '?' && i+1 < len(runes) && runes[i+1] == '.'
+}
+
+// parseName parses the name which begins at runes[i], returning the index of the rune which follows it. Names end
+// before ?., so that ${a?.b} refers to b within a.
+func (p *Parser) parseName(runes []rune, i int) (Segment, int, error) {
+	start := i
+	for i < len(runes) && p.isNameRune(runes[i]) && !nullSafe(runes, i) {
+		i++
+	}
+	if i == start {
+		if i == len(runes) {
+			return Segment{}, i, errors.New("expected name")
+		}
+		return Segment{}, i, fmt.Errorf("expected name, found %q", runes[i])
+	}
+	return Field(string(runes[start:i])), i, nil
+}
+
+// parseBracket parses the contents of the brackets which open at runes[i-1], returning the index of the rune which
+// follows the closing bracket.
+func (p *Parser) parseBracket(runes []rune, i int) (Segment, int, error) {
+	end := i
+	var seg Segment
+	switch {
+	case i >= len(runes):
+		return Segment{}, i, errors.New("unclosed '['")
+	case runes[i] == '*':
+		seg, end = Wildcard(), i+1
+	case p.isQuote(runes[i]):
+		end = closingQuote(runes, i) + 1
+		if end == 0 {
+			return Segment{}, i, errors.New("unterminated quoted key")
+		}
+		key, err := parse.Unquote(string(runes[i:end]))
+		if err != nil {
+			return Segment{}, i, err
+		}
+		seg = Field(key)
+	default:
+		for end < len(runes) && (runes[end] == '-' || '0' <= runes[end] && runes[end] <= '9') {
+			end++
+		}
+		index, err := strconv.Atoi(string(runes[i:end]))
+		if err != nil {
+			return Segment{}, i, fmt.Errorf("invalid index %q", string(runes[i:end]))
+		}
+		seg = Index(index)
+	}
+	if end >= len(runes) || runes[end] != ']' {
+		return Segment{}, end, errors.New("expected ']'")
+	}
+	return seg, end + 1, nil
+}
+
+// closingQuote returns the index of the rune closing the quoted key which begins at runes[start], or -1 if the key is
+// not terminated.
+func closingQuote(runes []rune, start int) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case runes[start]:
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *Parser) isQuote(r rune) bool {
+	for _, q := range p.quotes {
+		if r == q {
+			return true
+		}
+	}
+	return false
+}
+
+// reserved lists the runes which have a meaning of their own in a reference, and so cannot appear in names or be used
+// as quotes.
+const reserved = ".*$[]{}\\"
+
+// isNameRune returns true iff r may appear in a name without being quoted.
+func (p *Parser) isNameRune(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsControl(r) && !strings.ContainsRune(reserved, r) && !p.isQuote(r)
+}
+
+// Print implements parse.Printer, rendering Ref nodes using the syntax recognized by this Parser, such that parsing the
+// resulting text produces the original reference.
+func (p *Parser) Print(ast parse.AST, f *parse.Formatter) (string, int, error) {
+	ref, ok := ast.(*Ref)
+	if !ok {
+		return "", 0, fmt.Errorf("%w: %T", parse.ErrUnknownAST, ast)
+	}
+	if len(ref.Path) == 0 || ref.Path[0].Kind != SegmentField || ref.Path[0].NullSafe {
+		return "", 0, errors.New("cannot print reference which does not begin with a field")
+	}
+	var sb strings.Builder
+	sb.WriteString("${")
+	for i, seg := range ref.Path {
+		if seg.NullSafe {
+			if seg.Kind == SegmentWildcard {
+				return "", 0, errors.New("cannot print null-safe wildcard")
+			}
+			sb.WriteString("?.")
+		}
+		switch seg.Kind {
+		case SegmentField:
+			if !isName(seg.Key) {
+				sb.WriteString("[" + parse.Quote(seg.Key, p.quotes[0]) + "]")
+				continue
+			}
+			if i > 0 && !seg.NullSafe {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(seg.Key)
+		case SegmentIndex:
+			sb.WriteString("[" + strconv.Itoa(seg.Index) + "]")
+		case SegmentWildcard:
+			sb.WriteString("[*]")
+		default:
+			return "", 0, fmt.Errorf("cannot print segment of unknown kind %d", seg.Kind)
+		}
+	}
+	sb.WriteString("}")
+	return sb.String(), parse.PrecAtom, nil
+}
+
+// isName returns true iff the provided key can be printed without quotes. Although names may contain other runes, only
+// keys made up of letters, digits and underscores are printed unquoted, so that they are not split by other parsers,
+// such as those of the arith package.
+func isName(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultParser is used to implement the String method of Ref.
+var defaultParser, _ = NewParser()
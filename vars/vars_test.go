@@ -0,0 +1,182 @@
+package vars
+
+import (
+	"github.com/orkes-io/go-parse"
+	"github.com/orkes-io/go-parse/internal/asttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestParser_Parse(t *testing.T) {
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"${a}", ref(Field("a"))},
+		{"${workflow.input.items[0].name}", ref(Field("workflow"), Field("input"), Field("items"), Index(0), Field("name"))},
+		{"${items[-1]}", ref(Field("items"), Index(-1))},
+		{"${items[*].price}", ref(Field("items"), Wildcard(), Field("price"))},
+		{"${items.*.price}", ref(Field("items"), Wildcard(), Field("price"))},
+		{`${tasks["task-ref"].output}`, ref(Field("tasks"), Field("task-ref"), Field("output"))},
+		{`${a['b c']['d\'e']}`, ref(Field("a"), Field("b c"), Field("d'e"))},
+		{"${['a.b'].c}", ref(Field("a.b"), Field("c"))},
+		{"${task_ref-1.output}", ref(Field("task_ref-1"), Field("output"))},
+		{"${é.ü}", ref(Field("é"), Field("ü"))},
+		{"${task?.output?.result}", ref(Field("task"), NullSafe(Field("output")), NullSafe(Field("result")))},
+		{`${a?.[0]?.["b c"].d}`, ref(Field("a"), NullSafe(Index(0)), NullSafe(Field("b c")), Field("d"))},
+		{"${a?.b?}", ref(Field("a"), NullSafe(Field("b?")))},
+		{"${a?b}", ref(Field("a?b"))},
+		{"a", un("a")},
+		{"${a} b", un("${a}", "b")},
+		{"$a", un("$a")},
+		{"${a", un("${a")},
+		{"'${a}'", un("'${a}'")},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ast, err := p.ParseStr(tt.input)
+			require.NoError(t, err)
+			expected := tt.output
+			if unparsed, ok := expected.(parse.Unparsed); ok && tt.input == "'${a}'" {
+				unparsed.Contents[0].Quote = '\''
+			}
+			assert.EqualValues(t, expected, asttest.StripSpans(ast))
+		})
+	}
+}
+
+func TestParser_ParseError(t *testing.T) {
+	tests := []struct {
+		input string
+		msg   string
+	}{
+		{"${}", "empty path"},
+		{"${.a}", `expected name, found '.'`},
+		{"${a.}", "expected name"},
+		{"${a..b}", `expected name, found '.'`},
+		{"${a[}", "unclosed '['"},
+		{"${a[0}", "expected ']'"},
+		{"${a[x]}", `invalid index ""`},
+		{"${a[1-]}", `invalid index "1-"`},
+		{"${a['b]}", "unterminated string literal"},
+		{`${a['\q']}`, "unknown escape sequence"},
+		{"${a[0]b}", `unexpected 'b'`},
+		{"${[0]}", "path must begin with a name"},
+		{"${*}", `expected name, found '*'`},
+		{"${a{b}", `unexpected '{'`},
+		{"${?.a}", `expected name, found '?'`},
+		{"${a?.}", "expected name"},
+		{"${a?..b}", `expected name, found '.'`},
+		{"${a?.*}", `expected name, found '*'`},
+		{"${a?.[*]}", "wildcards cannot be null-safe"},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := p.ParseStr(tt.input)
+			var syntaxErr *parse.SyntaxError
+			require.ErrorAs(t, err, &syntaxErr)
+			assert.Contains(t, err.Error(), tt.msg)
+			assert.Equal(t, tt.input, syntaxErr.Source)
+		})
+	}
+}
+
+func TestParser_Concurrent(t *testing.T) {
+	p, err := NewParser()
+	require.NoError(t, err)
+
+	tests := []struct {
+		input  string
+		output parse.AST
+	}{
+		{"${a.b}", ref(Field("a"), Field("b"))},
+		{"${a[1]}", ref(Field("a"), Index(1))},
+		{"${a[*]}", ref(Field("a"), Wildcard())},
+		{"a b", un("a", "b")},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				tt := tests[(g+i)%len(tests)]
+				ast, err := p.ParseStr(tt.input)
+				if assert.NoError(t, err, tt.input) {
+					assert.EqualValues(t, tt.output, asttest.StripSpans(ast), tt.input)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestParser_Print(t *testing.T) {
+	tests := []struct {
+		input  parse.AST
+		output string
+	}{
+		{ref(Field("a")), "${a}"},
+		{ref(Field("items"), Index(-2), Wildcard(), Field("name")), "${items[-2][*].name}"},
+		{ref(Field("a.b"), Field("task-ref"), Field("it's \"x\"")), `${["a.b"]["task-ref"]["it's \"x\""]}`},
+		{ref(Field(""), Field("$")), `${[""]["$"]}`},
+		{ref(Field("é_1"), Field("0")), "${é_1.0}"},
+		{ref(Field("a"), NullSafe(Field("b")), NullSafe(Index(1)), NullSafe(Field("c-d")), Field("e")), `${a?.b?.[1]?.["c-d"].e}`},
+	}
+	p, err := NewParser()
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.output, func(t *testing.T) {
+			text, err := parse.Format(tt.input, p)
+			require.NoError(t, err)
+			assert.Equal(t, tt.output, text)
+			assert.Equal(t, tt.output, tt.input.(*Ref).String())
+
+			ast, err := p.ParseStr(text)
+			require.NoError(t, err)
+			assert.EqualValues(t, tt.input, asttest.StripSpans(ast))
+		})
+	}
+
+	for _, input := range []parse.AST{ref(), ref(Index(0)), ref(Field("a"), Segment{}), ref(NullSafe(Field("a"))), ref(Field("a"), NullSafe(Wildcard()))} {
+		_, err := parse.Format(input, p)
+		assert.Error(t, err)
+	}
+}
+
+func TestWithQuotes(t *testing.T) {
+	p, err := NewParser(WithQuotes('|'))
+	require.NoError(t, err)
+
+	ast, err := p.ParseStr(`${a."d"}`)
+	require.NoError(t, err)
+	assert.EqualValues(t, ref(Field("a"), Field(`"d"`)), asttest.StripSpans(ast))
+
+	ast, err = p.ParseStr(`${a[|b c|]}`)
+	require.NoError(t, err)
+	assert.EqualValues(t, ref(Field("a"), Field("b c")), asttest.StripSpans(ast))
+	text, err := parse.Format(ast, p)
+	require.NoError(t, err)
+	assert.Equal(t, `${a[|b c|]}`, text)
+
+	for _, quotes := range [][]rune{nil, {'['}, {' '}, {'.'}} {
+		_, err := NewParser(WithQuotes(quotes...))
+		assert.ErrorIs(t, err, parse.ErrConfig, string(quotes))
+	}
+}
+
+func ref(path ...Segment) parse.AST {
+	return &Ref{Path: path}
+}
+
+// un stands for unparsed and returns a parse.Unparsed
+func un(tokens ...string) parse.AST {
+	return parse.Unparsed{Contents: parse.NewTokens(tokens...)}
+}
@@ -0,0 +1,157 @@
+package parse
+
+import "fmt"
+
+// Branch is implemented by AST nodes which have children. Every AST provided by this module implements Branch, except
+// for Unparsed, which is always a leaf. Nodes which do not implement Branch are treated as leaves by Walk, Inspect and
+// Rewrite, so AST nodes defined outside this module should implement Branch if they have children.
+type Branch interface {
+	AST
+
+	// Children returns the children of this node, in the order in which they appear in the source text. Callers must
+	// not modify the returned slice.
+	Children() []AST
+
+	// WithChildren returns a shallow copy of this node with its children replaced by the provided ASTs, in the same
+	// order as returned by Children. The node it is called on is not modified. WithChildren panics if the number of
+	// children provided differs from the number returned by Children.
+	WithChildren(children []AST) AST
+}
+
+// Validator is implemented by AST nodes which check their contents when built by WithChildren, such as nodes which
+// compile a pattern held by one of their children. Rewrite returns the error reported by Validate, if any, for each
+// Branch it copies.
+type Validator interface {
+	AST
+
+	// Validate returns an error if this node is invalid.
+	Validate() error
+}
+
+// ChildrenOf returns the children of the provided AST, or nil if it does not implement Branch.
+func ChildrenOf(ast AST) []AST {
+	if b, ok := ast.(Branch); ok {
+		return b.Children()
+	}
+	return nil
+}
+
+// A Visitor's Visit method is called by Walk for each node in an AST. If the Visitor w returned is not nil, Walk visits
+// each of the children of the node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(ast AST) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, in the same manner as go/ast.Walk. It starts by calling v.Visit(ast),
+// which must not be nil. If the Visitor w returned by v.Visit(ast) is not nil, Walk is invoked recursively with w for
+// each of the children of the node, followed by a call of w.Visit(nil). Walk does not recurse on the Go stack, so it is
+// safe to use on very deep trees.
+func Walk(v Visitor, ast AST) {
+	type frame struct {
+		v        Visitor
+		children []AST
+	}
+	if v = v.Visit(ast); v == nil {
+		return
+	}
+	stack := []frame{{v: v, children: ChildrenOf(ast)}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if len(top.children) == 0 {
+			top.v.Visit(nil)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		child := top.children[0]
+		top.children = top.children[1:]
+		if w := top.v.Visit(child); w != nil {
+			stack = append(stack, frame{v: w, children: ChildrenOf(child)})
+		}
+	}
+}
+
+type inspector func(AST) bool
+
+func (f inspector) Visit(ast AST) Visitor {
+	if f(ast) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order, in the same manner as go/ast.Inspect. It starts by calling f(ast),
+// which must not be nil. If f returns true, Inspect invokes f recursively for each of the children of the node,
+// followed by a call of f(nil).
+func Inspect(ast AST, f func(AST) bool) {
+	Walk(inspector(f), ast)
+}
+
+// Rewrite returns a copy of the provided AST in which every node has been replaced by the result of calling f. Nodes
+// are rewritten bottom-up, so that f is called on each node only after its children have been rewritten, and the node
+// passed to f already contains the rewritten children. If f returns an error, Rewrite stops and returns it. The
+// provided AST is not modified; Branch nodes are copied using WithChildren, and if WithChildren panics, as it does when
+// a Branch returns inconsistent children, Rewrite returns an error instead. Copies which implement Validator are
+// validated before being passed to f. Rewrite does not recurse on the Go stack,
+// so it is safe to use on very deep trees.
+//
+// For example, to replace every NOT (a == b) with a != b:
+//
+//	result, err := parse.Rewrite(ast, func(ast parse.AST) (parse.AST, error) {
+//		if not, ok := ast.(*bools.UnaryExpr); ok {
+//			if eq, ok := not.Expr.(*comp.EqualExpr); ok && eq.Op == comp.OpEqual {
+//				return &comp.EqualExpr{LHS: eq.LHS, RHS: eq.RHS, Op: comp.OpNotEqual, Src: not.Src}, nil
+//			}
+//		}
+//		return ast, nil
+//	})
+func Rewrite(ast AST, f func(AST) (AST, error)) (AST, error) {
+	type frame struct {
+		node     AST
+		children []AST // children holds the rewritten children of node, followed by those not yet rewritten.
+		next     int
+	}
+	newFrame := func(node AST) *frame {
+		return &frame{node: node, children: append([]AST(nil), ChildrenOf(node)...)}
+	}
+	stack := []*frame{newFrame(ast)}
+	for {
+		top := stack[len(stack)-1]
+		if top.next < len(top.children) {
+			stack = append(stack, newFrame(top.children[top.next]))
+			continue
+		}
+		node := top.node
+		if b, ok := node.(Branch); ok {
+			var err error
+			if node, err = withChildren(b, top.children); err != nil {
+				return nil, err
+			}
+			if v, ok := node.(Validator); ok {
+				if err := v.Validate(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		node, err := f(node)
+		if err != nil {
+			return nil, err
+		}
+		stack = stack[:len(stack)-1]
+		if len(stack) == 0 {
+			return node, nil
+		}
+		parent := stack[len(stack)-1]
+		parent.children[parent.next] = node
+		parent.next++
+	}
+}
+
+// withChildren calls b.WithChildren, returning an error rather than panicking if b rejects the children provided.
+func withChildren(b Branch, children []AST) (ast AST, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot rewrite %T: %v", b, r)
+		}
+	}()
+	return b.WithChildren(children), nil
+}
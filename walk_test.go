@@ -0,0 +1,158 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func (p *pair) Children() []AST {
+	return []AST{p.LHS, p.RHS}
+}
+
+func (p *pair) WithChildren(children []AST) AST {
+	return &pair{LHS: children[0], RHS: children[1]}
+}
+
+// lopsided is a Branch whose WithChildren rejects the children returned by Children.
+type lopsided struct {
+	pair
+}
+
+func (l *lopsided) WithChildren(children []AST) AST {
+	panic(fmt.Sprintf("lopsided.WithChildren called with %d children; expected 3", len(children)))
+}
+
+// leaf is an AST node with no children which does not implement Branch.
+type leaf string
+
+func (l leaf) Parse(Parser) error {
+	return nil
+}
+
+func TestInspect(t *testing.T) {
+	ast := &pair{LHS: &pair{LHS: leaf("a"), RHS: leaf("b")}, RHS: leaf("c")}
+
+	var visited []string
+	Inspect(ast, func(ast AST) bool {
+		switch ast := ast.(type) {
+		case leaf:
+			visited = append(visited, string(ast))
+		case *pair:
+			visited = append(visited, "(")
+		case nil:
+			visited = append(visited, ")")
+		}
+		return true
+	})
+	assert.Equal(t, "( ( a ) b ) ) c ) )", strings.Join(visited, " "))
+
+	visited = nil
+	Inspect(ast, func(ast AST) bool {
+		if l, ok := ast.(leaf); ok {
+			visited = append(visited, string(l))
+		}
+		// skip the children of the inner pair
+		if p, ok := ast.(*pair); ok {
+			_, inner := p.LHS.(leaf)
+			return !inner
+		}
+		return true
+	})
+	assert.Equal(t, []string{"c"}, visited)
+}
+
+// counter counts the nodes visited at each depth.
+type counter struct {
+	depth  int
+	counts map[int]int
+}
+
+func (c *counter) Visit(ast AST) Visitor {
+	if ast == nil {
+		return nil
+	}
+	c.counts[c.depth]++
+	return &counter{depth: c.depth + 1, counts: c.counts}
+}
+
+func TestWalk(t *testing.T) {
+	ast := &pair{LHS: &pair{LHS: leaf("a"), RHS: &pair{LHS: leaf("b"), RHS: leaf("c")}}, RHS: leaf("d")}
+
+	c := &counter{counts: make(map[int]int)}
+	Walk(c, ast)
+	assert.Equal(t, map[int]int{0: 1, 1: 2, 2: 2, 3: 2}, c.counts)
+}
+
+func TestRewrite(t *testing.T) {
+	ast := &pair{LHS: &pair{LHS: leaf("a"), RHS: leaf("b")}, RHS: leaf("c")}
+
+	var order []string
+	result, err := Rewrite(ast, func(ast AST) (AST, error) {
+		switch ast := ast.(type) {
+		case leaf:
+			order = append(order, string(ast))
+			return leaf(strings.ToUpper(string(ast))), nil
+		case *pair:
+			order = append(order, "pair")
+			if l, ok := ast.RHS.(leaf); ok && l == "B" {
+				return leaf("AB"), nil
+			}
+		}
+		return ast, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &pair{LHS: leaf("AB"), RHS: leaf("C")}, result)
+	assert.Equal(t, []string{"a", "b", "pair", "c", "pair"}, order)
+
+	// the original AST is not modified
+	assert.Equal(t, &pair{LHS: &pair{LHS: leaf("a"), RHS: leaf("b")}, RHS: leaf("c")}, ast)
+
+	errStop := errors.New("stop")
+	_, err = Rewrite(ast, func(ast AST) (AST, error) {
+		if ast == AST(leaf("b")) {
+			return nil, errStop
+		}
+		return ast, nil
+	})
+	assert.ErrorIs(t, err, errStop)
+
+	_, err = Rewrite(&pair{LHS: &lopsided{}, RHS: leaf("c")}, func(ast AST) (AST, error) {
+		return ast, nil
+	})
+	assert.EqualError(t, err, "cannot rewrite *parse.lopsided: lopsided.WithChildren called with 2 children; expected 3")
+}
+
+func TestWalk_Deep(t *testing.T) {
+	const depth = 100_000
+	var ast AST = leaf("x")
+	for i := 0; i < depth; i++ {
+		ast = &pair{LHS: ast, RHS: leaf("y")}
+	}
+
+	count := 0
+	Inspect(ast, func(ast AST) bool {
+		if ast != nil {
+			count++
+		}
+		return true
+	})
+	assert.Equal(t, 2*depth+1, count)
+
+	result, err := Rewrite(ast, func(ast AST) (AST, error) {
+		if ast == AST(leaf("y")) {
+			return leaf("z"), nil
+		}
+		return ast, nil
+	})
+	require.NoError(t, err)
+	for i := 0; i < depth; i++ {
+		p := result.(*pair)
+		require.Equal(t, leaf("z"), p.RHS)
+		result = p.LHS
+	}
+	assert.Equal(t, leaf("x"), result)
+}